@@ -11,12 +11,13 @@ import (
 	"time"
 )
 
-// MockLLMServer provides an HTTP server that mimics OpenAI/Anthropic APIs for testing.
+// MockLLMServer provides an HTTP server that mimics OpenAI/Anthropic/Gemini APIs for testing.
 type MockLLMServer struct {
-	server   *httptest.Server
-	config   *MockLLMConfig
-	requests []MockRequest
-	mu       sync.Mutex
+	server      *httptest.Server
+	config      *MockLLMConfig
+	requests    []MockRequest
+	promptCache map[string]bool // Hashes of the cached Anthropic prompt prefixes
+	mu          sync.Mutex
 }
 
 // MockRequest records incoming requests for verification.
@@ -45,6 +46,12 @@ func NewMockLLMServerWithConfig(config *MockLLMConfig) *MockLLMServer {
 	mux.HandleFunc("/v1/chat/completions", m.handleChatCompletions)
 	mux.HandleFunc("/chat/completions", m.handleChatCompletions)
 
+	// Anthropic-compatible endpoint
+	mux.HandleFunc("/v1/messages", m.handleAnthropic)
+
+	// Gemini-compatible endpoint
+	mux.HandleFunc("/v1beta/models/", m.handleGemini)
+
 	// Health check
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,388 @@
+package testutil
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxCacheBreakpoints is the number of cache_control blocks Anthropic accepts
+// in a request.
+const maxCacheBreakpoints = 4
+
+// handleAnthropic handles Anthropic Messages API requests at /v1/messages.
+// Prompt caching is simulated: the prefix up to each cache_control block is
+// cached, and later requests sharing it report cache reads.
+func (m *MockLLMServer) handleAnthropic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "Invalid JSON")
+		return
+	}
+
+	blocks := anthropicPromptBlocks(req)
+	breakpoints := 0
+	for _, block := range blocks {
+		if block.breakpoint {
+			breakpoints++
+		}
+	}
+	if breakpoints > maxCacheBreakpoints {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("A maximum of %d blocks with cache_control may be provided. Found %d.", maxCacheBreakpoints, breakpoints))
+		return
+	}
+	if err := validateAnthropicMessages(req); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// Record request
+	m.mu.Lock()
+	m.requests = append(m.requests, MockRequest{
+		Timestamp: time.Now(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Body:      req,
+	})
+	usage := m.cacheUsage(blocks)
+	m.mu.Unlock()
+
+	// Apply artificial lag if configured
+	if m.config.Settings.LagMS > 0 {
+		time.Sleep(time.Duration(m.config.Settings.LagMS) * time.Millisecond)
+	}
+
+	response := m.generateResponse(extractAnthropicPrompt(req), extractAnthropicTools(req))
+
+	if stream, _ := req["stream"].(bool); stream && m.config.Settings.EnableStreaming {
+		m.writeAnthropicStreamingResponse(w, response, usage)
+	} else {
+		writeAnthropicResponse(w, response, usage)
+	}
+}
+
+// promptBlock is a block of the prompt, in the order Anthropic caches them:
+// tools, then system, then messages.
+type promptBlock struct {
+	content    []byte // JSON of the block without its cache_control
+	tokens     int
+	breakpoint bool
+}
+
+// anthropicPromptBlocks returns the blocks of the prompt of a request.
+func anthropicPromptBlocks(req map[string]interface{}) []promptBlock {
+	var blocks []promptBlock
+	add := func(v interface{}) {
+		breakpoint := false
+		if block, ok := v.(map[string]interface{}); ok {
+			if _, breakpoint = block["cache_control"]; breakpoint {
+				stripped := make(map[string]interface{}, len(block))
+				for k, v := range block {
+					if k != "cache_control" {
+						stripped[k] = v
+					}
+				}
+				v = stripped
+			}
+		}
+		content, _ := json.Marshal(v)
+		blocks = append(blocks, promptBlock{content: content, tokens: max(len(content)/4, 1), breakpoint: breakpoint})
+	}
+
+	tools, _ := req["tools"].([]interface{})
+	for _, tool := range tools {
+		add(tool)
+	}
+	switch system := req["system"].(type) {
+	case string:
+		add(system)
+	case []interface{}:
+		for _, block := range system {
+			add(block)
+		}
+	}
+	messages, _ := req["messages"].([]interface{})
+	for _, m := range messages {
+		msg, _ := m.(map[string]interface{})
+		switch content := msg["content"].(type) {
+		case string:
+			add(content)
+		case []interface{}:
+			for _, block := range content {
+				add(block)
+			}
+		}
+	}
+	return blocks
+}
+
+// anthropicUsage is the prompt usage of a request.
+type anthropicUsage struct {
+	input, cacheRead, cacheWrite int
+}
+
+// cacheUsage returns the usage of a prompt, reading the longest cached
+// prefix ending at a breakpoint and caching the prefixes of every
+// breakpoint. The caller holds m.mu.
+func (m *MockLLMServer) cacheUsage(blocks []promptBlock) anthropicUsage {
+	if m.promptCache == nil {
+		m.promptCache = make(map[string]bool)
+	}
+
+	var keys []string
+	var prefixTokens []int
+	total := 0
+	h := sha256.New()
+	for _, block := range blocks {
+		h.Write(block.content)
+		total += block.tokens
+		if block.breakpoint {
+			keys = append(keys, fmt.Sprintf("%x", h.Sum(nil)))
+			prefixTokens = append(prefixTokens, total)
+		}
+	}
+
+	usage := anthropicUsage{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if m.promptCache[keys[i]] {
+			usage.cacheRead = prefixTokens[i]
+			break
+		}
+	}
+	if len(keys) > 0 {
+		usage.cacheWrite = prefixTokens[len(keys)-1] - usage.cacheRead
+	}
+	for _, key := range keys {
+		m.promptCache[key] = true
+	}
+	usage.input = total - usage.cacheRead - usage.cacheWrite
+	return usage
+}
+
+// validateAnthropicMessages checks that there are messages and that none is
+// empty.
+func validateAnthropicMessages(req map[string]interface{}) error {
+	messages, ok := req["messages"].([]interface{})
+	if !ok || len(messages) == 0 {
+		return fmt.Errorf("messages: field required")
+	}
+	for i, m := range messages {
+		msg, _ := m.(map[string]interface{})
+		switch content := msg["content"].(type) {
+		case string:
+			if content == "" {
+				return fmt.Errorf("messages.%d: all messages must have non-empty content", i)
+			}
+		case []interface{}:
+			if len(content) == 0 {
+				return fmt.Errorf("messages.%d: all messages must have non-empty content", i)
+			}
+		default:
+			return fmt.Errorf("messages.%d.content: field required", i)
+		}
+	}
+	return nil
+}
+
+// extractAnthropicPrompt extracts the text of the last user message.
+// Messages holding only tool results are skipped, as OpenAI tool messages are.
+func extractAnthropicPrompt(req map[string]interface{}) string {
+	messages, _ := req["messages"].([]interface{})
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg, ok := messages[i].(map[string]interface{})
+		if !ok || msg["role"] != "user" {
+			continue
+		}
+		switch content := msg["content"].(type) {
+		case string:
+			return content
+		case []interface{}:
+			var texts []string
+			for _, b := range content {
+				block, _ := b.(map[string]interface{})
+				if block["type"] == "text" {
+					text, _ := block["text"].(string)
+					texts = append(texts, text)
+				}
+			}
+			if len(texts) > 0 {
+				return strings.Join(texts, "\n")
+			}
+		}
+	}
+	return ""
+}
+
+// extractAnthropicTools extracts the tool names declared in the request.
+func extractAnthropicTools(req map[string]interface{}) []string {
+	var toolNames []string
+	tools, _ := req["tools"].([]interface{})
+	for _, t := range tools {
+		tool, _ := t.(map[string]interface{})
+		if name, ok := tool["name"].(string); ok {
+			toolNames = append(toolNames, name)
+		}
+	}
+	return toolNames
+}
+
+// anthropicContent returns the content blocks of a response.
+func anthropicContent(resp *mockResponse) []map[string]interface{} {
+	var content []map[string]interface{}
+	if resp.content != "" {
+		content = append(content, map[string]interface{}{"type": "text", "text": resp.content})
+	}
+	for _, tc := range resp.toolCalls {
+		var input map[string]interface{}
+		_ = json.Unmarshal([]byte(tc.arguments), &input)
+		content = append(content, map[string]interface{}{
+			"type":  "tool_use",
+			"id":    tc.id,
+			"name":  tc.name,
+			"input": input,
+		})
+	}
+	return content
+}
+
+// anthropicStopReason returns the stop reason of a response.
+func anthropicStopReason(resp *mockResponse) string {
+	if len(resp.toolCalls) > 0 {
+		return "tool_use"
+	}
+	return "end_turn"
+}
+
+// anthropicMessage builds a message object with the given prompt usage.
+func anthropicMessage(content []map[string]interface{}, stopReason interface{}, usage anthropicUsage, outputTokens int) map[string]interface{} {
+	if content == nil {
+		content = []map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"id":            "msg_mockllm_" + generateMockID(),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         "claude-sonnet-4-20250514",
+		"content":       content,
+		"stop_reason":   stopReason,
+		"stop_sequence": nil,
+		"usage": map[string]interface{}{
+			"input_tokens":                usage.input,
+			"cache_creation_input_tokens": usage.cacheWrite,
+			"cache_read_input_tokens":     usage.cacheRead,
+			"output_tokens":               outputTokens,
+		},
+	}
+}
+
+// writeAnthropicResponse writes a non-streaming Anthropic response.
+func writeAnthropicResponse(w http.ResponseWriter, resp *mockResponse, usage anthropicUsage) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anthropicMessage(anthropicContent(resp), anthropicStopReason(resp), usage, 50))
+}
+
+// writeAnthropicStreamingResponse writes a streaming Anthropic response, as
+// named server-sent events.
+func (m *MockLLMServer) writeAnthropicStreamingResponse(w http.ResponseWriter, resp *mockResponse, usage anthropicUsage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	send := func(event map[string]interface{}) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event["type"], data)
+		flusher.Flush()
+	}
+
+	// Get chunk delay from config
+	chunkDelay := time.Duration(m.config.Settings.ChunkDelayMS) * time.Millisecond
+	if chunkDelay <= 0 {
+		chunkDelay = 5 * time.Millisecond
+	}
+
+	send(map[string]interface{}{
+		"type":    "message_start",
+		"message": anthropicMessage(nil, nil, usage, 1),
+	})
+
+	index := 0
+	if resp.content != "" {
+		send(map[string]interface{}{
+			"type":          "content_block_start",
+			"index":         index,
+			"content_block": map[string]interface{}{"type": "text", "text": ""},
+		})
+		for _, chunk := range m.splitIntoChunks(resp.content) {
+			send(map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": index,
+				"delta": map[string]interface{}{"type": "text_delta", "text": chunk},
+			})
+			time.Sleep(chunkDelay)
+		}
+		send(map[string]interface{}{"type": "content_block_stop", "index": index})
+		index++
+	}
+	for _, tc := range resp.toolCalls {
+		send(map[string]interface{}{
+			"type":  "content_block_start",
+			"index": index,
+			"content_block": map[string]interface{}{
+				"type":  "tool_use",
+				"id":    tc.id,
+				"name":  tc.name,
+				"input": map[string]interface{}{},
+			},
+		})
+		send(map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": index,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": tc.arguments},
+		})
+		send(map[string]interface{}{"type": "content_block_stop", "index": index})
+		index++
+	}
+
+	send(map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": anthropicStopReason(resp), "stop_sequence": nil},
+		"usage": map[string]interface{}{"output_tokens": 50},
+	})
+	send(map[string]interface{}{"type": "message_stop"})
+}
+
+// writeAnthropicError writes an error in the Anthropic API format.
+func writeAnthropicError(w http.ResponseWriter, code int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    errType,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,214 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/internal/provider"
+)
+
+func newAnthropicProvider(t *testing.T, server *MockLLMServer, modelID, strategy string) *provider.AnthropicProvider {
+	t.Helper()
+	p, err := provider.NewAnthropicProvider(context.Background(), &provider.AnthropicConfig{
+		APIKey:        "mock-api-key",
+		BaseURL:       server.URL(),
+		Model:         modelID,
+		MaxTokens:     1024,
+		CacheStrategy: strategy,
+	})
+	if err != nil {
+		t.Fatalf("NewAnthropicProvider failed: %v", err)
+	}
+	return p
+}
+
+// anthropicRequest returns a request with a system prompt, two tools and the
+// given conversation.
+func anthropicRequest(conversation ...*schema.Message) *provider.CompletionRequest {
+	return &provider.CompletionRequest{
+		Messages: append([]*schema.Message{{Role: schema.System, Content: "You are a helpful assistant."}}, conversation...),
+		Tools: []*schema.ToolInfo{
+			{Name: "glob", Desc: "Find files"},
+			{
+				Name: "read",
+				Desc: "Read a file",
+				ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+					"file_path": {Type: schema.String, Required: true},
+				}),
+			},
+		},
+		MaxTokens: 1024,
+	}
+}
+
+// hasBreakpoint reports whether a block of a recorded request is a cache
+// breakpoint.
+func hasBreakpoint(block interface{}) bool {
+	b, _ := block.(map[string]interface{})
+	_, ok := b["cache_control"]
+	return ok
+}
+
+// breakpoints returns where the cache breakpoints of a recorded request are:
+// "tool:NAME", "system:N" and "message:N".
+func breakpoints(req map[string]interface{}) []string {
+	var found []string
+	tools, _ := req["tools"].([]interface{})
+	for _, tool := range tools {
+		if hasBreakpoint(tool) {
+			found = append(found, fmt.Sprintf("tool:%s", tool.(map[string]interface{})["name"]))
+		}
+	}
+	system, _ := req["system"].([]interface{})
+	for i, block := range system {
+		if hasBreakpoint(block) {
+			found = append(found, fmt.Sprintf("system:%d", i))
+		}
+	}
+	messages, _ := req["messages"].([]interface{})
+	for i, m := range messages {
+		content, _ := m.(map[string]interface{})["content"].([]interface{})
+		for _, block := range content {
+			if hasBreakpoint(block) {
+				found = append(found, fmt.Sprintf("message:%d", i))
+			}
+		}
+	}
+	return found
+}
+
+// streamUsage reads a completion stream to the end and returns its prompt,
+// cache read and cache write tokens.
+func streamUsage(t *testing.T, stream *provider.CompletionStream) (prompt, read, written int) {
+	t.Helper()
+	defer stream.Close()
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return prompt, read, written
+		}
+		if msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil && msg.ResponseMeta.Usage.PromptTokens > 0 {
+			prompt = msg.ResponseMeta.Usage.PromptTokens
+			read = msg.ResponseMeta.Usage.PromptTokenDetails.CachedTokens
+		}
+		if n := provider.CacheWriteTokens(msg); n > 0 {
+			written = n
+		}
+	}
+}
+
+func TestMockLLMAnthropic_CacheBreakpoints(t *testing.T) {
+	server := NewMockLLMServer()
+	defer server.Close()
+	p := newAnthropicProvider(t, server, "", "")
+	ctx := context.Background()
+
+	first := []*schema.Message{{Role: schema.User, Content: "What is 2+2?"}}
+	stream, err := p.CreateCompletion(ctx, anthropicRequest(first...))
+	if err != nil {
+		t.Fatalf("CreateCompletion failed: %v", err)
+	}
+	prompt, read, written := streamUsage(t, stream)
+	if read != 0 || written == 0 || written > prompt {
+		t.Errorf("First request: got %d prompt tokens, %d read and %d written", prompt, read, written)
+	}
+
+	second := append(first,
+		&schema.Message{Role: schema.Assistant, Content: "4"},
+		&schema.Message{Role: schema.User, Content: "What is 3+3?"})
+	stream, err = p.CreateCompletion(ctx, anthropicRequest(second...))
+	if err != nil {
+		t.Fatalf("CreateCompletion failed: %v", err)
+	}
+	prompt, read, written = streamUsage(t, stream)
+	if read == 0 || written == 0 || read+written > prompt {
+		t.Errorf("Second request: got %d prompt tokens, %d read and %d written", prompt, read, written)
+	}
+
+	requests := server.GetRequests()
+	if len(requests) != 2 || requests[0].Path != "/v1/messages" {
+		t.Fatalf("Got requests %+v", requests)
+	}
+	want := [][]string{
+		{"tool:read", "system:0", "message:0"},
+		{"tool:read", "system:0", "message:1", "message:2"},
+	}
+	for i, req := range requests {
+		if got := breakpoints(req.Body); strings.Join(got, ",") != strings.Join(want[i], ",") {
+			t.Errorf("Request %d: got breakpoints %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestMockLLMAnthropic_CacheStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		modelID  string
+		strategy string
+		want     []string
+	}{
+		{"static", "", provider.CacheStatic, []string{"tool:read", "system:0"}},
+		{"off", "", provider.CacheOff, nil},
+		{"model without caching", "claude-2.1", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewMockLLMServer()
+			defer server.Close()
+			p := newAnthropicProvider(t, server, tt.modelID, tt.strategy)
+
+			stream, err := p.CreateCompletion(context.Background(), anthropicRequest(
+				&schema.Message{Role: schema.User, Content: "What is 2+2?"},
+				&schema.Message{Role: schema.Assistant, Content: "4"},
+				&schema.Message{Role: schema.User, Content: "What is 3+3?"}))
+			if err != nil {
+				t.Fatalf("CreateCompletion failed: %v", err)
+			}
+			_, _, written := streamUsage(t, stream)
+
+			requests := server.GetRequests()
+			if len(requests) != 1 {
+				t.Fatalf("Got %d requests", len(requests))
+			}
+			if got := breakpoints(requests[0].Body); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Got breakpoints %v, want %v", got, tt.want)
+			}
+			if (written > 0) != (len(tt.want) > 0) {
+				t.Errorf("Got %d cache write tokens with breakpoints %v", written, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockLLMAnthropic_TooManyBreakpoints(t *testing.T) {
+	server := NewMockLLMServer()
+	defer server.Close()
+
+	block := `{"type":"text","text":"hi","cache_control":{"type":"ephemeral"}}`
+	body := `{"model":"claude-sonnet-4-20250514","max_tokens":10,"messages":[{"role":"user","content":[` +
+		strings.Repeat(block+",", 4) + block + `]}]}`
+	resp, err := http.Post(server.URL()+"/v1/messages", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Got status %d, want 400", resp.StatusCode)
+	}
+	var errBody struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil || errBody.Error.Type != "invalid_request_error" {
+		t.Errorf("Got error %+v (%v)", errBody, err)
+	}
+}
@@ -0,0 +1,256 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleGemini handles Gemini generateContent and streamGenerateContent
+// requests, at /v1beta/models/{model}:{method}.
+func (m *MockLLMServer) handleGemini(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1beta/models/"), ":")
+	if !ok || (method != "generateContent" && method != "streamGenerateContent") {
+		writeGeminiError(w, http.StatusNotFound, "NOT_FOUND", "unknown method: "+r.URL.Path)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload received.")
+		return
+	}
+
+	// Validate contents - Gemini rejects contents without parts
+	if err := validateGeminiContents(req); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	// Record request
+	m.mu.Lock()
+	m.requests = append(m.requests, MockRequest{
+		Timestamp: time.Now(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Body:      req,
+	})
+	m.mu.Unlock()
+
+	// Apply artificial lag if configured
+	if m.config.Settings.LagMS > 0 {
+		time.Sleep(time.Duration(m.config.Settings.LagMS) * time.Millisecond)
+	}
+
+	response := m.generateResponse(extractGeminiPrompt(req), extractGeminiTools(req))
+
+	if method == "streamGenerateContent" && m.config.Settings.EnableStreaming {
+		m.writeGeminiStreamingResponse(w, response)
+	} else {
+		writeGeminiResponse(w, response)
+	}
+}
+
+// validateGeminiContents checks that there are contents and each has parts.
+func validateGeminiContents(req map[string]interface{}) error {
+	contents, ok := req["contents"].([]interface{})
+	if !ok || len(contents) == 0 {
+		return fmt.Errorf("* GenerateContentRequest.contents: contents is not specified")
+	}
+	for i, c := range contents {
+		content, _ := c.(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		if len(parts) == 0 {
+			return fmt.Errorf("* GenerateContentRequest.contents[%d].parts: contents.parts must not be empty", i)
+		}
+	}
+	return nil
+}
+
+// extractGeminiPrompt extracts the text of the last user content. Contents
+// holding only function responses are skipped, as OpenAI tool messages are.
+func extractGeminiPrompt(req map[string]interface{}) string {
+	contents, _ := req["contents"].([]interface{})
+	for i := len(contents) - 1; i >= 0; i-- {
+		content, ok := contents[i].(map[string]interface{})
+		if !ok || content["role"] != "user" {
+			continue
+		}
+		parts, _ := content["parts"].([]interface{})
+		var texts []string
+		for _, p := range parts {
+			part, _ := p.(map[string]interface{})
+			if text, ok := part["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) > 0 {
+			return strings.Join(texts, "\n")
+		}
+	}
+	return ""
+}
+
+// extractGeminiTools extracts the function names declared in the request.
+func extractGeminiTools(req map[string]interface{}) []string {
+	var toolNames []string
+	tools, _ := req["tools"].([]interface{})
+	for _, t := range tools {
+		tool, _ := t.(map[string]interface{})
+		declarations, _ := tool["functionDeclarations"].([]interface{})
+		for _, d := range declarations {
+			declaration, _ := d.(map[string]interface{})
+			if name, ok := declaration["name"].(string); ok {
+				toolNames = append(toolNames, name)
+			}
+		}
+	}
+	return toolNames
+}
+
+// geminiCandidate builds a response candidate from parts.
+func geminiCandidate(parts []map[string]interface{}, finishReason string) map[string]interface{} {
+	candidate := map[string]interface{}{
+		"index": 0,
+		"content": map[string]interface{}{
+			"role":  "model",
+			"parts": parts,
+		},
+	}
+	if finishReason != "" {
+		candidate["finishReason"] = finishReason
+	}
+	return candidate
+}
+
+// geminiFunctionCallParts returns the function call parts of a response.
+func geminiFunctionCallParts(resp *mockResponse) []map[string]interface{} {
+	parts := make([]map[string]interface{}, 0, len(resp.toolCalls))
+	for _, tc := range resp.toolCalls {
+		var args map[string]interface{}
+		_ = json.Unmarshal([]byte(tc.arguments), &args)
+		parts = append(parts, map[string]interface{}{
+			"functionCall": map[string]interface{}{
+				"id":   tc.id,
+				"name": tc.name,
+				"args": args,
+			},
+		})
+	}
+	return parts
+}
+
+// geminiUsageMetadata returns the fixed usage reported by the mock.
+func geminiUsageMetadata() map[string]interface{} {
+	return map[string]interface{}{
+		"promptTokenCount":     100,
+		"candidatesTokenCount": 50,
+		"totalTokenCount":      150,
+	}
+}
+
+// writeGeminiResponse writes a non-streaming Gemini response.
+func writeGeminiResponse(w http.ResponseWriter, resp *mockResponse) {
+	var parts []map[string]interface{}
+	if resp.content != "" {
+		parts = append(parts, map[string]interface{}{"text": resp.content})
+	}
+	parts = append(parts, geminiFunctionCallParts(resp)...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"candidates":    []map[string]interface{}{geminiCandidate(parts, "STOP")},
+		"usageMetadata": geminiUsageMetadata(),
+		"modelVersion":  "gemini-2.5-flash",
+	})
+}
+
+// writeGeminiStreamingResponse writes a streaming Gemini response, as
+// server-sent events with one GenerateContentResponse each.
+func (m *MockLLMServer) writeGeminiStreamingResponse(w http.ResponseWriter, resp *mockResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	send := func(chunk map[string]interface{}) {
+		data, _ := json.Marshal(chunk)
+		w.Write([]byte("data: " + string(data) + "\r\n\r\n"))
+		flusher.Flush()
+	}
+
+	// Get chunk delay from config
+	chunkDelay := time.Duration(m.config.Settings.ChunkDelayMS) * time.Millisecond
+	if chunkDelay <= 0 {
+		chunkDelay = 5 * time.Millisecond
+	}
+
+	var last []map[string]interface{}
+	if len(resp.toolCalls) > 0 {
+		// Function calls arrive whole, after any text
+		if resp.content != "" {
+			send(map[string]interface{}{
+				"candidates": []map[string]interface{}{
+					geminiCandidate([]map[string]interface{}{{"text": resp.content}}, ""),
+				},
+			})
+		}
+		last = geminiFunctionCallParts(resp)
+	} else {
+		chunks := m.splitIntoChunks(resp.content)
+		for i, chunkContent := range chunks {
+			part := []map[string]interface{}{{"text": chunkContent}}
+			if i == len(chunks)-1 {
+				last = part
+				break
+			}
+			send(map[string]interface{}{
+				"candidates": []map[string]interface{}{geminiCandidate(part, "")},
+			})
+			time.Sleep(chunkDelay)
+		}
+	}
+	if last == nil {
+		last = []map[string]interface{}{{"text": ""}}
+	}
+
+	// The last chunk carries the finish reason and usage
+	send(map[string]interface{}{
+		"candidates":    []map[string]interface{}{geminiCandidate(last, "STOP")},
+		"usageMetadata": geminiUsageMetadata(),
+		"modelVersion":  "gemini-2.5-flash",
+	})
+}
+
+// writeGeminiError writes an error in the Gemini API format.
+func writeGeminiError(w http.ResponseWriter, code int, status, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"status":  status,
+		},
+	})
+}
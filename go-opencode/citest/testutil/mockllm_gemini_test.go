@@ -0,0 +1,128 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/internal/provider"
+)
+
+func newGeminiProvider(t *testing.T, server *MockLLMServer) *provider.GeminiProvider {
+	t.Helper()
+	p, err := provider.NewGeminiProvider(context.Background(), &provider.GeminiConfig{
+		APIKey:  "mock-api-key",
+		BaseURL: server.URL() + "/v1beta",
+	})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	return p
+}
+
+// collect reads a completion stream to the end.
+func collect(t *testing.T, stream *provider.CompletionStream) (content string, last *schema.Message) {
+	t.Helper()
+	defer stream.Close()
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return content, last
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		content += msg.Content
+		last = msg
+	}
+}
+
+func TestMockLLMGemini_Stream(t *testing.T) {
+	server := NewMockLLMServer()
+	defer server.Close()
+	p := newGeminiProvider(t, server)
+
+	stream, err := p.CreateCompletion(context.Background(), &provider.CompletionRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []*schema.Message{{Role: schema.User, Content: "What is 2+2?"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateCompletion failed: %v", err)
+	}
+	content, last := collect(t, stream)
+
+	if content != "4" {
+		t.Errorf("Got %q, want 4", content)
+	}
+	if last.ResponseMeta == nil || last.ResponseMeta.FinishReason != "stop" || last.ResponseMeta.Usage.PromptTokens != 100 {
+		t.Errorf("Got response meta %+v", last.ResponseMeta)
+	}
+
+	requests := server.GetRequests()
+	if len(requests) != 1 || requests[0].Path != "/v1beta/models/gemini-2.5-flash:streamGenerateContent" {
+		t.Errorf("Got requests %+v", requests)
+	}
+}
+
+func TestMockLLMGemini_ToolCall(t *testing.T) {
+	server := NewMockLLMServer()
+	defer server.Close()
+	p := newGeminiProvider(t, server)
+
+	stream, err := p.CreateCompletion(context.Background(), &provider.CompletionRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []*schema.Message{{Role: schema.User, Content: "read /tmp/notes.txt"}},
+		Tools: []*schema.ToolInfo{{
+			Name: "read",
+			Desc: "Read a file",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"file_path": {Type: schema.String, Required: true},
+			}),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateCompletion failed: %v", err)
+	}
+	_, last := collect(t, stream)
+
+	if len(last.ToolCalls) != 1 || last.ToolCalls[0].Function.Name != "read" {
+		t.Fatalf("Got tool calls %+v", last.ToolCalls)
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(last.ToolCalls[0].Function.Arguments), &args); err != nil || args["file_path"] != "/tmp/notes.txt" {
+		t.Errorf("Got arguments %q", last.ToolCalls[0].Function.Arguments)
+	}
+	if last.ResponseMeta.FinishReason != "tool-calls" {
+		t.Errorf("Got finish reason %q, want tool-calls", last.ResponseMeta.FinishReason)
+	}
+}
+
+func TestMockLLMGemini_InvalidRequest(t *testing.T) {
+	server := NewMockLLMServer()
+	defer server.Close()
+
+	resp, err := http.Post(server.URL()+"/v1beta/models/gemini-2.5-flash:generateContent", "application/json",
+		strings.NewReader(`{"contents":[{"role":"user","parts":[]}]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Got status %d, want 400", resp.StatusCode)
+	}
+	var body struct {
+		Error struct {
+			Status string `json:"status"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error.Status != "INVALID_ARGUMENT" {
+		t.Errorf("Got error status %q", body.Error.Status)
+	}
+}
@@ -23,7 +23,7 @@ type TestServer struct {
 	Server      *server.Server
 	BaseURL     string
 	Config      *types.Config
-	Storage     *storage.Storage
+	Storage     storage.Storage
 	ProviderReg *provider.Registry
 	ToolReg     *tool.Registry
 	TempDir     string
@@ -36,8 +36,10 @@ type TestServer struct {
 type TestServerOption func(*testServerConfig)
 
 type testServerConfig struct {
-	workDir string
-	envFile string
+	workDir   string
+	envFile   string
+	recordDir string
+	replayDir string
 }
 
 // WithWorkDir sets the working directory
@@ -54,9 +56,29 @@ func WithEnvFile(path string) TestServerOption {
 	}
 }
 
+// WithRecordDir records the LLM traffic of each session to a cassette in dir.
+// Defaults to $TEST_RECORD_DIR.
+func WithRecordDir(dir string) TestServerOption {
+	return func(c *testServerConfig) {
+		c.recordDir = dir
+	}
+}
+
+// WithReplayDir replays the LLM traffic of sessions from the cassettes in dir
+// instead of calling the provider, so a recorded bug report runs offline.
+// Defaults to $TEST_REPLAY_DIR.
+func WithReplayDir(dir string) TestServerOption {
+	return func(c *testServerConfig) {
+		c.replayDir = dir
+	}
+}
+
 // StartTestServer creates and starts a test server
 func StartTestServer(opts ...TestServerOption) (*TestServer, error) {
-	cfg := &testServerConfig{}
+	cfg := &testServerConfig{
+		recordDir: os.Getenv("TEST_RECORD_DIR"),
+		replayDir: os.Getenv("TEST_REPLAY_DIR"),
+	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
@@ -91,7 +113,7 @@ func StartTestServer(opts ...TestServerOption) (*TestServer, error) {
 	var mockLLM *MockLLMServer
 
 	switch testProvider {
-	case "mockllm":
+	case "mockllm", "mockllm-gemini":
 		// Start MockLLM server with config from mockllm.yaml
 		configDir := getMockLLMConfigDir()
 		var err error
@@ -109,8 +131,14 @@ func StartTestServer(opts ...TestServerOption) (*TestServer, error) {
 		os.Setenv("OPENAI_BASE_URL", mockLLM.URL())
 		os.Setenv("OPENAI_API_KEY", "mock-api-key")
 		os.Setenv("OPENAI_MODEL_ID", "gpt-4o-mini")
+		os.Setenv("GEMINI_BASE_URL", mockLLM.URL()+"/v1beta")
+		os.Setenv("GEMINI_API_KEY", "mock-api-key")
 		if os.Getenv("OPENCODE_MODEL") == "" {
-			os.Setenv("OPENCODE_MODEL", "openai/gpt-4o-mini")
+			if testProvider == "mockllm-gemini" {
+				os.Setenv("OPENCODE_MODEL", "google/gemini-2.5-flash")
+			} else {
+				os.Setenv("OPENCODE_MODEL", "openai/gpt-4o-mini")
+			}
 		}
 
 	case "ark":
@@ -195,6 +223,21 @@ func StartTestServer(opts ...TestServerOption) (*TestServer, error) {
 		return nil, fmt.Errorf("failed to initialize providers: %w", err)
 	}
 
+	// Record or replay LLM traffic
+	if cfg.recordDir != "" || cfg.replayDir != "" {
+		var recorder *provider.Recorder
+		if cfg.replayDir != "" {
+			recorder, err = provider.NewReplayer(cfg.replayDir)
+		} else {
+			recorder, err = provider.NewRecorder(cfg.recordDir)
+		}
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to set up cassettes: %w", err)
+		}
+		providerReg.SetRecorder(recorder)
+	}
+
 	// Initialize tools
 	toolReg := tool.DefaultRegistry(workDir, store)
 
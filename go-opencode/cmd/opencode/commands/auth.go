@@ -2,12 +2,16 @@ package commands
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/opencode-ai/opencode/internal/auth"
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/pkg/types"
 	"github.com/spf13/cobra"
 )
 
@@ -32,7 +36,8 @@ var authListCmd = &cobra.Command{
 var authLoginCmd = &cobra.Command{
 	Use:   "login [provider]",
 	Short: "Log in to a provider",
-	Long: `Log in to a provider by providing an API key.
+	Long: `Log in to a provider with an API key, or with OAuth for providers
+whose oauth options are set in the configuration.
 
 Supported providers:
   anthropic    Anthropic (Claude)
@@ -47,26 +52,24 @@ var authLogoutCmd = &cobra.Command{
 	RunE:  runAuthLogout,
 }
 
+var authLoginMethod int
+
 func init() {
 	authCmd.AddCommand(authListCmd)
 	authCmd.AddCommand(authLoginCmd)
 	authCmd.AddCommand(authLogoutCmd)
-}
-
-// Auth represents stored authentication data
-type Auth struct {
-	Providers map[string]AuthProvider `json:"providers"`
-}
 
-type AuthProvider struct {
-	APIKey string `json:"apiKey,omitempty"`
+	authLoginCmd.Flags().IntVar(&authLoginMethod, "method", 0, "Login method number, prompted for if 0")
 }
 
 func runAuthList(cmd *cobra.Command, args []string) error {
 	paths := config.GetPaths()
 
 	// Load auth file
-	auth, _ := loadAuth(paths.AuthPath())
+	stored, err := auth.NewStore(paths.AuthPath()).All()
+	if err != nil {
+		return fmt.Errorf("failed to read auth file: %w", err)
+	}
 
 	// Known providers and their environment variables
 	providers := map[string]string{
@@ -75,6 +78,11 @@ func runAuthList(cmd *cobra.Command, args []string) error {
 		"google":    "GOOGLE_API_KEY",
 		"bedrock":   "AWS_ACCESS_KEY_ID",
 	}
+	for provider := range stored {
+		if _, ok := providers[provider]; !ok {
+			providers[provider] = ""
+		}
+	}
 
 	fmt.Println("Provider Authentication Status:")
 	fmt.Println()
@@ -83,14 +91,19 @@ func runAuthList(cmd *cobra.Command, args []string) error {
 		status := "not configured"
 
 		// Check environment variable
-		if os.Getenv(envVar) != "" {
+		if envVar != "" && os.Getenv(envVar) != "" {
 			status = fmt.Sprintf("configured (via %s)", envVar)
 		}
 
 		// Check auth file
-		if auth != nil && auth.Providers != nil {
-			if p, ok := auth.Providers[provider]; ok && p.APIKey != "" {
+		if info, ok := stored[provider]; ok {
+			switch {
+			case info.Type != auth.TypeOAuth:
 				status = "configured (via auth file)"
+			case info.Expires == 0:
+				status = "logged in with OAuth"
+			default:
+				status = fmt.Sprintf("logged in with OAuth (token expires %s)", time.UnixMilli(info.Expires).Format(time.DateTime))
 			}
 		}
 
@@ -109,11 +122,53 @@ func runAuthLogin(cmd *cobra.Command, args []string) error {
 	}
 
 	provider := args[0]
-	paths := config.GetPaths()
+	store := auth.NewStore(config.GetPaths().AuthPath())
+	reader := bufio.NewReader(os.Stdin)
+
+	// Offer the OAuth flows of providers configured with oauth options
+	var oauthConfig *types.OAuthConfig
+	if workDir, err := os.Getwd(); err == nil {
+		if appConfig, err := config.Load(workDir); err == nil {
+			if p, ok := appConfig.Provider[provider]; ok && p.Options != nil {
+				oauthConfig = p.Options.OAuth
+			}
+		}
+	}
+	methods := auth.Methods(oauthConfig)
+
+	choice := authLoginMethod
+	if choice == 0 && len(methods) > 1 {
+		fmt.Printf("Log in to %s:\n", provider)
+		for i, m := range methods {
+			fmt.Printf("  %d. %s\n", i+1, m.Label)
+		}
+		fmt.Print("Select a method: ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if choice, err = strconv.Atoi(strings.TrimSpace(line)); err != nil {
+			return fmt.Errorf("invalid method: %s", strings.TrimSpace(line))
+		}
+	}
+	if choice == 0 {
+		choice = 1
+	}
+	if choice < 1 || choice > len(methods) {
+		return fmt.Errorf("invalid method %d, expected 1 to %d", choice, len(methods))
+	}
+	method := methods[choice-1]
+
+	if method.Type == auth.TypeOAuth {
+		if err := loginOAuth(cmd.Context(), store, provider, oauthConfig, method, reader); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully logged in to %s\n", provider)
+		return nil
+	}
 
 	// Prompt for API key
 	fmt.Printf("Enter API key for %s: ", provider)
-	reader := bufio.NewReader(os.Stdin)
 	apiKey, err := reader.ReadString('\n')
 	if err != nil {
 		return err
@@ -124,19 +179,8 @@ func runAuthLogin(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("API key cannot be empty")
 	}
 
-	// Load existing auth
-	auth, _ := loadAuth(paths.AuthPath())
-	if auth == nil {
-		auth = &Auth{Providers: make(map[string]AuthProvider)}
-	}
-	if auth.Providers == nil {
-		auth.Providers = make(map[string]AuthProvider)
-	}
-
 	// Save API key
-	auth.Providers[provider] = AuthProvider{APIKey: apiKey}
-
-	if err := saveAuth(paths.AuthPath(), auth); err != nil {
+	if err := store.Set(provider, auth.Info{Type: auth.TypeAPI, Key: apiKey}); err != nil {
 		return fmt.Errorf("failed to save auth: %w", err)
 	}
 
@@ -144,63 +188,53 @@ func runAuthLogin(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func runAuthLogout(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("provider name required. Use: opencode auth logout <provider>")
-	}
-
-	provider := args[0]
-	paths := config.GetPaths()
-
-	// Load existing auth
-	auth, err := loadAuth(paths.AuthPath())
+// loginOAuth runs an OAuth flow and stores its tokens.
+func loginOAuth(ctx context.Context, store *auth.Store, provider string, cfg *types.OAuthConfig, method auth.Method, reader *bufio.Reader) error {
+	flow, err := auth.NewClient(cfg, nil).Start(ctx, method)
 	if err != nil {
-		return fmt.Errorf("no auth file found")
+		return fmt.Errorf("failed to start the login: %w", err)
 	}
+	defer flow.Close()
 
-	if auth.Providers == nil {
-		return fmt.Errorf("not logged in to %s", provider)
-	}
+	fmt.Printf("Open %s\n", flow.URL)
+	fmt.Println(flow.Instructions)
 
-	if _, ok := auth.Providers[provider]; !ok {
-		return fmt.Errorf("not logged in to %s", provider)
+	var code string
+	if flow.Method == auth.MethodCode {
+		fmt.Print("Authorization code: ")
+		if code, err = reader.ReadString('\n'); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("Waiting for authorization...")
 	}
 
-	// Remove provider
-	delete(auth.Providers, provider)
-
-	if err := saveAuth(paths.AuthPath(), auth); err != nil {
+	token, err := flow.Finish(ctx, code)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	if err := store.Set(provider, token.Info()); err != nil {
 		return fmt.Errorf("failed to save auth: %w", err)
 	}
-
-	fmt.Printf("Successfully logged out from %s\n", provider)
 	return nil
 }
 
-func loadAuth(path string) (*Auth, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var auth Auth
-	if err := json.Unmarshal(data, &auth); err != nil {
-		return nil, err
+func runAuthLogout(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("provider name required. Use: opencode auth logout <provider>")
 	}
 
-	return &auth, nil
-}
+	provider := args[0]
 
-func saveAuth(path string, auth *Auth) error {
-	data, err := json.MarshalIndent(auth, "", "  ")
+	// Remove provider
+	removed, err := auth.NewStore(config.GetPaths().AuthPath()).Remove(provider)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to save auth: %w", err)
 	}
-
-	// Ensure directory exists
-	if err := os.MkdirAll(config.GetPaths().Data, 0755); err != nil {
-		return err
+	if !removed {
+		return fmt.Errorf("not logged in to %s", provider)
 	}
 
-	return os.WriteFile(path, data, 0600)
+	fmt.Printf("Successfully logged out from %s\n", provider)
+	return nil
 }
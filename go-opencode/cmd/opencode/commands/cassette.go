@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/opencode-ai/opencode/internal/provider"
+)
+
+// setupCassettes installs the recorder selected by the --record or --replay
+// flag on the provider registry.
+func setupCassettes(providerReg *provider.Registry) error {
+	if recordDir != "" && replayDir != "" {
+		return fmt.Errorf("--record and --replay cannot be used together")
+	}
+	if replayLenient && replayDir == "" {
+		return fmt.Errorf("--replay-lenient needs --replay")
+	}
+
+	var recorder *provider.Recorder
+	var err error
+	switch {
+	case recordDir != "":
+		recorder, err = provider.NewRecorder(recordDir)
+	case replayDir != "":
+		recorder, err = provider.NewReplayer(replayDir)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	recorder.SetLenient(replayLenient)
+	providerReg.SetRecorder(recorder)
+	return nil
+}
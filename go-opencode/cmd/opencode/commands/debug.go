@@ -65,6 +65,8 @@ func runDebugPaths(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  State:    %s\n", paths.State)
 	fmt.Printf("  Storage:  %s\n", paths.StoragePath())
 	fmt.Printf("  Auth:     %s\n", paths.AuthPath())
+	fmt.Printf("  Models:   %s\n", paths.CatalogPath())
+	fmt.Printf("  Tokenizers: %s\n", paths.TokenizerPath())
 	fmt.Println()
 
 	// Also show TS-compatible paths
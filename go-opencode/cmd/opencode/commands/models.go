@@ -6,6 +6,7 @@ import (
 	"os"
 	"text/tabwriter"
 
+	"github.com/opencode-ai/opencode/internal/catalog"
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/provider"
 	"github.com/spf13/cobra"
@@ -14,6 +15,8 @@ import (
 var (
 	modelsVerbose bool
 	modelsRefresh bool
+
+	modelsUpdateFrom string
 )
 
 var modelsCmd = &cobra.Command{
@@ -24,13 +27,49 @@ var modelsCmd = &cobra.Command{
 Examples:
   opencode models              # List all models
   opencode models anthropic    # List only Anthropic models
-  opencode models --verbose    # Show pricing information`,
+  opencode models --verbose    # Show pricing information
+  opencode models --refresh    # Ask OpenAI-compatible servers for their models again`,
 	RunE: runModels,
 }
 
+var modelsUpdateCmd = &cobra.Command{
+	Use:   "update --from <file>",
+	Short: "Update the model catalog",
+	Long: `Install a model catalog in the models.dev format, such as a download of
+https://models.dev/api.json. Its providers and models are merged over the
+catalog built into opencode, which gives the capabilities, limits and prices
+of models.
+
+Examples:
+  curl -o api.json https://models.dev/api.json
+  opencode models update --from api.json`,
+	Args: cobra.NoArgs,
+	RunE: runModelsUpdate,
+}
+
 func init() {
 	modelsCmd.Flags().BoolVarP(&modelsVerbose, "verbose", "v", false, "Include metadata like costs")
-	modelsCmd.Flags().BoolVar(&modelsRefresh, "refresh", false, "Refresh models cache")
+	modelsCmd.Flags().BoolVar(&modelsRefresh, "refresh", false, "Refresh the models discovered from OpenAI-compatible servers")
+
+	modelsUpdateCmd.Flags().StringVar(&modelsUpdateFrom, "from", "", "Catalog file to install")
+	modelsUpdateCmd.MarkFlagRequired("from")
+	modelsCmd.AddCommand(modelsUpdateCmd)
+}
+
+func runModelsUpdate(cmd *cobra.Command, args []string) error {
+	path := config.GetPaths().CatalogPath()
+	c, err := catalog.Update(modelsUpdateFrom, path)
+	if err != nil {
+		return fmt.Errorf("failed to update the model catalog: %w", err)
+	}
+
+	models := 0
+	providers := c.Providers()
+	for _, p := range providers {
+		models += len(p.Models)
+	}
+	fmt.Printf("Installed %d models of %d providers to %s\n", models, len(providers), path)
+	return nil
 }
 
 func runModels(cmd *cobra.Command, args []string) error {
@@ -65,6 +104,13 @@ func runModels(cmd *cobra.Command, args []string) error {
 		providerFilter = args[0]
 	}
 
+	// Bypass the cache of discovered models
+	if modelsRefresh {
+		if err := providerReg.RefreshModels(ctx, providerFilter); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to refresh models: %v\n", err)
+		}
+	}
+
 	// Get models using AllModels
 	models := providerReg.AllModels()
 
@@ -72,7 +118,7 @@ func runModels(cmd *cobra.Command, args []string) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 	if modelsVerbose {
-		fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT\tMAX OUTPUT\tINPUT PRICE\tOUTPUT PRICE\t")
+		fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT\tMAX OUTPUT\tINPUT PRICE\tOUTPUT PRICE\tCACHE READ\tCACHE WRITE\t")
 	} else {
 		fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT\tFEATURES\t")
 	}
@@ -84,13 +130,15 @@ func runModels(cmd *cobra.Command, args []string) error {
 		}
 
 		if modelsVerbose {
-			fmt.Fprintf(w, "%s\t%s\t%dk\t%d\t$%.2f/1M\t$%.2f/1M\t\n",
+			fmt.Fprintf(w, "%s\t%s\t%dk\t%d\t$%.2f/1M\t$%.2f/1M\t$%.2f/1M\t$%.2f/1M\t\n",
 				model.ProviderID,
 				model.ID,
 				model.ContextLength/1000,
 				model.MaxOutputTokens,
 				model.InputPrice,
 				model.OutputPrice,
+				model.CacheReadPrice,
+				model.CacheWritePrice,
 			)
 		} else {
 			features := ""
@@ -19,11 +19,14 @@ var (
 
 // Global flags
 var (
-	printLogs   bool
-	logLevel    string
-	logFile     bool
-	showConfig  bool
-	globalModel string
+	printLogs     bool
+	logLevel      string
+	logFile       bool
+	showConfig    bool
+	globalModel   string
+	recordDir     string
+	replayDir     string
+	replayLenient bool
 )
 
 var rootCmd = &cobra.Command{
@@ -97,6 +100,9 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&logFile, "log-file", false, "Write logs to /tmp/opencode-YYYYMMDD-HHMMSS.log")
 	rootCmd.PersistentFlags().BoolVar(&showConfig, "show-config", false, "Print merged configuration as JSON and exit")
 	rootCmd.PersistentFlags().StringVarP(&globalModel, "model", "m", "", "Model to use (provider/model format)")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Record LLM traffic to one cassette per session in this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Replay LLM traffic from the cassettes in this directory instead of calling providers")
+	rootCmd.PersistentFlags().BoolVar(&replayLenient, "replay-lenient", false, "Replay a request matching no recording with the next completion of the same kind")
 
 	// Version template
 	rootCmd.SetVersionTemplate(fmt.Sprintf("opencode %s (%s)\n", Version, BuildTime))
@@ -108,6 +114,10 @@ func init() {
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(agentCmd)
 	rootCmd.AddCommand(debugCmd)
+	rootCmd.AddCommand(storageCmd)
+	rootCmd.AddCommand(sessionCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(tokenizerCmd)
 }
 
 // Execute runs the root command.
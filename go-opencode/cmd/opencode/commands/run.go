@@ -2,10 +2,17 @@ package commands
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/oklog/ulid/v2"
 	"github.com/opencode-ai/opencode/internal/agent"
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/executor"
@@ -30,6 +37,8 @@ var (
 	runPromptFile   string
 	runPromptInline string
 	runDir          string
+	runCompare      []string
+	runIsolate      bool
 )
 
 var runCmd = &cobra.Command{
@@ -41,7 +50,9 @@ Examples:
   opencode run "Fix the bug in main.go"
   opencode -m anthropic/claude-sonnet-4 run "Explain this code"
   opencode run --continue  # Continue last session
-  opencode run --file main.go "Review this file"`,
+  opencode run --file main.go "Review this file"
+  opencode run --file screenshot.png "What is wrong with this page?"
+  opencode run --compare openai/gpt-4o,anthropic/claude-sonnet-4 "Fix the bug"`,
 	RunE: runInteractive,
 }
 
@@ -56,6 +67,8 @@ func init() {
 	runCmd.Flags().StringVar(&runPromptFile, "prompt-file", "", "Custom prompt from file")
 	runCmd.Flags().StringVar(&runPromptInline, "prompt-inline", "", "Custom prompt as inline text")
 	runCmd.Flags().StringVar(&runDir, "directory", "", "Working directory")
+	runCmd.Flags().StringSliceVar(&runCompare, "compare", nil, "Run the message on each of these models (provider/model) in forks of the session and compare")
+	runCmd.Flags().BoolVar(&runIsolate, "isolate", false, "With --compare, run each fork on its own copy of the working directory")
 }
 
 func runInteractive(cmd *cobra.Command, args []string) error {
@@ -89,7 +102,11 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 	}
 
 	// Initialize storage
-	store := storage.New(paths.StoragePath())
+	store, err := storage.Open(paths.StoragePath(), appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
 
 	// Initialize providers
 	ctx := context.Background()
@@ -97,6 +114,9 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to initialize providers: %w", err)
 	}
+	if err := setupCassettes(providerReg); err != nil {
+		return err
+	}
 
 	// Initialize tool registry
 	toolReg := tool.DefaultRegistry(workDir, store)
@@ -155,13 +175,23 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Handle file attachments - read and include in message
+	// Handle file attachments - images and PDFs are attached to the message,
+	// other files are read and included in it
 	var fileContent strings.Builder
+	var files []types.FilePart
 	for _, file := range runFiles {
 		content, err := os.ReadFile(file)
 		if err != nil {
 			return fmt.Errorf("failed to read file %s: %w", file, err)
 		}
+		if mime := attachmentMime(file, content); mime != "" {
+			files = append(files, types.FilePart{
+				Mime:     mime,
+				Filename: filepath.Base(file),
+				URL:      fmt.Sprintf("data:%s;base64,%s", mime, base64.StdEncoding.EncodeToString(content)),
+			})
+			continue
+		}
 		fileContent.WriteString(fmt.Sprintf("\n\n--- File: %s ---\n%s", file, string(content)))
 	}
 	if fileContent.Len() > 0 {
@@ -211,8 +241,17 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 	})
 	toolReg.SetTaskExecutor(subagentExecutor)
 
+	if len(runCompare) > 0 {
+		service := session.NewServiceWithProcessor(store, providerReg, toolReg, permChecker, defaultProviderID, defaultModelID)
+		service.SetBudgets(session.NewBudgets(appConfig))
+		service.SetFallbacks(session.NewFallbacks(appConfig))
+		return runComparison(ctx, service, workDir, sessionID, message, files)
+	}
+
 	// Create processor
 	processor := session.NewProcessor(providerReg, toolReg, store, permChecker, defaultProviderID, defaultModelID)
+	processor.SetBudgets(session.NewBudgets(appConfig))
+	processor.SetFallbacks(session.NewFallbacks(appConfig))
 
 	// Create agent configuration
 	agentName := runAgent
@@ -233,8 +272,12 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Note: User message will be added by the processor
-	// The message content is passed through the agent's input
+	// Continuing a session without a message answers its last one
+	if message != "" || len(files) > 0 {
+		if err := saveUserMessage(ctx, store, sessionID, agentName, message, files); err != nil {
+			return fmt.Errorf("failed to save message: %w", err)
+		}
+	}
 
 	// Run the agentic loop
 	fmt.Printf("Starting session %s...\n", sessionID)
@@ -249,6 +292,129 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// attachmentMime returns the MIME type of an image or PDF file, sent to the
+// model as an attachment, or "" for files included in the message as text.
+func attachmentMime(path string, content []byte) string {
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return "application/pdf"
+	}
+	mime, _, _ := strings.Cut(http.DetectContentType(content), ";")
+	if strings.HasPrefix(mime, "image/") || mime == "application/pdf" {
+		return mime
+	}
+	return ""
+}
+
+// saveUserMessage stores the message to answer, with the files attached to
+// it, as the last user message of the session.
+func saveUserMessage(ctx context.Context, store storage.Storage, sessionID, agentName, text string, files []types.FilePart) error {
+	msg := &types.Message{
+		ID:        ulid.Make().String(),
+		SessionID: sessionID,
+		Role:      "user",
+		Agent:     agentName,
+		Summary: &types.UserMessageSummary{
+			Diffs: []types.FileDiff{},
+		},
+		Time: types.MessageTime{
+			Created: time.Now().UnixMilli(),
+		},
+	}
+	if err := store.Put(ctx, []string{"message", sessionID, msg.ID}, msg); err != nil {
+		return err
+	}
+
+	parts := []types.Part{&types.TextPart{
+		ID:        ulid.Make().String(),
+		SessionID: sessionID,
+		MessageID: msg.ID,
+		Type:      "text",
+		Text:      text,
+	}}
+	for i := range files {
+		file := files[i]
+		file.ID = ulid.Make().String()
+		file.SessionID = sessionID
+		file.MessageID = msg.ID
+		file.Type = "file"
+		parts = append(parts, &file)
+	}
+	for _, part := range parts {
+		if err := store.Put(ctx, []string{"part", msg.ID, part.PartID()}, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runComparison sends the message to every model of --compare in forks of
+// the session, created if the command does not continue one, and prints the
+// summary of the runs.
+func runComparison(ctx context.Context, service *session.Service, workDir, sessionID, message string, files []types.FilePart) error {
+	if runSession == "" && !runContinue {
+		base, err := service.Create(ctx, workDir, runTitle)
+		if err != nil {
+			return err
+		}
+		sessionID = base.ID
+	}
+
+	fmt.Fprintf(os.Stderr, "Comparing %d models on session %s...\n", len(runCompare), sessionID)
+	result, err := service.Compare(ctx, session.CompareInput{
+		SessionID: sessionID,
+		Text:      message,
+		Files:     files,
+		Agent:     runAgent,
+		Models:    runCompare,
+		Isolate:   runIsolate,
+	})
+	if err != nil {
+		return err
+	}
+
+	if runFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tSESSION\tSTATUS\tLATENCY\tINPUT\tOUTPUT\tCOST\tFILES\tCHANGES\t")
+	for _, run := range result.Runs {
+		status := "ok"
+		if run.Error != "" {
+			status = "error"
+		}
+		additions, deletions := 0, 0
+		for _, d := range run.Diffs {
+			additions += d.Additions
+			deletions += d.Deletions
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\t%.1fs\t%d\t%d\t$%.4f\t%d\t+%d -%d\t\n",
+			run.Model.ProviderID, run.Model.ModelID, run.SessionID, status,
+			float64(run.Latency)/1000, run.Tokens.Input, run.Tokens.Output, run.Cost,
+			len(run.Diffs), additions, deletions)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, run := range result.Runs {
+		fmt.Printf("\n=== %s/%s", run.Model.ProviderID, run.Model.ModelID)
+		if run.Directory != workDir {
+			fmt.Printf(" (%s)", run.Directory)
+		}
+		fmt.Println(" ===")
+		if run.Error != "" {
+			fmt.Printf("Error: %s\n", run.Error)
+		}
+		if run.Text != "" {
+			fmt.Println(run.Text)
+		}
+	}
+	return nil
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
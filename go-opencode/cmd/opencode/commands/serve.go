@@ -17,6 +17,7 @@ import (
 	"github.com/opencode-ai/opencode/internal/mcp"
 	"github.com/opencode-ai/opencode/internal/provider"
 	"github.com/opencode-ai/opencode/internal/server"
+	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/internal/tool"
 	"github.com/spf13/cobra"
@@ -76,7 +77,11 @@ func runServe(cmd *cobra.Command, args []string) error {
 	}
 
 	// Initialize storage
-	store := storage.New(paths.StoragePath())
+	store, err := storage.Open(paths.StoragePath(), appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
 
 	// Initialize providers
 	ctx := context.Background()
@@ -84,12 +89,16 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		logging.Warn().Err(err).Msg("Failed to initialize some providers")
 	}
+	if err := setupCassettes(providerReg); err != nil {
+		return err
+	}
 
 	// Initialize tool registry
 	toolReg := tool.DefaultRegistry(workDir, store)
 
 	// Initialize agent registry
 	agentReg := agent.NewRegistry()
+	agentReg.LoadFromAppConfig(appConfig.Agent)
 	logging.Info().
 		Int("agentCount", agentReg.Count()).
 		Strs("agents", agentReg.Names()).
@@ -126,10 +135,47 @@ func runServe(cmd *cobra.Command, args []string) error {
 	serverConfig := server.DefaultConfig()
 	serverConfig.Port = servePort
 	serverConfig.Directory = workDir
+	serverConfig.AuthPath = paths.AuthPath()
+	serverConfig.Agents = agentReg
+	if appConfig.Snapshot == nil || *appConfig.Snapshot {
+		serverConfig.SnapshotDir = paths.SnapshotPath()
+	}
 
 	// Create server
 	srv := server.New(serverConfig, appConfig, store, providerReg, toolReg)
 
+	// Mark sessions interrupted by a previous crash as aborted so they can be resumed
+	if recovered, err := srv.RecoverSessions(ctx); err != nil {
+		logging.Warn().Err(err).Msg("Failed to recover interrupted sessions")
+	} else if len(recovered) > 0 {
+		logging.Info().Strs("sessions", recovered).Msg("Recovered interrupted sessions")
+	}
+
+	// Apply the storage retention policy, if one is configured
+	if appConfig.Storage != nil && appConfig.Storage.Retention != nil {
+		if report, err := srv.CollectGarbage(ctx, session.NewRetentionPolicy(appConfig.Storage.Retention)); err != nil {
+			logging.Warn().Err(err).Msg("Storage garbage collection failed")
+		} else if report.Records > 0 {
+			logging.Info().
+				Int("expiredSessions", len(report.ExpiredSessions)).
+				Int("records", report.Records).
+				Int64("bytes", report.Bytes).
+				Msg("Storage garbage collection completed")
+		}
+	}
+
+	// Start the scheduled sessions of the configuration
+	if err := srv.StartScheduler(ctx); err != nil {
+		logging.Warn().Err(err).Msg("Failed to start the scheduler")
+	}
+
+	// Load the session search index without delaying startup
+	go func() {
+		if err := srv.LoadSearchIndex(ctx); err != nil {
+			logging.Warn().Err(err).Msg("Failed to load session search index")
+		}
+	}()
+
 	// Initialize MCP servers from config
 	if err := srv.InitializeMCP(ctx); err != nil {
 		logging.Warn().Err(err).Msg("Failed to initialize some MCP servers")
@@ -0,0 +1,288 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/search"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/snapshot"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionSearchProject string
+	sessionSearchAgent   string
+	sessionSearchSince   string
+	sessionSearchUntil   string
+	sessionSearchLimit   int
+	sessionSearchJSON    bool
+
+	sessionExportFormat      string
+	sessionExportOutput      string
+	sessionExportGzip        bool
+	sessionExportNoSnapshots bool
+	sessionImportDirectory   string
+)
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Work with stored sessions",
+}
+
+var sessionSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search messages across all sessions",
+	Long: `Search text, reasoning and tool output of every stored session. Every word
+of the query must appear in a result. Dates accept unix milliseconds,
+RFC 3339 or YYYY-MM-DD.
+
+Examples:
+  opencode session search "sse flush"
+  opencode session search migration --agent build --since 2025-01-01
+  opencode session search panic --project 4b0ea68d --json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSessionSearch,
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export <sessionID>",
+	Short: "Export a session as a bundle or transcript",
+	Long: `Export a session with its messages, todos and file snapshots as a JSON
+bundle that "opencode session import" reads back, or as a Markdown or HTML
+transcript for reading.
+
+Examples:
+  opencode session export ses_01J... -o session.json.gz --gzip
+  opencode session export ses_01J... --format markdown > session.md`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionExport,
+}
+
+var sessionImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session bundle",
+	Long: `Import a session from a bundle written by "opencode session export",
+gzipped or not. Use - to read from stdin. Sessions, messages and parts that
+are already stored get new IDs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionImport,
+}
+
+func init() {
+	sessionSearchCmd.Flags().StringVar(&sessionSearchProject, "project", "", "Only search sessions of this project ID")
+	sessionSearchCmd.Flags().StringVar(&sessionSearchAgent, "agent", "", "Only search messages of this agent")
+	sessionSearchCmd.Flags().StringVar(&sessionSearchSince, "since", "", "Only search messages created at or after this time")
+	sessionSearchCmd.Flags().StringVar(&sessionSearchUntil, "until", "", "Only search messages created at or before this time, or on or before this date")
+	sessionSearchCmd.Flags().IntVarP(&sessionSearchLimit, "limit", "n", search.DefaultLimit, "Maximum number of results")
+	sessionSearchCmd.Flags().BoolVar(&sessionSearchJSON, "json", false, "Print results as JSON")
+	sessionCmd.AddCommand(sessionSearchCmd)
+
+	sessionExportCmd.Flags().StringVarP(&sessionExportFormat, "format", "f", session.FormatJSON, "Output format: json, markdown or html")
+	sessionExportCmd.Flags().StringVarP(&sessionExportOutput, "output", "o", "", "Write to this file instead of stdout")
+	sessionExportCmd.Flags().BoolVar(&sessionExportGzip, "gzip", false, "Gzip the JSON bundle")
+	sessionExportCmd.Flags().BoolVar(&sessionExportNoSnapshots, "no-snapshots", false, "Leave file snapshots out of the bundle")
+	sessionCmd.AddCommand(sessionExportCmd)
+
+	sessionImportCmd.Flags().StringVar(&sessionImportDirectory, "directory", "", "Place the session in this project directory instead of the original one")
+	sessionCmd.AddCommand(sessionImportCmd)
+}
+
+func runSessionSearch(cmd *cobra.Command, args []string) error {
+	q := search.Query{
+		Text:      strings.Join(args, " "),
+		ProjectID: sessionSearchProject,
+		Agent:     sessionSearchAgent,
+		Limit:     sessionSearchLimit,
+	}
+
+	var err error
+	if sessionSearchSince != "" {
+		if q.Since, err = search.ParseTime(sessionSearchSince); err != nil {
+			return err
+		}
+	}
+	if sessionSearchUntil != "" {
+		if q.Until, err = search.ParseUntil(sessionSearchUntil); err != nil {
+			return err
+		}
+	}
+
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	// The saved index is caught up with what was written since, and saved
+	// again so the next search starts from there
+	index := search.NewIndex(store)
+	hits, err := index.Search(context.Background(), q)
+	if err != nil {
+		return err
+	}
+	if err := index.Save(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save search index: %v\n", err)
+	}
+
+	if sessionSearchJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hits)
+	}
+
+	if len(hits) == 0 {
+		fmt.Println("No matches")
+		return nil
+	}
+
+	highlight := isTerminal(os.Stdout)
+	for _, hit := range hits {
+		title := hit.SessionTitle
+		if title == "" {
+			title = "(untitled)"
+		}
+		source := hit.Kind
+		if hit.Tool != "" {
+			source += ":" + hit.Tool
+		}
+
+		fmt.Printf("%s  %s\n", hit.SessionID, title)
+		fmt.Printf("  %s  %s  %s\n", time.UnixMilli(hit.Time).Format("2006-01-02 15:04"), source, hit.MessageID)
+		fmt.Printf("  %s\n\n", renderSnippet(hit, highlight))
+	}
+
+	return nil
+}
+
+func runSessionExport(cmd *cobra.Command, args []string) error {
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	bundle, err := newSessionService(store, appConfig).Export(ctx, args[0], !sessionExportNoSnapshots)
+	if err != nil {
+		return fmt.Errorf("failed to export session %s: %w", args[0], err)
+	}
+
+	out := os.Stdout
+	if sessionExportOutput != "" {
+		if out, err = os.Create(sessionExportOutput); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	if err := session.WriteExport(out, bundle, sessionExportFormat, sessionExportGzip); err != nil {
+		return err
+	}
+	if sessionExportOutput != "" {
+		return out.Close()
+	}
+	return nil
+}
+
+func runSessionImport(cmd *cobra.Command, args []string) error {
+	in := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	bundle, err := session.ReadBundle(in)
+	if err != nil {
+		return err
+	}
+
+	directory := sessionImportDirectory
+	if directory != "" {
+		if directory, err = filepath.Abs(directory); err != nil {
+			return err
+		}
+	}
+
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	info, err := newSessionService(store, appConfig).Import(context.Background(), bundle, directory)
+	if err != nil {
+		return fmt.Errorf("failed to import session: %w", err)
+	}
+
+	fmt.Printf("Imported session %s", info.ID)
+	if info.ID != bundle.Info.ID {
+		fmt.Printf(" (exported as %s)", bundle.Info.ID)
+	}
+	fmt.Println()
+	return nil
+}
+
+// newSessionService creates a session service for commands working on
+// stored sessions, with file snapshots unless they are disabled.
+func newSessionService(store storage.Storage, appConfig *types.Config) *session.Service {
+	service := session.NewService(store)
+	if appConfig.Snapshot == nil || *appConfig.Snapshot {
+		service.SetSnapshots(snapshot.New(config.GetPaths().SnapshotPath()))
+	}
+	return service
+}
+
+// renderSnippet marks the matched terms of a hit, in bold on a terminal and
+// with brackets otherwise.
+func renderSnippet(hit search.Hit, bold bool) string {
+	before, after := "[", "]"
+	if bold {
+		before, after = "\033[1m", "\033[0m"
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, h := range hit.Highlights {
+		b.WriteString(hit.Snippet[last:h[0]])
+		b.WriteString(before)
+		b.WriteString(hit.Snippet[h[0]:h[1]])
+		b.WriteString(after)
+		last = h[1]
+	}
+	b.WriteString(hit.Snippet[last:])
+	return b.String()
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/opencode-ai/opencode/internal/project"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsDays     int
+	statsProject  string
+	statsSessions int
+	statsJSON     bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show token usage and cost",
+	Long: `Show the tokens used and their cost, per project, per session and per day.
+Costs are computed from the model prices when each step finishes, including
+overrides set under provider.<id>.models.<id>.cost in the config.
+
+Examples:
+  opencode stats                   # All projects, all time
+  opencode stats --days 7          # The last 7 days, today included
+  opencode stats --project ""      # The project of the current directory
+  opencode stats --json`,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().IntVar(&statsDays, "days", 0, "Only count the last N days, today included")
+	statsCmd.Flags().StringVar(&statsProject, "project", "", "Only count this project ID; empty for the current project")
+	statsCmd.Flags().IntVar(&statsSessions, "sessions", 10, "Number of most expensive sessions to list")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Print the report as JSON")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	filter := session.UsageFilter{ProjectID: statsProject}
+	if cmd.Flags().Changed("project") && statsProject == "" {
+		workDir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if filter.ProjectID, err = project.GetProjectID(workDir); err != nil {
+			return fmt.Errorf("failed to get project ID: %w", err)
+		}
+	}
+	if statsDays > 0 {
+		filter.Since = session.DaysAgo(statsDays)
+	}
+
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	report, err := session.NewService(store).Usage(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+
+	if statsJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	if report.Total.Messages == 0 {
+		fmt.Println("No usage recorded.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Sessions\t%d\t\n", len(report.Sessions))
+	fmt.Fprintf(w, "Messages\t%d\t\n", report.Total.Messages)
+	fmt.Fprintf(w, "Days\t%d\t\n", len(report.Days))
+	fmt.Fprintf(w, "Total cost\t$%.4f\t\n", report.Total.Cost)
+	if len(report.Days) > 0 {
+		fmt.Fprintf(w, "Cost per day\t$%.4f\t\n", report.Total.Cost/float64(len(report.Days)))
+	}
+	fmt.Fprintf(w, "Input tokens\t%d\t\n", report.Total.Tokens.Input)
+	fmt.Fprintf(w, "Output tokens\t%d\t\n", report.Total.Tokens.Output)
+	fmt.Fprintf(w, "Cache read tokens\t%d\t\n", report.Total.Tokens.Cache.Read)
+	fmt.Fprintf(w, "Cache write tokens\t%d\t\n", report.Total.Tokens.Cache.Write)
+	w.Flush()
+	fmt.Println()
+
+	fmt.Fprintln(w, "PROJECT\tDIRECTORY\tMESSAGES\tINPUT\tOUTPUT\tCACHE READ\tCOST\t")
+	for _, p := range report.Projects {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t$%.4f\t\n",
+			p.ProjectID, p.Directory, p.Messages,
+			p.Tokens.Input, p.Tokens.Output, p.Tokens.Cache.Read, p.Cost)
+	}
+	w.Flush()
+	fmt.Println()
+
+	fmt.Fprintln(w, "SESSION\tTITLE\tMESSAGES\tINPUT\tOUTPUT\tCACHE READ\tCOST\t")
+	for i, s := range report.Sessions {
+		if statsSessions > 0 && i >= statsSessions {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t$%.4f\t\n",
+			s.SessionID, truncate(s.Title, 40), s.Messages,
+			s.Tokens.Input, s.Tokens.Output, s.Tokens.Cache.Read, s.Cost)
+	}
+	w.Flush()
+	fmt.Println()
+
+	fmt.Fprintln(w, "DAY\tMESSAGES\tINPUT\tOUTPUT\tCACHE READ\tCOST\t")
+	for _, d := range report.Days {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t$%.4f\t\n",
+			d.Date, d.Messages, d.Tokens.Input, d.Tokens.Output, d.Tokens.Cache.Read, d.Cost)
+	}
+
+	return w.Flush()
+}
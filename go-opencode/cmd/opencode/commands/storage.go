@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	storageGCDryRun      bool
+	storageRotateKeyFile string
+	storageRotateKeyEnv  string
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage session storage",
+}
+
+var storageGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove expired sessions and orphaned data",
+	Long: `Remove sessions expired under the "storage.retention" policy together with
+their messages, parts and todos, then remove messages, parts and todos whose
+session or message no longer exists.
+
+Without a retention policy only orphaned data is removed. Avoid running this
+while a server is using the same storage.
+
+Examples:
+  opencode storage gc --dry-run   # Report what would be reclaimed
+  opencode storage gc             # Reclaim it`,
+	RunE: runStorageGC,
+}
+
+var storageVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that every stored record decrypts and parses",
+	Long: `Read every record in storage and check that it decrypts with the key in
+"storage.encryption" and parses as JSON. Records that fail are listed and the
+command exits with an error.`,
+	RunE: runStorageVerify,
+}
+
+var storageRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Re-encrypt all records with a new key",
+	Long: `Re-encrypt every record in place with a new key. Records encrypted with the
+key currently configured in "storage.encryption", and plaintext records, are
+rewritten; records already under the new key are skipped, so an interrupted
+rotation can be rerun. Afterwards point "storage.encryption" at the new key.
+
+Run this with no server using the same storage. Keys are 32 random bytes,
+base64 encoded, e.g. from "openssl rand -base64 32".
+
+Examples:
+  opencode storage rotate-key --new-key-file ~/.config/opencode/storage.key
+  opencode storage rotate-key --new-key-env OPENCODE_STORAGE_KEY_NEW`,
+	RunE: runStorageRotateKey,
+}
+
+func init() {
+	storageGCCmd.Flags().BoolVar(&storageGCDryRun, "dry-run", false, "Report what would be removed without deleting anything")
+	storageRotateKeyCmd.Flags().StringVar(&storageRotateKeyFile, "new-key-file", "", "File containing the new key")
+	storageRotateKeyCmd.Flags().StringVar(&storageRotateKeyEnv, "new-key-env", "", "Environment variable containing the new key")
+	storageCmd.AddCommand(storageGCCmd)
+	storageCmd.AddCommand(storageVerifyCmd)
+	storageCmd.AddCommand(storageRotateKeyCmd)
+}
+
+// loadStorageConfig loads the configuration for the current directory and
+// returns it with the storage base path.
+func loadStorageConfig() (*types.Config, string, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Initialize paths
+	paths := config.GetPaths()
+	if err := paths.EnsurePaths(); err != nil {
+		return nil, "", err
+	}
+
+	// Load configuration
+	appConfig, err := config.Load(workDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return appConfig, paths.StoragePath(), nil
+}
+
+func runStorageGC(cmd *cobra.Command, args []string) error {
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	var retention *types.RetentionConfig
+	if appConfig.Storage != nil {
+		retention = appConfig.Storage.Retention
+	}
+
+	report, err := session.NewService(store).CollectGarbage(context.Background(), session.NewRetentionPolicy(retention), storageGCDryRun)
+	if err != nil {
+		return fmt.Errorf("garbage collection failed: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Expired sessions:\t%d\n", len(report.ExpiredSessions))
+	fmt.Fprintf(w, "Orphaned messages:\t%d\n", report.OrphanMessages)
+	fmt.Fprintf(w, "Orphaned parts:\t%d\n", report.OrphanParts)
+	fmt.Fprintf(w, "Orphaned todos:\t%d\n", report.OrphanTodos)
+	if report.DryRun {
+		fmt.Fprintf(w, "Would reclaim:\t%d records, %s\n", report.Records, formatBytes(report.Bytes))
+	} else {
+		fmt.Fprintf(w, "Reclaimed:\t%d records, %s\n", report.Records, formatBytes(report.Bytes))
+	}
+
+	return w.Flush()
+}
+
+func runStorageVerify(cmd *cobra.Command, args []string) error {
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Open(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	report, err := storage.Verify(context.Background(), store)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	for _, failure := range report.Failures {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", failure.Path, failure.Err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Records:\t%d\n", report.Records)
+	fmt.Fprintf(w, "Encrypted:\t%d\n", report.Encrypted)
+	fmt.Fprintf(w, "Plaintext:\t%d\n", report.Plaintext)
+	fmt.Fprintf(w, "Failed:\t%d\n", len(report.Failures))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d records failed verification", len(report.Failures))
+	}
+	return nil
+}
+
+func runStorageRotateKey(cmd *cobra.Command, args []string) error {
+	if (storageRotateKeyFile == "") == (storageRotateKeyEnv == "") {
+		return fmt.Errorf("specify exactly one of --new-key-file or --new-key-env")
+	}
+
+	appConfig, storagePath, err := loadStorageConfig()
+	if err != nil {
+		return err
+	}
+
+	newKey, err := storage.LoadKey(&types.EncryptionConfig{KeyFile: storageRotateKeyFile, KeyEnv: storageRotateKeyEnv})
+	if err != nil {
+		return err
+	}
+
+	// The current key, if any, is only needed to read existing records
+	var oldKeys [][]byte
+	if appConfig.Storage != nil && appConfig.Storage.Encryption != nil {
+		oldKey, err := storage.LoadKey(appConfig.Storage.Encryption)
+		if err != nil {
+			return err
+		}
+		oldKeys = append(oldKeys, oldKey)
+	}
+
+	backend, err := storage.OpenBackend(storagePath, appConfig.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer backend.Close()
+
+	store, err := storage.NewEncrypted(backend, newKey, oldKeys...)
+	if err != nil {
+		return err
+	}
+
+	n, err := store.Reencrypt(context.Background())
+	if err != nil {
+		return fmt.Errorf("re-encryption stopped after %d records: %w", n, err)
+	}
+
+	fmt.Printf("Re-encrypted %d records.\n", n)
+	fmt.Println(`Update "storage.encryption" in your config to use the new key.`)
+	return nil
+}
+
+// formatBytes renders a byte count using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
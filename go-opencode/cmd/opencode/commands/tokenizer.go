@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/opencode-ai/opencode/internal/tokenizer"
+	"github.com/spf13/cobra"
+)
+
+var tokenizerInstallFrom string
+
+var tokenizerCmd = &cobra.Command{
+	Use:   "tokenizer",
+	Short: "List the tokenizer encodings and whether they are available",
+	Long: `List the byte-pair encodings that count the tokens of OpenAI models, and
+whether their vocabularies are available, embedded in the binary or
+installed. Models whose vocabulary is not available, Claude models and
+models of other providers are counted with an estimate.`,
+	Args: cobra.NoArgs,
+	RunE: runTokenizer,
+}
+
+var tokenizerInstallCmd = &cobra.Command{
+	Use:   "install <encoding> --from <file>",
+	Short: "Install the vocabulary of an encoding",
+	Long: `Install a vocabulary in the tiktoken format, one base64 token and its rank
+per line, for an encoding. It is checked, then copied to the tokenizers
+directory of the data directory, where it takes precedence over the one
+embedded in the binary. Builds without embedded vocabularies need it.
+
+Examples:
+  curl -o cl100k_base.tiktoken https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken
+  opencode tokenizer install cl100k_base --from cl100k_base.tiktoken`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokenizerInstall,
+}
+
+func init() {
+	tokenizerInstallCmd.Flags().StringVar(&tokenizerInstallFrom, "from", "", "Vocabulary file to install")
+	tokenizerInstallCmd.MarkFlagRequired("from")
+	tokenizerCmd.AddCommand(tokenizerInstallCmd)
+}
+
+func runTokenizer(cmd *cobra.Command, args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENCODING\tAVAILABLE\t")
+	for _, name := range tokenizer.Encodings() {
+		available := "no"
+		if tokenizer.Installed(name) != nil {
+			available = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t\n", name, available)
+	}
+	return w.Flush()
+}
+
+func runTokenizerInstall(cmd *cobra.Command, args []string) error {
+	path, err := tokenizer.Install(args[0], tokenizerInstallFrom)
+	if err != nil {
+		return fmt.Errorf("failed to install the %s vocabulary: %w", args[0], err)
+	}
+	fmt.Printf("Installed the %s vocabulary to %s\n", args[0], path)
+	return nil
+}
@@ -66,17 +66,23 @@ func (a *Agent) ToolEnabled(toolID string) bool {
 	return true
 }
 
-// CheckBashPermission checks bash command permission for this agent.
+// CheckBashPermission checks bash command permission for this agent. The
+// longest pattern matching the command wins, so that "find * -delete*"
+// overrides "find *".
 func (a *Agent) CheckBashPermission(command string) permission.PermissionAction {
-	// Check each pattern (more specific patterns first would be ideal)
+	matched := false
+	best := ""
+	result := permission.ActionAsk // Default: ask
 	for pattern, action := range a.Permission.Bash {
-		if matchWildcard(pattern, command) {
-			return action
+		if !matchWildcard(pattern, command) {
+			continue
+		}
+		// Ties go to the first pattern in sort order, keeping the result stable
+		if !matched || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			matched, best, result = true, pattern, action
 		}
 	}
-
-	// Default: ask
-	return permission.ActionAsk
+	return result
 }
 
 // GetPermission returns the permission action for a given permission type.
@@ -167,8 +173,7 @@ func (a *Agent) Clone() *Agent {
 }
 
 // matchWildcard checks if a string matches a wildcard pattern.
-// For simple patterns (* at start/end), uses string matching.
-// For complex patterns (containing **), uses doublestar.
+// Patterns containing ** use doublestar; in others * matches any text.
 func matchWildcard(pattern, s string) bool {
 	// Global wildcard matches everything
 	if pattern == "*" {
@@ -181,22 +186,22 @@ func matchWildcard(pattern, s string) bool {
 		return matched
 	}
 
-	// Simple suffix wildcard (prefix*)
-	if strings.HasSuffix(pattern, "*") && !strings.HasPrefix(pattern, "*") {
-		prefix := strings.TrimSuffix(pattern, "*")
-		return strings.HasPrefix(s, prefix)
-	}
-
-	// Simple prefix wildcard (*suffix)
-	if strings.HasPrefix(pattern, "*") && !strings.HasSuffix(pattern, "*") {
-		suffix := strings.TrimPrefix(pattern, "*")
-		return strings.HasSuffix(s, suffix)
-	}
-
-	// For patterns with * in the middle or multiple *, use doublestar
+	// Otherwise * matches any text, spaces and slashes included, so that
+	// "find * -delete*" matches "find src/ -delete"
 	if strings.Contains(pattern, "*") {
-		matched, _ := doublestar.Match(pattern, s)
-		return matched
+		parts := strings.Split(pattern, "*")
+		if !strings.HasPrefix(s, parts[0]) {
+			return false
+		}
+		s = s[len(parts[0]):]
+		for _, part := range parts[1 : len(parts)-1] {
+			i := strings.Index(s, part)
+			if i < 0 {
+				return false
+			}
+			s = s[i+len(part):]
+		}
+		return strings.HasSuffix(s, parts[len(parts)-1])
 	}
 
 	// Exact match
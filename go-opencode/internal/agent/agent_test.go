@@ -124,6 +124,20 @@ func TestAgent_CheckBashPermission(t *testing.T) {
 			command:  "rm -rf /",
 			expected: permission.ActionDeny,
 		},
+		{
+			name: "longest pattern wins",
+			agent: &Agent{
+				Permission: AgentPermission{
+					Bash: map[string]permission.PermissionAction{
+						"*":               permission.ActionAsk,
+						"find *":          permission.ActionAllow,
+						"find * -delete*": permission.ActionDeny,
+					},
+				},
+			},
+			command:  "find src/ -delete",
+			expected: permission.ActionDeny,
+		},
 		{
 			name: "default to ask",
 			agent: &Agent{
@@ -2,9 +2,11 @@ package agent
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/pkg/types"
 )
 
 // Registry manages agent configurations.
@@ -230,3 +232,80 @@ type AgentPermissionConfig struct {
 	ExternalDir permission.PermissionAction            `json:"external_directory,omitempty"`
 	DoomLoop    permission.PermissionAction            `json:"doom_loop,omitempty"`
 }
+
+// LoadFromAppConfig loads the agents of the application configuration.
+// Agents it adds start with the permissions of the build agent, as the
+// /agent endpoint reports them; disabled agents are removed.
+func (r *Registry) LoadFromAppConfig(agents map[string]types.AgentConfig) {
+	config := make(map[string]AgentConfig, len(agents))
+	for name, cfg := range agents {
+		if cfg.Disable {
+			r.Unregister(name)
+			continue
+		}
+		if !r.Exists(name) {
+			if build, err := r.Get("build"); err == nil {
+				custom := build.Clone()
+				custom.Name = name
+				custom.Description = ""
+				custom.Mode = ModeAll
+				custom.BuiltIn = false
+				r.Register(custom)
+			}
+		}
+
+		c := AgentConfig{
+			Description: cfg.Description,
+			Mode:        Mode(cfg.Mode),
+			Prompt:      cfg.Prompt,
+			Color:       cfg.Color,
+			Tools:       cfg.Tools,
+		}
+		if providerID, modelID, ok := strings.Cut(cfg.Model, "/"); ok {
+			c.Model = &ModelRef{ProviderID: providerID, ModelID: modelID}
+		}
+		if cfg.Temperature != nil {
+			c.Temperature = *cfg.Temperature
+		}
+		if cfg.TopP != nil {
+			c.TopP = *cfg.TopP
+		}
+		if p := cfg.Permission; p != nil {
+			c.Permission = &AgentPermissionConfig{
+				Edit:        permission.PermissionAction(p.Edit),
+				Bash:        bashPermissions(p.Bash),
+				WebFetch:    permission.PermissionAction(p.WebFetch),
+				ExternalDir: permission.PermissionAction(p.ExternalDir),
+				DoomLoop:    permission.PermissionAction(p.DoomLoop),
+			}
+		}
+		config[name] = c
+	}
+	r.LoadFromConfig(config)
+}
+
+// bashPermissions converts the bash permission of a configuration, a single
+// action or actions by command pattern.
+func bashPermissions(bash any) map[string]permission.PermissionAction {
+	switch b := bash.(type) {
+	case string:
+		if b != "" {
+			return map[string]permission.PermissionAction{"*": permission.PermissionAction(b)}
+		}
+	case map[string]string:
+		result := make(map[string]permission.PermissionAction, len(b))
+		for pattern, action := range b {
+			result[pattern] = permission.PermissionAction(action)
+		}
+		return result
+	case map[string]any:
+		result := make(map[string]permission.PermissionAction, len(b))
+		for pattern, action := range b {
+			if s, ok := action.(string); ok {
+				result[pattern] = permission.PermissionAction(s)
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -196,6 +197,36 @@ func TestRegistry_LoadFromConfig_MergesPermissions(t *testing.T) {
 	assert.Equal(t, permission.ActionAllow, plan.Permission.Bash["npm*"])
 }
 
+func TestRegistry_LoadFromAppConfig(t *testing.T) {
+	r := NewRegistry()
+	temperature := 0.2
+
+	r.LoadFromAppConfig(map[string]types.AgentConfig{
+		"review": {
+			Model:       "anthropic/claude-sonnet-4",
+			Temperature: &temperature,
+			Permission: &types.PermissionConfig{
+				Bash: map[string]any{"git *": "allow", "*": "deny"},
+			},
+		},
+		"build":   {Permission: &types.PermissionConfig{Bash: "ask"}},
+		"explore": {Disable: true},
+	})
+
+	review, err := r.Get("review")
+	require.NoError(t, err)
+	assert.Equal(t, ModeAll, review.Mode)
+	assert.Equal(t, 0.2, review.Temperature)
+	assert.Equal(t, &ModelRef{ProviderID: "anthropic", ModelID: "claude-sonnet-4"}, review.Model)
+	assert.Equal(t, permission.ActionAllow, review.CheckBashPermission("git log"))
+	assert.Equal(t, permission.ActionDeny, review.CheckBashPermission("rm -rf /"))
+	assert.Equal(t, permission.ActionAllow, review.Permission.Edit, "new agents start from the build agent")
+
+	build, _ := r.Get("build")
+	assert.Equal(t, permission.ActionAsk, build.CheckBashPermission("ls"))
+	assert.False(t, r.Exists("explore"))
+}
+
 func TestRegistry_Concurrency(t *testing.T) {
 	r := NewRegistry()
 
@@ -0,0 +1,478 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// Ways a Flow finishes, as reported to clients.
+const (
+	// MethodAuto flows finish on their own: opencode receives the
+	// authorization code or polls for the device authorization.
+	MethodAuto = "auto"
+	// MethodCode flows finish with the code the user pastes.
+	MethodCode = "code"
+)
+
+// Grants of the login methods.
+const (
+	grantCode   = "authorization_code"
+	grantDevice = "urn:ietf:params:oauth:grant-type:device_code"
+	grantAPIKey = "api_key"
+)
+
+// Method is a way to log in to a provider.
+type Method struct {
+	Type  string `json:"type"` // TypeOAuth or TypeAPI
+	Label string `json:"label"`
+	grant string
+}
+
+// Methods returns the login methods of a provider: the OAuth flows its
+// endpoints allow, if any, then entering an API key.
+func Methods(cfg *types.OAuthConfig) []Method {
+	var methods []Method
+	if cfg != nil && cfg.AuthorizationURL != "" {
+		methods = append(methods, Method{Type: TypeOAuth, Label: "Log in with a browser", grant: grantCode})
+	}
+	if cfg != nil && cfg.DeviceAuthorizationURL != "" {
+		methods = append(methods, Method{Type: TypeOAuth, Label: "Log in with a device code", grant: grantDevice})
+	}
+	return append(methods, Method{Type: TypeAPI, Label: "Manually enter API Key", grant: grantAPIKey})
+}
+
+// Token is the result of a login or a refresh.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+
+	// Expiry is set from ExpiresIn when the token is received, zero if the
+	// token does not expire.
+	Expiry time.Time `json:"-"`
+}
+
+// Info returns the credentials to store for the token.
+func (t *Token) Info() Info {
+	info := Info{Type: TypeOAuth, Access: t.AccessToken, Refresh: t.RefreshToken}
+	if !t.Expiry.IsZero() {
+		info.Expires = t.Expiry.UnixMilli()
+	}
+	return info
+}
+
+// Error is an error response of an authorization server.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	StatusCode  int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth: %s (status %d)", e.Code, e.StatusCode)
+}
+
+// Client logs in to the authorization server of a provider.
+type Client struct {
+	config     *types.OAuthConfig
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the OAuth endpoints cfg. httpClient sends
+// the token requests; http.DefaultClient if nil.
+func NewClient(cfg *types.OAuthConfig, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{config: cfg, httpClient: httpClient}
+}
+
+// Flow is a login waiting for the user to authorize it.
+type Flow struct {
+	URL          string `json:"url"`
+	Method       string `json:"method"` // MethodAuto or MethodCode
+	Instructions string `json:"instructions"`
+
+	finish func(ctx context.Context, code string) (*Token, error)
+	close  func()
+}
+
+// Finish waits for the authorization and returns the token. The code pasted
+// by the user is needed for MethodCode flows and ignored by others.
+func (f *Flow) Finish(ctx context.Context, code string) (*Token, error) {
+	return f.finish(ctx, code)
+}
+
+// Close stops the flow, releasing the callback listener of a flow that
+// did not finish.
+func (f *Flow) Close() {
+	if f.close != nil {
+		f.close()
+	}
+}
+
+// Start starts logging in with an OAuth method of Methods.
+func (c *Client) Start(ctx context.Context, m Method) (*Flow, error) {
+	switch m.grant {
+	case grantCode:
+		return c.startCode()
+	case grantDevice:
+		return c.startDevice(ctx)
+	default:
+		return nil, fmt.Errorf("%q is not an OAuth login method", m.Label)
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token. The refresh
+// token is kept when the server issues no new one.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	token, err := c.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// startCode starts the authorization code flow with PKCE (RFC 7636). The
+// code is received by a loopback listener when the redirect URL allows it,
+// and pasted by the user otherwise.
+func (c *Client) startCode() (*Flow, error) {
+	verifier := randomString(32)
+	state := randomString(16)
+	challenge := sha256.Sum256([]byte(verifier))
+
+	redirectURL := c.config.RedirectURL
+	var callback *callbackServer
+	if redirectURL == "" || isLoopback(redirectURL) {
+		var err error
+		if callback, err = listenCallback(redirectURL, state); err != nil {
+			return nil, err
+		}
+		redirectURL = callback.redirectURL
+	}
+
+	authURL, err := url.Parse(c.config.AuthorizationURL)
+	if err != nil {
+		callback.Close()
+		return nil, fmt.Errorf("invalid authorization URL: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.config.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if len(c.config.Scopes) > 0 {
+		query.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+	authURL.RawQuery = query.Encode()
+
+	exchange := func(ctx context.Context, code string) (*Token, error) {
+		return c.token(ctx, url.Values{
+			"grant_type":    {grantCode},
+			"code":          {code},
+			"redirect_uri":  {redirectURL},
+			"code_verifier": {verifier},
+		})
+	}
+
+	if callback != nil {
+		return &Flow{
+			URL:          authURL.String(),
+			Method:       MethodAuto,
+			Instructions: "Complete the login in your browser.",
+			finish: func(ctx context.Context, _ string) (*Token, error) {
+				defer callback.Close()
+				code, err := callback.wait(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return exchange(ctx, code)
+			},
+			close: callback.Close,
+		}, nil
+	}
+	return &Flow{
+		URL:          authURL.String(),
+		Method:       MethodCode,
+		Instructions: "Paste the authorization code shown after logging in.",
+		finish: func(ctx context.Context, pasted string) (*Token, error) {
+			code, err := pastedCode(pasted, state)
+			if err != nil {
+				return nil, err
+			}
+			return exchange(ctx, code)
+		},
+	}, nil
+}
+
+// pastedCode returns the code of what the user pasted: the code, the
+// "code#state" some servers show, or the URL redirected to.
+func pastedCode(pasted, state string) (string, error) {
+	pasted = strings.TrimSpace(pasted)
+	if u, err := url.Parse(pasted); err == nil && u.Query().Has("code") {
+		if s := u.Query().Get("state"); s != "" && s != state {
+			return "", errors.New("oauth: state mismatch")
+		}
+		return u.Query().Get("code"), nil
+	}
+	if code, s, ok := strings.Cut(pasted, "#"); ok {
+		if s != state {
+			return "", errors.New("oauth: state mismatch")
+		}
+		pasted = code
+	}
+	if pasted == "" {
+		return "", errors.New("oauth: authorization code required")
+	}
+	return pasted, nil
+}
+
+// deviceAuthorization is the response of a device authorization endpoint
+// (RFC 8628).
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURL         string `json:"verification_url"` // Google
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	// Interval is in seconds, 5 if unset.
+	Interval float64 `json:"interval"`
+}
+
+// startDevice starts the device code flow: the user enters a code at a URL
+// while the token endpoint is polled.
+func (c *Client) startDevice(ctx context.Context) (*Flow, error) {
+	form := url.Values{"client_id": {c.config.ClientID}}
+	if len(c.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+	var device deviceAuthorization
+	if err := c.post(ctx, c.config.DeviceAuthorizationURL, form, &device); err != nil {
+		return nil, err
+	}
+	if device.DeviceCode == "" {
+		return nil, errors.New("oauth: no device code in the device authorization response")
+	}
+
+	verificationURL := cmp.Or(device.VerificationURIComplete, device.VerificationURI, device.VerificationURL)
+	interval := 5 * time.Second
+	if device.Interval > 0 {
+		interval = time.Duration(device.Interval * float64(time.Second))
+	}
+	var deadline time.Time
+	if device.ExpiresIn > 0 {
+		deadline = time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	}
+
+	return &Flow{
+		URL:          verificationURL,
+		Method:       MethodAuto,
+		Instructions: fmt.Sprintf("Enter code %s at %s", device.UserCode, cmp.Or(device.VerificationURI, device.VerificationURL)),
+		finish: func(ctx context.Context, _ string) (*Token, error) {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(interval):
+				}
+				if !deadline.IsZero() && time.Now().After(deadline) {
+					return nil, &Error{Code: "expired_token", Description: "the device code expired"}
+				}
+				token, err := c.token(ctx, url.Values{
+					"grant_type":  {grantDevice},
+					"device_code": {device.DeviceCode},
+				})
+				var oauthErr *Error
+				if errors.As(err, &oauthErr) {
+					switch oauthErr.Code {
+					case "authorization_pending":
+						continue
+					case "slow_down":
+						interval += 5 * time.Second
+						continue
+					}
+				}
+				return token, err
+			}
+		},
+	}, nil
+}
+
+// token requests a token from the token endpoint.
+func (c *Client) token(ctx context.Context, form url.Values) (*Token, error) {
+	form.Set("client_id", c.config.ClientID)
+	if c.config.ClientSecret != "" {
+		form.Set("client_secret", c.config.ClientSecret)
+	}
+	var token Token
+	if err := c.post(ctx, c.config.TokenURL, form, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("oauth: no access token in the token response")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+// post posts a form to an endpoint and decodes its JSON response into v.
+func (c *Client) post(ctx context.Context, endpoint string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, oauthErr) != nil || oauthErr.Code == "" {
+			oauthErr.Code = "server_error"
+			oauthErr.Description = strings.TrimSpace(string(body))
+		}
+		return oauthErr
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("oauth: invalid response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// callbackServer receives the authorization code at a loopback redirect URL.
+type callbackServer struct {
+	redirectURL string
+	server      *http.Server
+	result      chan callbackResult
+	once        sync.Once
+}
+
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// listenCallback listens at the host, port and path of redirectURL, or at
+// an unused port of 127.0.0.1 when it is empty.
+func listenCallback(redirectURL, state string) (*callbackServer, error) {
+	addr, path := "127.0.0.1:0", "/callback"
+	if redirectURL != "" {
+		u, err := url.Parse(redirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect URL: %w", err)
+		}
+		addr, path = u.Host, cmp.Or(u.Path, "/")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the OAuth callback: %w", err)
+	}
+	if redirectURL == "" {
+		redirectURL = "http://" + listener.Addr().String() + path
+	}
+
+	cb := &callbackServer{redirectURL: redirectURL, result: make(chan callbackResult, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var result callbackResult
+		switch {
+		case query.Get("state") != state:
+			result.err = errors.New("oauth: state mismatch")
+		case query.Get("error") != "":
+			result.err = &Error{Code: query.Get("error"), Description: query.Get("error_description")}
+		case query.Get("code") == "":
+			result.err = errors.New("oauth: no authorization code in the callback")
+		default:
+			result.code = query.Get("code")
+		}
+		if result.err != nil {
+			http.Error(w, "Login failed: "+result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Login complete. You can close this window.")
+		}
+		select {
+		case cb.result <- result:
+		default:
+		}
+	})
+	cb.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go cb.server.Serve(listener)
+	return cb, nil
+}
+
+// wait returns the code received by the callback.
+func (cb *callbackServer) wait(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-cb.result:
+		return result.code, result.err
+	}
+}
+
+// Close stops listening. It may be called more than once, and on nil.
+func (cb *callbackServer) Close() {
+	if cb == nil {
+		return
+	}
+	cb.once.Do(func() { cb.server.Close() })
+}
+
+// isLoopback reports whether a redirect URL is served on this machine.
+func isLoopback(redirectURL string) bool {
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// randomString returns n random bytes, base64url-encoded.
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// stubServer is an authorization server that authorizes every request.
+type stubServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> code_challenge
+	polls      int
+	refreshes  int
+	expiresIn  int64
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	s := &stubServer{challenges: make(map[string]string), expiresIn: 3600}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "test-client" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.challenges["code-1"] = q.Get("code_challenge")
+		s.mu.Unlock()
+		redirect, _ := url.Parse(q.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {"code-1"}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		writeStubJSON(w, http.StatusOK, map[string]any{
+			"device_code":      "device-1",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": s.URL + "/activate",
+			"expires_in":       60,
+			"interval":         0.01,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Form.Get("grant_type") {
+		case grantCode:
+			challenge := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if s.challenges[r.Form.Get("code")] != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+				writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+		case grantDevice:
+			if s.polls++; s.polls < 3 {
+				writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+				return
+			}
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh-1" {
+				writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			s.refreshes++
+			writeStubJSON(w, http.StatusOK, map[string]any{"access_token": "access-2", "expires_in": s.expiresIn})
+			return
+		}
+		writeStubJSON(w, http.StatusOK, map[string]any{"access_token": "access-1", "refresh_token": "refresh-1", "expires_in": s.expiresIn})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubServer) config() *types.OAuthConfig {
+	return &types.OAuthConfig{
+		ClientID:               "test-client",
+		AuthorizationURL:       s.URL + "/authorize",
+		DeviceAuthorizationURL: s.URL + "/device",
+		TokenURL:               s.URL + "/token",
+		Scopes:                 []string{"inference"},
+	}
+}
+
+func writeStubJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestMethods(t *testing.T) {
+	if methods := Methods(nil); len(methods) != 1 || methods[0].Type != TypeAPI {
+		t.Errorf("Expected only the API key method, got %+v", methods)
+	}
+	methods := Methods(&types.OAuthConfig{AuthorizationURL: "https://auth.example.com/authorize"})
+	if len(methods) != 2 || methods[0].Type != TypeOAuth || methods[1].Type != TypeAPI {
+		t.Errorf("Expected the browser and API key methods, got %+v", methods)
+	}
+}
+
+func TestCodeFlow_Loopback(t *testing.T) {
+	stub := newStubServer(t)
+	client := NewClient(stub.config(), nil)
+
+	flow, err := client.Start(context.Background(), Methods(stub.config())[0])
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer flow.Close()
+	if flow.Method != MethodAuto {
+		t.Errorf("Expected an auto flow, got %s", flow.Method)
+	}
+
+	// The browser follows the redirect to the callback listener
+	resp, err := http.Get(flow.URL)
+	if err != nil {
+		t.Fatalf("Authorization failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the callback to succeed, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	token, err := flow.Finish(ctx, "")
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.Expiry.IsZero() {
+		t.Errorf("Got token %+v", token)
+	}
+}
+
+func TestCodeFlow_Pasted(t *testing.T) {
+	stub := newStubServer(t)
+	cfg := stub.config()
+	cfg.RedirectURL = "https://console.example.com/oauth/code/callback"
+	client := NewClient(cfg, nil)
+
+	flow, err := client.Start(context.Background(), Methods(cfg)[0])
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if flow.Method != MethodCode {
+		t.Fatalf("Expected a code flow, got %s", flow.Method)
+	}
+
+	// The user is redirected to the page showing the code
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(flow.URL)
+	if err != nil {
+		t.Fatalf("Authorization failed: %v", err)
+	}
+	resp.Body.Close()
+	redirect, _ := url.Parse(resp.Header.Get("Location"))
+
+	if _, err := flow.Finish(context.Background(), "code-1#wrong-state"); err == nil {
+		t.Error("A code with another state should be rejected")
+	}
+	pasted := redirect.Query().Get("code") + "#" + redirect.Query().Get("state")
+	token, err := flow.Finish(context.Background(), pasted)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if token.AccessToken != "access-1" {
+		t.Errorf("Got token %+v", token)
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	stub := newStubServer(t)
+	client := NewClient(stub.config(), nil)
+
+	flow, err := client.Start(context.Background(), Methods(stub.config())[1])
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if flow.Method != MethodAuto || flow.URL != stub.URL+"/activate" {
+		t.Errorf("Got flow %+v", flow)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	token, err := flow.Finish(ctx, "")
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if token.AccessToken != "access-1" || stub.polls != 3 {
+		t.Errorf("Got token %+v after %d polls", token, stub.polls)
+	}
+}
+
+func TestRefresh_Error(t *testing.T) {
+	stub := newStubServer(t)
+	_, err := NewClient(stub.config(), nil).Refresh(context.Background(), "revoked")
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" {
+		t.Errorf("Expected invalid_grant, got %v", err)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	stub := newStubServer(t)
+	store := NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	expired := Info{Type: TypeOAuth, Access: "access-1", Refresh: "refresh-1", Expires: time.Now().Add(30 * time.Second).UnixMilli()}
+	if err := store.Set("stub", expired); err != nil {
+		t.Fatal(err)
+	}
+
+	var authorization, apiKey string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, apiKey = r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+	}))
+	defer api.Close()
+
+	client := &http.Client{Transport: NewTransport(store, "stub", NewClient(stub.config(), nil))}
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodPost, api.URL, nil)
+		req.Header.Set("X-Api-Key", PlaceholderKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if authorization != "Bearer access-2" || apiKey != "" {
+		t.Errorf("Got Authorization %q and X-Api-Key %q", authorization, apiKey)
+	}
+	if stub.refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", stub.refreshes)
+	}
+	info, _ := store.Get("stub")
+	if info.Access != "access-2" || info.Refresh != "refresh-1" || info.ExpiresWithin(RefreshMargin) {
+		t.Errorf("Expected the refreshed token to be stored, got %+v", info)
+	}
+}
+
+func TestTransport_NotLoggedIn(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	client := &http.Client{Transport: NewTransport(store, "stub", nil)}
+	if _, err := client.Get("http://127.0.0.1:1"); err == nil {
+		t.Error("Requests without credentials should fail")
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	legacy := `{"providers": {"openai": {"apiKey": "sk-legacy"}}, "anthropic": {"type": "api", "key": "sk-ant"}, "bad": {"type": "unknown"}}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(path)
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 2 || all["openai"].Key != "sk-legacy" || all["anthropic"].Key != "sk-ant" {
+		t.Errorf("Got %+v", all)
+	}
+
+	if err := store.Set("google", Info{Type: TypeAPI, Key: "g"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if removed, err := store.Remove("openai"); !removed || err != nil {
+		t.Errorf("Remove: got %v, %v", removed, err)
+	}
+	if removed, _ := store.Remove("openai"); removed {
+		t.Error("Removing twice should report nothing removed")
+	}
+	if info, _ := store.Get("google"); info == nil || info.Key != "g" {
+		t.Errorf("Got %+v", info)
+	}
+	if stat, _ := os.Stat(path); stat.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", stat.Mode().Perm())
+	}
+}
@@ -0,0 +1,176 @@
+// Package auth stores provider credentials and logs in to providers with
+// OAuth 2.0.
+//
+// Credentials are kept in auth.json in the data directory, in the format of
+// the TypeScript implementation: an object of credentials by provider ID.
+// OAuth logins use the authorization code flow with PKCE or the device code
+// flow, against the endpoints set in the oauth options of a provider, and
+// Transport refreshes their tokens before they expire.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Credential types.
+const (
+	TypeAPI       = "api"
+	TypeOAuth     = "oauth"
+	TypeWellKnown = "wellknown"
+)
+
+// Info holds the credentials of a provider.
+type Info struct {
+	Type string `json:"type"`
+
+	// API key of TypeAPI. For TypeWellKnown, the environment variable
+	// Token is the value of.
+	Key   string `json:"key,omitempty"`
+	Token string `json:"token,omitempty"`
+
+	// Tokens of TypeOAuth. Expires is in unix milliseconds, 0 if the access
+	// token does not expire.
+	Access        string `json:"access,omitempty"`
+	Refresh       string `json:"refresh,omitempty"`
+	Expires       int64  `json:"expires,omitempty"`
+	EnterpriseURL string `json:"enterpriseUrl,omitempty"`
+}
+
+// ExpiresWithin reports whether the OAuth access token expires within d.
+func (i *Info) ExpiresWithin(d time.Duration) bool {
+	return i.Expires > 0 && time.Now().Add(d).UnixMilli() >= i.Expires
+}
+
+// Store reads and writes the credentials file.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore returns the store of the credentials file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the path of the credentials file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// All returns the credentials of every provider. A missing file holds none.
+func (s *Store) All() (map[string]Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Get returns the credentials of a provider, nil if there are none.
+func (s *Store) Get(providerID string) (*Info, error) {
+	all, err := s.All()
+	if err != nil {
+		return nil, err
+	}
+	info, ok := all[providerID]
+	if !ok {
+		return nil, nil
+	}
+	return &info, nil
+}
+
+// Set stores the credentials of a provider.
+func (s *Store) Set(providerID string, info Info) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	all[providerID] = info
+	return s.write(all)
+}
+
+// Remove deletes the credentials of a provider. It reports whether there
+// were any.
+func (s *Store) Remove(providerID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := all[providerID]; !ok {
+		return false, nil
+	}
+	delete(all, providerID)
+	return true, s.write(all)
+}
+
+// read parses the credentials file. Entries that are not credentials are
+// skipped, and the API keys of the {"providers": {...}} layout written by
+// earlier versions are read as TypeAPI credentials.
+func (s *Store) read() (map[string]Info, error) {
+	all := make(map[string]Info)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for id, raw := range entries {
+		if id == "providers" {
+			var legacy map[string]struct {
+				APIKey string `json:"apiKey"`
+			}
+			if json.Unmarshal(raw, &legacy) == nil {
+				for id, p := range legacy {
+					if _, ok := entries[id]; !ok && p.APIKey != "" {
+						all[id] = Info{Type: TypeAPI, Key: p.APIKey}
+					}
+				}
+				continue
+			}
+		}
+		var info Info
+		if json.Unmarshal(raw, &info) != nil {
+			continue
+		}
+		switch info.Type {
+		case TypeAPI, TypeOAuth, TypeWellKnown:
+			all[id] = info
+		}
+	}
+	return all, nil
+}
+
+// write replaces the credentials file, readable by the user only.
+func (s *Store) write(all map[string]Info) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
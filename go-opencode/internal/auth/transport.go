@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RefreshMargin is how long before it expires an access token is refreshed.
+const RefreshMargin = time.Minute
+
+// PlaceholderKey stands for the API key of providers logged in with OAuth,
+// whose SDKs require one. Transport replaces it with the access token.
+const PlaceholderKey = "oauth"
+
+// apiKeyHeaders carry the API keys of the provider SDKs; they are removed
+// from requests authorized with an access token.
+var apiKeyHeaders = []string{"X-Api-Key", "Api-Key", "X-Goog-Api-Key"}
+
+// Transport authorizes the requests to a provider with the OAuth access
+// token stored for it, refreshing the token before it expires.
+type Transport struct {
+	Base       http.RoundTripper // http.DefaultTransport if nil
+	Store      *Store
+	ProviderID string
+	Client     *Client
+
+	mu sync.Mutex
+}
+
+// NewTransport returns a transport authorizing the requests to providerID.
+// Without a client, the access token is used until it expires.
+func NewTransport(store *Store, providerID string, client *Client) *Transport {
+	return &Transport{Store: store, ProviderID: providerID, Client: client}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	access, err := t.accessToken(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	for _, header := range apiKeyHeaders {
+		req.Header.Del(header)
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// accessToken returns the stored access token, refreshed and stored again if
+// it expires within RefreshMargin.
+func (t *Transport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := t.Store.Get(t.ProviderID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the credentials of %s: %w", t.ProviderID, err)
+	}
+	if info == nil || info.Type != TypeOAuth {
+		return "", fmt.Errorf("not logged in to %s, run: opencode auth login %s", t.ProviderID, t.ProviderID)
+	}
+	if !info.ExpiresWithin(RefreshMargin) {
+		return info.Access, nil
+	}
+	if info.Refresh == "" || t.Client == nil {
+		return "", fmt.Errorf("the login to %s expired, run: opencode auth login %s", t.ProviderID, t.ProviderID)
+	}
+
+	token, err := t.Client.Refresh(ctx, info.Refresh)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh the login to %s: %w", t.ProviderID, err)
+	}
+	refreshed := token.Info()
+	refreshed.EnterpriseURL = info.EnterpriseURL
+	if err := t.Store.Set(t.ProviderID, refreshed); err != nil {
+		return "", fmt.Errorf("failed to store the credentials of %s: %w", t.ProviderID, err)
+	}
+	return refreshed.Access, nil
+}
@@ -0,0 +1,335 @@
+// Package catalog provides the capabilities, limits and prices of models, in
+// the models.dev format.
+//
+// A snapshot of the catalog is embedded in the binary. The models.json file
+// in the data directory, written by "opencode models update", is merged over
+// it, so that models released since the build are known without an upgrade.
+package catalog
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+//go:embed models.json
+var snapshot []byte
+
+// Provider is a provider of the catalog.
+type Provider struct {
+	ID     string           `json:"id"`
+	Name   string           `json:"name"`
+	API    string           `json:"api,omitempty"`
+	Env    []string         `json:"env"`
+	Npm    string           `json:"npm,omitempty"`
+	Doc    string           `json:"doc,omitempty"`
+	Models map[string]Model `json:"models"`
+}
+
+// Model is a model of the catalog. Prices are in USD per million tokens.
+type Model struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	ReleaseDate string     `json:"release_date,omitempty"` // YYYY-MM-DD
+	Attachment  bool       `json:"attachment"`
+	Reasoning   bool       `json:"reasoning"`
+	Temperature bool       `json:"temperature"`
+	ToolCall    bool       `json:"tool_call"`
+	Modalities  Modalities `json:"modalities"`
+	Cost        Cost       `json:"cost"`
+	Limit       Limit      `json:"limit"`
+}
+
+// Modalities lists the kinds of content a model reads and writes: "text",
+// "image", "audio", "video" or "pdf".
+type Modalities struct {
+	Input  []string `json:"input"`
+	Output []string `json:"output"`
+}
+
+// Cost holds the prices of a model.
+type Cost struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// Limit holds the context window and the maximum output of a model.
+type Limit struct {
+	Context int `json:"context"`
+	Output  int `json:"output"`
+}
+
+// Input reports whether the model reads the given modality.
+func (m Model) Input(modality string) bool {
+	return slices.Contains(m.Modalities.Input, modality)
+}
+
+// Model returns the registry model of providerID. Models priced for cache
+// writes are those that cache prompts at explicit breakpoints.
+func (m Model) Model(providerID string) types.Model {
+	return types.Model{
+		ID:                m.ID,
+		Name:              m.Name,
+		ProviderID:        providerID,
+		ContextLength:     m.Limit.Context,
+		MaxOutputTokens:   m.Limit.Output,
+		SupportsTools:     m.ToolCall,
+		SupportsVision:    m.Input("image"),
+		SupportsPDF:       m.Input("pdf"),
+		SupportsReasoning: m.Reasoning,
+		InputPrice:        m.Cost.Input,
+		OutputPrice:       m.Cost.Output,
+		CacheReadPrice:    m.Cost.CacheRead,
+		CacheWritePrice:   m.Cost.CacheWrite,
+		Options: types.ModelOptions{
+			PromptCaching: m.Cost.CacheWrite > 0,
+		},
+	}
+}
+
+// Catalog is a set of providers and their models.
+type Catalog struct {
+	providers map[string]Provider
+}
+
+// Parse parses a catalog in the models.dev format: an object of providers
+// by ID, each with an object of models by ID.
+func Parse(data []byte) (*Catalog, error) {
+	var providers map[string]Provider
+	if err := json.Unmarshal(data, &providers); err != nil {
+		return nil, fmt.Errorf("invalid model catalog: %w", err)
+	}
+	models := 0
+	for id, p := range providers {
+		if p.ID == "" {
+			p.ID = id
+		}
+		for modelID, m := range p.Models {
+			if m.ID == "" {
+				m.ID = modelID
+				p.Models[modelID] = m
+			}
+		}
+		models += len(p.Models)
+		providers[id] = p
+	}
+	if models == 0 {
+		return nil, errors.New("invalid model catalog: no models")
+	}
+	return &Catalog{providers: providers}, nil
+}
+
+// Load returns the embedded catalog with the catalog at path, if it exists,
+// merged over it.
+func Load(path string) (*Catalog, error) {
+	c, err := Parse(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	override, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.merge(override)
+	return c, nil
+}
+
+// merge adds the providers and models of other, replacing those with the
+// same IDs.
+func (c *Catalog) merge(other *Catalog) {
+	for id, p := range other.providers {
+		base, ok := c.providers[id]
+		if !ok {
+			c.providers[id] = p
+			continue
+		}
+		if p.Name != "" {
+			base.Name = p.Name
+		}
+		if p.API != "" {
+			base.API = p.API
+		}
+		if len(p.Env) > 0 {
+			base.Env = p.Env
+		}
+		if p.Npm != "" {
+			base.Npm = p.Npm
+		}
+		if p.Doc != "" {
+			base.Doc = p.Doc
+		}
+		if base.Models == nil {
+			base.Models = make(map[string]Model)
+		}
+		for modelID, m := range p.Models {
+			base.Models[modelID] = m
+		}
+		c.providers[id] = base
+	}
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultCatalog *Catalog
+)
+
+// Default returns the catalog of the user: the embedded one with the
+// override file merged over it. An override that cannot be read is ignored.
+func Default() *Catalog {
+	defaultOnce.Do(func() {
+		path := config.GetPaths().CatalogPath()
+		c, err := Load(path)
+		if err != nil {
+			logging.Warn().Err(err).Str("path", path).Msg("Ignoring the model catalog override")
+			c, _ = Load("")
+		}
+		defaultCatalog = c
+	})
+	return defaultCatalog
+}
+
+// Update validates the catalog in the file from and installs it as the
+// override file at path.
+func Update(from, path string) (*Catalog, error) {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Providers returns the providers of the catalog, sorted by ID.
+func (c *Catalog) Providers() []Provider {
+	providers := make([]Provider, 0, len(c.providers))
+	for _, p := range c.providers {
+		providers = append(providers, p)
+	}
+	slices.SortFunc(providers, func(a, b Provider) int { return cmp.Compare(a.ID, b.ID) })
+	return providers
+}
+
+// Provider returns a provider of the catalog.
+func (c *Catalog) Provider(id string) (Provider, bool) {
+	p, ok := c.providers[id]
+	return p, ok
+}
+
+// Model returns a model of a provider of the catalog.
+func (c *Catalog) Model(providerID, modelID string) (Model, bool) {
+	m, ok := c.providers[providerID].Models[modelID]
+	return m, ok
+}
+
+// Find returns a model of the catalog by its ID, for providers registered
+// under another name than their catalog ID. Providers are searched in ID
+// order.
+func (c *Catalog) Find(modelID string) (Model, bool) {
+	for _, p := range c.Providers() {
+		if m, ok := p.Models[modelID]; ok {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// Models returns the models of the catalog provider catalogID as registry
+// models of providerID, best first.
+func (c *Catalog) Models(catalogID, providerID string) []types.Model {
+	p := c.providers[catalogID]
+	models := make([]types.Model, 0, len(p.Models))
+	for _, m := range p.Models {
+		models = append(models, m.Model(providerID))
+	}
+	c.Sort(models)
+	return models
+}
+
+// Sort sorts registry models best first, by their catalog entries: models
+// that call tools, then the most recent, then the most expensive, as
+// providers price their most capable models highest. Models missing from the
+// catalog come last. Ties are sorted by ID.
+func (c *Catalog) Sort(models []types.Model) {
+	entries := make(map[string]*Model, len(models))
+	for _, m := range models {
+		key := m.ProviderID + "/" + m.ID
+		if _, ok := entries[key]; ok {
+			continue
+		}
+		if entry, ok := c.Model(m.ProviderID, m.ID); ok {
+			entries[key] = &entry
+		} else if entry, ok := c.Find(m.ID); ok {
+			entries[key] = &entry
+		} else {
+			entries[key] = nil
+		}
+	}
+	slices.SortFunc(models, func(a, b types.Model) int {
+		if order := compare(entries[a.ProviderID+"/"+a.ID], entries[b.ProviderID+"/"+b.ID]); order != 0 {
+			return order
+		}
+		return cmp.Or(cmp.Compare(a.ProviderID, b.ProviderID), cmp.Compare(a.ID, b.ID))
+	})
+}
+
+// compare orders catalog models best first.
+func compare(a, b *Model) int {
+	switch {
+	case a == nil || b == nil:
+		return boolOrder(a != nil, b != nil)
+	case a.ToolCall != b.ToolCall:
+		return boolOrder(a.ToolCall, b.ToolCall)
+	case a.ReleaseDate != b.ReleaseDate:
+		return cmp.Compare(b.ReleaseDate, a.ReleaseDate)
+	default:
+		return cmp.Compare(b.Cost.Output, a.Cost.Output)
+	}
+}
+
+// boolOrder orders true before false.
+func boolOrder(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,155 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestSnapshot(t *testing.T) {
+	c, err := Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, id := range []string{"anthropic", "google", "openai"} {
+		p, ok := c.Provider(id)
+		if !ok || len(p.Models) == 0 {
+			t.Errorf("Expected models for provider %s", id)
+		}
+		for modelID, m := range p.Models {
+			if m.ID != modelID || m.Name == "" || m.ReleaseDate == "" || m.Limit.Context == 0 || m.Limit.Output == 0 {
+				t.Errorf("Incomplete model %s/%s: %+v", id, modelID, m)
+			}
+		}
+	}
+}
+
+func TestModel(t *testing.T) {
+	c, _ := Load("")
+	m, ok := c.Model("anthropic", "claude-sonnet-4-20250514")
+	if !ok {
+		t.Fatal("Expected claude-sonnet-4-20250514 in the catalog")
+	}
+	model := m.Model("claude")
+	if model.ProviderID != "claude" || model.ContextLength != 200000 || model.MaxOutputTokens != 64000 {
+		t.Errorf("Got %+v", model)
+	}
+	if model.InputPrice != 3 || model.OutputPrice != 15 || model.CacheReadPrice != 0.3 || model.CacheWritePrice != 3.75 {
+		t.Errorf("Got prices %+v", model)
+	}
+	if !model.SupportsTools || !model.SupportsVision || !model.SupportsPDF || !model.Options.PromptCaching {
+		t.Errorf("Got capabilities %+v", model)
+	}
+
+	m, _ = c.Model("openai", "gpt-4o")
+	if m.Model("openai").Options.PromptCaching {
+		t.Error("Models without cache write prices should not get cache breakpoints")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, data := range []string{`[]`, `{}`, `{"openai":{"models":{}}}`, `not json`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s) should fail", data)
+		}
+	}
+}
+
+func TestLoad_Override(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	override := `{
+		"openai": {"models": {
+			"gpt-4o": {"name": "GPT-4o", "tool_call": true, "cost": {"input": 2, "output": 8}, "limit": {"context": 128000, "output": 16384}},
+			"gpt-6": {"name": "GPT-6", "release_date": "2026-09-01", "tool_call": true, "limit": {"context": 1000000, "output": 128000}}
+		}},
+		"local": {"name": "Local", "models": {"llama": {"name": "Llama", "limit": {"context": 8192, "output": 2048}}}}
+	}`
+	if err := os.WriteFile(path, []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m, _ := c.Model("openai", "gpt-4o"); m.ID != "gpt-4o" || m.Cost.Input != 2 {
+		t.Errorf("Expected the override of gpt-4o, got %+v", m)
+	}
+	if _, ok := c.Model("openai", "gpt-5"); !ok {
+		t.Error("Models missing from the override should be kept")
+	}
+	if p, _ := c.Provider("openai"); p.Name != "OpenAI" || len(p.Env) == 0 {
+		t.Errorf("Provider fields missing from the override should be kept, got %+v", p)
+	}
+	if m, ok := c.Model("local", "llama"); !ok || m.ID != "llama" {
+		t.Errorf("Expected the new provider, got %+v", m)
+	}
+	if models := c.Models("openai", "openai"); models[0].ID != "gpt-6" {
+		t.Errorf("Expected gpt-6 first, got %s", models[0].ID)
+	}
+}
+
+func TestLoad_InvalidOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(path, []byte(`{"openai":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected an error naming the override, got %v", err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("A missing override should be ignored, got %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "api.json")
+	path := filepath.Join(dir, "data", "models.json")
+
+	if err := os.WriteFile(from, []byte(`{"openai":{"models":{}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Update(from, path); err == nil {
+		t.Error("Update should reject an invalid catalog")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("An invalid catalog should not be installed")
+	}
+
+	if err := os.WriteFile(from, []byte(`{"openai":{"models":{"gpt-6":{"name":"GPT-6"}}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Update(from, path); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := c.Model("openai", "gpt-6"); !ok {
+		t.Error("Expected the updated catalog to be loaded")
+	}
+}
+
+func TestSort(t *testing.T) {
+	c, _ := Load("")
+	models := []types.Model{
+		{ID: "unknown", ProviderID: "local"},
+		{ID: "gpt-4o", ProviderID: "openai"},
+		{ID: "gpt-5-mini", ProviderID: "openai"},
+		{ID: "claude-3-5-sonnet-20241022", ProviderID: "claude"}, // Found by ID
+		{ID: "gpt-5", ProviderID: "openai"},
+	}
+	c.Sort(models)
+
+	want := []string{"gpt-5", "gpt-5-mini", "claude-3-5-sonnet-20241022", "gpt-4o", "unknown"}
+	for i, id := range want {
+		if models[i].ID != id {
+			t.Errorf("Model %d: got %s, want %s", i, models[i].ID, id)
+		}
+	}
+}
@@ -274,6 +274,7 @@ func (e *Executor) buildTemplateContext(args map[string]string) map[string]any {
 	// Add arguments
 	ctx["args"] = args
 	ctx["input"] = args["input"]
+	ctx["ARGUMENTS"] = args["input"] // $ARGUMENTS in TypeScript-style templates
 
 	// Add numbered args directly
 	for k, v := range args {
@@ -340,6 +340,31 @@ func TestExecuteWithInput(t *testing.T) {
 	}
 }
 
+func TestExecuteWithArgumentsPlaceholder(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "command-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cfg := &types.Config{
+		Command: map[string]types.CommandConfig{
+			"review": {Template: "Review $ARGUMENTS carefully"},
+		},
+	}
+
+	executor := NewExecutor(tempDir, cfg)
+
+	result, err := executor.Execute(context.Background(), "review", "main.go util.go")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.Prompt != "Review main.go util.go carefully" {
+		t.Errorf("unexpected prompt: %s", result.Prompt)
+	}
+}
+
 func TestExecuteBracketSyntax(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "command-test-*")
 	if err != nil {
@@ -342,6 +342,31 @@ func mergeConfig(target, source *types.Config) {
 		target.Watcher = source.Watcher
 	}
 
+	// Merge storage config
+	if source.Storage != nil {
+		target.Storage = source.Storage
+	}
+
+	// Merge snapshot setting
+	if source.Snapshot != nil {
+		target.Snapshot = source.Snapshot
+	}
+
+	// Merge budget
+	if source.Budget != nil {
+		target.Budget = source.Budget
+	}
+
+	// Merge schedule
+	if source.Schedule != nil {
+		if target.Schedule == nil {
+			target.Schedule = make(map[string]types.ScheduleConfig)
+		}
+		for k, v := range source.Schedule {
+			target.Schedule[k] = v
+		}
+	}
+
 	// Merge experimental config
 	if source.Experimental != nil {
 		target.Experimental = source.Experimental
@@ -669,7 +669,8 @@ func TestOpenAICompatibleProvider(t *testing.T) {
 	qwenMax := qwen.Models["qwen-max"]
 	assert.Equal(t, "qwen-max", qwenMax.ID)
 	assert.True(t, qwenMax.Reasoning)
-	assert.True(t, qwenMax.ToolCall)
+	require.NotNil(t, qwenMax.ToolCall)
+	assert.True(t, *qwenMax.ToolCall)
 }
 
 func TestProviderWithoutOptions(t *testing.T) {
@@ -40,6 +40,27 @@ func (p *Paths) StoragePath() string {
 	return filepath.Join(p.Data, "storage")
 }
 
+// SnapshotPath returns the path to the file snapshot repositories.
+func (p *Paths) SnapshotPath() string {
+	return filepath.Join(p.Data, "snapshot")
+}
+
+// ModelsCachePath returns the path to the models discovered from providers.
+func (p *Paths) ModelsCachePath() string {
+	return filepath.Join(p.Cache, "models")
+}
+
+// CatalogPath returns the path to the model catalog merged over the embedded
+// one.
+func (p *Paths) CatalogPath() string {
+	return filepath.Join(p.Data, "models.json")
+}
+
+// TokenizerPath returns the path to the BPE vocabularies of the tokenizers.
+func (p *Paths) TokenizerPath() string {
+	return filepath.Join(p.Data, "tokenizers")
+}
+
 // AuthPath returns the path to the auth file.
 func (p *Paths) AuthPath() string {
 	return filepath.Join(p.Data, "auth.json")
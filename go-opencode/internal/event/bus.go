@@ -45,6 +45,8 @@ const (
 	SessionDiff      EventType = "session.diff"
 	SessionError     EventType = "session.error"
 	SessionCompacted EventType = "session.compacted"
+	SessionQueue     EventType = "session.queue"
+	SessionBudget    EventType = "session.budget"
 	MessageCreated     EventType = "message.created"
 	MessageUpdated     EventType = "message.updated"
 	MessageRemoved     EventType = "message.removed"
@@ -72,6 +74,9 @@ const (
 	// Command Events
 	CommandExecuted EventType = "command.executed"
 
+	// Schedule events
+	ScheduleRun EventType = "schedule.run"
+
 	// Client Tool Events
 	ClientToolRequest      EventType = "client-tool.request"
 	ClientToolRegistered   EventType = "client-tool.registered"
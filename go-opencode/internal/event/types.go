@@ -34,7 +34,10 @@ type SessionStatusData struct {
 
 // SessionStatusInfo represents the current status of a session.
 type SessionStatusInfo struct {
-	Type string `json:"type"` // "busy" | "idle"
+	Type    string `json:"type"`              // "busy" | "idle" | "retry"
+	Attempt int    `json:"attempt,omitempty"` // Only for retry
+	Message string `json:"message,omitempty"` // Only for retry
+	Next    int64  `json:"next,omitempty"`    // Only for retry, unix ms
 }
 
 // SessionDiffData is the data for session.diff events.
@@ -55,6 +58,22 @@ type SessionCompactedData struct {
 	SessionID string `json:"sessionID"`
 }
 
+// SessionQueueData is the data for session.queue events, sent with the
+// whole queue whenever a message is queued, delivered or cancelled.
+type SessionQueueData struct {
+	SessionID string                 `json:"sessionID"`
+	Queue     []*types.QueuedMessage `json:"queue"`
+}
+
+// SessionBudgetData is the data for session.budget events, sent when a
+// session stops because it hit a budget.
+type SessionBudgetData struct {
+	SessionID string              `json:"sessionID"`
+	Exceeded  string              `json:"exceeded"` // "cost" | "inputTokens" | "outputTokens" | "time"
+	Budget    *types.BudgetConfig `json:"budget"`
+	Usage     types.BudgetUsage   `json:"usage"`
+}
+
 // MessageCreatedData is the data for message.created events.
 // SDK compatible: uses "info" field for message object.
 type MessageCreatedData struct {
@@ -221,3 +240,9 @@ type CommandExecutedData struct {
 	Arguments string `json:"arguments"`
 	MessageID string `json:"messageID"`
 }
+
+// ScheduleRunData is the data for schedule.run events, sent when a scheduled
+// run starts, finishes or is skipped.
+type ScheduleRunData struct {
+	Run *types.ScheduleRun `json:"run"`
+}
@@ -23,7 +23,7 @@ import (
 
 // SubagentExecutor implements tool.TaskExecutor to run subagent tasks.
 type SubagentExecutor struct {
-	storage           *storage.Storage
+	storage           storage.Storage
 	providerRegistry  *provider.Registry
 	toolRegistry      *tool.Registry
 	permissionChecker *permission.Checker
@@ -37,7 +37,7 @@ type SubagentExecutor struct {
 
 // SubagentExecutorConfig holds configuration for creating a SubagentExecutor.
 type SubagentExecutorConfig struct {
-	Storage           *storage.Storage
+	Storage           storage.Storage
 	ProviderRegistry  *provider.Registry
 	ToolRegistry      *tool.Registry
 	PermissionChecker *permission.Checker
@@ -7,6 +7,7 @@ import (
 
 	einotool "github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"github.com/opencode-ai/opencode/internal/provider"
 	"github.com/opencode-ai/opencode/internal/tool"
 )
 
@@ -76,11 +77,10 @@ type mcpEinoWrapper struct {
 
 // Info returns the tool information.
 func (e *mcpEinoWrapper) Info(ctx context.Context) (*schema.ToolInfo, error) {
-	params := parseInputSchemaToParams(e.wrapper.mcpTool.InputSchema)
 	return &schema.ToolInfo{
 		Name:        e.wrapper.ID(),
 		Desc:        e.wrapper.Description(),
-		ParamsOneOf: schema.NewParamsOneOfByParams(params),
+		ParamsOneOf: provider.ToolParams(e.wrapper.mcpTool.InputSchema),
 	}, nil
 }
 
@@ -93,51 +93,6 @@ func (e *mcpEinoWrapper) InvokableRun(ctx context.Context, argsJSON string, opts
 	return result.Output, nil
 }
 
-// parseInputSchemaToParams converts JSON Schema to Eino ParameterInfo.
-func parseInputSchemaToParams(schemaJSON json.RawMessage) map[string]*schema.ParameterInfo {
-	var jsonSchema struct {
-		Properties map[string]struct {
-			Type        string `json:"type"`
-			Description string `json:"description"`
-		} `json:"properties"`
-		Required []string `json:"required"`
-	}
-
-	if err := json.Unmarshal(schemaJSON, &jsonSchema); err != nil {
-		return nil
-	}
-
-	requiredSet := make(map[string]bool)
-	for _, r := range jsonSchema.Required {
-		requiredSet[r] = true
-	}
-
-	params := make(map[string]*schema.ParameterInfo)
-	for name, prop := range jsonSchema.Properties {
-		paramType := schema.String
-		switch prop.Type {
-		case "integer":
-			paramType = schema.Integer
-		case "number":
-			paramType = schema.Number
-		case "boolean":
-			paramType = schema.Boolean
-		case "array":
-			paramType = schema.Array
-		case "object":
-			paramType = schema.Object
-		}
-
-		params[name] = &schema.ParameterInfo{
-			Type:     paramType,
-			Desc:     prop.Description,
-			Required: requiredSet[name],
-		}
-	}
-
-	return params
-}
-
 // RegisterMCPTools registers all MCP tools from the client to a tool registry.
 // This function fetches all available tools from connected MCP servers
 // and wraps them to implement the tool.Tool interface.
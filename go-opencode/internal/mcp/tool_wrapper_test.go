@@ -91,7 +91,7 @@ func TestMCPToolWrapper_EinoTool(t *testing.T) {
 	assert.NotNil(t, info.ParamsOneOf)
 }
 
-func TestParseInputSchemaToParams(t *testing.T) {
+func TestMCPToolWrapper_EinoToolParams(t *testing.T) {
 	tests := []struct {
 		name           string
 		schema         json.RawMessage
@@ -149,15 +149,22 @@ func TestParseInputSchemaToParams(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			params := parseInputSchemaToParams(tt.schema)
+			wrapper := NewMCPToolWrapper(Tool{Name: "test_tool", InputSchema: tt.schema}, nil)
+			info, err := wrapper.EinoTool().Info(context.Background())
+			require.NoError(t, err)
+			params, err := info.ParamsOneOf.ToJSONSchema()
+			require.NoError(t, err)
+			assert.Equal(t, "object", params.Type)
 
 			if len(tt.expectedParams) == 0 {
-				assert.Empty(t, params)
+				assert.Zero(t, params.Properties.Len())
 				return
 			}
 
 			for _, expectedName := range tt.expectedParams {
-				assert.Contains(t, params, expectedName)
+				prop, ok := params.Properties.Get(expectedName)
+				require.True(t, ok, "missing %s", expectedName)
+				assert.Equal(t, tt.expectedTypes[expectedName], prop.Type)
 			}
 		})
 	}
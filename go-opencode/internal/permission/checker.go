@@ -50,6 +50,16 @@ func (c *Checker) Check(ctx context.Context, req Request, action PermissionActio
 	return nil
 }
 
+// unattendedKey is the context key of the callback of an unattended run.
+type unattendedKey struct{}
+
+// Unattended returns a context for a run nobody answers permission requests
+// for, such as a scheduled one. Requests that would ask the user are denied
+// instead, and passed to denied.
+func Unattended(ctx context.Context, denied func(Request)) context.Context {
+	return context.WithValue(ctx, unattendedKey{}, denied)
+}
+
 // Ask prompts the user for permission.
 func (c *Checker) Ask(ctx context.Context, req Request) error {
 	// Check if already approved for this session and type
@@ -79,6 +89,17 @@ func (c *Checker) Ask(ctx context.Context, req Request) error {
 	}
 	c.mu.RUnlock()
 
+	if denied, ok := ctx.Value(unattendedKey{}).(func(Request)); ok {
+		denied(req)
+		return &RejectedError{
+			SessionID: req.SessionID,
+			Type:      req.Type,
+			CallID:    req.CallID,
+			Metadata:  req.Metadata,
+			Message:   "Permission denied: nobody can answer permission requests in this run",
+		}
+	}
+
 	// Generate request ID if not set
 	if req.ID == "" {
 		req.ID = ulid.Make().String()
@@ -135,6 +156,18 @@ func (c *Checker) Ask(ctx context.Context, req Request) error {
 	return nil
 }
 
+// Pending returns a permission request waiting for a response.
+func (c *Checker) Pending(requestID string) (Request, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pending, ok := c.pending[requestID]
+	if !ok {
+		return Request{}, false
+	}
+	return pending.Request, true
+}
+
 // Respond handles a user's response to a permission request.
 func (c *Checker) Respond(requestID string, action string) {
 	c.mu.RLock()
@@ -377,6 +377,24 @@ func TestChecker_PatternApproved(t *testing.T) {
 	}
 }
 
+func TestChecker_Unattended(t *testing.T) {
+	checker := NewChecker()
+	checker.ApprovePattern("test-session", "git status")
+
+	var denied []Request
+	ctx := Unattended(context.Background(), func(req Request) {
+		denied = append(denied, req)
+	})
+
+	// Approved requests still pass; others are denied without asking
+	assert.NoError(t, checker.Ask(ctx, Request{SessionID: "test-session", Type: PermBash, Pattern: []string{"git status"}}))
+	err := checker.Check(ctx, Request{SessionID: "test-session", Type: PermBash, Pattern: []string{"rm -rf /"}}, ActionAsk)
+	var rejected *RejectedError
+	require.ErrorAs(t, err, &rejected)
+	require.Len(t, denied, 1)
+	assert.Equal(t, []string{"rm -rf /"}, denied[0].Pattern)
+}
+
 func TestChecker_AskAndRespond(t *testing.T) {
 	// Reset event bus for clean test
 	event.Reset()
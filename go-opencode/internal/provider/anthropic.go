@@ -3,11 +3,14 @@ package provider
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino-ext/components/model/claude"
+	"github.com/cloudwego/eino/schema"
 
+	"github.com/opencode-ai/opencode/internal/catalog"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -15,6 +18,7 @@ import (
 type AnthropicProvider struct {
 	chatModel model.ToolCallingChatModel
 	models    []types.Model
+	modelID   string
 	config    *AnthropicConfig
 }
 
@@ -31,6 +35,13 @@ type AnthropicConfig struct {
 	// Extended thinking support
 	Thinking *claude.Thinking
 
+	// CacheStrategy places the prompt cache breakpoints for models with
+	// PromptCaching: CacheAuto (default), CacheStatic or CacheOff
+	CacheStrategy string
+
+	// HTTPClient sends the API requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+
 	// Bedrock configuration
 	UseBedrock bool
 	Region     string
@@ -70,11 +81,17 @@ func NewAnthropicProvider(ctx context.Context, config *AnthropicConfig) (*Anthro
 		})
 	} else {
 		// Use direct API
+		transport := http.DefaultTransport
+		if config.HTTPClient != nil && config.HTTPClient.Transport != nil {
+			transport = config.HTTPClient.Transport
+		}
 		cfg := &claude.Config{
 			APIKey:    apiKey,
 			Model:     modelID,
 			MaxTokens: config.MaxTokens,
 			Thinking:  config.Thinking,
+			// Reads the cache write tokens Eino does not report
+			HTTPClient: &http.Client{Transport: &cacheUsageTransport{base: transport}},
 		}
 		if config.BaseURL != "" {
 			cfg.BaseURL = &config.BaseURL
@@ -86,11 +103,13 @@ func NewAnthropicProvider(ctx context.Context, config *AnthropicConfig) (*Anthro
 		return nil, fmt.Errorf("failed to create Claude model: %w", err)
 	}
 
-	return &AnthropicProvider{
+	p := &AnthropicProvider{
 		chatModel: chatModel,
-		models:    anthropicModels(),
+		modelID:   modelID,
 		config:    config,
-	}, nil
+	}
+	p.models = anthropicModels(p.ID())
+	return p, nil
 }
 
 // ID returns the provider identifier.
@@ -119,11 +138,17 @@ func (p *AnthropicProvider) CreateCompletion(ctx context.Context, req *Completio
 	fmt.Printf("[anthropic] CreateCompletion: messages=%d, tools=%d, maxTokens=%d\n",
 		len(req.Messages), len(req.Tools), req.MaxTokens)
 
+	// Place the prompt cache breakpoints
+	messages, tools := req.Messages, req.Tools
+	if p.promptCaching() {
+		messages, tools = cacheBreakpoints(req, p.config.CacheStrategy)
+	}
+
 	// Bind tools if provided
 	chatModel := p.chatModel
-	if len(req.Tools) > 0 {
+	if len(tools) > 0 {
 		var err error
-		chatModel, err = chatModel.WithTools(req.Tools)
+		chatModel, err = chatModel.WithTools(tools)
 		if err != nil {
 			return nil, fmt.Errorf("failed to bind tools: %w", err)
 		}
@@ -132,7 +157,8 @@ func (p *AnthropicProvider) CreateCompletion(ctx context.Context, req *Completio
 
 	// Create streaming request
 	fmt.Printf("[anthropic] Creating stream...\n")
-	stream, err := chatModel.Stream(ctx, req.Messages,
+	ctx, setCacheUsage := withCacheUsage(ctx)
+	stream, err := chatModel.Stream(ctx, messages,
 		model.WithMaxTokens(req.MaxTokens),
 		model.WithTemperature(float32(req.Temperature)),
 	)
@@ -142,90 +168,28 @@ func (p *AnthropicProvider) CreateCompletion(ctx context.Context, req *Completio
 	}
 	fmt.Printf("[anthropic] Stream created successfully\n")
 
-	return NewCompletionStream(stream), nil
+	return NewCompletionStream(schema.StreamReaderWithConvert(stream, setCacheUsage)), nil
+}
+
+// promptCaching reports whether the model of the provider caches prompts.
+func (p *AnthropicProvider) promptCaching() bool {
+	if p.config.CacheStrategy == CacheOff {
+		return false
+	}
+	for _, m := range p.models {
+		if m.ID == p.modelID {
+			return m.Options.PromptCaching
+		}
+	}
+	return false
 }
 
-// anthropicModels returns the list of Anthropic models.
-func anthropicModels() []types.Model {
-	return []types.Model{
-		{
-			ID:                "claude-sonnet-4-20250514",
-			Name:              "Claude Sonnet 4",
-			ProviderID:        "anthropic",
-			ContextLength:     200000,
-			MaxOutputTokens:   64000,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			SupportsReasoning: false,
-			InputPrice:        3.0,
-			OutputPrice:       15.0,
-			Options: types.ModelOptions{
-				PromptCaching:  true,
-				ExtendedOutput: true,
-			},
-		},
-		{
-			ID:                "claude-opus-4-20250514",
-			Name:              "Claude Opus 4",
-			ProviderID:        "anthropic",
-			ContextLength:     200000,
-			MaxOutputTokens:   32000,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			SupportsReasoning: true,
-			InputPrice:        15.0,
-			OutputPrice:       75.0,
-			Options: types.ModelOptions{
-				PromptCaching: true,
-			},
-		},
-		{
-			ID:                "claude-3-5-sonnet-20241022",
-			Name:              "Claude 3.5 Sonnet",
-			ProviderID:        "anthropic",
-			ContextLength:     200000,
-			MaxOutputTokens:   8192,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			InputPrice:        3.0,
-			OutputPrice:       15.0,
-			Options: types.ModelOptions{
-				PromptCaching: true,
-			},
-		},
-		{
-			ID:                "claude-3-5-haiku-20241022",
-			Name:              "Claude 3.5 Haiku",
-			ProviderID:        "anthropic",
-			ContextLength:     200000,
-			MaxOutputTokens:   8192,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			InputPrice:        0.8,
-			OutputPrice:       4.0,
-		},
-		{
-			ID:                "claude-haiku-4-5-20251001",
-			Name:              "Claude 4.5 Haiku",
-			ProviderID:        "anthropic",
-			ContextLength:     200000,
-			MaxOutputTokens:   8192,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			InputPrice:        0.8,
-			OutputPrice:       4.0,
-		},
-		// Alias for claude-haiku-4-5-20251001
-		{
-			ID:                "claude-haiku-4-5",
-			Name:              "Claude 4.5 Haiku",
-			ProviderID:        "anthropic",
-			ContextLength:     200000,
-			MaxOutputTokens:   8192,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			InputPrice:        0.8,
-			OutputPrice:       4.0,
-		},
+// anthropicModels returns the Anthropic models of the catalog. Eino's Claude
+// model sends no file parts, so PDFs are not offered.
+func anthropicModels(providerID string) []types.Model {
+	models := catalog.Default().Models("anthropic", providerID)
+	for i := range models {
+		models[i].SupportsPDF = false
 	}
+	return models
 }
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -406,3 +407,45 @@ func TestAnthropicProvider_EmptyContentHandling(t *testing.T) {
 		t.Logf("Response: %s", fullResponse)
 	})
 }
+
+func TestCacheBreakpoints(t *testing.T) {
+	req := &CompletionRequest{
+		Messages: []*schema.Message{
+			{Role: schema.System, Content: "system"},
+			{Role: schema.User, Content: "one"},
+			{Role: schema.Assistant, Content: "two"},
+			{Role: schema.User, Content: "three"},
+		},
+		Tools: []*schema.ToolInfo{{Name: "glob"}, {Name: "read"}},
+	}
+	marked := func(extra map[string]any) bool { return len(extra) > 0 }
+
+	messages, tools := cacheBreakpoints(req, CacheAuto)
+	var got []bool
+	for _, msg := range messages {
+		got = append(got, marked(msg.Extra))
+	}
+	if fmt.Sprint(got) != "[true false true true]" {
+		t.Errorf("Got message breakpoints %v", got)
+	}
+	if marked(tools[0].Extra) || !marked(tools[1].Extra) {
+		t.Error("Expected a breakpoint on the last tool only")
+	}
+	for _, msg := range req.Messages {
+		if msg.Extra != nil {
+			t.Fatal("The request should be left untouched")
+		}
+	}
+	if req.Tools[1].Extra != nil {
+		t.Fatal("The request tools should be left untouched")
+	}
+
+	messages, _ = cacheBreakpoints(req, CacheStatic)
+	got = nil
+	for _, msg := range messages {
+		got = append(got, marked(msg.Extra))
+	}
+	if fmt.Sprint(got) != "[true false false false]" {
+		t.Errorf("Got static message breakpoints %v", got)
+	}
+}
@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cloudwego/eino-ext/components/model/claude"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Prompt caching strategies, set with the cacheStrategy option of a provider.
+const (
+	// CacheAuto caches the tool definitions, the system prompt and the
+	// conversation up to its last message. The message before it is marked
+	// too, so that the prefix the previous step wrote is read back even when
+	// many blocks were added since.
+	CacheAuto = "auto"
+	// CacheStatic caches the tool definitions and the system prompt only,
+	// for sessions whose history changes too much to gain from caching.
+	CacheStatic = "static"
+	// CacheOff disables prompt caching.
+	CacheOff = "off"
+)
+
+// cacheBreakpoints returns the messages and tools of a request with the
+// cache breakpoints of strategy set on them, at most the four Anthropic
+// accepts. The request itself is left untouched, as sessions reuse it.
+func cacheBreakpoints(req *CompletionRequest, strategy string) ([]*schema.Message, []*schema.ToolInfo) {
+	if strategy == CacheOff {
+		return req.Messages, req.Tools
+	}
+
+	tools := req.Tools
+	if len(tools) > 0 {
+		tools = append([]*schema.ToolInfo(nil), tools...)
+		tools[len(tools)-1] = claude.SetToolInfoBreakpoint(tools[len(tools)-1])
+	}
+
+	messages := append([]*schema.Message(nil), req.Messages...)
+	lastSystem := -1
+	var conversation []int
+	for i, msg := range messages {
+		if msg.Role == schema.System {
+			lastSystem = i
+		} else {
+			conversation = append(conversation, i)
+		}
+	}
+	if lastSystem >= 0 {
+		messages[lastSystem] = claude.SetMessageBreakpoint(messages[lastSystem])
+	}
+	if strategy != CacheStatic {
+		for _, i := range conversation[max(len(conversation)-2, 0):] {
+			messages[i] = claude.SetMessageBreakpoint(messages[i])
+		}
+	}
+	return messages, tools
+}
+
+// cacheUsageKey is the context key of the cacheUsage of a request.
+type cacheUsageKey struct{}
+
+// cacheUsage holds the prompt tokens a request wrote to the cache. Eino
+// reports the tokens read from the cache but folds those written into the
+// prompt tokens, so they are read from the response stream as it goes by.
+type cacheUsage struct {
+	written atomic.Int64
+}
+
+// cacheUsageTransport records the cache usage of the streamed Anthropic
+// responses to the requests whose context holds a cacheUsage.
+type cacheUsageTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *cacheUsageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	usage, ok := req.Context().Value(cacheUsageKey{}).(*cacheUsage)
+	if ok && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &cacheUsageReader{body: resp.Body, usage: usage}
+	}
+	return resp, nil
+}
+
+// cacheUsageReader scans the server-sent events it reads for the usage of
+// the message_start event.
+type cacheUsageReader struct {
+	body  io.ReadCloser
+	usage *cacheUsage
+	line  []byte
+	done  bool
+}
+
+func (r *cacheUsageReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if !r.done {
+		r.scan(p[:n])
+	}
+	return n, err
+}
+
+func (r *cacheUsageReader) Close() error {
+	return r.body.Close()
+}
+
+// scan parses the complete lines of data, keeping the rest for the next
+// read.
+func (r *cacheUsageReader) scan(data []byte) {
+	r.line = append(r.line, data...)
+	for !r.done {
+		line, rest, ok := bytes.Cut(r.line, []byte("\n"))
+		if !ok {
+			return
+		}
+		r.line = rest
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage struct {
+					CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(bytes.TrimSpace(payload), &event) == nil && event.Type == "message_start" {
+			r.usage.written.Store(event.Message.Usage.CacheCreationInputTokens)
+			r.done = true
+		}
+	}
+	r.line = nil
+}
+
+// withCacheUsage returns a context recording the cache usage of the request
+// made with it, and a function setting that usage on the chunks of its
+// response stream.
+func withCacheUsage(ctx context.Context) (context.Context, func(*schema.Message) (*schema.Message, error)) {
+	usage := &cacheUsage{}
+	return context.WithValue(ctx, cacheUsageKey{}, usage), func(msg *schema.Message) (*schema.Message, error) {
+		if msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil && msg.ResponseMeta.Usage.PromptTokens > 0 {
+			if written := usage.written.Load(); written > 0 {
+				if msg.Extra == nil {
+					msg.Extra = map[string]any{}
+				}
+				msg.Extra[ExtraCacheWriteTokens] = int(written)
+			}
+		}
+		return msg, nil
+	}
+}
@@ -0,0 +1,459 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// CassetteVersion is the version of the cassette file format.
+const CassetteVersion = 1
+
+// Purposes of the completions a session makes. Replay tells them apart so a
+// title request running next to the agentic loop is not served a loop turn.
+const (
+	PurposeChat       = "chat"
+	PurposeTitle      = "title"
+	PurposeCompaction = "compaction"
+)
+
+// Cassette holds the LLM traffic of one session: every completion request and
+// the chunks streamed back, in the order the requests were made.
+type Cassette struct {
+	Version      int            `json:"version"`
+	SessionID    string         `json:"sessionID"`
+	Created      int64          `json:"created"` // Unix milliseconds
+	Models       []types.Model  `json:"models,omitempty"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded completion.
+type Interaction struct {
+	ProviderID  string             `json:"providerID"`
+	Purpose     string             `json:"purpose,omitempty"`
+	Digest      string             `json:"digest"` // Hash of the provider and normalized request
+	Request     *CompletionRequest `json:"request"`
+	Chunks      []*schema.Message  `json:"chunks,omitempty"`
+	Error       string             `json:"error,omitempty"`       // CreateCompletion failed
+	StreamError string             `json:"streamError,omitempty"` // The stream failed after Chunks
+}
+
+type sessionScopeKey struct{}
+
+type sessionScope struct {
+	sessionID string
+	purpose   string
+}
+
+// WithSession marks the completions made with ctx as made by a session for
+// purpose, so a Recorder files them in the session's cassette.
+func WithSession(ctx context.Context, sessionID, purpose string) context.Context {
+	return context.WithValue(ctx, sessionScopeKey{}, sessionScope{sessionID: sessionID, purpose: purpose})
+}
+
+func sessionFromContext(ctx context.Context) sessionScope {
+	scope, _ := ctx.Value(sessionScopeKey{}).(sessionScope)
+	return scope
+}
+
+// Recorder records the completions of sessions to cassette files, one per
+// session, or replays them from those files without calling the providers.
+// Install it with Registry.SetRecorder.
+type Recorder struct {
+	dir     string
+	replay  bool
+	lenient bool
+
+	mu      sync.Mutex
+	tapes   map[string]*tape // By session ID
+	unbound []*tape          // Replay: cassettes not yet used by a session
+}
+
+// tape is a cassette in use.
+type tape struct {
+	cassette *Cassette
+	path     string
+	used     []bool // Replay: interactions already served
+}
+
+// NewRecorder returns a Recorder writing cassettes to dir, which is created
+// if needed.
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cassette dir: %w", err)
+	}
+	return &Recorder{dir: dir, tapes: make(map[string]*tape)}, nil
+}
+
+// NewReplayer returns a Recorder replaying the cassettes in dir.
+//
+// A session replays the cassette recorded under its own ID if there is one.
+// Otherwise it takes the first unused cassette holding its first request, or
+// else the oldest unused cassette, so sessions created afresh replay the
+// cassettes recorded in the same order.
+//
+// Each request must match a recorded one, once the working directory and date
+// of the system prompt are set aside; see SetLenient.
+func NewReplayer(dir string) (*Recorder, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no cassettes in %s", dir)
+	}
+
+	r := &Recorder{dir: dir, replay: true, tapes: make(map[string]*tape)}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		var cassette Cassette
+		if err := json.Unmarshal(data, &cassette); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+		if cassette.Version != CassetteVersion {
+			return nil, fmt.Errorf("cassette %s has unsupported version %d", path, cassette.Version)
+		}
+		// Digests are taken again, as cassettes may predate normalization
+		for _, in := range cassette.Interactions {
+			if in.Request != nil {
+				in.Digest = requestDigest(in.ProviderID, in.Request)
+			}
+		}
+		r.unbound = append(r.unbound, &tape{
+			cassette: &cassette,
+			path:     path,
+			used:     make([]bool, len(cassette.Interactions)),
+		})
+	}
+	sort.SliceStable(r.unbound, func(i, j int) bool {
+		return r.unbound[i].cassette.Created < r.unbound[j].cassette.Created
+	})
+	return r, nil
+}
+
+// SetLenient makes a replaying recorder serve a request matching no recorded
+// one the next unused completion with the same purpose, instead of failing.
+func (r *Recorder) SetLenient(lenient bool) {
+	r.lenient = lenient
+}
+
+// Replaying reports whether the recorder replays cassettes.
+func (r *Recorder) Replaying() bool {
+	return r.replay
+}
+
+// Dir returns the cassette directory.
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+// models returns the recorded models by provider ID.
+func (r *Recorder) models() map[string][]types.Model {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	models := make(map[string][]types.Model)
+	for _, t := range r.unbound {
+		for _, m := range t.cassette.Models {
+			if !containsModel(models[m.ProviderID], m.ID) {
+				models[m.ProviderID] = append(models[m.ProviderID], m)
+			}
+		}
+	}
+	return models
+}
+
+// wrap returns provider with its completions recorded or replayed.
+func (r *Recorder) wrap(provider Provider) Provider {
+	return &recordedProvider{Provider: provider, recorder: r}
+}
+
+// recordedProvider is a provider whose completions go through a Recorder.
+type recordedProvider struct {
+	Provider
+	recorder *Recorder
+}
+
+func (p *recordedProvider) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionStream, error) {
+	if p.recorder.replay {
+		return p.recorder.play(ctx, p.ID(), req)
+	}
+	return p.recorder.record(ctx, p.Provider, req)
+}
+
+// record runs a completion and records it with its chunks. Completions made
+// outside a session are not recorded.
+func (r *Recorder) record(ctx context.Context, prov Provider, req *CompletionRequest) (*CompletionStream, error) {
+	scope := sessionFromContext(ctx)
+	if scope.sessionID == "" {
+		return prov.CreateCompletion(ctx, req)
+	}
+
+	interaction := &Interaction{
+		ProviderID: prov.ID(),
+		Purpose:    scope.purpose,
+		Digest:     requestDigest(prov.ID(), req),
+		Request:    snapshotRequest(req),
+	}
+	r.mu.Lock()
+	t := r.tapes[scope.sessionID]
+	if t == nil {
+		t = &tape{
+			cassette: &Cassette{
+				Version:   CassetteVersion,
+				SessionID: scope.sessionID,
+				Created:   time.Now().UnixMilli(),
+			},
+			path: filepath.Join(r.dir, scope.sessionID+".json"),
+		}
+		r.tapes[scope.sessionID] = t
+	}
+	// Requests are recorded in the order they are made
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	if !containsModel(t.cassette.Models, req.Model) {
+		for _, m := range prov.Models() {
+			if m.ID == req.Model {
+				t.cassette.Models = append(t.cassette.Models, m)
+				break
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	stream, err := prov.CreateCompletion(ctx, req)
+	if err != nil {
+		r.mu.Lock()
+		interaction.Error = err.Error()
+		r.mu.Unlock()
+		r.save(t)
+		return nil, err
+	}
+
+	reader, writer := schema.Pipe[*schema.Message](16)
+	go func() {
+		// The stream ends once the cassette is saved
+		defer writer.Close()
+		defer r.save(t)
+		defer stream.Close()
+
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			r.mu.Lock()
+			if err != nil {
+				interaction.StreamError = err.Error()
+			} else {
+				interaction.Chunks = append(interaction.Chunks, chunk)
+			}
+			r.mu.Unlock()
+			if closed := writer.Send(chunk, err); closed || err != nil {
+				return
+			}
+		}
+	}()
+	return NewCompletionStream(reader), nil
+}
+
+// save writes a cassette to its file.
+func (r *Recorder) save(t *tape) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		fmt.Printf("[provider] Failed to encode cassette %s: %v\n", t.path, err)
+		return
+	}
+
+	// Write and rename so a crash leaves the last complete cassette
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		fmt.Printf("[provider] Failed to write cassette %s: %v\n", t.path, err)
+		return
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		fmt.Printf("[provider] Failed to write cassette %s: %v\n", t.path, err)
+	}
+}
+
+// play serves a completion from the cassette of the session: the first unused
+// interaction with the same request, or if lenient the next unused one with
+// the same purpose.
+func (r *Recorder) play(ctx context.Context, providerID string, req *CompletionRequest) (*CompletionStream, error) {
+	scope := sessionFromContext(ctx)
+	if scope.sessionID == "" {
+		return nil, errors.New("replay: completion made outside a session")
+	}
+	digest := requestDigest(providerID, req)
+
+	r.mu.Lock()
+	t := r.bind(scope.sessionID, digest)
+	if t == nil {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("replay: no cassette left for session %s", scope.sessionID)
+	}
+	index := -1
+	for i, in := range t.cassette.Interactions {
+		if !t.used[i] && in.Digest == digest {
+			index = i
+			break
+		}
+	}
+	if index < 0 && r.lenient {
+		for i, in := range t.cassette.Interactions {
+			if !t.used[i] && in.Purpose == scope.purpose {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		r.mu.Unlock()
+		if r.lenient {
+			return nil, fmt.Errorf("replay: cassette %s has no %s completion left", filepath.Base(t.path), scope.purpose)
+		}
+		return nil, fmt.Errorf("replay: no unused %s completion of cassette %s matches the request, digest %s", scope.purpose, filepath.Base(t.path), digest)
+	}
+	t.used[index] = true
+	interaction := t.cassette.Interactions[index]
+	r.mu.Unlock()
+
+	if interaction.Error != "" {
+		return nil, errors.New(interaction.Error)
+	}
+
+	reader, writer := schema.Pipe[*schema.Message](len(interaction.Chunks) + 1)
+	go func() {
+		defer writer.Close()
+		for _, chunk := range interaction.Chunks {
+			if closed := writer.Send(chunk, nil); closed {
+				return
+			}
+		}
+		if interaction.StreamError != "" {
+			writer.Send(nil, errors.New(interaction.StreamError))
+		}
+	}()
+	return NewCompletionStream(reader), nil
+}
+
+// bind returns the cassette replayed by a session, choosing one on its first
+// request. The caller holds r.mu.
+func (r *Recorder) bind(sessionID, digest string) *tape {
+	if t, ok := r.tapes[sessionID]; ok {
+		return t
+	}
+	if len(r.unbound) == 0 {
+		return nil
+	}
+
+	chosen := 0
+	if i := r.findUnbound(func(c *Cassette) bool { return c.SessionID == sessionID }); i >= 0 {
+		chosen = i
+	} else if i := r.findUnbound(func(c *Cassette) bool {
+		for _, in := range c.Interactions {
+			if in.Digest == digest {
+				return true
+			}
+		}
+		return false
+	}); i >= 0 {
+		chosen = i
+	}
+
+	t := r.unbound[chosen]
+	r.unbound = append(r.unbound[:chosen], r.unbound[chosen+1:]...)
+	r.tapes[sessionID] = t
+	return t
+}
+
+func (r *Recorder) findUnbound(match func(c *Cassette) bool) int {
+	for i, t := range r.unbound {
+		if match(t.cassette) {
+			return i
+		}
+	}
+	return -1
+}
+
+// volatileLines matches the lines of the system prompt that change from run
+// to run.
+var volatileLines = regexp.MustCompile(`(?m)^(Working Directory|Current Date): .*$`)
+
+// requestDigest hashes a completion request made to a provider, with its
+// volatile lines blanked.
+func requestDigest(providerID string, req *CompletionRequest) string {
+	normalized := *req
+	normalized.Messages = make([]*schema.Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		if msg != nil && volatileLines.MatchString(msg.Content) {
+			copied := *msg
+			copied.Content = volatileLines.ReplaceAllString(msg.Content, "$1: -")
+			msg = &copied
+		}
+		normalized.Messages[i] = msg
+	}
+
+	data, _ := json.Marshal(&normalized)
+	sum := sha256.Sum256(append([]byte(providerID+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// snapshotRequest copies a request, so the recording is not changed by
+// callers reusing its messages.
+func snapshotRequest(req *CompletionRequest) *CompletionRequest {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return req
+	}
+	var snapshot CompletionRequest
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return req
+	}
+	return &snapshot
+}
+
+func containsModel(models []types.Model, modelID string) bool {
+	for _, m := range models {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// replayProvider stands in during replay for a provider that was recorded but
+// is not configured, such as one whose API key is not set.
+type replayProvider struct {
+	id     string
+	models []types.Model
+}
+
+func (p *replayProvider) ID() string                            { return p.id }
+func (p *replayProvider) Name() string                          { return p.id }
+func (p *replayProvider) Models() []types.Model                 { return p.models }
+func (p *replayProvider) ChatModel() model.ToolCallingChatModel { return nil }
+
+func (p *replayProvider) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionStream, error) {
+	return nil, fmt.Errorf("provider %s is only available for replay", p.id)
+}
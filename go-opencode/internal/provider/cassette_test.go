@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// scriptedProvider streams the words of its replies in turn.
+type scriptedProvider struct {
+	replies [][]string
+	calls   int
+}
+
+func (p *scriptedProvider) ID() string   { return "scripted" }
+func (p *scriptedProvider) Name() string { return "Scripted" }
+func (p *scriptedProvider) Models() []types.Model {
+	return []types.Model{{ID: "script-1", ProviderID: "scripted", Name: "Script 1"}}
+}
+func (p *scriptedProvider) ChatModel() model.ToolCallingChatModel { return nil }
+
+func (p *scriptedProvider) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionStream, error) {
+	if p.calls >= len(p.replies) {
+		return nil, errors.New("rate limited")
+	}
+	reply := p.replies[p.calls]
+	p.calls++
+
+	chunks := make([]*schema.Message, len(reply))
+	for i, word := range reply {
+		chunks[i] = &schema.Message{Role: schema.Assistant, Content: word}
+	}
+	return NewCompletionStream(schema.StreamReaderFromArray(chunks)), nil
+}
+
+func completionRequest(prompt string) *CompletionRequest {
+	return &CompletionRequest{
+		Model:    "script-1",
+		Messages: []*schema.Message{{Role: schema.User, Content: prompt}},
+	}
+}
+
+// complete runs a completion and joins the streamed content.
+func complete(ctx context.Context, prov Provider, prompt string) (string, error) {
+	stream, err := prov.CreateCompletion(ctx, completionRequest(prompt))
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var content string
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return content, nil
+		}
+		if err != nil {
+			return content, err
+		}
+		content += msg.Content
+	}
+}
+
+func recordScripted(t *testing.T, dir string) {
+	t.Helper()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	registry := NewRegistry(nil)
+	registry.Register(&scriptedProvider{replies: [][]string{
+		{"Hello", ", ", "world"},
+		{"Fix", "ing"},
+	}})
+	registry.SetRecorder(recorder)
+
+	prov, err := registry.Get("scripted")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	ctx := WithSession(context.Background(), "ses1", PurposeChat)
+	for _, prompt := range []string{"hi", "fix it"} {
+		if _, err := complete(ctx, prov, prompt); err != nil {
+			t.Fatalf("complete(%q) failed: %v", prompt, err)
+		}
+	}
+	if _, err := complete(ctx, prov, "again"); err == nil {
+		t.Fatal("Expected the provider error")
+	}
+
+	// Completions outside a session are not recorded
+	if _, err := complete(context.Background(), prov, "other"); err == nil {
+		t.Fatal("Expected the provider error")
+	}
+}
+
+func TestRecorder_Record(t *testing.T) {
+	dir := t.TempDir()
+	recordScripted(t, dir)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 || filepath.Base(files[0]) != "ses1.json" {
+		t.Fatalf("Got cassette files %v, want ses1.json", files)
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+	cassette := replayer.unbound[0].cassette
+	if cassette.SessionID != "ses1" {
+		t.Errorf("Got session %q, want ses1", cassette.SessionID)
+	}
+	if len(cassette.Models) != 1 || cassette.Models[0].ID != "script-1" {
+		t.Errorf("Got models %v, want script-1", cassette.Models)
+	}
+	if len(cassette.Interactions) != 3 {
+		t.Fatalf("Got %d interactions, want 3", len(cassette.Interactions))
+	}
+	first := cassette.Interactions[0]
+	if first.Purpose != PurposeChat || len(first.Chunks) != 3 || first.Request.Messages[0].Content != "hi" {
+		t.Errorf("Unexpected first interaction: %+v", first)
+	}
+	if first.Digest != requestDigest("scripted", completionRequest("hi")) {
+		t.Errorf("Digest does not match the request")
+	}
+	if cassette.Interactions[2].Error != "rate limited" {
+		t.Errorf("Got error %q, want rate limited", cassette.Interactions[2].Error)
+	}
+}
+
+func TestRecorder_Replay(t *testing.T) {
+	dir := t.TempDir()
+	recordScripted(t, dir)
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+
+	// The provider is not configured; it is stood in for from the cassette
+	registry := NewRegistry(nil)
+	registry.SetRecorder(replayer)
+	model, err := registry.GetModel("scripted", "script-1")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	if model.Name != "Script 1" {
+		t.Errorf("Got model %q, want Script 1", model.Name)
+	}
+	prov, err := registry.Get("scripted")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// A new session takes the unused cassette; matching requests are served
+	// their own reply even out of order
+	ctx := WithSession(context.Background(), "ses2", PurposeChat)
+	content, err := complete(ctx, prov, "fix it")
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if content != "Fixing" {
+		t.Errorf("Got %q, want Fixing", content)
+	}
+
+	// Other requests fail, unless replay is lenient; then they are served in
+	// order
+	if _, err := complete(ctx, prov, "hello there"); err == nil || !strings.Contains(err.Error(), "matches the request") {
+		t.Errorf("Got error %v, want a digest mismatch", err)
+	}
+	replayer.SetLenient(true)
+	content, err = complete(ctx, prov, "hello there")
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if content != "Hello, world" {
+		t.Errorf("Got %q, want Hello, world", content)
+	}
+
+	// Recorded errors are replayed
+	if _, err := complete(ctx, prov, "again"); err == nil || err.Error() != "rate limited" {
+		t.Errorf("Got error %v, want rate limited", err)
+	}
+
+	if _, err := complete(ctx, prov, "more"); err == nil {
+		t.Error("Expected an error once the cassette is used up")
+	}
+	if _, err := complete(WithSession(context.Background(), "ses3", PurposeChat), prov, "hi"); err == nil {
+		t.Error("Expected an error with no cassette left")
+	}
+	if _, err := complete(context.Background(), prov, "hi"); err == nil {
+		t.Error("Expected an error outside a session")
+	}
+}
+
+func TestRequestDigest(t *testing.T) {
+	request := func(system string) *CompletionRequest {
+		req := completionRequest("hi")
+		req.Messages = append([]*schema.Message{{Role: schema.System, Content: system}}, req.Messages...)
+		return req
+	}
+	recorded := request("# Environment Information\n\nWorking Directory: /tmp/run1\nCurrent Date: 2025-01-02\nPlatform: linux/amd64\n")
+	digest := requestDigest("scripted", recorded)
+
+	// The working directory and date are set aside
+	replayed := request("# Environment Information\n\nWorking Directory: /tmp/run2\nCurrent Date: 2025-03-04\nPlatform: linux/amd64\n")
+	if requestDigest("scripted", replayed) != digest {
+		t.Error("Requests differing in working directory and date should match")
+	}
+	if !strings.Contains(recorded.Messages[0].Content, "/tmp/run1") {
+		t.Error("The request should not be changed")
+	}
+
+	other := request("# Environment Information\n\nWorking Directory: /tmp/run1\nCurrent Date: 2025-01-02\nPlatform: darwin/arm64\n")
+	if requestDigest("scripted", other) == digest {
+		t.Error("Requests differing otherwise should not match")
+	}
+}
+
+func TestNewReplayer_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewReplayer(dir); err == nil {
+		t.Error("Expected an error with no cassettes")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "ses1.json"), []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReplayer(dir); err == nil {
+		t.Error("Expected an error for an unsupported version")
+	}
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ModelRefresher is implemented by providers that discover their models, so
+// the model list can be refreshed while running.
+type ModelRefresher interface {
+	RefreshModels(ctx context.Context) error
+}
+
+// CompatibleProvider implements Provider for OpenAI-compatible servers, such
+// as Ollama, vLLM or llama.cpp, with the models the server lists.
+type CompatibleProvider struct {
+	*OpenAIProvider
+	discovery  *ModelDiscovery
+	configured []string
+
+	mu     sync.RWMutex
+	models []types.Model
+}
+
+// CompatibleConfig holds configuration for an OpenAI-compatible provider.
+type CompatibleConfig struct {
+	ID        string
+	APIKey    string
+	BaseURL   string
+	Model     string
+	MaxTokens int
+
+	// Models are the model IDs from the config, listed even when the server
+	// doesn't report them.
+	Models []string
+
+	// CacheDir holds discovered models, see ModelDiscovery.
+	CacheDir   string
+	CacheTTL   time.Duration
+	HTTPClient *http.Client
+}
+
+// modelsCacheDir returns where discovered models are cached.
+func modelsCacheDir() string {
+	return config.GetPaths().ModelsCachePath()
+}
+
+// NewCompatibleProvider creates a provider for an OpenAI-compatible server and
+// discovers its models. A server that can't be reached is not an error, the
+// provider starts with the cached or configured models.
+func NewCompatibleProvider(ctx context.Context, config *CompatibleConfig) (*CompatibleProvider, error) {
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("baseURL is required for OpenAI-compatible provider %s", config.ID)
+	}
+
+	p := &CompatibleProvider{
+		discovery: &ModelDiscovery{
+			ProviderID: config.ID,
+			BaseURL:    config.BaseURL,
+			APIKey:     config.APIKey,
+			CacheDir:   config.CacheDir,
+			TTL:        config.CacheTTL,
+			HTTPClient: config.HTTPClient,
+		},
+		configured: config.Models,
+	}
+
+	discovered, err := p.discovery.Models(ctx)
+	if err != nil {
+		fmt.Printf("[provider] Model discovery failed for %s: %v\n", config.ID, err)
+	}
+	p.setModels(discovered)
+
+	// The model is chosen per request, this is only the fallback
+	modelID := config.Model
+	if modelID == "" && len(p.models) > 0 {
+		modelID = p.models[0].ID
+	}
+	openAI, err := NewOpenAIProvider(ctx, &OpenAIConfig{
+		ID:         config.ID,
+		APIKey:     config.APIKey,
+		BaseURL:    config.BaseURL,
+		Model:      modelID,
+		MaxTokens:  config.MaxTokens,
+		HTTPClient: config.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.OpenAIProvider = openAI
+	return p, nil
+}
+
+// Name returns the human-readable provider name.
+func (p *CompatibleProvider) Name() string { return p.ID() }
+
+// Models returns the discovered and configured models.
+func (p *CompatibleProvider) Models() []types.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.models
+}
+
+// RefreshModels asks the server for its models again, bypassing the cache.
+func (p *CompatibleProvider) RefreshModels(ctx context.Context) error {
+	discovered, err := p.discovery.Refresh(ctx)
+	if err != nil {
+		return err
+	}
+	p.setModels(discovered)
+	return nil
+}
+
+// setModels replaces the models with those discovered, followed by the
+// configured models the server didn't report.
+func (p *CompatibleProvider) setModels(discovered []types.Model) {
+	seen := make(map[string]bool, len(discovered))
+	for _, m := range discovered {
+		seen[m.ID] = true
+	}
+	models := discovered
+	for _, id := range p.configured {
+		if !seen[id] {
+			models = append(models, discoveredModelDefaults(p.discovery.ProviderID, id, DefaultDiscoveredContextLength))
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = models
+}
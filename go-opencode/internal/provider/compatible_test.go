@@ -0,0 +1,208 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// compatibleServer is an OpenAI-compatible server listing models, which
+// records the models requested for completions.
+type compatibleServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	models    string
+	listed    int
+	completed []string
+}
+
+func newCompatibleServer(t *testing.T, models string) *compatibleServer {
+	t.Helper()
+	s := &compatibleServer{models: models}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v1/models":
+			s.listed++
+			if s.models == "" {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(s.models))
+		case "/v1/chat/completions":
+			var req struct {
+				Model string `json:"model"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			s.completed = append(s.completed, req.Model)
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`+"\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *compatibleServer) setModels(models string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = models
+}
+
+func (s *compatibleServer) listCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listed
+}
+
+func TestInitializeProviders_Compatible(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+
+	server := newCompatibleServer(t, `{"object":"list","data":[`+
+		`{"id":"llama3.1:8b","object":"model","owned_by":"library"},`+
+		`{"id":"qwen2.5-coder","object":"model","max_model_len":65536}]}`)
+
+	noTools := false
+	config := &types.Config{
+		Provider: map[string]types.ProviderConfig{
+			"ollama": {
+				Npm:     NpmOpenAICompatible,
+				Options: &types.ProviderOptions{BaseURL: server.URL + "/v1"},
+				Models: map[string]types.ModelConfig{
+					"llama3.1:8b": {Name: "Llama 3.1", ToolCall: &noTools, Limit: &types.ModelLimitConfig{Context: 8192}},
+					"offline":     {},
+				},
+			},
+		},
+	}
+
+	registry, err := InitializeProviders(context.Background(), config)
+	if err != nil {
+		t.Fatalf("InitializeProviders failed: %v", err)
+	}
+
+	llama, err := registry.GetModel("ollama", "llama3.1:8b")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	if llama.Name != "Llama 3.1" || llama.SupportsTools || llama.ContextLength != 8192 {
+		t.Errorf("Config overrides not applied: %+v", llama)
+	}
+
+	qwen, err := registry.GetModel("ollama", "qwen2.5-coder")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	if qwen.ProviderID != "ollama" || !qwen.SupportsTools || qwen.ContextLength != 65536 || qwen.MaxOutputTokens != DefaultDiscoveredOutputTokens {
+		t.Errorf("Got discovered model %+v", qwen)
+	}
+
+	// Configured models are listed even when the server doesn't report them
+	if _, err := registry.GetModel("ollama", "offline"); err != nil {
+		t.Errorf("Configured model missing: %v", err)
+	}
+
+	// A second start uses the cache
+	if _, err := InitializeProviders(context.Background(), config); err != nil {
+		t.Fatalf("InitializeProviders failed: %v", err)
+	}
+	if n := server.listCount(); n != 1 {
+		t.Errorf("Models listed %d times, want 1", n)
+	}
+
+	// Refreshing bypasses it
+	server.setModels(`{"data":[{"id":"deepseek-r1"}]}`)
+	if err := registry.RefreshModels(context.Background(), "ollama"); err != nil {
+		t.Fatalf("RefreshModels failed: %v", err)
+	}
+	if _, err := registry.GetModel("ollama", "deepseek-r1"); err != nil {
+		t.Errorf("Refreshed model missing: %v", err)
+	}
+	if _, err := registry.GetModel("ollama", "qwen2.5-coder"); err == nil {
+		t.Error("Model no longer served should be gone after a refresh")
+	}
+}
+
+func TestCompatibleProvider_CompletionModel(t *testing.T) {
+	server := newCompatibleServer(t, `{"data":[{"id":"model-a"},{"id":"model-b"}]}`)
+	p, err := NewCompatibleProvider(context.Background(), &CompatibleConfig{
+		ID:      "local",
+		BaseURL: server.URL + "/v1",
+	})
+	if err != nil {
+		t.Fatalf("NewCompatibleProvider failed: %v", err)
+	}
+	if p.Name() != "local" || len(p.Models()) != 2 {
+		t.Errorf("Got provider %s with models %+v", p.Name(), p.Models())
+	}
+
+	stream, err := p.CreateCompletion(context.Background(), &CompletionRequest{
+		Model:    "model-b",
+		Messages: []*schema.Message{{Role: schema.User, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateCompletion failed: %v", err)
+	}
+	defer stream.Close()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+	}
+
+	if len(server.completed) != 1 || server.completed[0] != "model-b" {
+		t.Errorf("Completions requested models %v, want [model-b]", server.completed)
+	}
+}
+
+func TestModelDiscovery_StaleCache(t *testing.T) {
+	server := newCompatibleServer(t, `{"data":[{"id":"model-a"}]}`)
+	discovery := &ModelDiscovery{
+		ProviderID: "local",
+		BaseURL:    server.URL + "/v1",
+		CacheDir:   t.TempDir(),
+		TTL:        time.Nanosecond,
+	}
+	if _, err := discovery.Models(context.Background()); err != nil {
+		t.Fatalf("Models failed: %v", err)
+	}
+
+	// The server goes away and the cache expires
+	server.setModels("")
+	time.Sleep(time.Millisecond)
+
+	models, err := discovery.Models(context.Background())
+	if err == nil {
+		t.Error("Expected the failed request to be reported")
+	}
+	if len(models) != 1 || models[0].ID != "model-a" {
+		t.Errorf("Got models %+v, want the cached model-a", models)
+	}
+
+	// A cache for another server is not used
+	discovery.BaseURL = server.URL + "/other/v1"
+	if models, _ := discovery.Models(context.Background()); len(models) != 0 {
+		t.Errorf("Got models %+v from another server's cache", models)
+	}
+}
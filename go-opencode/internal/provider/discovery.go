@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// Defaults for discovered models, as OpenAI-compatible servers report little
+// more than model IDs. The models entries of a provider's config override them.
+const (
+	DefaultDiscoveredContextLength = 32768
+	DefaultDiscoveredOutputTokens  = 4096
+)
+
+// DefaultModelCacheTTL is how long discovered models are used before the
+// server is asked again.
+const DefaultModelCacheTTL = 24 * time.Hour
+
+// ModelDiscovery lists the models served by an OpenAI-compatible server, from
+// its /models endpoint, and caches them on disk.
+type ModelDiscovery struct {
+	ProviderID string
+	BaseURL    string // Including the /v1 prefix, as for completions
+	APIKey     string
+
+	// CacheDir holds a file of models per provider. Empty disables caching.
+	CacheDir string
+	TTL      time.Duration
+
+	HTTPClient *http.Client
+}
+
+// modelCache is the cached model list of a provider.
+type modelCache struct {
+	BaseURL   string            `json:"baseURL"`
+	FetchedAt time.Time         `json:"fetchedAt"`
+	Models    []discoveredModel `json:"models"`
+}
+
+// discoveredModel is an entry of a /models response. Servers that know the
+// context size of a model report it under different names.
+type discoveredModel struct {
+	ID            string `json:"id"`
+	OwnedBy       string `json:"owned_by,omitempty"`
+	MaxModelLen   int    `json:"max_model_len,omitempty"`  // vLLM
+	ContextLength int    `json:"context_length,omitempty"` // LM Studio, OpenRouter
+}
+
+// Models returns the models of the server, from the cache while it is fresh.
+// When the server can't be reached, a stale cache is returned along with the
+// error.
+func (d *ModelDiscovery) Models(ctx context.Context) ([]types.Model, error) {
+	cache := d.readCache()
+	if cache != nil && time.Since(cache.FetchedAt) < d.ttl() {
+		return d.toModels(cache.Models), nil
+	}
+
+	models, err := d.Refresh(ctx)
+	if err != nil && cache != nil {
+		return d.toModels(cache.Models), err
+	}
+	return models, err
+}
+
+// Refresh asks the server for its models and updates the cache.
+func (d *ModelDiscovery) Refresh(ctx context.Context) ([]types.Model, error) {
+	discovered, err := d.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.writeCache(&modelCache{
+		BaseURL:   d.BaseURL,
+		FetchedAt: time.Now(),
+		Models:    discovered,
+	})
+	return d.toModels(discovered), nil
+}
+
+// fetch lists the models of the server.
+func (d *ModelDiscovery) fetch(ctx context.Context) ([]discoveredModel, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(d.BaseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.APIKey)
+	}
+
+	client := d.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models of %s: %w", d.ProviderID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to list models of %s: %s: %s", d.ProviderID, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var list struct {
+		Data []discoveredModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid model list from %s: %w", d.ProviderID, err)
+	}
+	return list.Data, nil
+}
+
+// toModels builds the models of the provider, with the default limits where
+// the server doesn't report them.
+func (d *ModelDiscovery) toModels(discovered []discoveredModel) []types.Model {
+	models := make([]types.Model, 0, len(discovered))
+	for _, m := range discovered {
+		if m.ID == "" {
+			continue
+		}
+		contextLength := DefaultDiscoveredContextLength
+		if m.MaxModelLen > 0 {
+			contextLength = m.MaxModelLen
+		} else if m.ContextLength > 0 {
+			contextLength = m.ContextLength
+		}
+		models = append(models, discoveredModelDefaults(d.ProviderID, m.ID, contextLength))
+	}
+	return models
+}
+
+// discoveredModelDefaults returns a model of an OpenAI-compatible server with
+// the default capabilities.
+func discoveredModelDefaults(providerID, modelID string, contextLength int) types.Model {
+	return types.Model{
+		ID:              modelID,
+		Name:            modelID,
+		ProviderID:      providerID,
+		ContextLength:   contextLength,
+		MaxOutputTokens: min(DefaultDiscoveredOutputTokens, contextLength),
+		SupportsTools:   true,
+	}
+}
+
+func (d *ModelDiscovery) ttl() time.Duration {
+	if d.TTL > 0 {
+		return d.TTL
+	}
+	return DefaultModelCacheTTL
+}
+
+func (d *ModelDiscovery) cachePath() string {
+	return filepath.Join(d.CacheDir, d.ProviderID+".json")
+}
+
+// readCache returns the cached models, or nil if there are none for the
+// configured server.
+func (d *ModelDiscovery) readCache() *modelCache {
+	if d.CacheDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(d.cachePath())
+	if err != nil {
+		return nil
+	}
+	var cache modelCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.BaseURL != d.BaseURL {
+		return nil
+	}
+	return &cache
+}
+
+// writeCache saves the models. Failing to is not an error, the server is
+// asked again next time.
+func (d *ModelDiscovery) writeCache(cache *modelCache) {
+	if d.CacheDir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(d.CacheDir, 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(d.cachePath(), data, 0644)
+}
@@ -2,7 +2,7 @@
 //
 // This package implements a unified interface for different Large Language Model
 // providers using the Eino framework. It supports multiple providers including
-// Anthropic Claude, OpenAI GPT, and Volcengine ARK models.
+// Anthropic Claude, OpenAI GPT, Google Gemini and Volcengine ARK models.
 //
 // # Core Components
 //
@@ -35,6 +35,13 @@
 //     MaxTokens: 8192,
 //     })
 //
+// Models with the PromptCaching option get cache breakpoints on the last tool
+// definition, the system prompt and the last two conversation messages, so
+// that each step of a session reads the prefix the previous one wrote. The
+// cacheStrategy option of the provider narrows this to the tools and system
+// prompt (CacheStatic) or turns it off (CacheOff). Tokens written to the
+// cache are reported under ExtraCacheWriteTokens.
+//
 // ## OpenAI (GPT)
 //
 // Supports OpenAI models and OpenAI-compatible endpoints including:
@@ -62,6 +69,36 @@
 //	    MaxTokens: 4096,
 //	})
 //
+// ## Google (Gemini)
+//
+// Supports Gemini 2.x models through the Gemini API, with streaming, function
+// calling, image, audio, video and file inputs, and thinking. Thinking
+// tokens are reported apart from the output, under ExtraReasoningTokens:
+//
+//	provider, err := NewGeminiProvider(ctx, &GeminiConfig{
+//	    ID:        "google",
+//	    APIKey:    "...",
+//	    Model:     "gemini-2.5-flash",
+//	    MaxTokens: 8192,
+//	})
+//
+// ## OpenAI-Compatible Servers
+//
+// Providers configured with the @ai-sdk/openai-compatible npm package and a
+// baseURL, such as Ollama, vLLM or llama.cpp servers, list their models from
+// the server's /models endpoint. The list is cached under the cache directory
+// for a day, and RefreshModels asks the server again. Discovered models get
+// default limits and tool support, which the provider's models config
+// overrides:
+//
+//	"ollama": {
+//	    "npm": "@ai-sdk/openai-compatible",
+//	    "options": {"baseURL": "http://localhost:11434/v1"},
+//	    "models": {
+//	        "llama3.1:8b": {"toolcall": false, "limit": {"context": 8192}}
+//	    }
+//	}
+//
 // # Registry Usage
 //
 // The Registry manages all configured providers and provides unified access:
@@ -77,9 +114,13 @@
 //	// Get default model based on configuration
 //	model, err := registry.DefaultModel()
 //
-//	// List all available models across providers
+//	// List all available models across providers, best first
 //	models := registry.AllModels()
 //
+// The models of the Anthropic, OpenAI and Gemini providers, their limits,
+// prices and capabilities, come from the model catalog of package catalog,
+// which also ranks them for AllModels and DefaultModel.
+//
 // # Configuration
 //
 // Providers can be configured through:
@@ -96,6 +137,11 @@
 //	[provider.anthropic.options]
 //	apiKey = "sk-..."
 //
+// Providers without an API key use the credentials stored by "opencode auth
+// login" in auth.json. Those logged in with OAuth, at the endpoints of their
+// oauth options, send the access token as a bearer token through an
+// auth.Transport, which refreshes it before it expires.
+//
 // # Streaming Completions
 //
 // All providers support streaming chat completions through a unified interface:
@@ -124,7 +170,23 @@
 //	einoTools := ConvertToEinoTools(tools)
 //
 //	// Convert messages between formats
-//	einoMessages := ConvertToEinoMessages(messages, parts)
+//	einoMessages := ConvertToEinoMessages(messages, parts, model)
+//
+// ToolParams converts the JSON Schema of a tool's parameters, built-in or
+// from an MCP server, keeping nested objects, array items, enums, defaults
+// and descriptions. Local $ref are inlined, since not every provider follows
+// them, while oneOf, anyOf and additionalProperties are kept; Gemini gets
+// them rewritten to its OpenAPI subset.
+//
+// # Attachments
+//
+// UserMessage converts the file parts of a user message, and
+// AttachmentsMessage the files tools return such as images read by the read
+// tool, into Eino multi-content messages. Images go to models with
+// SupportsVision, downscaled to MaxImageDimension, and PDFs to models with
+// SupportsPDF. Text files are inlined. Any other file, or one over
+// MaxImageSize or MaxFileSize, is replaced by a note telling the model it
+// was not sent and why.
 //
 // # Error Handling
 //
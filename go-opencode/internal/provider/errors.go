@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	openai "github.com/meguminnnnnnnnn/go-openai"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// ErrorKind classifies a failed completion request, to decide whether to
+// retry it, switch to another model or give up.
+type ErrorKind string
+
+const (
+	// ErrorAuth is a missing or rejected credential.
+	ErrorAuth ErrorKind = "auth"
+	// ErrorRateLimit is a request refused for exceeding rate limits (429).
+	ErrorRateLimit ErrorKind = "rate_limit"
+	// ErrorOverloaded is a provider too busy to serve the request (529).
+	ErrorOverloaded ErrorKind = "overloaded"
+	// ErrorContextLength is a prompt too long for the model.
+	ErrorContextLength ErrorKind = "context_length"
+	// ErrorTransient is a server or network failure worth retrying, and any
+	// error that can't be classified.
+	ErrorTransient ErrorKind = "transient"
+	// ErrorInvalid is a request the provider rejects as malformed.
+	ErrorInvalid ErrorKind = "invalid"
+	// ErrorCanceled is a request canceled by its context.
+	ErrorCanceled ErrorKind = "canceled"
+)
+
+// Retryable reports whether the same request may succeed if sent again.
+func (k ErrorKind) Retryable() bool {
+	return k == ErrorRateLimit || k == ErrorOverloaded || k == ErrorTransient
+}
+
+// FailsOver reports whether another provider may serve the request.
+func (k ErrorKind) FailsOver() bool {
+	return k.Retryable() || k == ErrorAuth
+}
+
+// APIError is a completion error with its classification and, when the
+// provider sent one, the delay it asked for before retrying.
+type APIError struct {
+	Kind       ErrorKind
+	StatusCode int           // 0 when the request got no response
+	RetryAfter time.Duration // 0 when not given
+	Err        error
+}
+
+func (e *APIError) Error() string { return e.Err.Error() }
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// ClassifyError classifies an error returned by CreateCompletion or a
+// completion stream. The status code and Retry-After header are taken from
+// the SDK errors of the providers, and the message is matched otherwise.
+func ClassifyError(err error) *APIError {
+	if err == nil {
+		return nil
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	classified := &APIError{Err: err}
+	var header http.Header
+	var (
+		anthropicErr  *anthropic.Error
+		openaiErr     *openai.APIError
+		openaiReqErr  *openai.RequestError
+		arkErr        *arkmodel.APIError
+		arkReqErr     *arkmodel.RequestError
+		geminiErr     *geminiAPIError
+		netErr        net.Error
+		contextLength = isContextLengthMessage(err.Error())
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		classified.Kind = ErrorCanceled
+		return classified
+	case errors.As(err, &anthropicErr):
+		classified.StatusCode = anthropicErr.StatusCode
+		if anthropicErr.Response != nil {
+			header = anthropicErr.Response.Header
+		}
+	case errors.As(err, &openaiErr):
+		classified.StatusCode = openaiErr.HTTPStatusCode
+	case errors.As(err, &openaiReqErr):
+		classified.StatusCode = openaiReqErr.HTTPStatusCode
+	case errors.As(err, &arkErr):
+		classified.StatusCode = arkErr.HTTPStatusCode
+	case errors.As(err, &arkReqErr):
+		classified.StatusCode = arkReqErr.HTTPStatusCode
+	case errors.As(err, &geminiErr):
+		classified.StatusCode = geminiErr.Code
+		classified.RetryAfter = geminiErr.retryAfter
+	}
+	if header != nil {
+		classified.RetryAfter = retryAfter(header)
+	}
+
+	switch code := classified.StatusCode; {
+	case contextLength || code == http.StatusRequestEntityTooLarge:
+		classified.Kind = ErrorContextLength
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		classified.Kind = ErrorAuth
+	case code == http.StatusTooManyRequests:
+		classified.Kind = ErrorRateLimit
+	case code == 529 || (code == http.StatusServiceUnavailable && isOverloadedMessage(err.Error())):
+		classified.Kind = ErrorOverloaded
+	case code == http.StatusRequestTimeout || code == http.StatusConflict || code >= 500:
+		classified.Kind = ErrorTransient
+	case code >= 400:
+		classified.Kind = ErrorInvalid
+	default:
+		// No response, or an error event in the middle of a stream
+		classified.Kind = classifyMessage(err.Error())
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+			classified.Kind = ErrorTransient
+		}
+	}
+	return classified
+}
+
+// classifyMessage classifies an error without a status code by its message.
+func classifyMessage(msg string) ErrorKind {
+	lower := strings.ToLower(msg)
+	switch {
+	case isOverloadedMessage(msg):
+		return ErrorOverloaded
+	case strings.Contains(lower, "rate_limit") || strings.Contains(lower, "rate limit") ||
+		strings.Contains(lower, "too many requests") || strings.Contains(msg, "RESOURCE_EXHAUSTED"):
+		return ErrorRateLimit
+	case strings.Contains(lower, "authentication_error") || strings.Contains(lower, "invalid api key") ||
+		strings.Contains(lower, "invalid x-api-key") || strings.Contains(msg, "UNAUTHENTICATED") ||
+		strings.Contains(msg, "API_KEY_INVALID"):
+		return ErrorAuth
+	case strings.Contains(lower, "invalid_request_error") || strings.Contains(msg, "INVALID_ARGUMENT"):
+		return ErrorInvalid
+	default:
+		return ErrorTransient
+	}
+}
+
+func isOverloadedMessage(msg string) bool {
+	return strings.Contains(strings.ToLower(msg), "overloaded")
+}
+
+// isContextLengthMessage reports whether an error message says the prompt
+// is too long, as each provider words it.
+func isContextLengthMessage(msg string) bool {
+	lower := strings.ToLower(msg)
+	for _, s := range []string{
+		"prompt is too long",         // Anthropic
+		"context_length_exceeded",    // OpenAI
+		"maximum context length",     // OpenAI, vLLM
+		"exceeds the context window", // OpenAI
+		"input token count",          // Gemini
+	} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter returns the delay asked for by the retry-after-ms or
+// Retry-After headers, the latter in seconds or as an HTTP date.
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
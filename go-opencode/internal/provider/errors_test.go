@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/cloudwego/eino/schema"
+	openai "github.com/meguminnnnnnnnn/go-openai"
+)
+
+func TestClassifyError(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", nil)
+	anthropicErr := func(code int, header http.Header) error {
+		return fmt.Errorf("create new streaming message fail: %w", &anthropic.Error{
+			StatusCode: code,
+			Request:    req,
+			Response:   &http.Response{StatusCode: code, Header: header},
+		})
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		kind       ErrorKind
+		retryAfter time.Duration
+	}{
+		{"anthropic rate limit", anthropicErr(429, http.Header{"Retry-After": {"12"}}), ErrorRateLimit, 12 * time.Second},
+		{"anthropic overloaded", anthropicErr(529, http.Header{"Retry-After-Ms": {"1500"}}), ErrorOverloaded, 1500 * time.Millisecond},
+		{"anthropic auth", anthropicErr(401, nil), ErrorAuth, 0},
+		{"anthropic server error", anthropicErr(500, nil), ErrorTransient, 0},
+		{"openai rate limit", fmt.Errorf("failed to create stream: %w", &openai.APIError{HTTPStatusCode: 429, Message: "Rate limit reached"}), ErrorRateLimit, 0},
+		{"openai context length", &openai.APIError{HTTPStatusCode: 400, Message: "This model's maximum context length is 128000 tokens", Code: "context_length_exceeded"}, ErrorContextLength, 0},
+		{"openai bad request", &openai.APIError{HTTPStatusCode: 400, Message: "Invalid schema"}, ErrorInvalid, 0},
+		{"openai gateway", &openai.RequestError{HTTPStatusCode: 502, Err: errors.New("bad gateway")}, ErrorTransient, 0},
+		{"stream overloaded", errors.New(`received error while streaming: {"type":"overloaded_error","message":"Overloaded"}`), ErrorOverloaded, 0},
+		{"stream context length", errors.New("prompt is too long: 210000 tokens > 200000 maximum"), ErrorContextLength, 0},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrorTransient, 0},
+		{"canceled", fmt.Errorf("failed: %w", context.Canceled), ErrorCanceled, 0},
+		{"unknown", errors.New("something broke"), ErrorTransient, 0},
+	}
+	for _, tt := range tests {
+		got := ClassifyError(tt.err)
+		if got.Kind != tt.kind || got.RetryAfter != tt.retryAfter {
+			t.Errorf("%s: got %s after %v, want %s after %v", tt.name, got.Kind, got.RetryAfter, tt.kind, tt.retryAfter)
+		}
+		if !errors.Is(got, tt.err) {
+			t.Errorf("%s: the classified error should wrap the original", tt.name)
+		}
+	}
+}
+
+func TestClassifyError_Gemini(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"code":503,"message":"The model is overloaded.","status":"UNAVAILABLE"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGemini(t, server.URL).CreateCompletion(context.Background(), &CompletionRequest{
+		Messages: []*schema.Message{{Role: schema.User, Content: "hi"}},
+	})
+	got := ClassifyError(err)
+	if got.Kind != ErrorOverloaded || got.StatusCode != 503 || got.RetryAfter != 7*time.Second {
+		t.Errorf("Got %+v", got)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	if !ErrorAuth.FailsOver() || ErrorAuth.Retryable() {
+		t.Error("Auth errors fail over without retries")
+	}
+	if ErrorContextLength.FailsOver() || ErrorInvalid.FailsOver() || ErrorCanceled.FailsOver() {
+		t.Error("Errors of the request itself don't fail over")
+	}
+}
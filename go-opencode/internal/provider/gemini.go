@@ -0,0 +1,753 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/oklog/ulid/v2"
+
+	"github.com/opencode-ai/opencode/internal/catalog"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// geminiBaseURL is the Gemini API endpoint used unless one is configured.
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiProvider implements Provider for Google Gemini models.
+type GeminiProvider struct {
+	chatModel *geminiChatModel
+	models    []types.Model
+	config    *GeminiConfig
+}
+
+// GeminiConfig holds configuration for Gemini provider.
+type GeminiConfig struct {
+	// ID is the provider identifier (e.g., "google", "gemini")
+	// If empty, defaults to "google"
+	ID        string
+	APIKey    string
+	BaseURL   string
+	Model     string // Model ID (e.g., "gemini-2.5-pro", "gemini-2.5-flash")
+	MaxTokens int
+
+	// ThinkingBudget caps the thinking tokens of models that reason. Nil
+	// lets the model decide; 0 turns thinking off where the model allows it.
+	ThinkingBudget *int
+
+	// HTTPClient sends the API requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// NewGeminiProvider creates a new Gemini provider.
+func NewGeminiProvider(ctx context.Context, config *GeminiConfig) (*GeminiProvider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("GEMINI_API_KEY")
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("GOOGLE_GENERATIVE_AI_API_KEY")
+	}
+
+	if apiKey == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY not set")
+	}
+
+	modelID := config.Model
+	if modelID == "" {
+		modelID = "gemini-2.5-flash"
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = geminiBaseURL
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	p := &GeminiProvider{
+		chatModel: &geminiChatModel{
+			client:         client,
+			baseURL:        strings.TrimSuffix(baseURL, "/"),
+			apiKey:         apiKey,
+			model:          modelID,
+			maxTokens:      config.MaxTokens,
+			thinkingBudget: config.ThinkingBudget,
+		},
+		config: config,
+	}
+	p.models = geminiModels(p.ID())
+	return p, nil
+}
+
+// ID returns the provider identifier.
+func (p *GeminiProvider) ID() string {
+	if p.config.ID != "" {
+		return p.config.ID
+	}
+	return "google"
+}
+
+// Name returns the human-readable provider name.
+func (p *GeminiProvider) Name() string { return "Google" }
+
+// Models returns the list of available models.
+func (p *GeminiProvider) Models() []types.Model {
+	return p.models
+}
+
+// ChatModel returns the Eino ChatModel.
+func (p *GeminiProvider) ChatModel() model.ToolCallingChatModel {
+	return p.chatModel
+}
+
+// CreateCompletion creates a streaming completion.
+func (p *GeminiProvider) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionStream, error) {
+	// Bind tools if provided
+	var chatModel model.ToolCallingChatModel = p.chatModel
+	if len(req.Tools) > 0 {
+		var err error
+		chatModel, err = chatModel.WithTools(req.Tools)
+		if err != nil {
+			return nil, fmt.Errorf("failed to bind tools: %w", err)
+		}
+	}
+
+	opts := []model.Option{}
+	if req.Model != "" {
+		opts = append(opts, model.WithModel(req.Model))
+	}
+	if req.MaxTokens > 0 {
+		opts = append(opts, model.WithMaxTokens(req.MaxTokens))
+	}
+	if req.Temperature > 0 {
+		opts = append(opts, model.WithTemperature(float32(req.Temperature)))
+	}
+	if req.TopP > 0 {
+		opts = append(opts, model.WithTopP(float32(req.TopP)))
+	}
+	if len(req.StopWords) > 0 {
+		opts = append(opts, model.WithStop(req.StopWords))
+	}
+
+	// Create streaming request
+	stream, err := chatModel.Stream(ctx, req.Messages, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	return NewCompletionStream(stream), nil
+}
+
+// geminiChatModel is an Eino ChatModel calling the Gemini generateContent API.
+type geminiChatModel struct {
+	client         *http.Client
+	baseURL        string
+	apiKey         string
+	model          string
+	maxTokens      int
+	thinkingBudget *int
+	tools          []*schema.ToolInfo
+}
+
+// WithTools returns a copy of the model with tools bound.
+func (m *geminiChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := *m
+	bound.tools = tools
+	return &bound, nil
+}
+
+// Generate sends a request and returns the whole answer.
+func (m *geminiChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	stream, err := m.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.ConcatMessageStream(stream)
+}
+
+// Stream sends a request and streams the answer as it is generated.
+func (m *geminiChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	options := model.GetCommonOptions(&model.Options{Model: &m.model}, opts...)
+	tools := m.tools
+	if options.Tools != nil {
+		tools = options.Tools
+	}
+
+	reqBody, err := m.buildRequest(input, tools, options)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", m.baseURL, *options.Model)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", m.apiKey)
+
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, geminiError(resp)
+	}
+
+	reader, writer := schema.Pipe[*schema.Message](8)
+	go func() {
+		defer resp.Body.Close()
+		defer writer.Close()
+
+		if err := readGeminiStream(resp.Body, writer); err != nil {
+			writer.Send(nil, err)
+		}
+	}()
+	return reader, nil
+}
+
+// buildRequest converts Eino messages and tools into a Gemini request.
+func (m *geminiChatModel) buildRequest(input []*schema.Message, tools []*schema.ToolInfo, options *model.Options) (*geminiRequest, error) {
+	req := &geminiRequest{
+		Contents:         geminiContents(input),
+		GenerationConfig: &geminiGenerationConfig{},
+	}
+
+	var system []geminiPart
+	for _, msg := range input {
+		if msg.Role == schema.System && msg.Content != "" {
+			system = append(system, geminiPart{Text: msg.Content})
+		}
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &geminiContent{Parts: system}
+	}
+
+	if len(tools) > 0 {
+		declarations := make([]geminiFunctionDeclaration, len(tools))
+		for i, tool := range tools {
+			params, err := geminiParameters(tool)
+			if err != nil {
+				return nil, fmt.Errorf("tool %s: %w", tool.Name, err)
+			}
+			declarations[i] = geminiFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Desc,
+				Parameters:  params,
+			}
+		}
+		req.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+	}
+
+	cfg := req.GenerationConfig
+	cfg.MaxOutputTokens = m.maxTokens
+	if options.MaxTokens != nil && *options.MaxTokens > 0 {
+		cfg.MaxOutputTokens = *options.MaxTokens
+	}
+	cfg.Temperature = options.Temperature
+	cfg.TopP = options.TopP
+	cfg.StopSequences = options.Stop
+	if geminiThinks(*options.Model) {
+		cfg.ThinkingConfig = &geminiThinkingConfig{
+			IncludeThoughts: m.thinkingBudget == nil || *m.thinkingBudget != 0,
+			ThinkingBudget:  m.thinkingBudget,
+		}
+	}
+
+	return req, nil
+}
+
+// geminiContents converts Eino messages into Gemini contents. System
+// messages go to the system instruction; tool results are sent by the user,
+// and consecutive contents of the same role are merged, as Gemini expects
+// the parallel results of a step in one content.
+func geminiContents(input []*schema.Message) []geminiContent {
+	var contents []geminiContent
+	toolNames := make(map[string]string) // Call ID to function name
+
+	add := func(role string, parts []geminiPart) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	for _, msg := range input {
+		switch msg.Role {
+		case schema.System:
+			continue
+
+		case schema.Assistant:
+			var parts []geminiPart
+			if msg.Content != "" {
+				parts = append(parts, geminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				args := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{
+					Name: call.Function.Name,
+					Args: args,
+				}})
+			}
+			add("model", parts)
+
+		case schema.Tool:
+			name := msg.ToolName
+			if name == "" {
+				name = toolNames[msg.ToolCallID]
+			}
+			add("user", []geminiPart{{FunctionResponse: &geminiFunctionResponse{
+				Name:     name,
+				Response: map[string]any{"content": msg.Content},
+			}}})
+
+		default:
+			add("user", geminiUserParts(msg))
+		}
+	}
+	return contents
+}
+
+// geminiUserParts converts the text and media of a user message.
+func geminiUserParts(msg *schema.Message) []geminiPart {
+	if len(msg.UserInputMultiContent) == 0 {
+		if msg.Content == "" {
+			return nil
+		}
+		return []geminiPart{{Text: msg.Content}}
+	}
+
+	var parts []geminiPart
+	for _, in := range msg.UserInputMultiContent {
+		var media *schema.MessagePartCommon
+		switch in.Type {
+		case schema.ChatMessagePartTypeText:
+			if in.Text != "" {
+				parts = append(parts, geminiPart{Text: in.Text})
+			}
+			continue
+		case schema.ChatMessagePartTypeImageURL:
+			if in.Image != nil {
+				media = &in.Image.MessagePartCommon
+			}
+		case schema.ChatMessagePartTypeAudioURL:
+			if in.Audio != nil {
+				media = &in.Audio.MessagePartCommon
+			}
+		case schema.ChatMessagePartTypeVideoURL:
+			if in.Video != nil {
+				media = &in.Video.MessagePartCommon
+			}
+		case schema.ChatMessagePartTypeFileURL:
+			if in.File != nil {
+				media = &in.File.MessagePartCommon
+			}
+		}
+		if part, ok := geminiMediaPart(media); ok {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// geminiMediaPart converts media given as base64 data, a data URL or a file
+// URL.
+func geminiMediaPart(media *schema.MessagePartCommon) (geminiPart, bool) {
+	if media == nil {
+		return geminiPart{}, false
+	}
+	if media.Base64Data != nil {
+		return geminiPart{InlineData: &geminiBlob{MimeType: media.MIMEType, Data: *media.Base64Data}}, true
+	}
+	if media.URL == nil {
+		return geminiPart{}, false
+	}
+
+	url := *media.URL
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		// data:<mime>;base64,<data>
+		header, data, ok := strings.Cut(rest, ",")
+		if !ok || !strings.HasSuffix(header, ";base64") {
+			return geminiPart{}, false
+		}
+		mimeType := strings.TrimSuffix(header, ";base64")
+		if mimeType == "" {
+			mimeType = media.MIMEType
+		}
+		return geminiPart{InlineData: &geminiBlob{MimeType: mimeType, Data: data}}, true
+	}
+	return geminiPart{FileData: &geminiFileData{MimeType: media.MIMEType, FileURI: url}}, true
+}
+
+// geminiParameters returns the parameters of a tool as the OpenAPI schema
+// subset Gemini accepts.
+func geminiParameters(tool *schema.ToolInfo) (map[string]any, error) {
+	if tool.ParamsOneOf == nil {
+		return nil, nil
+	}
+	js, err := tool.ParamsOneOf.ToJSONSchema()
+	if err != nil || js == nil {
+		return nil, err
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]any
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	return geminiSchema(params), nil
+}
+
+// geminiSchemaKeys are the schema keywords Gemini accepts; it rejects
+// requests with others such as "$schema" or "additionalProperties".
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "title": true, "description": true,
+	"nullable": true, "enum": true, "properties": true, "required": true,
+	"items": true, "minItems": true, "maxItems": true, "minimum": true,
+	"maximum": true, "minLength": true, "maxLength": true, "pattern": true,
+	"anyOf": true, "propertyOrdering": true, "default": true,
+}
+
+// geminiSchema drops the keywords Gemini does not accept from a schema, and
+// rewrites those it accepts in another form: a type union becomes nullable,
+// or no type when it lists several, oneOf becomes anyOf and const an enum.
+func geminiSchema(s map[string]any) map[string]any {
+	out := make(map[string]any, len(s))
+	for key, value := range s {
+		switch key {
+		case "type":
+			types, ok := value.([]any)
+			if !ok {
+				break
+			}
+			value = nil
+			var nonNull []any
+			for _, t := range types {
+				if t == "null" {
+					out["nullable"] = true
+				} else {
+					nonNull = append(nonNull, t)
+				}
+			}
+			if len(nonNull) == 1 {
+				value = nonNull[0]
+			}
+		case "oneOf":
+			key = "anyOf"
+		case "const":
+			key = "enum"
+			value = []any{value}
+		}
+		if !geminiSchemaKeys[key] || value == nil {
+			continue
+		}
+		switch key {
+		case "properties":
+			if props, ok := value.(map[string]any); ok {
+				cleaned := make(map[string]any, len(props))
+				for name, prop := range props {
+					if sub, ok := prop.(map[string]any); ok {
+						cleaned[name] = geminiSchema(sub)
+					}
+				}
+				value = cleaned
+			}
+		case "items":
+			if sub, ok := value.(map[string]any); ok {
+				value = geminiSchema(sub)
+			}
+		case "anyOf":
+			if subs, ok := value.([]any); ok {
+				cleaned := make([]any, 0, len(subs))
+				for _, item := range subs {
+					sub, ok := item.(map[string]any)
+					if !ok {
+						continue
+					}
+					if sub["type"] == "null" {
+						out["nullable"] = true
+						continue
+					}
+					cleaned = append(cleaned, geminiSchema(sub))
+				}
+				value = cleaned
+			}
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// readGeminiStream reads the server-sent events of a streamGenerateContent
+// response and sends each as a message chunk.
+func readGeminiStream(body io.Reader, writer *schema.StreamWriter[*schema.Message]) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	calls := 0
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" {
+			continue
+		}
+
+		var resp geminiResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			return fmt.Errorf("invalid Gemini stream event: %w", err)
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		msg, err := geminiMessage(&resp, &calls)
+		if err != nil {
+			return err
+		}
+		if writer.Send(msg, nil) {
+			return nil // Closed by the reader
+		}
+	}
+	return scanner.Err()
+}
+
+// geminiMessage converts a streamed response into a message chunk. calls
+// counts the function calls of the stream so far, to index them.
+func geminiMessage(resp *geminiResponse, calls *int) (*schema.Message, error) {
+	msg := &schema.Message{Role: schema.Assistant}
+
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("prompt blocked by Gemini: %s", resp.PromptFeedback.BlockReason)
+		}
+	} else {
+		candidate := resp.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				index := *calls
+				*calls++
+				id := part.FunctionCall.ID
+				if id == "" {
+					id = "call_" + strings.ToLower(ulid.Make().String())
+				}
+				args := string(part.FunctionCall.Args)
+				if args == "" || args == "null" {
+					args = "{}"
+				}
+				msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+					Index: &index,
+					ID:    id,
+					Type:  "function",
+					Function: schema.FunctionCall{
+						Name:      part.FunctionCall.Name,
+						Arguments: args,
+					},
+				})
+			case part.Thought:
+				msg.ReasoningContent += part.Text
+			default:
+				msg.Content += part.Text
+			}
+		}
+
+		if reason := candidate.FinishReason; reason != "" {
+			msg.ResponseMeta = &schema.ResponseMeta{FinishReason: geminiFinishReason(reason, *calls > 0)}
+		}
+	}
+
+	if usage := resp.UsageMetadata; usage != nil {
+		if msg.ResponseMeta == nil {
+			msg.ResponseMeta = &schema.ResponseMeta{}
+		}
+		// Gemini bills thinking as output but counts it apart
+		msg.ResponseMeta.Usage = &schema.TokenUsage{
+			PromptTokens:       usage.PromptTokenCount,
+			PromptTokenDetails: schema.PromptTokenDetails{CachedTokens: usage.CachedContentTokenCount},
+			CompletionTokens:   usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+			TotalTokens:        usage.TotalTokenCount,
+		}
+		if usage.ThoughtsTokenCount > 0 {
+			msg.Extra = map[string]any{ExtraReasoningTokens: usage.ThoughtsTokenCount}
+		}
+	}
+
+	return msg, nil
+}
+
+// geminiFinishReason maps a Gemini finish reason to the ones the session loop
+// knows.
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "STOP":
+		if toolCalls {
+			return "tool-calls"
+		}
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content-filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// geminiThinks reports whether a model reasons, and so takes a thinking
+// configuration.
+func geminiThinks(modelID string) bool {
+	return strings.HasPrefix(modelID, "gemini-2.5") || strings.HasPrefix(modelID, "gemini-3")
+}
+
+// geminiError returns the error of a failed request.
+func geminiError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var body struct {
+		Error *geminiAPIError `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == nil {
+		body.Error = &geminiAPIError{Status: http.StatusText(resp.StatusCode), Message: strings.TrimSpace(string(data))}
+	}
+	body.Error.Code = resp.StatusCode
+	body.Error.retryAfter = retryAfter(resp.Header)
+	return body.Error
+}
+
+// Gemini API types
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FileData         *geminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // Base64
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	Temperature     *float32              `json:"temperature,omitempty"`
+	TopP            *float32              `json:"topP,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates     []geminiCandidate `json:"candidates"`
+	UsageMetadata  *geminiUsage      `json:"usageMetadata,omitempty"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason,omitempty"`
+	} `json:"promptFeedback,omitempty"`
+	Error *geminiAPIError `json:"error,omitempty"`
+}
+
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+}
+
+type geminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// geminiAPIError is the error body of the Gemini API.
+type geminiAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+
+	retryAfter time.Duration
+}
+
+func (e *geminiAPIError) Error() string {
+	return fmt.Sprintf("gemini: %d %s: %s", e.Code, e.Status, e.Message)
+}
+
+// geminiModels returns the Google models of the catalog.
+func geminiModels(providerID string) []types.Model {
+	return catalog.Default().Models("google", providerID)
+}
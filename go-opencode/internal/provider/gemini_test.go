@@ -0,0 +1,282 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// geminiServer serves the given events for every streamGenerateContent
+// request, and passes each decoded request to inspect.
+func geminiServer(t *testing.T, events []string, inspect func(r *http.Request, req map[string]any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		if inspect != nil {
+			inspect(r, req)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", event)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestGemini(t *testing.T, baseURL string) *GeminiProvider {
+	t.Helper()
+	p, err := NewGeminiProvider(context.Background(), &GeminiConfig{
+		APIKey:    "test-key",
+		BaseURL:   baseURL,
+		MaxTokens: 1024,
+	})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	return p
+}
+
+func TestGeminiProvider_Stream(t *testing.T) {
+	events := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Looking at ","thought":true}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"the file","thought":true}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"I'll read it."}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"read","args":{"file_path":"main.go"}}}]},"finishReason":"STOP"}],` +
+			`"usageMetadata":{"promptTokenCount":120,"candidatesTokenCount":30,"thoughtsTokenCount":50,"cachedContentTokenCount":100,"totalTokenCount":200}}`,
+	}
+
+	var gotPath, gotKey string
+	var gotReq map[string]any
+	server := geminiServer(t, events, func(r *http.Request, req map[string]any) {
+		gotPath = r.URL.String()
+		gotKey = r.Header.Get("x-goog-api-key")
+		gotReq = req
+	})
+	p := newTestGemini(t, server.URL)
+
+	params := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           orderedmap.New[string, *jsonschema.Schema](),
+		Required:             []string{"file_path"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+	params.Properties.Set("file_path", &jsonschema.Schema{Type: "string", Description: "Path"})
+
+	stream, err := p.CreateCompletion(context.Background(), &CompletionRequest{
+		Model: "gemini-2.5-pro",
+		Messages: []*schema.Message{
+			{Role: schema.System, Content: "You are a coding agent."},
+			{Role: schema.User, Content: "Read main.go"},
+		},
+		Tools: []*schema.ToolInfo{{
+			Name:        "read",
+			Desc:        "Read a file",
+			ParamsOneOf: schema.NewParamsOneOfByJSONSchema(params),
+		}},
+		MaxTokens: 2048,
+	})
+	if err != nil {
+		t.Fatalf("CreateCompletion failed: %v", err)
+	}
+	defer stream.Close()
+
+	var chunks []*schema.Message
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		chunks = append(chunks, msg)
+	}
+
+	if gotPath != "/models/gemini-2.5-pro:streamGenerateContent?alt=sse" {
+		t.Errorf("Got path %q", gotPath)
+	}
+	if gotKey != "test-key" {
+		t.Errorf("Got API key %q, want test-key", gotKey)
+	}
+
+	// The request
+	system := gotReq["systemInstruction"].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	if system["text"] != "You are a coding agent." {
+		t.Errorf("Got system instruction %v", system)
+	}
+	if contents := gotReq["contents"].([]any); len(contents) != 1 {
+		t.Errorf("Got %d contents, want 1 without the system message", len(contents))
+	}
+	config := gotReq["generationConfig"].(map[string]any)
+	if config["maxOutputTokens"] != float64(2048) {
+		t.Errorf("Got maxOutputTokens %v, want 2048", config["maxOutputTokens"])
+	}
+	if thinking, ok := config["thinkingConfig"].(map[string]any); !ok || thinking["includeThoughts"] != true {
+		t.Errorf("Expected thoughts to be included, got %v", config["thinkingConfig"])
+	}
+	declaration := gotReq["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)[0].(map[string]any)
+	parameters := declaration["parameters"].(map[string]any)
+	if _, ok := parameters["additionalProperties"]; ok {
+		t.Error("additionalProperties should be left out of the parameters")
+	}
+	if parameters["properties"].(map[string]any)["file_path"].(map[string]any)["type"] != "string" {
+		t.Errorf("Got parameters %v", parameters)
+	}
+
+	// The answer
+	var reasoning, content string
+	for _, chunk := range chunks {
+		reasoning += chunk.ReasoningContent
+		content += chunk.Content
+	}
+	if reasoning != "Looking at the file" {
+		t.Errorf("Got reasoning %q", reasoning)
+	}
+	if content != "I'll read it." {
+		t.Errorf("Got content %q", content)
+	}
+
+	last := chunks[len(chunks)-1]
+	if len(last.ToolCalls) != 1 {
+		t.Fatalf("Got %d tool calls, want 1", len(last.ToolCalls))
+	}
+	call := last.ToolCalls[0]
+	if call.ID == "" || call.Index == nil || *call.Index != 0 {
+		t.Errorf("Tool call needs an ID and index: %+v", call)
+	}
+	if call.Function.Name != "read" || call.Function.Arguments != `{"file_path":"main.go"}` {
+		t.Errorf("Got tool call %+v", call.Function)
+	}
+	if last.ResponseMeta.FinishReason != "tool-calls" {
+		t.Errorf("Got finish reason %q, want tool-calls", last.ResponseMeta.FinishReason)
+	}
+	usage := last.ResponseMeta.Usage
+	if usage.PromptTokens != 120 || usage.CompletionTokens != 80 || usage.PromptTokenDetails.CachedTokens != 100 {
+		t.Errorf("Got usage %+v", usage)
+	}
+	if n := ReasoningTokens(last); n != 50 {
+		t.Errorf("Got %d reasoning tokens, want 50", n)
+	}
+}
+
+func TestGeminiContents(t *testing.T) {
+	image := "data:image/png;base64,iVBORw0KGgo="
+	index := 0
+	contents := geminiContents([]*schema.Message{
+		{Role: schema.System, Content: "system"},
+		{Role: schema.User, UserInputMultiContent: []schema.MessageInputPart{
+			{Type: schema.ChatMessagePartTypeText, Text: "What is this?"},
+			{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{
+				MessagePartCommon: schema.MessagePartCommon{URL: &image},
+			}},
+		}},
+		{Role: schema.Assistant, ToolCalls: []schema.ToolCall{
+			{Index: &index, ID: "call_1", Function: schema.FunctionCall{Name: "read", Arguments: `{"file_path":"a.png"}`}},
+			{ID: "call_2", Function: schema.FunctionCall{Name: "glob", Arguments: ""}},
+		}},
+		{Role: schema.Tool, ToolCallID: "call_1", Content: "a picture"},
+		{Role: schema.Tool, ToolCallID: "call_2", Content: "a.png"},
+		{Role: schema.Assistant, Content: "It is a picture."},
+	})
+
+	if len(contents) != 4 {
+		t.Fatalf("Got %d contents, want 4: %+v", len(contents), contents)
+	}
+	roles := []string{"user", "model", "user", "model"}
+	for i, role := range roles {
+		if contents[i].Role != role {
+			t.Errorf("Content %d has role %q, want %q", i, contents[i].Role, role)
+		}
+	}
+
+	user := contents[0].Parts
+	if len(user) != 2 || user[0].Text != "What is this?" || user[1].InlineData == nil {
+		t.Fatalf("Got user parts %+v", user)
+	}
+	if user[1].InlineData.MimeType != "image/png" || user[1].InlineData.Data != "iVBORw0KGgo=" {
+		t.Errorf("Got inline data %+v", user[1].InlineData)
+	}
+
+	calls := contents[1].Parts
+	if len(calls) != 2 || calls[0].FunctionCall.Name != "read" || string(calls[1].FunctionCall.Args) != "{}" {
+		t.Errorf("Got function calls %+v", calls)
+	}
+
+	// Parallel results share one content, named after their calls
+	results := contents[2].Parts
+	if len(results) != 2 || results[0].FunctionResponse.Name != "read" || results[1].FunctionResponse.Name != "glob" {
+		t.Fatalf("Got function responses %+v", results)
+	}
+	if results[1].FunctionResponse.Response["content"] != "a.png" {
+		t.Errorf("Got response %v", results[1].FunctionResponse.Response)
+	}
+}
+
+func TestGeminiProvider_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`))
+	}))
+	defer server.Close()
+
+	p := newTestGemini(t, server.URL)
+	_, err := p.CreateCompletion(context.Background(), &CompletionRequest{
+		Messages: []*schema.Message{{Role: schema.User, Content: "hi"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "RESOURCE_EXHAUSTED") || !strings.Contains(err.Error(), "Quota exceeded") {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestGeminiFinishReason(t *testing.T) {
+	tests := []struct {
+		reason    string
+		toolCalls bool
+		want      string
+	}{
+		{"STOP", false, "stop"},
+		{"STOP", true, "tool-calls"},
+		{"MAX_TOKENS", false, "length"},
+		{"SAFETY", false, "content-filter"},
+		{"MALFORMED_FUNCTION_CALL", false, "malformed_function_call"},
+	}
+	for _, tt := range tests {
+		if got := geminiFinishReason(tt.reason, tt.toolCalls); got != tt.want {
+			t.Errorf("geminiFinishReason(%q, %v) = %q, want %q", tt.reason, tt.toolCalls, got, tt.want)
+		}
+	}
+}
+
+func TestInitializeProviders_Gemini(t *testing.T) {
+	config := &types.Config{
+		Provider: map[string]types.ProviderConfig{
+			"google": {Options: &types.ProviderOptions{APIKey: "test-key"}},
+		},
+	}
+	registry, err := InitializeProviders(context.Background(), config)
+	if err != nil {
+		t.Fatalf("InitializeProviders failed: %v", err)
+	}
+	model, err := registry.GetModel("google", "gemini-2.5-flash")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	if !model.SupportsReasoning || model.ProviderID != "google" {
+		t.Errorf("Got model %+v", model)
+	}
+}
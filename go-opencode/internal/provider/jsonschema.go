@@ -0,0 +1,380 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// anyType is the type of a schema that accepts any value.
+var anyType = []string{"string", "number", "boolean", "object", "array", "null"}
+
+// ToolParams converts the JSON Schema of a tool's parameters to Eino's
+// parameters. The whole schema is kept: nested objects, array items, enums,
+// defaults and descriptions at every level. A schema that can't be parsed
+// gives a tool without parameters.
+func ToolParams(schemaJSON json.RawMessage) *schema.ParamsOneOf {
+	s, err := ConvertJSONSchema(schemaJSON)
+	if err != nil {
+		s = &jsonschema.Schema{Type: "object", Properties: jsonschema.NewProperties()}
+	}
+	return schema.NewParamsOneOfByJSONSchema(s)
+}
+
+// ConvertJSONSchema parses the JSON Schema of a tool's parameters into the
+// form providers accept:
+//
+//   - local $ref are inlined, from $defs, draft-07 definitions or any JSON
+//     pointer into the schema, and recursive ones are inlined once
+//   - allOf branches constraining the same object are merged
+//   - schemas leaving out their type get the one their keywords imply
+//   - draft-04 items arrays and boolean exclusive bounds are upgraded
+//
+// oneOf, anyOf and additionalProperties are kept as they are.
+func ConvertJSONSchema(schemaJSON json.RawMessage) (*jsonschema.Schema, error) {
+	if len(bytes.TrimSpace(schemaJSON)) == 0 {
+		return &jsonschema.Schema{Type: "object", Properties: jsonschema.NewProperties()}, nil
+	}
+	root, err := decodeSchema(schemaJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	if isBoolSchema(root) {
+		root = &jsonschema.Schema{}
+	}
+	if root.Type == "" && root.TypeEnhanced == nil {
+		root.Type = "object"
+	}
+	if root.Type == "object" && root.Properties == nil {
+		root.Properties = jsonschema.NewProperties()
+	}
+
+	r := &schemaResolver{root: schemaJSON}
+	return r.resolve(root, []string{"#"}), nil
+}
+
+// decodeSchema decodes a JSON Schema, upgrading the draft-04 keywords that
+// don't decode as 2020-12 ones.
+func decodeSchema(data []byte) (*jsonschema.Schema, error) {
+	s := &jsonschema.Schema{}
+	err := json.Unmarshal(data, s)
+	if err == nil {
+		return s, nil
+	}
+
+	// The order of properties is lost on this path, which only schemas
+	// written for draft-04 take
+	var v any
+	if json.Unmarshal(data, &v) != nil {
+		return nil, err
+	}
+	upgraded, merr := json.Marshal(upgradeDraft4(v))
+	if merr != nil {
+		return nil, err
+	}
+	s = &jsonschema.Schema{}
+	if json.Unmarshal(upgraded, s) != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// upgradeDraft4 rewrites items arrays to prefixItems, and boolean
+// exclusiveMinimum and exclusiveMaximum to the numeric bounds of later drafts.
+func upgradeDraft4(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, sub := range v {
+			switch key {
+			case "enum", "const", "default", "examples":
+			default:
+				v[key] = upgradeDraft4(sub)
+			}
+		}
+		if items, ok := v["items"].([]any); ok {
+			v["prefixItems"] = items
+			delete(v, "items")
+		}
+		for exclusive, inclusive := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+			if b, ok := v[exclusive].(bool); ok {
+				delete(v, exclusive)
+				if limit, ok := v[inclusive]; ok && b {
+					v[exclusive] = limit
+					delete(v, inclusive)
+				}
+			}
+		}
+	case []any:
+		for i, sub := range v {
+			v[i] = upgradeDraft4(sub)
+		}
+	}
+	return v
+}
+
+// schemaResolver inlines the references of a schema.
+type schemaResolver struct {
+	root json.RawMessage
+}
+
+// resolve rewrites s in the form providers accept. refs are the references
+// being inlined, to cut recursive schemas.
+func (r *schemaResolver) resolve(s *jsonschema.Schema, refs []string) *jsonschema.Schema {
+	if s == nil || isBoolSchema(s) {
+		return s
+	}
+	if s.Ref != "" {
+		target, err := r.lookup(s.Ref)
+		switch {
+		case err != nil:
+			// Left open, as a remote or broken reference can't be followed
+			target = &jsonschema.Schema{}
+		case slices.Contains(refs, s.Ref):
+			// A recursive schema stops at its second occurrence
+			inferType(target)
+			target = &jsonschema.Schema{
+				Type:         target.Type,
+				TypeEnhanced: target.TypeEnhanced,
+				Title:        target.Title,
+				Description:  target.Description,
+			}
+		default:
+			refs = append(slices.Clip(refs), s.Ref)
+		}
+		annotate(target, s)
+		s = target
+	}
+	s.Version = ""
+	s.ID = ""
+	s.Anchor = ""
+	s.Ref = ""
+	s.DynamicRef = ""
+	s.Definitions = nil
+	s.Comments = ""
+
+	if s.Properties != nil {
+		for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			pair.Value = r.resolve(pair.Value, refs)
+		}
+	}
+	for _, subs := range [][]*jsonschema.Schema{s.AllOf, s.AnyOf, s.OneOf, s.PrefixItems} {
+		for i := range subs {
+			subs[i] = r.resolve(subs[i], refs)
+		}
+	}
+	for _, subs := range []map[string]*jsonschema.Schema{s.PatternProperties, s.DependentSchemas} {
+		for key := range subs {
+			subs[key] = r.resolve(subs[key], refs)
+		}
+	}
+	for _, sub := range []**jsonschema.Schema{
+		&s.Items, &s.AdditionalProperties, &s.PropertyNames, &s.Contains,
+		&s.Not, &s.If, &s.Then, &s.Else, &s.ContentSchema,
+	} {
+		*sub = r.resolve(*sub, refs)
+	}
+
+	mergeAllOf(s)
+	inferType(s)
+	return s
+}
+
+// lookup decodes the schema a local reference points to, such as
+// "#/$defs/Task", "#/definitions/Task" or "#/properties/from".
+func (r *schemaResolver) lookup(ref string) (*jsonschema.Schema, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("reference %q is not local", ref)
+	}
+	pointer, err := url.PathUnescape(pointer)
+	if err != nil {
+		return nil, fmt.Errorf("reference %q: %w", ref, err)
+	}
+	if pointer != "" && !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("reference %q is not a JSON pointer", ref)
+	}
+
+	node := r.root
+	for _, token := range strings.Split(pointer, "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		var object map[string]json.RawMessage
+		var array []json.RawMessage
+		switch {
+		case json.Unmarshal(node, &object) == nil:
+			next, ok := object[token]
+			if !ok {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			node = next
+		case json.Unmarshal(node, &array) == nil:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(array) {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			node = array[i]
+		default:
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+	}
+	return decodeSchema(node)
+}
+
+// annotate copies the annotations next to a $ref onto the schema it
+// points to, where they take precedence.
+func annotate(target, site *jsonschema.Schema) {
+	if site.Title != "" {
+		target.Title = site.Title
+	}
+	if site.Description != "" {
+		target.Description = site.Description
+	}
+	if site.Default != nil {
+		target.Default = site.Default
+	}
+	if site.Examples != nil {
+		target.Examples = site.Examples
+	}
+	target.Deprecated = target.Deprecated || site.Deprecated
+	target.ReadOnly = target.ReadOnly || site.ReadOnly
+	target.WriteOnly = target.WriteOnly || site.WriteOnly
+}
+
+// mergeAllOf merges allOf branches into s when they all describe objects,
+// as pydantic and zod intersections generate: not all providers accept
+// allOf. Other allOf are kept.
+func mergeAllOf(s *jsonschema.Schema) {
+	if len(s.AllOf) == 0 || (s.Type != "" && s.Type != "object") || s.TypeEnhanced != nil {
+		return
+	}
+	for _, branch := range s.AllOf {
+		if isBoolSchema(branch) || branch.Type != "object" {
+			return
+		}
+	}
+
+	s.Type = "object"
+	if s.Properties == nil {
+		s.Properties = jsonschema.NewProperties()
+	}
+	for _, branch := range s.AllOf {
+		if branch.Properties != nil {
+			for pair := branch.Properties.Oldest(); pair != nil; pair = pair.Next() {
+				if _, ok := s.Properties.Get(pair.Key); !ok {
+					s.Properties.Set(pair.Key, pair.Value)
+				}
+			}
+		}
+		for _, name := range branch.Required {
+			if !slices.Contains(s.Required, name) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		if s.Title == "" {
+			s.Title = branch.Title
+		}
+		if s.Description == "" {
+			s.Description = branch.Description
+		}
+		if s.AdditionalProperties == nil {
+			s.AdditionalProperties = branch.AdditionalProperties
+		}
+	}
+	s.AllOf = nil
+}
+
+// inferType sets the type of a schema that leaves it out to the one its
+// keywords imply, or to any type: the type of a schema is serialized even
+// when empty, which providers reject.
+func inferType(s *jsonschema.Schema) {
+	if s.Type != "" || s.TypeEnhanced != nil || isBoolSchema(s) || reflect.DeepEqual(*s, jsonschema.Schema{}) {
+		return
+	}
+	switch {
+	case s.Properties != nil || s.AdditionalProperties != nil || s.PatternProperties != nil || len(s.Required) > 0:
+		s.Type = "object"
+	case s.Items != nil || s.PrefixItems != nil:
+		s.Type = "array"
+	case len(s.Enum) > 0:
+		setTypes(s, valueTypes(s.Enum))
+	case s.Const != nil:
+		setTypes(s, valueTypes([]any{s.Const}))
+	case len(s.AnyOf) > 0:
+		setTypes(s, branchTypes(s.AnyOf))
+	case len(s.OneOf) > 0:
+		setTypes(s, branchTypes(s.OneOf))
+	default:
+		setTypes(s, anyType)
+	}
+}
+
+func setTypes(s *jsonschema.Schema, types []string) {
+	if len(types) == 1 {
+		s.Type = types[0]
+	} else {
+		s.TypeEnhanced = types
+	}
+}
+
+// valueTypes returns the types of enum or const values.
+func valueTypes(values []any) []string {
+	var types []string
+	for _, v := range values {
+		var t string
+		switch v := v.(type) {
+		case string:
+			t = "string"
+		case float64:
+			t = "number"
+			if v == math.Trunc(v) {
+				t = "integer"
+			}
+		case bool:
+			t = "boolean"
+		case nil:
+			t = "null"
+		case []any:
+			t = "array"
+		default:
+			t = "object"
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// branchTypes returns the types the branches of anyOf or oneOf accept
+// together.
+func branchTypes(branches []*jsonschema.Schema) []string {
+	var types []string
+	for _, branch := range branches {
+		branchTypes := branch.TypeEnhanced
+		if branch.Type != "" {
+			branchTypes = []string{branch.Type}
+		}
+		if len(branchTypes) == 0 {
+			return anyType
+		}
+		for _, t := range branchTypes {
+			if !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		}
+	}
+	return types
+}
+
+// isBoolSchema reports whether s is the true or false schema.
+func isBoolSchema(s *jsonschema.Schema) bool {
+	return reflect.DeepEqual(*s, *jsonschema.TrueSchema) || reflect.DeepEqual(*s, *jsonschema.FalseSchema)
+}
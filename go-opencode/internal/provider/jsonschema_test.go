@@ -0,0 +1,355 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// mcpToolsFixture is a tools/list result of an MCP server.
+type mcpToolsFixture struct {
+	Tools []struct {
+		Name        string          `json:"name"`
+		InputSchema json.RawMessage `json:"inputSchema"`
+	} `json:"tools"`
+}
+
+// TestConvertJSONSchema_MCPTools checks the conversion of the tool schemas of
+// real MCP servers in testdata/mcp_tools: every keyword of a schema is kept
+// where it applies once references are inlined, and the result holds nothing
+// providers reject.
+func TestConvertJSONSchema_MCPTools(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "mcp_tools", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fixture mcpToolsFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+
+		for _, tool := range fixture.Tools {
+			t.Run(strings.TrimSuffix(filepath.Base(file), ".json")+"/"+tool.Name, func(t *testing.T) {
+				s, err := ConvertJSONSchema(tool.InputSchema)
+				if err != nil {
+					t.Fatalf("ConvertJSONSchema failed: %v", err)
+				}
+				var in, out map[string]any
+				if err := json.Unmarshal(tool.InputSchema, &in); err != nil {
+					t.Fatal(err)
+				}
+				converted, err := json.Marshal(s)
+				if err != nil {
+					t.Fatalf("Marshal failed: %v", err)
+				}
+				if err := json.Unmarshal(converted, &out); err != nil {
+					t.Fatal(err)
+				}
+
+				if out["type"] != "object" {
+					t.Errorf("Got root type %v, want object", out["type"])
+				}
+				checkProviderSchema(t, "", out)
+				checkConforms(t, "", in, out, in, []string{"#"})
+
+				params, err := geminiParameters(&schema.ToolInfo{ParamsOneOf: ToolParams(tool.InputSchema)})
+				if err != nil {
+					t.Fatalf("geminiParameters failed: %v", err)
+				}
+				checkGeminiSchema(t, "", params)
+			})
+		}
+	}
+}
+
+func TestConvertJSONSchema(t *testing.T) {
+	convert := func(t *testing.T, schemaJSON string) map[string]any {
+		t.Helper()
+		s, err := ConvertJSONSchema(json.RawMessage(schemaJSON))
+		if err != nil {
+			t.Fatalf("ConvertJSONSchema failed: %v", err)
+		}
+		data, _ := json.Marshal(s)
+		var out map[string]any
+		json.Unmarshal(data, &out)
+		return out
+	}
+	get := func(v any, path ...string) any {
+		for _, key := range path {
+			m, _ := v.(map[string]any)
+			v = m[key]
+		}
+		return v
+	}
+
+	t.Run("recursive references are inlined once", func(t *testing.T) {
+		out := convert(t, `{"type":"object","properties":{"node":{"$ref":"#/$defs/Node"}},"$defs":{"Node":{"type":"object","description":"A node","properties":{"next":{"$ref":"#/$defs/Node"}}}}}`)
+		if get(out, "properties", "node", "properties", "next", "type") != "object" {
+			t.Errorf("Got %v", out)
+		}
+		if next := get(out, "properties", "node", "properties", "next").(map[string]any); next["properties"] != nil || next["description"] != "A node" {
+			t.Errorf("Expected the recursion cut at next, got %v", next)
+		}
+	})
+
+	t.Run("root references", func(t *testing.T) {
+		out := convert(t, `{"type":"object","description":"A person","properties":{"name":{"type":"string"},"parent":{"$ref":"#"}}}`)
+		if parent := get(out, "properties", "parent").(map[string]any); parent["type"] != "object" || parent["properties"] != nil || parent["description"] != "A person" {
+			t.Errorf("Expected the recursion cut at parent, got %v", parent)
+		}
+	})
+
+	t.Run("remote references are left open", func(t *testing.T) {
+		out := convert(t, `{"type":"object","properties":{"geo":{"$ref":"https://example.com/geo.json","description":"Location"}}}`)
+		geo := get(out, "properties", "geo").(map[string]any)
+		if geo["$ref"] != nil || geo["description"] != "Location" || geo["type"] == "" {
+			t.Errorf("Got %v", geo)
+		}
+	})
+
+	t.Run("types are inferred", func(t *testing.T) {
+		out := convert(t, `{"properties":{
+			"mode":{"enum":["fast","slow"]},
+			"level":{"enum":[1,2,null]},
+			"kind":{"const":"file"},
+			"id":{"anyOf":[{"type":"string"},{"type":"integer"}]},
+			"opts":{"properties":{"a":{"type":"string"}}},
+			"list":{"items":{"type":"string"}},
+			"value":{"description":"Any value"}
+		}}`)
+		want := map[string]any{
+			"mode":  "string",
+			"level": []any{"integer", "null"},
+			"kind":  "string",
+			"id":    []any{"string", "integer"},
+			"opts":  "object",
+			"list":  "array",
+			"value": []any{"string", "number", "boolean", "object", "array", "null"},
+		}
+		for name, typ := range want {
+			if got := get(out, "properties", name, "type"); !reflect.DeepEqual(got, typ) {
+				t.Errorf("%s: got type %v, want %v", name, got, typ)
+			}
+		}
+		if out["type"] != "object" {
+			t.Errorf("Got root type %v", out["type"])
+		}
+	})
+
+	t.Run("allOf objects are merged", func(t *testing.T) {
+		out := convert(t, `{"type":"object","properties":{"p":{"allOf":[
+			{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]},
+			{"type":"object","properties":{"b":{"type":"integer"}},"required":["b"]}
+		]}}}`)
+		p := get(out, "properties", "p").(map[string]any)
+		if p["allOf"] != nil || p["type"] != "object" || len(p["properties"].(map[string]any)) != 2 || len(p["required"].([]any)) != 2 {
+			t.Errorf("Got %v", p)
+		}
+	})
+
+	t.Run("draft-04 keywords are upgraded", func(t *testing.T) {
+		out := convert(t, `{"type":"object","properties":{
+			"point":{"type":"array","items":[{"type":"number"},{"type":"number"}]},
+			"ratio":{"type":"number","minimum":0,"exclusiveMinimum":true}
+		}}`)
+		if items := get(out, "properties", "point", "prefixItems"); items == nil || len(items.([]any)) != 2 {
+			t.Errorf("Got point %v", get(out, "properties", "point"))
+		}
+		if ratio := get(out, "properties", "ratio").(map[string]any); ratio["exclusiveMinimum"] != float64(0) || ratio["minimum"] != nil {
+			t.Errorf("Got ratio %v", ratio)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ConvertJSONSchema(json.RawMessage(`{"type":`)); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestGeminiSchema(t *testing.T) {
+	var in map[string]any
+	json.Unmarshal([]byte(`{"type":"object","additionalProperties":false,"properties":{
+		"due":{"anyOf":[{"type":"string","format":"date"},{"type":"null"}]},
+		"level":{"type":["integer","null"]},
+		"value":{"type":["string","number"]},
+		"kind":{"type":"string","const":"file"},
+		"id":{"oneOf":[{"type":"string"},{"type":"integer"}]}
+	}}`), &in)
+	out := geminiSchema(in)
+	props := out["properties"].(map[string]any)
+
+	if due := props["due"].(map[string]any); due["nullable"] != true || len(due["anyOf"].([]any)) != 1 {
+		t.Errorf("Got due %v", due)
+	}
+	if level := props["level"].(map[string]any); level["type"] != "integer" || level["nullable"] != true {
+		t.Errorf("Got level %v", level)
+	}
+	if value := props["value"].(map[string]any); value["type"] != nil {
+		t.Errorf("Got value %v, want no type", value)
+	}
+	if kind := props["kind"].(map[string]any); !reflect.DeepEqual(kind["enum"], []any{"file"}) {
+		t.Errorf("Got kind %v", kind)
+	}
+	if id := props["id"].(map[string]any); id["oneOf"] != nil || len(id["anyOf"].([]any)) != 2 {
+		t.Errorf("Got id %v", id)
+	}
+	if _, ok := out["additionalProperties"]; ok {
+		t.Error("Expected additionalProperties to be dropped")
+	}
+}
+
+// schemaMaps are the keywords whose value maps names to schemas.
+var schemaMaps = []string{"properties", "patternProperties", "dependentSchemas"}
+
+// schemaLists are the keywords whose value is a list of schemas.
+var schemaLists = []string{"anyOf", "oneOf", "allOf", "prefixItems"}
+
+// checkProviderSchema checks that a converted schema has no references left
+// and no empty type.
+func checkProviderSchema(t *testing.T, path string, v any) {
+	t.Helper()
+	switch v := v.(type) {
+	case map[string]any:
+		for _, key := range []string{"$ref", "$defs", "definitions", "$schema"} {
+			if _, ok := v[key]; ok {
+				t.Errorf("%s: unexpected %s", path, key)
+			}
+		}
+		if typ, ok := v["type"]; ok && (typ == "" || typ == nil) {
+			t.Errorf("%s: empty type", path)
+		}
+		for key, sub := range v {
+			switch {
+			case slices.Contains(schemaMaps, key):
+				for name, prop := range sub.(map[string]any) {
+					checkProviderSchema(t, path+"."+key+"."+name, prop)
+				}
+			case slices.Contains(schemaLists, key):
+				for _, item := range sub.([]any) {
+					checkProviderSchema(t, path+"."+key, item)
+				}
+			case key == "items" || key == "additionalProperties" || key == "not":
+				checkProviderSchema(t, path+"."+key, sub)
+			}
+		}
+	}
+}
+
+// checkConforms checks that every keyword of the input schema in is kept in
+// the converted schema out, following the references of in from root. refs
+// are the references followed, whose recursion the conversion cuts.
+func checkConforms(t *testing.T, path string, in map[string]any, out any, root map[string]any, refs []string) {
+	t.Helper()
+	if ref, ok := in["$ref"].(string); ok {
+		if slices.Contains(refs, ref) {
+			return
+		}
+		target := resolvePointer(t, root, ref)
+		merged := make(map[string]any, len(target)+len(in))
+		for key, value := range target {
+			merged[key] = value
+		}
+		for key, value := range in {
+			merged[key] = value
+		}
+		in = merged
+		refs = append(slices.Clip(refs), ref)
+	}
+
+	outMap, ok := out.(map[string]any)
+	if !ok {
+		t.Errorf("%s: got %v, want a schema", path, out)
+		return
+	}
+	for key, value := range in {
+		switch {
+		case key == "$ref" || key == "$defs" || key == "definitions" || key == "$schema":
+		case slices.Contains(schemaMaps, key):
+			outProps, _ := outMap[key].(map[string]any)
+			for name, prop := range value.(map[string]any) {
+				checkConforms(t, path+"."+key+"."+name, prop.(map[string]any), outProps[name], root, refs)
+			}
+		case key == "allOf" && outMap["allOf"] == nil:
+			// Merged into the schema
+			for _, branch := range value.([]any) {
+				checkConforms(t, path, branch.(map[string]any), outMap, root, refs)
+			}
+		case slices.Contains(schemaLists, key):
+			outList, _ := outMap[key].([]any)
+			if len(outList) != len(value.([]any)) {
+				t.Errorf("%s.%s: got %d schemas, want %d", path, key, len(outList), len(value.([]any)))
+				continue
+			}
+			for i, item := range value.([]any) {
+				checkConforms(t, path+"."+key, item.(map[string]any), outList[i], root, refs)
+			}
+		case key == "required":
+			outRequired, _ := outMap[key].([]any)
+			for _, name := range value.([]any) {
+				if !slices.Contains(outRequired, name) {
+					t.Errorf("%s: %v is no longer required", path, name)
+				}
+			}
+		default:
+			if sub, ok := value.(map[string]any); ok && (key == "items" || key == "additionalProperties" || key == "not") {
+				checkConforms(t, path+"."+key, sub, outMap[key], root, refs)
+			} else if !reflect.DeepEqual(value, outMap[key]) {
+				t.Errorf("%s.%s: got %v, want %v", path, key, outMap[key], value)
+			}
+		}
+	}
+}
+
+// resolvePointer returns the schema a local reference points to.
+func resolvePointer(t *testing.T, root map[string]any, ref string) map[string]any {
+	t.Helper()
+	var node any = root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		node = node.(map[string]any)[token]
+	}
+	target, ok := node.(map[string]any)
+	if !ok {
+		t.Fatalf("Reference %s not found", ref)
+	}
+	return target
+}
+
+// checkGeminiSchema checks that a schema only holds keywords Gemini accepts,
+// with a single type.
+func checkGeminiSchema(t *testing.T, path string, s map[string]any) {
+	t.Helper()
+	for key, value := range s {
+		if !geminiSchemaKeys[key] {
+			t.Errorf("%s: Gemini rejects %s", path, key)
+		}
+		switch key {
+		case "type":
+			if typ, ok := value.(string); !ok || typ == "" || typ == "null" {
+				t.Errorf("%s: got type %v", path, value)
+			}
+		case "properties":
+			for name, prop := range value.(map[string]any) {
+				checkGeminiSchema(t, path+".properties."+name, prop.(map[string]any))
+			}
+		case "items":
+			checkGeminiSchema(t, path+".items", value.(map[string]any))
+		case "anyOf":
+			for _, item := range value.([]any) {
+				checkGeminiSchema(t, path+".anyOf", item.(map[string]any))
+			}
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Decoded to be downscaled
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// Limits of the files sent to models.
+const (
+	// MaxImageDimension is the longest side, in pixels, of the images sent;
+	// larger images are downscaled.
+	MaxImageDimension = 2000
+	// MaxImageSize is the size of the largest image sent, once downscaled.
+	MaxImageSize = 5 << 20
+	// MaxImagePixels is the pixel count of the largest image decoded to be
+	// downscaled. Decoded images take 4 to 8 bytes per pixel in memory.
+	MaxImagePixels = 64_000_000
+	// MaxFileSize is the size of the largest PDF or text file sent.
+	MaxFileSize = 20 << 20
+)
+
+// errFileTooLarge is returned for files over the size limits.
+var errFileTooLarge = errors.New("file is too large")
+
+// fittedImages caches the images fitImage downscaled, by content: the history
+// is converted again on every step of the loop, with the same images.
+var fittedImages = &imageCache{maxSize: 64 << 20, items: make(map[[sha256.Size]byte]fittedImage)}
+
+// imageCache holds fitted images up to a total size, dropping the oldest
+// first.
+type imageCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	order   [][sha256.Size]byte
+	items   map[[sha256.Size]byte]fittedImage
+}
+
+// fittedImage is the result of fitting an image.
+type fittedImage struct {
+	data []byte
+	mime string
+	err  error
+}
+
+func (c *imageCache) get(key [sha256.Size]byte) (fittedImage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fitted, ok := c.items[key]
+	return fitted, ok
+}
+
+func (c *imageCache) put(key [sha256.Size]byte, fitted fittedImage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; ok || len(fitted.data) > c.maxSize {
+		return
+	}
+	for c.size+len(fitted.data) > c.maxSize {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.items[oldest].data)
+		delete(c.items, oldest)
+	}
+	c.items[key] = fitted
+	c.order = append(c.order, key)
+	c.size += len(fitted.data)
+}
+
+// UserMessage converts the text and files of a user message for model. Images
+// are sent to models supporting vision, downscaled to MaxImageDimension, PDFs
+// to models supporting them, and text files inline. Other files, and files
+// over the size limits, are replaced by a note telling the model why it
+// can't see them. A nil model is taken to support images and PDFs.
+func UserMessage(text string, files []*types.FilePart, model *types.Model) *schema.Message {
+	if len(files) == 0 {
+		return &schema.Message{Role: schema.User, Content: text}
+	}
+
+	var parts []schema.MessageInputPart
+	if text != "" {
+		parts = append(parts, schema.MessageInputPart{Type: schema.ChatMessagePartTypeText, Text: text})
+	}
+	for _, file := range files {
+		parts = append(parts, filePart(file, model))
+	}
+	return inputMessage(parts)
+}
+
+// AttachmentsMessage returns a user message with the files tools returned
+// alongside their output, such as the images the read tool reads: tool
+// results only hold text. It returns nil if the tools returned no files.
+func AttachmentsMessage(tools []*types.ToolPart, model *types.Model) *schema.Message {
+	var parts []schema.MessageInputPart
+	for _, tool := range tools {
+		if len(tool.State.Attachments) == 0 {
+			continue
+		}
+		parts = append(parts, schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: fmt.Sprintf("Files returned by the %s tool call %s:", tool.Tool, tool.CallID),
+		})
+		for i := range tool.State.Attachments {
+			parts = append(parts, filePart(&tool.State.Attachments[i], model))
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return inputMessage(parts)
+}
+
+// inputMessage returns a user message of parts, as plain text when none is
+// media: not every model accepts multi-content messages.
+func inputMessage(parts []schema.MessageInputPart) *schema.Message {
+	var text []string
+	for _, part := range parts {
+		if part.Type != schema.ChatMessagePartTypeText {
+			return &schema.Message{Role: schema.User, UserInputMultiContent: parts}
+		}
+		text = append(text, part.Text)
+	}
+	return &schema.Message{Role: schema.User, Content: strings.Join(text, "\n\n")}
+}
+
+// filePart converts a file for model, or returns the note replacing it.
+func filePart(file *types.FilePart, model *types.Model) schema.MessageInputPart {
+	name := fileName(file)
+	note := func(reason string) schema.MessageInputPart {
+		return schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: fmt.Sprintf("[File %s is attached but was not sent: %s]", name, reason),
+		}
+	}
+	modelName := "this model"
+	if model != nil {
+		modelName = model.ProviderID + "/" + model.ID
+	}
+
+	mime := file.Mime
+	if strings.HasPrefix(file.URL, "http://") || strings.HasPrefix(file.URL, "https://") {
+		// Remote images are fetched by the provider
+		if !strings.HasPrefix(mime, "image/") {
+			return note("only images are sent by URL")
+		}
+		if model != nil && !model.SupportsVision {
+			return note(modelName + " does not support images")
+		}
+		u := file.URL
+		return imagePart(&schema.MessageInputImage{MessagePartCommon: schema.MessagePartCommon{URL: &u, MIMEType: mime}})
+	}
+
+	data, mime, err := loadFile(file)
+	if err != nil {
+		return note(err.Error())
+	}
+
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		if model != nil && !model.SupportsVision {
+			return note(modelName + " does not support images")
+		}
+		data, mime, err = fitImage(data, mime)
+		if err != nil {
+			return note(err.Error())
+		}
+		encoded := base64.StdEncoding.EncodeToString(data)
+		return imagePart(&schema.MessageInputImage{MessagePartCommon: schema.MessagePartCommon{Base64Data: &encoded, MIMEType: mime}})
+
+	case mime == "application/pdf":
+		if model != nil && !model.SupportsPDF {
+			return note(modelName + " does not support PDF files")
+		}
+		encoded := base64.StdEncoding.EncodeToString(data)
+		return schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeFileURL,
+			File: &schema.MessageInputFile{MessagePartCommon: schema.MessagePartCommon{Base64Data: &encoded, MIMEType: mime}},
+		}
+
+	case isTextMime(mime) && utf8.Valid(data):
+		return schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: fmt.Sprintf("File %s:\n%s", name, data),
+		}
+
+	default:
+		return note(fmt.Sprintf("%s files are not supported", mime))
+	}
+}
+
+func imagePart(image *schema.MessageInputImage) schema.MessageInputPart {
+	return schema.MessageInputPart{Type: schema.ChatMessagePartTypeImageURL, Image: image}
+}
+
+// fileName returns the name a file is shown to the model with.
+func fileName(file *types.FilePart) string {
+	switch {
+	case file.Filename != "":
+		return file.Filename
+	case strings.HasPrefix(file.URL, "data:"):
+		return "(" + file.Mime + ")"
+	default:
+		return path.Base(file.URL)
+	}
+}
+
+// loadFile returns the content and MIME type of a file given as a data URL
+// or a file URL. The type of the part prevails over the one of a data URL,
+// and the content is sniffed when neither is known.
+func loadFile(file *types.FilePart) ([]byte, string, error) {
+	var data []byte
+	mime := file.Mime
+	switch {
+	case strings.HasPrefix(file.URL, "data:"):
+		header, payload, ok := strings.Cut(strings.TrimPrefix(file.URL, "data:"), ",")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid data URL")
+		}
+		header, isBase64 := strings.CutSuffix(header, ";base64")
+		if mime == "" {
+			mime, _, _ = strings.Cut(header, ";")
+		}
+		var err error
+		if isBase64 {
+			data, err = base64.StdEncoding.DecodeString(payload)
+		} else {
+			var s string
+			s, err = url.PathUnescape(payload)
+			data = []byte(s)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid data URL: %w", err)
+		}
+
+	case strings.HasPrefix(file.URL, "file://"):
+		u, err := url.Parse(file.URL)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid file URL: %w", err)
+		}
+		info, err := os.Stat(u.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot read file: %w", err)
+		}
+		if info.Size() > MaxFileSize {
+			return nil, "", fmt.Errorf("%w (%d MB, the limit is %d MB)", errFileTooLarge, info.Size()>>20, MaxFileSize>>20)
+		}
+		if data, err = os.ReadFile(u.Path); err != nil {
+			return nil, "", fmt.Errorf("cannot read file: %w", err)
+		}
+
+	default:
+		return nil, "", fmt.Errorf("unsupported URL")
+	}
+
+	if len(data) > MaxFileSize {
+		return nil, "", fmt.Errorf("%w (%d MB, the limit is %d MB)", errFileTooLarge, len(data)>>20, MaxFileSize>>20)
+	}
+	if mime == "" || mime == "application/octet-stream" {
+		mime, _, _ = strings.Cut(http.DetectContentType(data), ";")
+	}
+	return data, mime, nil
+}
+
+// isTextMime reports whether files of a MIME type are text.
+func isTextMime(mime string) bool {
+	switch {
+	case strings.HasPrefix(mime, "text/"):
+		return true
+	case mime == "application/json", mime == "application/xml", mime == "application/x-yaml",
+		mime == "application/javascript", mime == "application/x-sh":
+		return true
+	}
+	return false
+}
+
+// fitImage downscales an image whose longest side exceeds MaxImageDimension,
+// and re-encodes it as JPEG if it is still over MaxImageSize. Images in
+// formats that can't be decoded are sent as they are, if small enough, and
+// images over MaxImagePixels are refused without being decoded. Downscaled
+// images are cached.
+func fitImage(data []byte, mime string) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if len(data) > MaxImageSize {
+			return nil, "", fmt.Errorf("%w (%d MB, the limit for images is %d MB)", errFileTooLarge, len(data)>>20, MaxImageSize>>20)
+		}
+		return data, mime, nil
+	}
+	if max(cfg.Width, cfg.Height) <= MaxImageDimension && len(data) <= MaxImageSize {
+		return data, mime, nil
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+		return nil, "", fmt.Errorf("%w (%dx%d pixels, the limit for images is %d megapixels)", errFileTooLarge, cfg.Width, cfg.Height, MaxImagePixels/1_000_000)
+	}
+
+	key := sha256.Sum256(data)
+	fitted, ok := fittedImages.get(key)
+	if !ok {
+		fitted.data, fitted.mime, fitted.err = downscaleImage(data, format)
+		fittedImages.put(key, fitted)
+	}
+	return fitted.data, fitted.mime, fitted.err
+}
+
+// downscaleImage decodes an image, downscales it to MaxImageDimension and
+// encodes it again, as JPEG if it is a JPEG or is too large as PNG.
+func downscaleImage(data []byte, format string) ([]byte, string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot decode image: %w", err)
+	}
+	img = downscale(img, MaxImageDimension)
+
+	var buf bytes.Buffer
+	var mime string
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		mime = "image/jpeg"
+	} else {
+		err = png.Encode(&buf, img)
+		mime = "image/png"
+	}
+	if err == nil && buf.Len() > MaxImageSize && format != "jpeg" {
+		buf.Reset()
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		mime = "image/jpeg"
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot encode image: %w", err)
+	}
+	if buf.Len() > MaxImageSize {
+		return nil, "", fmt.Errorf("%w (%d MB once downscaled, the limit for images is %d MB)", errFileTooLarge, buf.Len()>>20, MaxImageSize>>20)
+	}
+	return buf.Bytes(), mime, nil
+}
+
+// downscale resizes img so that its longest side is at most size pixels,
+// averaging the source pixels each pixel covers.
+func downscale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if max(w, h) <= size {
+		return img
+	}
+	dw, dh := size, max(1, h*size/w)
+	if h > w {
+		dw, dh = max(1, w*size/h), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func dataURL(mime string, data []byte) string {
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestUserMessage_TextOnly(t *testing.T) {
+	msg := UserMessage("hello", nil, &types.Model{ID: "gpt-4o"})
+	if msg.Role != schema.User || msg.Content != "hello" || msg.UserInputMultiContent != nil {
+		t.Errorf("Expected a plain text message, got %+v", msg)
+	}
+}
+
+func TestUserMessage_Image(t *testing.T) {
+	file := &types.FilePart{Mime: "image/png", Filename: "shot.png", URL: dataURL("image/png", testPNG(t, 4, 4))}
+
+	msg := UserMessage("what is this?", []*types.FilePart{file}, &types.Model{ID: "gpt-4o", SupportsVision: true})
+	if msg.Content != "" || len(msg.UserInputMultiContent) != 2 {
+		t.Fatalf("Expected text and image parts, got %+v", msg)
+	}
+	part := msg.UserInputMultiContent[1]
+	if part.Type != schema.ChatMessagePartTypeImageURL || part.Image == nil {
+		t.Fatalf("Expected an image part, got %+v", part)
+	}
+	if part.Image.Base64Data == nil || part.Image.MIMEType != "image/png" {
+		t.Errorf("Expected raw base64 PNG data, got %+v", part.Image)
+	}
+
+	// Models without vision get a note in place of the image
+	msg = UserMessage("what is this?", []*types.FilePart{file}, &types.Model{ID: "o1-mini", ProviderID: "openai"})
+	if msg.UserInputMultiContent != nil {
+		t.Fatalf("Expected a text message, got %+v", msg)
+	}
+	if !strings.Contains(msg.Content, "[File shot.png is attached but was not sent: openai/o1-mini does not support images]") {
+		t.Errorf("Expected a note about the image, got %q", msg.Content)
+	}
+}
+
+func TestUserMessage_RemoteImage(t *testing.T) {
+	file := &types.FilePart{Mime: "image/jpeg", URL: "https://example.com/cat.jpg"}
+	msg := UserMessage("", []*types.FilePart{file}, &types.Model{SupportsVision: true})
+	if len(msg.UserInputMultiContent) != 1 {
+		t.Fatalf("Expected one part, got %+v", msg)
+	}
+	image := msg.UserInputMultiContent[0].Image
+	if image == nil || image.URL == nil || *image.URL != file.URL {
+		t.Errorf("Expected the image to be sent by URL, got %+v", image)
+	}
+}
+
+func TestUserMessage_PDF(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	file := &types.FilePart{Mime: "application/pdf", Filename: "paper.pdf", URL: dataURL("application/pdf", pdf)}
+
+	msg := UserMessage("summarize", []*types.FilePart{file}, &types.Model{SupportsVision: true, SupportsPDF: true})
+	if len(msg.UserInputMultiContent) != 2 {
+		t.Fatalf("Expected text and file parts, got %+v", msg)
+	}
+	part := msg.UserInputMultiContent[1]
+	if part.Type != schema.ChatMessagePartTypeFileURL || part.File == nil || part.File.MIMEType != "application/pdf" {
+		t.Errorf("Expected a PDF file part, got %+v", part)
+	}
+
+	msg = UserMessage("summarize", []*types.FilePart{file}, &types.Model{ID: "gpt-4o", ProviderID: "openai", SupportsVision: true})
+	if !strings.Contains(msg.Content, "openai/gpt-4o does not support PDF files") {
+		t.Errorf("Expected a note about the PDF, got %q", msg.Content)
+	}
+}
+
+func TestUserMessage_TextFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("# Notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := &types.FilePart{Mime: "text/markdown", Filename: "notes.md", URL: "file://" + path}
+
+	msg := UserMessage("read this", []*types.FilePart{file}, &types.Model{})
+	if msg.UserInputMultiContent != nil || !strings.Contains(msg.Content, "File notes.md:\n# Notes\n") {
+		t.Errorf("Expected the file inlined, got %+v", msg)
+	}
+}
+
+func TestUserMessage_UnsupportedFile(t *testing.T) {
+	file := &types.FilePart{Mime: "application/zip", Filename: "src.zip", URL: dataURL("application/zip", []byte("PK\x03\x04"))}
+	msg := UserMessage("", []*types.FilePart{file}, nil)
+	if msg.Content != "[File src.zip is attached but was not sent: application/zip files are not supported]" {
+		t.Errorf("Got %q", msg.Content)
+	}
+}
+
+func TestUserMessage_LargeImageDownscaled(t *testing.T) {
+	file := &types.FilePart{Mime: "image/png", URL: dataURL("image/png", testPNG(t, 4000, 1000))}
+
+	msg := UserMessage("", []*types.FilePart{file}, nil)
+	if len(msg.UserInputMultiContent) != 1 || msg.UserInputMultiContent[0].Image == nil {
+		t.Fatalf("Expected an image part, got %+v", msg)
+	}
+	data, err := base64.StdEncoding.DecodeString(*msg.UserInputMultiContent[0].Image.Base64Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != MaxImageDimension || cfg.Height != 500 {
+		t.Errorf("Expected a %dx500 image, got %dx%d", MaxImageDimension, cfg.Width, cfg.Height)
+	}
+
+	// Converted again on the next step, the downscaled image is reused
+	if _, ok := fittedImages.get(sha256.Sum256(testPNG(t, 4000, 1000))); !ok {
+		t.Error("Expected the downscaled image to be cached")
+	}
+	again := UserMessage("", []*types.FilePart{file}, nil)
+	if *again.UserInputMultiContent[0].Image.Base64Data != *msg.UserInputMultiContent[0].Image.Base64Data {
+		t.Error("Expected the cached image to be sent")
+	}
+}
+
+func TestUserMessage_ImageOverPixelLimit(t *testing.T) {
+	// A small PNG declaring 100000x100000 pixels
+	data := testPNG(t, 1, 1)
+	ihdr := data[12:29] // Chunk type and data
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+	file := &types.FilePart{Mime: "image/png", Filename: "huge.png", URL: dataURL("image/png", data)}
+
+	msg := UserMessage("", []*types.FilePart{file}, nil)
+	if !strings.Contains(msg.Content, "[File huge.png is attached but was not sent: file is too large (100000x100000 pixels") {
+		t.Errorf("Expected a note about the pixel count, got %q", msg.Content)
+	}
+}
+
+func TestUserMessage_FileTooLarge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.pdf")
+	if err := os.WriteFile(path, make([]byte, MaxFileSize+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := &types.FilePart{Mime: "application/pdf", URL: "file://" + path}
+
+	msg := UserMessage("", []*types.FilePart{file}, nil)
+	if !strings.Contains(msg.Content, "[File big.pdf is attached but was not sent: file is too large") {
+		t.Errorf("Expected a note about the size, got %q", msg.Content)
+	}
+}
+
+func TestAttachmentsMessage(t *testing.T) {
+	if msg := AttachmentsMessage([]*types.ToolPart{{Tool: "bash", CallID: "call_1"}}, nil); msg != nil {
+		t.Errorf("Expected no message without attachments, got %+v", msg)
+	}
+
+	tools := []*types.ToolPart{{
+		Tool:   "read",
+		CallID: "call_2",
+		State: types.ToolState{Attachments: []types.FilePart{
+			{Mime: "image/png", Filename: "chart.png", URL: dataURL("image/png", testPNG(t, 2, 2))},
+		}},
+	}}
+	msg := AttachmentsMessage(tools, &types.Model{SupportsVision: true})
+	if msg == nil || msg.Role != schema.User || len(msg.UserInputMultiContent) != 2 {
+		t.Fatalf("Expected a user message with the image, got %+v", msg)
+	}
+	if msg.UserInputMultiContent[0].Text != "Files returned by the read tool call call_2:" {
+		t.Errorf("Got %q", msg.UserInputMultiContent[0].Text)
+	}
+	if msg.UserInputMultiContent[1].Type != schema.ChatMessagePartTypeImageURL {
+		t.Errorf("Expected an image part, got %+v", msg.UserInputMultiContent[1])
+	}
+}
@@ -3,11 +3,13 @@ package provider
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino-ext/components/model/openai"
 
+	"github.com/opencode-ai/opencode/internal/catalog"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -31,6 +33,9 @@ type OpenAIConfig struct {
 	// Azure configuration
 	UseAzure   bool
 	APIVersion string
+
+	// HTTPClient sends the API requests; http.DefaultClient if nil
+	HTTPClient *http.Client
 }
 
 // NewOpenAIProvider creates a new OpenAI provider.
@@ -44,7 +49,8 @@ func NewOpenAIProvider(ctx context.Context, config *OpenAIConfig) (*OpenAIProvid
 		}
 	}
 
-	if apiKey == "" {
+	// Local OpenAI-compatible servers usually need no key
+	if apiKey == "" && config.BaseURL == "" {
 		return nil, fmt.Errorf("OPENAI_API_KEY not set")
 	}
 
@@ -65,6 +71,7 @@ func NewOpenAIProvider(ctx context.Context, config *OpenAIConfig) (*OpenAIProvid
 		APIKey:              apiKey,
 		Model:               modelID,
 		MaxCompletionTokens: &maxTokens, // Use MaxCompletionTokens for GPT-5 compatibility
+		HTTPClient:          config.HTTPClient,
 	}
 
 	if config.BaseURL != "" {
@@ -85,11 +92,12 @@ func NewOpenAIProvider(ctx context.Context, config *OpenAIConfig) (*OpenAIProvid
 		return nil, fmt.Errorf("failed to create OpenAI model: %w", err)
 	}
 
-	return &OpenAIProvider{
+	p := &OpenAIProvider{
 		chatModel: chatModel,
-		models:    openAIModels(),
 		config:    config,
-	}, nil
+	}
+	p.models = openAIModels(p.ID())
+	return p, nil
 }
 
 // ID returns the provider identifier.
@@ -129,6 +137,9 @@ func (p *OpenAIProvider) CreateCompletion(ctx context.Context, req *CompletionRe
 	opts := []model.Option{
 		openai.WithMaxCompletionTokens(req.MaxTokens),
 	}
+	if req.Model != "" {
+		opts = append(opts, model.WithModel(req.Model))
+	}
 	if req.Temperature > 0 {
 		opts = append(opts, model.WithTemperature(float32(req.Temperature)))
 	}
@@ -142,90 +153,12 @@ func (p *OpenAIProvider) CreateCompletion(ctx context.Context, req *CompletionRe
 	return NewCompletionStream(stream), nil
 }
 
-// openAIModels returns the list of OpenAI models.
-func openAIModels() []types.Model {
-	return []types.Model{
-		// GPT-5 family (newest)
-		{
-			ID:                "gpt-5",
-			Name:              "GPT-5",
-			ProviderID:        "openai",
-			ContextLength:     272000,
-			MaxOutputTokens:   128000,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			SupportsReasoning: true,
-			InputPrice:        1.25,
-			OutputPrice:       10.0,
-		},
-		{
-			ID:                "gpt-5-mini",
-			Name:              "GPT-5 Mini",
-			ProviderID:        "openai",
-			ContextLength:     272000,
-			MaxOutputTokens:   128000,
-			SupportsTools:     true,
-			SupportsVision:    true,
-			SupportsReasoning: true,
-			InputPrice:        0.25,
-			OutputPrice:       2.0,
-		},
-		{
-			ID:              "gpt-5-nano",
-			Name:            "GPT-5 Nano",
-			ProviderID:      "openai",
-			ContextLength:   272000,
-			MaxOutputTokens: 128000,
-			SupportsTools:   true,
-			SupportsVision:  true,
-			InputPrice:      0.05,
-			OutputPrice:     0.4,
-		},
-		// GPT-4o family
-		{
-			ID:              "gpt-4o",
-			Name:            "GPT-4o",
-			ProviderID:      "openai",
-			ContextLength:   128000,
-			MaxOutputTokens: 16384,
-			SupportsTools:   true,
-			SupportsVision:  true,
-			InputPrice:      2.5,
-			OutputPrice:     10.0,
-		},
-		{
-			ID:              "gpt-4o-mini",
-			Name:            "GPT-4o Mini",
-			ProviderID:      "openai",
-			ContextLength:   128000,
-			MaxOutputTokens: 16384,
-			SupportsTools:   true,
-			SupportsVision:  true,
-			InputPrice:      0.15,
-			OutputPrice:     0.6,
-		},
-		// O1 family
-		{
-			ID:                "o1",
-			Name:              "O1",
-			ProviderID:        "openai",
-			ContextLength:     200000,
-			MaxOutputTokens:   100000,
-			SupportsTools:     true,
-			SupportsReasoning: true,
-			InputPrice:        15.0,
-			OutputPrice:       60.0,
-		},
-		{
-			ID:                "o1-mini",
-			Name:              "O1 Mini",
-			ProviderID:        "openai",
-			ContextLength:     128000,
-			MaxOutputTokens:   65536,
-			SupportsTools:     true,
-			SupportsReasoning: true,
-			InputPrice:        1.1,
-			OutputPrice:       4.4,
-		},
+// openAIModels returns the OpenAI models of the catalog. Eino's OpenAI model
+// sends no file parts, so PDFs are not offered.
+func openAIModels(providerID string) []types.Model {
+	models := catalog.Default().Models("openai", providerID)
+	for i := range models {
+		models[i].SupportsPDF = false
 	}
+	return models
 }
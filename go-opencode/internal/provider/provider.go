@@ -40,6 +40,40 @@ type CompletionRequest struct {
 	StopWords   []string          `json:"stopWords,omitempty"`
 }
 
+// ExtraReasoningTokens is the key in the Extra of a message chunk holding the
+// reasoning tokens of the request, for providers that report them. They are
+// included in the chunk's CompletionTokens.
+const ExtraReasoningTokens = "reasoning_tokens"
+
+// ReasoningTokens returns the reasoning tokens reported in a message chunk.
+func ReasoningTokens(msg *schema.Message) int {
+	switch n := msg.Extra[ExtraReasoningTokens].(type) {
+	case int:
+		return n
+	case float64: // Decoded from JSON, as when replayed
+		return int(n)
+	default:
+		return 0
+	}
+}
+
+// ExtraCacheWriteTokens is the key in the Extra of a message chunk holding
+// the prompt tokens the request wrote to the provider's prompt cache, for
+// providers that report them. They are included in the chunk's PromptTokens.
+const ExtraCacheWriteTokens = "cache_write_tokens"
+
+// CacheWriteTokens returns the cache write tokens reported in a message chunk.
+func CacheWriteTokens(msg *schema.Message) int {
+	switch n := msg.Extra[ExtraCacheWriteTokens].(type) {
+	case int:
+		return n
+	case float64: // Decoded from JSON, as when replayed
+		return int(n)
+	default:
+		return 0
+	}
+}
+
 // CompletionStream wraps an Eino stream reader.
 type CompletionStream struct {
 	reader *schema.StreamReader[*schema.Message]
@@ -71,66 +105,15 @@ type ToolInfo struct {
 func ConvertToEinoTools(tools []ToolInfo) []*schema.ToolInfo {
 	result := make([]*schema.ToolInfo, len(tools))
 	for i, t := range tools {
-		// Parse parameters from JSON schema
-		var params map[string]*schema.ParameterInfo
-		if len(t.Parameters) > 0 {
-			params = parseJSONSchemaToParams(t.Parameters)
-		}
-
 		result[i] = &schema.ToolInfo{
 			Name: t.Name,
 			Desc: t.Description,
-			ParamsOneOf: schema.NewParamsOneOfByParams(params),
+			ParamsOneOf: ToolParams(t.Parameters),
 		}
 	}
 	return result
 }
 
-// parseJSONSchemaToParams converts JSON Schema to Eino ParameterInfo.
-func parseJSONSchemaToParams(schemaJSON json.RawMessage) map[string]*schema.ParameterInfo {
-	var jsonSchema struct {
-		Properties map[string]struct {
-			Type        string `json:"type"`
-			Description string `json:"description"`
-		} `json:"properties"`
-		Required []string `json:"required"`
-	}
-
-	if err := json.Unmarshal(schemaJSON, &jsonSchema); err != nil {
-		return nil
-	}
-
-	requiredSet := make(map[string]bool)
-	for _, r := range jsonSchema.Required {
-		requiredSet[r] = true
-	}
-
-	params := make(map[string]*schema.ParameterInfo)
-	for name, prop := range jsonSchema.Properties {
-		paramType := schema.String
-		switch prop.Type {
-		case "integer":
-			paramType = schema.Integer
-		case "number":
-			paramType = schema.Number
-		case "boolean":
-			paramType = schema.Boolean
-		case "array":
-			paramType = schema.Array
-		case "object":
-			paramType = schema.Object
-		}
-
-		params[name] = &schema.ParameterInfo{
-			Type:     paramType,
-			Desc:     prop.Description,
-			Required: requiredSet[name],
-		}
-	}
-
-	return params
-}
-
 // ConvertFromEinoMessage converts Eino message to internal types.
 func ConvertFromEinoMessage(msg *schema.Message, sessionID string) *types.Message {
 	role := "assistant"
@@ -148,8 +131,11 @@ func ConvertFromEinoMessage(msg *schema.Message, sessionID string) *types.Messag
 	}
 }
 
-// ConvertToEinoMessages converts internal messages to Eino format.
-func ConvertToEinoMessages(messages []*types.Message, parts map[string][]types.Part) []*schema.Message {
+// ConvertToEinoMessages converts internal messages to Eino format for model.
+// Messages before the latest compaction summary are left out. The files of
+// user messages are converted as UserMessage does.
+func ConvertToEinoMessages(messages []*types.Message, parts map[string][]types.Part, model *types.Model) []*schema.Message {
+	messages = types.CompactedHistory(messages)
 	result := make([]*schema.Message, 0, len(messages))
 
 	for _, msg := range messages {
@@ -166,12 +152,17 @@ func ConvertToEinoMessages(messages []*types.Message, parts map[string][]types.P
 		// Build content from parts
 		content := ""
 		var toolCalls []schema.ToolCall
+		var files []*types.FilePart
 
 		if msgParts, ok := parts[msg.ID]; ok {
 			for _, part := range msgParts {
 				switch p := part.(type) {
 				case *types.TextPart:
 					content += p.Text
+				case *types.CompactionPart:
+					content += types.CompactionRequestText
+				case *types.FilePart:
+					files = append(files, p)
 				case *types.ToolPart:
 					inputJSON, _ := json.Marshal(p.State.Input)
 					toolCalls = append(toolCalls, schema.ToolCall{
@@ -190,6 +181,9 @@ func ConvertToEinoMessages(messages []*types.Message, parts map[string][]types.P
 			Content:   content,
 			ToolCalls: toolCalls,
 		}
+		if role == schema.User {
+			einoMsg = UserMessage(content, files, model)
+		}
 
 		result = append(result, einoMsg)
 	}
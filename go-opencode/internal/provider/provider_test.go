@@ -35,29 +35,6 @@ func TestParseModelString(t *testing.T) {
 	}
 }
 
-func TestModelPriority(t *testing.T) {
-	tests := []struct {
-		modelID       string
-		wantHigherThan string
-	}{
-		{"gpt-5-turbo", "claude-sonnet-4-latest"},
-		{"claude-sonnet-4-20250514", "gpt-4o-2024"},
-		{"claude-opus-4", "gpt-4o"},
-		{"gpt-4o-latest", "claude-3-5-sonnet"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.modelID+" > "+tt.wantHigherThan, func(t *testing.T) {
-			high := modelPriority(tt.modelID)
-			low := modelPriority(tt.wantHigherThan)
-			if high <= low {
-				t.Errorf("modelPriority(%q) = %d, should be > modelPriority(%q) = %d",
-					tt.modelID, high, tt.wantHigherThan, low)
-			}
-		})
-	}
-}
-
 func TestConvertToEinoTools(t *testing.T) {
 	tools := []ToolInfo{
 		{
@@ -104,7 +81,7 @@ func TestConvertToEinoTools(t *testing.T) {
 	}
 }
 
-func TestParseJSONSchemaToParams(t *testing.T) {
+func TestToolParams(t *testing.T) {
 	schemaJSON := json.RawMessage(`{
 		"type": "object",
 		"properties": {
@@ -118,84 +95,62 @@ func TestParseJSONSchemaToParams(t *testing.T) {
 		"required": ["stringParam", "intParam"]
 	}`)
 
-	params := parseJSONSchemaToParams(schemaJSON)
-
-	if params == nil {
-		t.Fatal("Expected non-nil params")
+	params, err := ToolParams(schemaJSON).ToJSONSchema()
+	if err != nil {
+		t.Fatalf("ToJSONSchema failed: %v", err)
 	}
 
-	// Check string param
-	if p, ok := params["stringParam"]; !ok {
-		t.Error("Missing stringParam")
-	} else {
-		if p.Type != schema.String {
-			t.Errorf("stringParam type = %v, want String", p.Type)
-		}
-		if !p.Required {
-			t.Error("stringParam should be required")
-		}
+	wantTypes := map[string]string{
+		"stringParam": "string",
+		"intParam":    "integer",
+		"numParam":    "number",
+		"boolParam":   "boolean",
+		"arrayParam":  "array",
+		"objectParam": "object",
 	}
-
-	// Check integer param
-	if p, ok := params["intParam"]; !ok {
-		t.Error("Missing intParam")
-	} else {
-		if p.Type != schema.Integer {
-			t.Errorf("intParam type = %v, want Integer", p.Type)
-		}
-		if !p.Required {
-			t.Error("intParam should be required")
-		}
-	}
-
-	// Check number param
-	if p, ok := params["numParam"]; !ok {
-		t.Error("Missing numParam")
-	} else {
-		if p.Type != schema.Number {
-			t.Errorf("numParam type = %v, want Number", p.Type)
+	for name, want := range wantTypes {
+		p, ok := params.Properties.Get(name)
+		if !ok {
+			t.Errorf("Missing %s", name)
+			continue
 		}
-		if p.Required {
-			t.Error("numParam should not be required")
+		if p.Type != want {
+			t.Errorf("%s type = %v, want %s", name, p.Type, want)
 		}
 	}
 
-	// Check boolean param
-	if p, ok := params["boolParam"]; !ok {
-		t.Error("Missing boolParam")
-	} else if p.Type != schema.Boolean {
-		t.Errorf("boolParam type = %v, want Boolean", p.Type)
+	if p, _ := params.Properties.Get("stringParam"); p.Description != "A string" {
+		t.Errorf("stringParam description = %q", p.Description)
 	}
-
-	// Check array param
-	if p, ok := params["arrayParam"]; !ok {
-		t.Error("Missing arrayParam")
-	} else if p.Type != schema.Array {
-		t.Errorf("arrayParam type = %v, want Array", p.Type)
+	if len(params.Required) != 2 || params.Required[0] != "stringParam" || params.Required[1] != "intParam" {
+		t.Errorf("Required = %v, want [stringParam intParam]", params.Required)
 	}
 
-	// Check object param
-	if p, ok := params["objectParam"]; !ok {
-		t.Error("Missing objectParam")
-	} else if p.Type != schema.Object {
-		t.Errorf("objectParam type = %v, want Object", p.Type)
+	// The order of properties is kept
+	if first := params.Properties.Oldest(); first == nil || first.Key != "stringParam" {
+		t.Error("Expected stringParam first")
 	}
 }
 
-func TestParseJSONSchemaToParams_InvalidJSON(t *testing.T) {
-	result := parseJSONSchemaToParams(json.RawMessage(`invalid json`))
-	if result != nil {
-		t.Error("Expected nil for invalid JSON")
+func TestToolParams_InvalidJSON(t *testing.T) {
+	params, err := ToolParams(json.RawMessage(`invalid json`)).ToJSONSchema()
+	if err != nil {
+		t.Fatalf("ToJSONSchema failed: %v", err)
+	}
+	if params.Type != "object" || params.Properties.Len() != 0 {
+		t.Errorf("Expected an object without properties for invalid JSON, got %+v", params)
 	}
 }
 
-func TestParseJSONSchemaToParams_EmptySchema(t *testing.T) {
-	result := parseJSONSchemaToParams(json.RawMessage(`{}`))
-	if result == nil {
-		t.Error("Expected non-nil map for empty schema")
-	}
-	if len(result) != 0 {
-		t.Errorf("Expected empty map, got %d entries", len(result))
+func TestToolParams_EmptySchema(t *testing.T) {
+	for _, schemaJSON := range []json.RawMessage{nil, json.RawMessage(`{}`)} {
+		params, err := ToolParams(schemaJSON).ToJSONSchema()
+		if err != nil {
+			t.Fatalf("ToJSONSchema failed: %v", err)
+		}
+		if params.Type != "object" || params.Properties == nil || params.Properties.Len() != 0 {
+			t.Errorf("Expected an object without properties for %q, got %+v", schemaJSON, params)
+		}
 	}
 }
 
@@ -261,7 +216,7 @@ func TestConvertToEinoMessages(t *testing.T) {
 		},
 	}
 
-	result := ConvertToEinoMessages(messages, parts)
+	result := ConvertToEinoMessages(messages, parts, nil)
 
 	if len(result) != 3 {
 		t.Fatalf("Expected 3 messages, got %d", len(result))
@@ -299,7 +254,7 @@ func TestConvertToEinoMessages(t *testing.T) {
 }
 
 func TestConvertToEinoMessages_Empty(t *testing.T) {
-	result := ConvertToEinoMessages(nil, nil)
+	result := ConvertToEinoMessages(nil, nil, nil)
 	if result == nil {
 		t.Error("Expected non-nil slice")
 	}
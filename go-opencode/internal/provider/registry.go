@@ -2,12 +2,17 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"sort"
 	"strings"
 	"sync"
 
+	"github.com/opencode-ai/opencode/internal/auth"
+	"github.com/opencode-ai/opencode/internal/catalog"
+	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -16,6 +21,7 @@ type Registry struct {
 	mu        sync.RWMutex
 	providers map[string]Provider
 	config    *types.Config
+	recorder  *Recorder
 }
 
 // NewRegistry creates a new provider registry.
@@ -42,9 +48,30 @@ func (r *Registry) Get(providerID string) (Provider, error) {
 	if !ok {
 		return nil, fmt.Errorf("provider not found: %s", providerID)
 	}
+	if r.recorder != nil {
+		return r.recorder.wrap(provider), nil
+	}
 	return provider, nil
 }
 
+// SetRecorder records or replays the completions of the providers returned by
+// Get. When replaying, providers recorded in the cassettes but not registered
+// are stood in for, so a session replays without their credentials.
+func (r *Registry) SetRecorder(recorder *Recorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.recorder = recorder
+	if !recorder.Replaying() {
+		return
+	}
+	for providerID, models := range recorder.models() {
+		if _, ok := r.providers[providerID]; !ok {
+			r.providers[providerID] = &replayProvider{id: providerID, models: models}
+		}
+	}
+}
+
 // List returns all available providers.
 func (r *Registry) List() []Provider {
 	r.mu.RLock()
@@ -66,6 +93,7 @@ func (r *Registry) GetModel(providerID, modelID string) (*types.Model, error) {
 
 	for _, model := range provider.Models() {
 		if model.ID == modelID {
+			model = r.withConfig(model)
 			return &model, nil
 		}
 	}
@@ -73,38 +101,80 @@ func (r *Registry) GetModel(providerID, modelID string) (*types.Model, error) {
 	return nil, fmt.Errorf("model not found: %s/%s", providerID, modelID)
 }
 
-// AllModels returns all models from all providers.
+// AllModels returns all models from all providers, best first as ranked by
+// the model catalog.
 func (r *Registry) AllModels() []types.Model {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	var models []types.Model
 	for _, p := range r.providers {
-		models = append(models, p.Models()...)
+		for _, model := range p.Models() {
+			models = append(models, r.withConfig(model))
+		}
 	}
 
-	// Sort by quality/priority
-	sort.Slice(models, func(i, j int) bool {
-		return modelPriority(models[i].ID) > modelPriority(models[j].ID)
-	})
-
+	catalog.Default().Sort(models)
 	return models
 }
 
-// DefaultModel returns the default model.
+// withConfig applies the configured overrides for a model, such as its prices
+// and limits.
+func (r *Registry) withConfig(model types.Model) types.Model {
+	if r.config == nil {
+		return model
+	}
+	providerCfg, ok := r.config.Provider[model.ProviderID]
+	if !ok {
+		return model
+	}
+	if modelCfg, ok := providerCfg.Models[model.ID]; ok {
+		model = model.WithConfig(modelCfg)
+	}
+	return model
+}
+
+// RefreshModels discovers the models of a provider again, or of every
+// provider that discovers its models when providerID is empty.
+func (r *Registry) RefreshModels(ctx context.Context, providerID string) error {
+	r.mu.RLock()
+	var refreshers []ModelRefresher
+	for id, p := range r.providers {
+		if providerID != "" && id != providerID {
+			continue
+		}
+		refresher, ok := p.(ModelRefresher)
+		if !ok {
+			if providerID != "" {
+				r.mu.RUnlock()
+				return fmt.Errorf("provider %s does not discover its models", providerID)
+			}
+			continue
+		}
+		refreshers = append(refreshers, refresher)
+	}
+	r.mu.RUnlock()
+
+	if providerID != "" && len(refreshers) == 0 {
+		return fmt.Errorf("provider not found: %s", providerID)
+	}
+
+	var errs []error
+	for _, refresher := range refreshers {
+		if err := refresher.RefreshModels(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DefaultModel returns the configured model, or else the best available one.
 func (r *Registry) DefaultModel() (*types.Model, error) {
 	if r.config != nil && r.config.Model != "" {
 		providerID, modelID := ParseModelString(r.config.Model)
 		return r.GetModel(providerID, modelID)
 	}
 
-	// Default to Claude Sonnet if available
-	model, err := r.GetModel("anthropic", "claude-sonnet-4-20250514")
-	if err == nil {
-		return model, nil
-	}
-
-	// Fall back to first available model
 	models := r.AllModels()
 	if len(models) == 0 {
 		return nil, fmt.Errorf("no models available")
@@ -121,31 +191,12 @@ func ParseModelString(s string) (providerID, modelID string) {
 	return "", s
 }
 
-// modelPriority returns sorting priority for models.
-func modelPriority(modelID string) int {
-	switch {
-	case strings.Contains(modelID, "gpt-5"):
-		return 100
-	case strings.Contains(modelID, "claude-sonnet-4"):
-		return 90
-	case strings.Contains(modelID, "claude-opus"):
-		return 85
-	case strings.Contains(modelID, "gpt-4o"):
-		return 80
-	case strings.Contains(modelID, "claude-3-5"):
-		return 75
-	case strings.Contains(modelID, "gemini-2"):
-		return 70
-	default:
-		return 50
-	}
-}
-
 // Npm package to provider type mapping
 const (
 	NpmOpenAI           = "@ai-sdk/openai"
 	NpmOpenAICompatible = "@ai-sdk/openai-compatible"
 	NpmAnthropic        = "@ai-sdk/anthropic"
+	NpmGoogle           = "@ai-sdk/google"
 )
 
 // InitializeProviders creates and registers all providers from config.
@@ -155,6 +206,9 @@ func InitializeProviders(ctx context.Context, config *types.Config) (*Registry,
 	// Track which providers are configured
 	configuredProviders := make(map[string]bool)
 
+	// Credentials saved by "opencode auth login" stand in for missing API keys
+	store := authStore()
+
 	// Iterate through all configured providers
 	for name, cfg := range config.Provider {
 		if cfg.Disable {
@@ -163,6 +217,14 @@ func InitializeProviders(ctx context.Context, config *types.Config) (*Registry,
 
 		configuredProviders[name] = true
 		apiKey, baseURL := getProviderCredentials(cfg)
+		var httpClient *http.Client
+		if apiKey == "" {
+			var oauth *types.OAuthConfig
+			if cfg.Options != nil {
+				oauth = cfg.Options.OAuth
+			}
+			apiKey, httpClient = storedCredentials(store, name, oauth)
+		}
 		fmt.Printf("[provider] Processing provider %s: apiKey=%t, baseURL=%s, model=%s\n", name, apiKey != "", baseURL, cfg.Model)
 
 		// Determine provider type from npm field or provider name
@@ -179,24 +241,60 @@ func InitializeProviders(ctx context.Context, config *types.Config) (*Registry,
 		case NpmAnthropic:
 			if apiKey != "" {
 				provider, err = NewAnthropicProvider(ctx, &AnthropicConfig{
-					ID:        name,
-					APIKey:    apiKey,
-					BaseURL:   baseURL,
-					Model:     cfg.Model,
-					MaxTokens: 8192,
+					ID:            name,
+					APIKey:        apiKey,
+					BaseURL:       baseURL,
+					Model:         cfg.Model,
+					MaxTokens:     8192,
+					CacheStrategy: cacheStrategy(cfg),
+					HTTPClient:    httpClient,
+				})
+			}
+
+		case NpmOpenAICompatible:
+			// Compatible servers list their models, local ones need no API key
+			if baseURL != "" {
+				provider, err = NewCompatibleProvider(ctx, &CompatibleConfig{
+					ID:         name,
+					APIKey:     apiKey,
+					BaseURL:    baseURL,
+					Model:      cfg.Model,
+					MaxTokens:  4096,
+					Models:     configuredModels(cfg),
+					CacheDir:   modelsCacheDir(),
+					HTTPClient: httpClient,
+				})
+			} else if apiKey != "" {
+				provider, err = NewOpenAIProvider(ctx, &OpenAIConfig{
+					ID:         name,
+					APIKey:     apiKey,
+					Model:      cfg.Model,
+					MaxTokens:  4096,
+					HTTPClient: httpClient,
 				})
 			}
 
-		case NpmOpenAI, NpmOpenAICompatible:
-			// OpenAI and OpenAI-compatible use the same provider with different baseURL
+		case NpmOpenAI:
 			if apiKey != "" || baseURL != "" {
-				// OpenAI-compatible may not require API key for local models
 				provider, err = NewOpenAIProvider(ctx, &OpenAIConfig{
-					ID:        name,
-					APIKey:    apiKey,
-					BaseURL:   baseURL,
-					Model:     cfg.Model,
-					MaxTokens: 4096,
+					ID:         name,
+					APIKey:     apiKey,
+					BaseURL:    baseURL,
+					Model:      cfg.Model,
+					MaxTokens:  4096,
+					HTTPClient: httpClient,
+				})
+			}
+
+		case NpmGoogle:
+			if apiKey != "" {
+				provider, err = NewGeminiProvider(ctx, &GeminiConfig{
+					ID:         name,
+					APIKey:     apiKey,
+					BaseURL:    baseURL,
+					Model:      cfg.Model,
+					MaxTokens:  8192,
+					HTTPClient: httpClient,
 				})
 			}
 
@@ -228,9 +326,10 @@ func InitializeProviders(ctx context.Context, config *types.Config) (*Registry,
 		}
 	}
 
-	// Auto-register default providers from environment variables if not already configured
+	// Auto-register default providers from saved API keys and environment
+	// variables if not already configured
 	if !configuredProviders["anthropic"] {
-		if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
+		if apiKey := storedAPIKey(store, "anthropic", "ANTHROPIC_API_KEY"); apiKey != "" {
 			fmt.Printf("[provider] Auto-registering anthropic provider from ANTHROPIC_API_KEY\n")
 			provider, err := NewAnthropicProvider(ctx, &AnthropicConfig{
 				ID:        "anthropic",
@@ -249,7 +348,7 @@ func InitializeProviders(ctx context.Context, config *types.Config) (*Registry,
 	}
 
 	if !configuredProviders["openai"] {
-		if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+		if apiKey := storedAPIKey(store, "openai", "OPENAI_API_KEY"); apiKey != "" {
 			provider, err := NewOpenAIProvider(ctx, &OpenAIConfig{
 				ID:        "openai",
 				APIKey:    apiKey,
@@ -261,9 +360,32 @@ func InitializeProviders(ctx context.Context, config *types.Config) (*Registry,
 		}
 	}
 
+	if !configuredProviders["google"] {
+		if apiKey := storedAPIKey(store, "google", "GEMINI_API_KEY"); apiKey != "" {
+			provider, err := NewGeminiProvider(ctx, &GeminiConfig{
+				ID:        "google",
+				APIKey:    apiKey,
+				MaxTokens: 8192,
+			})
+			if err == nil && provider != nil {
+				registry.Register(provider)
+			}
+		}
+	}
+
 	return registry, nil
 }
 
+// configuredModels returns the IDs of the models in a provider's config.
+func configuredModels(cfg types.ProviderConfig) []string {
+	ids := make([]string, 0, len(cfg.Models))
+	for id := range cfg.Models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // inferNpmFromProviderName maps well-known provider names to npm packages.
 func inferNpmFromProviderName(name string) string {
 	switch name {
@@ -271,11 +393,21 @@ func inferNpmFromProviderName(name string) string {
 		return NpmAnthropic
 	case "openai":
 		return NpmOpenAI
+	case "google", "gemini":
+		return NpmGoogle
 	default:
 		return ""
 	}
 }
 
+// cacheStrategy returns the prompt caching strategy configured for a provider.
+func cacheStrategy(cfg types.ProviderConfig) string {
+	if cfg.Options != nil {
+		return cfg.Options.CacheStrategy
+	}
+	return ""
+}
+
 // getProviderCredentials extracts API key and base URL from provider config.
 func getProviderCredentials(cfg types.ProviderConfig) (apiKey, baseURL string) {
 	if cfg.Options != nil {
@@ -284,3 +416,44 @@ func getProviderCredentials(cfg types.ProviderConfig) (apiKey, baseURL string) {
 	}
 	return apiKey, baseURL
 }
+
+// authStore returns the store of the credentials saved by "opencode auth
+// login".
+func authStore() *auth.Store {
+	return auth.NewStore(config.GetPaths().AuthPath())
+}
+
+// storedCredentials returns the API key saved for a provider or, when it is
+// logged in with OAuth, a placeholder key and an HTTP client authorizing its
+// requests with the access token, refreshed at the oauth endpoints.
+func storedCredentials(store *auth.Store, providerID string, oauth *types.OAuthConfig) (string, *http.Client) {
+	info, err := store.Get(providerID)
+	if err != nil {
+		fmt.Printf("[provider] Failed to read saved credentials: %v\n", err)
+		return "", nil
+	}
+	if info == nil {
+		return "", nil
+	}
+	switch info.Type {
+	case auth.TypeOAuth:
+		var client *auth.Client
+		if oauth != nil {
+			client = auth.NewClient(oauth, nil)
+		}
+		return auth.PlaceholderKey, &http.Client{Transport: auth.NewTransport(store, providerID, client)}
+	case auth.TypeWellKnown:
+		return info.Token, nil
+	default:
+		return info.Key, nil
+	}
+}
+
+// storedAPIKey returns the API key saved for a provider, or else the one in
+// the environment variable envVar.
+func storedAPIKey(store *auth.Store, providerID, envVar string) string {
+	if info, err := store.Get(providerID); err == nil && info != nil && info.Type == auth.TypeAPI {
+		return info.Key
+	}
+	return os.Getenv(envVar)
+}
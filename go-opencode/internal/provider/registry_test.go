@@ -84,6 +84,31 @@ func TestRegistry_GetModel(t *testing.T) {
 	}
 }
 
+func TestRegistry_GetModel_CostConfig(t *testing.T) {
+	input := 1.5
+	registry := NewRegistry(&types.Config{
+		Provider: map[string]types.ProviderConfig{
+			"test": {Models: map[string]types.ModelConfig{
+				"model-a": {Cost: &types.ModelCostConfig{Input: &input}},
+			}},
+		},
+	})
+	registry.Register(newMockProvider("test", "Test", []types.Model{
+		{ID: "model-a", ProviderID: "test", InputPrice: 3, OutputPrice: 15},
+	}))
+
+	model, err := registry.GetModel("test", "model-a")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	if model.InputPrice != 1.5 || model.OutputPrice != 15 {
+		t.Errorf("Got prices %v/%v, want 1.5/15", model.InputPrice, model.OutputPrice)
+	}
+	if all := registry.AllModels(); all[0].InputPrice != 1.5 {
+		t.Errorf("AllModels input price = %v, want 1.5", all[0].InputPrice)
+	}
+}
+
 func TestRegistry_GetModel_NotFound(t *testing.T) {
 	registry := NewRegistry(nil)
 
@@ -109,11 +134,11 @@ func TestRegistry_AllModels(t *testing.T) {
 	registry := NewRegistry(nil)
 
 	registry.Register(newMockProvider("p1", "Provider 1", []types.Model{
-		{ID: "gpt-4o-latest", Name: "GPT-4o"},
+		{ID: "gpt-4o", Name: "GPT-4o"},
 	}))
 	registry.Register(newMockProvider("p2", "Provider 2", []types.Model{
 		{ID: "claude-sonnet-4-20250514", Name: "Claude Sonnet 4"},
-		{ID: "claude-3-5-sonnet", Name: "Claude 3.5 Sonnet"},
+		{ID: "custom-model", Name: "Custom Model"},
 	}))
 
 	models := registry.AllModels()
@@ -121,9 +146,12 @@ func TestRegistry_AllModels(t *testing.T) {
 		t.Fatalf("Expected 3 models, got %d", len(models))
 	}
 
-	// Should be sorted by priority (claude-sonnet-4 > gpt-4o > claude-3-5)
-	if models[0].ID != "claude-sonnet-4-20250514" {
-		t.Errorf("First model should be claude-sonnet-4, got %s", models[0].ID)
+	// Sorted by the catalog, newest first, with unknown models last
+	want := []string{"claude-sonnet-4-20250514", "gpt-4o", "custom-model"}
+	for i, id := range want {
+		if models[i].ID != id {
+			t.Errorf("Model %d should be %s, got %s", i, id, models[i].ID)
+		}
 	}
 }
 
@@ -218,6 +246,8 @@ func TestInitializeProviders_NoConfig(t *testing.T) {
 		}
 	}()
 
+	t.Setenv("GEMINI_API_KEY", "")
+
 	config := &types.Config{
 		Provider: make(map[string]types.ProviderConfig),
 	}
@@ -242,6 +272,8 @@ func TestInferNpmFromProviderName(t *testing.T) {
 		{"anthropic", NpmAnthropic},
 		{"claude", NpmAnthropic},
 		{"openai", NpmOpenAI},
+		{"google", NpmGoogle},
+		{"gemini", NpmGoogle},
 		{"unknown", ""},
 		{"ark", ""},
 	}
@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestSpec_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, 1, 15, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 9-17 * * mon-fri", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2025, 1, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 feb *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * fri", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			spec, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, spec.Next(from))
+		})
+	}
+}
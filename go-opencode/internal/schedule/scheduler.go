@@ -0,0 +1,489 @@
+// Package schedule starts sessions on cron schedules read from the
+// configuration, and records the history of their runs.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// Policies for runs missed while the server was down.
+const (
+	CatchUpSkip = "skip" // Missed runs are dropped
+	CatchUpOnce = "once" // The last missed run is made up
+	CatchUpAll  = "all"  // Every missed run is made up, one after the other
+)
+
+// Policies for a run due while the last run of the entry is in progress.
+const (
+	OverlapSkip  = "skip"  // The run is recorded as skipped
+	OverlapQueue = "queue" // The run starts when the last one finishes
+	OverlapAllow = "allow" // The runs go side by side
+)
+
+// Statuses of a run.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+const (
+	// maxCatchUp bounds the missed runs made up for an entry at once.
+	maxCatchUp = 100
+
+	// historyLimit is the number of runs kept for each entry.
+	historyLimit = 100
+)
+
+// Scheduler starts the sessions of schedule entries when they are due.
+type Scheduler struct {
+	sessions  *session.Service
+	storage   storage.Storage
+	directory string
+	entries   []*entry // Sorted by name
+	now       func() time.Time
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type entry struct {
+	name   string
+	config types.ScheduleConfig
+	spec   *Spec
+
+	// Guarded by Scheduler.mu
+	next   time.Time
+	active int
+
+	// serial is held by the runs of entries that do not overlap
+	serial sync.Mutex
+}
+
+// Status is the state of a schedule entry.
+type Status struct {
+	Name    string               `json:"name"`
+	Config  types.ScheduleConfig `json:"config"`
+	Next    int64                `json:"next,omitempty"` // Unix ms of the next run
+	Running int                  `json:"running"`        // Runs in progress
+	LastRun *types.ScheduleRun   `json:"lastRun,omitempty"`
+}
+
+// New returns a scheduler for the configured entries. Disabled entries are
+// left out; entries run in directory unless they set their own.
+func New(sessions *session.Service, store storage.Storage, directory string, config map[string]types.ScheduleConfig) (*Scheduler, error) {
+	s := &Scheduler{
+		sessions:  sessions,
+		storage:   store,
+		directory: directory,
+		now:       time.Now,
+	}
+
+	for name, cfg := range config {
+		if cfg.Disable {
+			continue
+		}
+		spec, err := Parse(cfg.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", name, err)
+		}
+		if (cfg.Prompt == "") == (cfg.Command == "") {
+			return nil, fmt.Errorf("schedule %s: exactly one of prompt and command must be set", name)
+		}
+		switch cfg.CatchUp {
+		case "", CatchUpSkip, CatchUpOnce, CatchUpAll:
+		default:
+			return nil, fmt.Errorf("schedule %s: invalid catchUp %q", name, cfg.CatchUp)
+		}
+		switch cfg.Overlap {
+		case "", OverlapSkip, OverlapQueue, OverlapAllow:
+		default:
+			return nil, fmt.Errorf("schedule %s: invalid overlap %q", name, cfg.Overlap)
+		}
+		s.entries = append(s.entries, &entry{name: name, config: cfg, spec: spec})
+	}
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].name < s.entries[j].name
+	})
+
+	return s, nil
+}
+
+// Start makes up the runs each entry missed since its last recorded run, as
+// its catchUp policy says, then starts entries when they are due until Stop
+// is called.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+
+	now := s.now()
+	for _, e := range s.entries {
+		s.mu.Lock()
+		e.next = e.spec.Next(now)
+		s.mu.Unlock()
+
+		if missed := s.missedRuns(ctx, e, now); len(missed) > 0 {
+			s.dispatch(ctx, e, missed, time.Time{})
+		}
+	}
+
+	s.wg.Add(1)
+	go s.loop(ctx)
+}
+
+// Stop stops the scheduler, aborting the runs in progress, and waits for
+// them to finish.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		// With nothing left to run, wait for Stop
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if next := s.nextDue(); !next.IsZero() {
+			timer = time.NewTimer(next.Sub(s.now()))
+			wait = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wait:
+			s.tick(ctx, s.now())
+		}
+	}
+}
+
+// nextDue returns the earliest next run of the entries, or the zero time if
+// none will run.
+func (s *Scheduler) nextDue() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, e := range s.entries {
+		if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+// tick starts the entries due at now. An entry due several times since the
+// last tick, as after the machine slept, missed all but the last run.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	for _, e := range s.entries {
+		var due []time.Time
+		s.mu.Lock()
+		for !e.next.IsZero() && !e.next.After(now) {
+			due = appendBounded(due, e.next)
+			e.next = e.spec.Next(e.next)
+		}
+		s.mu.Unlock()
+		if len(due) == 0 {
+			continue
+		}
+
+		missed := catchUpRuns(e.config.CatchUp, due[:len(due)-1])
+		s.dispatch(ctx, e, missed, due[len(due)-1])
+	}
+}
+
+// missedRuns returns the runs of an entry to make up at startup: those due
+// between its last recorded run and now, filtered by its catchUp policy.
+// Entries that never ran have nothing to make up.
+func (s *Scheduler) missedRuns(ctx context.Context, e *entry, now time.Time) []time.Time {
+	runs, err := s.History(ctx, e.name, 1)
+	if err != nil || len(runs) == 0 {
+		return nil
+	}
+
+	var missed []time.Time
+	for t := e.spec.Next(time.UnixMilli(runs[0].Due).In(now.Location())); !t.IsZero() && !t.After(now); t = e.spec.Next(t) {
+		missed = appendBounded(missed, t)
+	}
+	return catchUpRuns(e.config.CatchUp, missed)
+}
+
+// catchUpRuns returns the missed runs to make up under a catchUp policy.
+func catchUpRuns(policy string, missed []time.Time) []time.Time {
+	if len(missed) == 0 {
+		return nil
+	}
+	switch policy {
+	case CatchUpOnce:
+		return missed[len(missed)-1:]
+	case CatchUpAll:
+		return missed
+	default:
+		return nil
+	}
+}
+
+// appendBounded appends t, keeping the last maxCatchUp+1 times: enough for
+// maxCatchUp missed runs and the one on time.
+func appendBounded(times []time.Time, t time.Time) []time.Time {
+	times = append(times, t)
+	if len(times) > maxCatchUp+1 {
+		times = times[1:]
+	}
+	return times
+}
+
+// dispatch starts the missed runs of an entry to make up, then its run on
+// time unless onTime is zero, one after the other, unless its overlap policy
+// skips them.
+func (s *Scheduler) dispatch(ctx context.Context, e *entry, missed []time.Time, onTime time.Time) {
+	type dueRun struct {
+		at      time.Time
+		catchUp bool
+	}
+	var due []dueRun
+	for _, t := range missed {
+		due = append(due, dueRun{at: t, catchUp: true})
+	}
+	if !onTime.IsZero() {
+		due = append(due, dueRun{at: onTime})
+	}
+
+	overlap := e.config.Overlap
+	if overlap == "" {
+		overlap = OverlapSkip
+	}
+
+	s.mu.Lock()
+	if e.active > 0 && overlap == OverlapSkip {
+		s.mu.Unlock()
+		for _, d := range due {
+			s.record(ctx, &types.ScheduleRun{
+				ID:       ulid.Make().String(),
+				Schedule: e.name,
+				Status:   StatusSkipped,
+				Due:      d.at.UnixMilli(),
+				Started:  s.now().UnixMilli(),
+				CatchUp:  d.catchUp,
+				Error:    "the last run is still in progress",
+			})
+		}
+		return
+	}
+	e.active++
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			e.active--
+			s.mu.Unlock()
+		}()
+
+		if overlap != OverlapAllow {
+			e.serial.Lock()
+			defer e.serial.Unlock()
+		}
+		for _, d := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			s.run(ctx, e, d.at, d.catchUp)
+		}
+	}()
+}
+
+// run starts a new session for an entry and waits for it to answer.
+func (s *Scheduler) run(ctx context.Context, e *entry, due time.Time, catchUp bool) {
+	run := &types.ScheduleRun{
+		ID:       ulid.Make().String(),
+		Schedule: e.name,
+		Status:   StatusRunning,
+		Due:      due.UnixMilli(),
+		Started:  s.now().UnixMilli(),
+		CatchUp:  catchUp,
+	}
+
+	directory := e.config.Directory
+	if directory == "" {
+		directory = s.directory
+	}
+	sess, err := s.sessions.Create(ctx, directory, "Scheduled: "+e.name)
+	if err != nil {
+		s.finish(ctx, run, err)
+		return
+	}
+	event.PublishSync(event.Event{
+		Type: event.SessionCreated,
+		Data: event.SessionCreatedData{Info: sess},
+	})
+	run.SessionID = sess.ID
+	s.record(ctx, run)
+
+	// Nobody is there to answer permission requests, which are denied
+	var mu sync.Mutex
+	var denied []permission.Request
+	ctx = permission.Unattended(ctx, func(req permission.Request) {
+		mu.Lock()
+		denied = append(denied, req)
+		mu.Unlock()
+	})
+
+	var msg *types.Message
+	if e.config.Command != "" {
+		msg, _, err = s.sessions.ExecuteCommand(ctx, session.CommandInput{
+			SessionID: sess.ID,
+			Command:   e.config.Command,
+			Arguments: e.config.Arguments,
+			Agent:     e.config.Agent,
+			Model:     e.config.Model,
+		}, nil)
+	} else {
+		msg, _, err = s.sessions.Prompt(ctx, session.PromptInput{
+			SessionID: sess.ID,
+			Text:      e.config.Prompt,
+			Agent:     e.config.Agent,
+			Model:     e.config.Model,
+		}, nil)
+	}
+	if err == nil && msg != nil && msg.Error != nil {
+		err = errors.New(msg.Error.Data.Message)
+	}
+	mu.Lock()
+	if err == nil && len(denied) > 0 {
+		err = fmt.Errorf("denied %d permission request(s), the first for %s: scheduled runs cannot ask", len(denied), denied[0].Type)
+	}
+	mu.Unlock()
+	s.finish(ctx, run, err)
+}
+
+// finish records the end of a run.
+func (s *Scheduler) finish(ctx context.Context, run *types.ScheduleRun, err error) {
+	run.Finished = s.now().UnixMilli()
+	run.Status = StatusCompleted
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	}
+	s.record(ctx, run)
+	s.prune(ctx, run.Schedule)
+}
+
+// record stores a run and publishes it on the event bus.
+func (s *Scheduler) record(ctx context.Context, run *types.ScheduleRun) {
+	// Runs aborted by Stop are still recorded
+	ctx = context.WithoutCancel(ctx)
+	if err := s.storage.Put(ctx, []string{"schedule", run.Schedule, run.ID}, run); err != nil {
+		logging.Warn().Err(err).Str("schedule", run.Schedule).Msg("Failed to record scheduled run")
+	}
+
+	event.PublishSync(event.Event{
+		Type: event.ScheduleRun,
+		Data: event.ScheduleRunData{Run: run},
+	})
+}
+
+// prune removes the oldest runs of an entry beyond historyLimit.
+func (s *Scheduler) prune(ctx context.Context, name string) {
+	ctx = context.WithoutCancel(ctx)
+	ids, err := s.storage.List(ctx, []string{"schedule", name})
+	if err != nil || len(ids) <= historyLimit {
+		return
+	}
+	sort.Strings(ids)
+	for _, id := range ids[:len(ids)-historyLimit] {
+		_ = s.storage.Delete(ctx, []string{"schedule", name, id})
+	}
+}
+
+// History returns the recorded runs of an entry, latest due first. A limit
+// of zero or less returns them all.
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]*types.ScheduleRun, error) {
+	var runs []*types.ScheduleRun
+	err := s.storage.Scan(ctx, []string{"schedule", name}, func(key string, data json.RawMessage) error {
+		var run types.ScheduleRun
+		if err := json.Unmarshal(data, &run); err != nil {
+			return err
+		}
+		runs = append(runs, &run)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Run IDs are ULIDs, ordered by start
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].Due != runs[j].Due {
+			return runs[i].Due > runs[j].Due
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// Entries returns the state of every entry, sorted by name.
+func (s *Scheduler) Entries(ctx context.Context) []Status {
+	statuses := make([]Status, 0, len(s.entries))
+	for _, e := range s.entries {
+		statuses = append(statuses, s.status(ctx, e))
+	}
+	return statuses
+}
+
+// Entry returns the state of the named entry.
+func (s *Scheduler) Entry(ctx context.Context, name string) (Status, bool) {
+	for _, e := range s.entries {
+		if e.name == name {
+			return s.status(ctx, e), true
+		}
+	}
+	return Status{}, false
+}
+
+func (s *Scheduler) status(ctx context.Context, e *entry) Status {
+	s.mu.Lock()
+	status := Status{
+		Name:    e.name,
+		Config:  e.config,
+		Running: e.active,
+	}
+	if !e.next.IsZero() {
+		status.Next = e.next.UnixMilli()
+	}
+	s.mu.Unlock()
+
+	if runs, err := s.History(ctx, e.name, 1); err == nil && len(runs) > 0 {
+		status.LastRun = runs[0]
+	}
+	return status
+}
@@ -0,0 +1,248 @@
+package schedule
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// gateProvider answers every completion once its gate is open. With a
+// command set, its first answer runs the command through the bash tool.
+type gateProvider struct {
+	gate    chan struct{}
+	command string
+	calls   atomic.Int32
+}
+
+func (p *gateProvider) ID() string   { return "gate" }
+func (p *gateProvider) Name() string { return "Gate" }
+func (p *gateProvider) Models() []types.Model {
+	return []types.Model{{ID: "gate-1", ProviderID: "gate", Name: "Gate 1"}}
+}
+func (p *gateProvider) ChatModel() model.ToolCallingChatModel { return nil }
+
+func (p *gateProvider) CreateCompletion(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionStream, error) {
+	select {
+	case <-p.gate:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.command != "" && p.calls.Add(1) == 1 {
+		index := 0
+		return provider.NewCompletionStream(schema.StreamReaderFromArray([]*schema.Message{{
+			Role: schema.Assistant,
+			ToolCalls: []schema.ToolCall{{
+				Index:    &index,
+				ID:       "call1",
+				Function: schema.FunctionCall{Name: "bash", Arguments: `{"command":"` + p.command + `"}`},
+			}},
+			ResponseMeta: &schema.ResponseMeta{FinishReason: "tool_calls"},
+		}})), nil
+	}
+	return provider.NewCompletionStream(schema.StreamReaderFromArray([]*schema.Message{{
+		Role:         schema.Assistant,
+		Content:      "Done",
+		ResponseMeta: &schema.ResponseMeta{FinishReason: "stop"},
+	}})), nil
+}
+
+// newScheduler returns a scheduler for one entry, with its gate open unless
+// closed is false, and a clock at now.
+func newScheduler(t *testing.T, cfg types.ScheduleConfig, open bool, now time.Time) (*Scheduler, *gateProvider) {
+	t.Helper()
+	store := storage.New(t.TempDir())
+	dir := t.TempDir()
+
+	prov := &gateProvider{gate: make(chan struct{})}
+	if open {
+		close(prov.gate)
+	}
+	providerReg := provider.NewRegistry(nil)
+	providerReg.Register(prov)
+	sessions := session.NewServiceWithProcessor(store, providerReg, tool.NewRegistry(dir, store), nil, "gate", "gate-1")
+
+	s, err := New(sessions, store, dir, map[string]types.ScheduleConfig{"nightly": cfg})
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+	t.Cleanup(s.Stop)
+	return s, prov
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, cfg := range map[string]types.ScheduleConfig{
+		"cron":    {Cron: "every night", Prompt: "hi"},
+		"neither": {Cron: "@daily"},
+		"both":    {Cron: "@daily", Prompt: "hi", Command: "review"},
+		"catchUp": {Cron: "@daily", Prompt: "hi", CatchUp: "later"},
+		"overlap": {Cron: "@daily", Prompt: "hi", Overlap: "never"},
+	} {
+		_, err := New(nil, nil, "", map[string]types.ScheduleConfig{name: cfg})
+		assert.Error(t, err, name)
+	}
+
+	s, err := New(nil, nil, "", map[string]types.ScheduleConfig{
+		"off": {Cron: "every night", Disable: true},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, s.entries)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	now := time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC)
+	s, _ := newScheduler(t, types.ScheduleConfig{Cron: "0 3 * * *", Prompt: "Triage new TODOs"}, true, now)
+	ctx := context.Background()
+
+	e := s.entries[0]
+	e.next = now
+	s.tick(ctx, now)
+	s.wg.Wait()
+
+	runs, err := s.History(ctx, "nightly", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	run := runs[0]
+	assert.Equal(t, StatusCompleted, run.Status, run.Error)
+	assert.Equal(t, now.UnixMilli(), run.Due)
+	assert.False(t, run.CatchUp)
+	assert.NotZero(t, run.Finished)
+
+	sess, err := s.sessions.Get(ctx, run.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "Scheduled: nightly", sess.Title)
+	messages, err := s.sessions.GetMessages(ctx, run.SessionID)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "assistant", messages[1].Role)
+
+	// The next run is due the next night
+	assert.Equal(t, now.AddDate(0, 0, 1), e.next)
+	status, ok := s.Entry(ctx, "nightly")
+	require.True(t, ok)
+	assert.Equal(t, run.ID, status.LastRun.ID)
+	assert.Equal(t, now.AddDate(0, 0, 1).UnixMilli(), status.Next)
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	now := time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		catchUp string
+		want    []bool // CatchUp of each run, oldest first
+	}{
+		{"", []bool{false}},
+		{CatchUpOnce, []bool{true, false}},
+		{CatchUpAll, []bool{true, true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.catchUp, func(t *testing.T) {
+			s, _ := newScheduler(t, types.ScheduleConfig{Cron: "0 3 * * *", Prompt: "hi", CatchUp: tt.catchUp}, true, now)
+			ctx := context.Background()
+
+			// The machine slept for three nights
+			s.entries[0].next = now.AddDate(0, 0, -3)
+			s.tick(ctx, now)
+			s.wg.Wait()
+
+			runs, err := s.History(ctx, "nightly", 0)
+			require.NoError(t, err)
+			require.Len(t, runs, len(tt.want))
+			for i, catchUp := range tt.want {
+				run := runs[len(runs)-1-i]
+				assert.Equal(t, catchUp, run.CatchUp)
+				assert.Equal(t, StatusCompleted, run.Status)
+			}
+			assert.Equal(t, now.UnixMilli(), runs[0].Due)
+		})
+	}
+}
+
+func TestScheduler_MissedRuns(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	s, _ := newScheduler(t, types.ScheduleConfig{Cron: "0 3 * * *", Prompt: "hi", CatchUp: CatchUpAll}, true, now)
+	ctx := context.Background()
+
+	// Never ran: nothing to make up
+	assert.Empty(t, s.missedRuns(ctx, s.entries[0], now))
+
+	last := time.Date(2025, 1, 12, 3, 0, 0, 0, time.UTC)
+	s.record(ctx, &types.ScheduleRun{ID: "01", Schedule: "nightly", Status: StatusCompleted, Due: last.UnixMilli()})
+	missed := s.missedRuns(ctx, s.entries[0], now)
+	assert.Equal(t, []time.Time{last.AddDate(0, 0, 1), last.AddDate(0, 0, 2), last.AddDate(0, 0, 3)}, missed)
+}
+
+func TestScheduler_OverlapSkip(t *testing.T) {
+	now := time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC)
+	s, prov := newScheduler(t, types.ScheduleConfig{Cron: "* * * * *", Prompt: "hi"}, false, now)
+	ctx := context.Background()
+	e := s.entries[0]
+
+	e.next = now
+	s.tick(ctx, now)
+
+	// The first run waits on the provider, so the next one is skipped
+	later := now.Add(time.Minute)
+	s.tick(ctx, later)
+	close(prov.gate)
+	s.wg.Wait()
+
+	runs, err := s.History(ctx, "nightly", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, StatusSkipped, runs[0].Status)
+	assert.Equal(t, later.UnixMilli(), runs[0].Due)
+	assert.Empty(t, runs[0].SessionID)
+	assert.Equal(t, StatusCompleted, runs[1].Status)
+}
+
+func TestScheduler_DeniesPermissionRequests(t *testing.T) {
+	now := time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC)
+	store := storage.New(t.TempDir())
+	dir := t.TempDir()
+
+	prov := &gateProvider{gate: make(chan struct{}), command: "echo hi"}
+	close(prov.gate)
+	providerReg := provider.NewRegistry(nil)
+	providerReg.Register(prov)
+	toolReg := tool.NewRegistry(dir, store)
+	toolReg.Register(tool.NewBashTool(dir))
+	sessions := session.NewServiceWithProcessor(store, providerReg, toolReg, permission.NewChecker(), "gate", "gate-1")
+
+	s, err := New(sessions, store, dir, map[string]types.ScheduleConfig{"nightly": {Cron: "0 3 * * *", Prompt: "Clean up"}})
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+	t.Cleanup(s.Stop)
+	ctx := context.Background()
+
+	// The default agent asks before running commands; nobody can answer
+	s.entries[0].next = now
+	done := make(chan struct{})
+	go func() {
+		s.tick(ctx, now)
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the run blocked on a permission request")
+	}
+
+	runs, err := s.History(ctx, "nightly", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, StatusFailed, runs[0].Status)
+	assert.Contains(t, runs[0].Error, "bash")
+}
@@ -0,0 +1,466 @@
+// Package search provides full-text search over stored sessions.
+//
+// The index covers text parts, reasoning parts and tool outputs. It is held in
+// memory and kept up to date from the event bus, so only parts that change are
+// re-indexed. It is saved to storage, and a saved index is loaded and caught
+// up with what was written since rather than rebuilt.
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// maxIndexedBytes caps how much of a single part is indexed. Large tool
+// outputs, such as whole files, are only searchable up to this size.
+const maxIndexedBytes = 256 * 1024
+
+// flushThreshold is the number of queued changes that triggers indexing
+// without waiting for the next search.
+const flushThreshold = 1000
+
+// Part kinds that are indexed.
+const (
+	KindText      = "text"
+	KindReasoning = "reasoning"
+	KindTool      = "tool"
+)
+
+// document is an indexed part.
+type document struct {
+	sessionID string
+	messageID string
+	kind      string
+	tool      string
+	terms     []string // Distinct terms, for removal
+	length    int      // Total term count
+}
+
+// messageMeta is the part of a message that search filters on.
+type messageMeta struct {
+	sessionID string
+	agent     string
+	created   int64
+}
+
+// sessionMeta is the part of a session that search filters on and reports.
+type sessionMeta struct {
+	projectID string
+	title     string
+}
+
+// partText is the searchable content of a part, captured when it changed.
+type partText struct {
+	id        string
+	sessionID string
+	messageID string
+	kind      string
+	tool      string
+	text      string
+}
+
+// changeOp is a queued index update.
+type changeOp int
+
+const (
+	opPart changeOp = iota
+	opMessage
+	opSession
+	opRemovePart
+	opRemoveMessage
+	opRemoveSession
+)
+
+type change struct {
+	op      changeOp
+	id      string
+	message messageMeta
+	session sessionMeta
+}
+
+// Index is an inverted index of session content.
+type Index struct {
+	storage storage.Storage
+
+	mu       sync.Mutex
+	built    bool
+	docs     map[string]*document      // By part ID
+	postings map[string]map[string]int // Term -> part ID -> term frequency
+	messages map[string]messageMeta    // By message ID
+	sessions map[string]sessionMeta    // By session ID
+	synced   time.Time                 // Records written before this are indexed
+	dirty    bool                      // Changed since loaded or saved
+
+	// Changes received from the event bus, applied on the next search.
+	// Updates to the same part are coalesced, as parts are republished
+	// on every streamed delta.
+	pendingMu    sync.Mutex
+	pending      []change
+	pendingParts map[string]partText
+	flushing     bool
+
+	unsubscribe func()
+}
+
+// NewIndex creates an empty index over store. Call Subscribe to keep it
+// current; it is loaded from storage on first search or by Load.
+func NewIndex(store storage.Storage) *Index {
+	return &Index{
+		storage:      store,
+		docs:         make(map[string]*document),
+		postings:     make(map[string]map[string]int),
+		messages:     make(map[string]messageMeta),
+		sessions:     make(map[string]sessionMeta),
+		pendingParts: make(map[string]partText),
+	}
+}
+
+// Subscribe starts following session, message and part events.
+func (i *Index) Subscribe() {
+	i.unsubscribe = event.SubscribeAll(i.onEvent)
+}
+
+// Close stops following events.
+func (i *Index) Close() {
+	if i.unsubscribe != nil {
+		i.unsubscribe()
+	}
+}
+
+// onEvent queues an index update. It runs in the publisher's goroutine, so it
+// only records what changed.
+func (i *Index) onEvent(e event.Event) {
+	var c change
+	switch data := e.Data.(type) {
+	case event.MessagePartUpdatedData:
+		text, ok := extractText(data.Part)
+		if !ok {
+			return
+		}
+		i.pendingMu.Lock()
+		if _, queued := i.pendingParts[text.id]; !queued {
+			i.pending = append(i.pending, change{op: opPart, id: text.id})
+		}
+		i.pendingParts[text.id] = text
+		i.queued()
+		i.pendingMu.Unlock()
+		return
+
+	case event.MessageCreatedData:
+		c = messageChange(data.Info)
+	case event.MessageUpdatedData:
+		c = messageChange(data.Info)
+	case event.SessionCreatedData:
+		c = sessionChange(data.Info)
+	case event.SessionUpdatedData:
+		c = sessionChange(data.Info)
+	case event.SessionDeletedData:
+		if data.Info == nil {
+			return
+		}
+		c = change{op: opRemoveSession, id: data.Info.ID}
+	case event.MessageRemovedData:
+		c = change{op: opRemoveMessage, id: data.MessageID}
+	case event.MessagePartRemovedData:
+		c = change{op: opRemovePart, id: data.PartID}
+	default:
+		return
+	}
+
+	if c.id == "" {
+		return
+	}
+
+	i.pendingMu.Lock()
+	i.pending = append(i.pending, c)
+	i.queued()
+	i.pendingMu.Unlock()
+}
+
+// queued starts a background flush once enough changes have accumulated.
+// Must be called with pendingMu held.
+func (i *Index) queued() {
+	if len(i.pending) < flushThreshold || i.flushing {
+		return
+	}
+	i.flushing = true
+	go func() {
+		i.mu.Lock()
+		if i.built {
+			i.flush()
+		}
+		i.mu.Unlock()
+
+		i.pendingMu.Lock()
+		i.flushing = false
+		i.pendingMu.Unlock()
+	}()
+}
+
+func messageChange(msg *types.Message) change {
+	if msg == nil {
+		return change{}
+	}
+	return change{op: opMessage, id: msg.ID, message: newMessageMeta(msg)}
+}
+
+func sessionChange(session *types.Session) change {
+	if session == nil {
+		return change{}
+	}
+	return change{op: opSession, id: session.ID, session: newSessionMeta(session)}
+}
+
+func newMessageMeta(msg *types.Message) messageMeta {
+	agent := msg.Agent
+	if agent == "" {
+		agent = msg.Mode // Assistant messages record the agent as their mode
+	}
+	return messageMeta{sessionID: msg.SessionID, agent: agent, created: msg.Time.Created}
+}
+
+func newSessionMeta(session *types.Session) sessionMeta {
+	return sessionMeta{projectID: session.ProjectID, title: session.Title}
+}
+
+// extractText returns the searchable content of a part, or false if the
+// part kind is not indexed.
+func extractText(part types.Part) (partText, bool) {
+	text := partText{id: part.PartID(), sessionID: part.PartSessionID(), messageID: part.PartMessageID()}
+
+	switch p := part.(type) {
+	case *types.TextPart:
+		text.kind, text.text = KindText, p.Text
+	case *types.ReasoningPart:
+		text.kind, text.text = KindReasoning, p.Text
+	case *types.ToolPart:
+		text.kind, text.tool = KindTool, p.Tool
+		switch p.State.Status {
+		case "completed":
+			text.text = p.State.Title + "\n" + p.State.Output
+		case "error":
+			text.text = p.State.Error
+		default:
+			return partText{}, false // Nothing to index until it finishes
+		}
+	default:
+		return partText{}, false
+	}
+
+	return text, true
+}
+
+// Build indexes everything in storage, replacing the current contents.
+// Changes received while building are applied afterwards.
+func (i *Index) Build(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.build(ctx)
+}
+
+// ensureBuilt loads the index if it has not been loaded yet and applies
+// queued changes. Must be called with mu held.
+func (i *Index) ensureBuilt(ctx context.Context) error {
+	if !i.built {
+		if err := i.load(ctx); err != nil {
+			return err
+		}
+	}
+	i.flush()
+	return nil
+}
+
+// build scans storage into a fresh index. Must be called with mu held.
+func (i *Index) build(ctx context.Context) error {
+	start := time.Now()
+	i.docs = make(map[string]*document)
+	i.postings = make(map[string]map[string]int)
+	i.messages = make(map[string]messageMeta)
+	i.sessions = make(map[string]sessionMeta)
+
+	projects, err := i.storage.List(ctx, []string{"session"})
+	if err != nil {
+		return err
+	}
+
+	for _, projectID := range projects {
+		var sessionIDs []string
+		err := i.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
+			var session types.Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				return nil // Skip unreadable sessions
+			}
+			i.sessions[session.ID] = newSessionMeta(&session)
+			sessionIDs = append(sessionIDs, session.ID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, sessionID := range sessionIDs {
+			if err := i.buildSession(ctx, sessionID); err != nil {
+				return err
+			}
+		}
+	}
+
+	i.built = true
+	i.synced = start
+	i.dirty = true
+	i.flush()
+	return nil
+}
+
+// buildSession indexes the messages and parts of one session.
+func (i *Index) buildSession(ctx context.Context, sessionID string) error {
+	var messageIDs []string
+	err := i.storage.Scan(ctx, []string{"message", sessionID}, func(key string, data json.RawMessage) error {
+		var msg types.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil
+		}
+		i.messages[msg.ID] = newMessageMeta(&msg)
+		messageIDs = append(messageIDs, msg.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, messageID := range messageIDs {
+		err := i.storage.Scan(ctx, []string{"part", messageID}, func(key string, data json.RawMessage) error {
+			part, err := types.UnmarshalPart(data)
+			if err != nil {
+				return nil
+			}
+			if text, ok := extractText(part); ok {
+				i.indexPart(text)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flush applies queued changes. Must be called with mu held.
+func (i *Index) flush() {
+	i.pendingMu.Lock()
+	pending := i.pending
+	parts := i.pendingParts
+	i.pending = nil
+	i.pendingParts = make(map[string]partText)
+	i.pendingMu.Unlock()
+
+	if len(pending) > 0 {
+		i.dirty = true
+	}
+
+	for _, c := range pending {
+		switch c.op {
+		case opPart:
+			i.indexPart(parts[c.id])
+		case opMessage:
+			i.messages[c.id] = c.message
+		case opSession:
+			i.sessions[c.id] = c.session
+		case opRemovePart:
+			i.removePart(c.id)
+		case opRemoveMessage:
+			i.removeMessage(c.id)
+		case opRemoveSession:
+			i.removeSession(c.id)
+		}
+	}
+}
+
+// indexPart replaces the postings of a part. Must be called with mu held.
+func (i *Index) indexPart(text partText) {
+	i.removePart(text.id)
+
+	content := text.text
+	if len(content) > maxIndexedBytes {
+		content = content[:maxIndexedBytes]
+	}
+
+	counts := make(map[string]int)
+	length := 0
+	tokenize(content, func(term string, start, end int) {
+		counts[term]++
+		length++
+	})
+	if length == 0 {
+		return
+	}
+
+	doc := &document{
+		sessionID: text.sessionID,
+		messageID: text.messageID,
+		kind:      text.kind,
+		tool:      text.tool,
+		terms:     make([]string, 0, len(counts)),
+		length:    length,
+	}
+	for term, n := range counts {
+		postings, ok := i.postings[term]
+		if !ok {
+			postings = make(map[string]int)
+			i.postings[term] = postings
+		}
+		postings[text.id] = n
+		doc.terms = append(doc.terms, term)
+	}
+	i.docs[text.id] = doc
+}
+
+// removePart drops a part from the index. Must be called with mu held.
+func (i *Index) removePart(partID string) {
+	doc, ok := i.docs[partID]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		postings := i.postings[term]
+		delete(postings, partID)
+		if len(postings) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.docs, partID)
+}
+
+// removeMessage drops a message and its parts. Must be called with mu held.
+func (i *Index) removeMessage(messageID string) {
+	for partID, doc := range i.docs {
+		if doc.messageID == messageID {
+			i.removePart(partID)
+		}
+	}
+	delete(i.messages, messageID)
+}
+
+// removeSession drops a session with its messages and parts. Must be called with mu held.
+func (i *Index) removeSession(sessionID string) {
+	for partID, doc := range i.docs {
+		if doc.sessionID == sessionID {
+			i.removePart(partID)
+		}
+	}
+	for messageID, meta := range i.messages {
+		if meta.sessionID == sessionID {
+			delete(i.messages, messageID)
+		}
+	}
+	delete(i.sessions, sessionID)
+}
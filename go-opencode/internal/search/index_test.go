@@ -0,0 +1,233 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// seed stores a session with one message holding the given parts.
+func seed(t *testing.T, store storage.Storage, projectID, sessionID, agent string, created time.Time, parts ...types.Part) {
+	t.Helper()
+	ctx := context.Background()
+
+	session := &types.Session{ID: sessionID, ProjectID: projectID, Title: "Session " + sessionID}
+	require.NoError(t, store.Put(ctx, []string{"session", projectID, sessionID}, session))
+
+	msgID := sessionID + "-msg"
+	msg := &types.Message{ID: msgID, SessionID: sessionID, Role: "assistant", Mode: agent, Time: types.MessageTime{Created: created.UnixMilli()}}
+	require.NoError(t, store.Put(ctx, []string{"message", sessionID, msgID}, msg))
+
+	for _, part := range parts {
+		require.NoError(t, store.Put(ctx, []string{"part", msgID, part.PartID()}, part))
+	}
+}
+
+func textPart(sessionID, id, text string) *types.TextPart {
+	return &types.TextPart{ID: id, SessionID: sessionID, MessageID: sessionID + "-msg", Type: "text", Text: text}
+}
+
+func partIDs(hits []Hit) []string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.PartID
+	}
+	return ids
+}
+
+func TestIndex_SearchAndFilters(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	old := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	seed(t, store, "proj1", "ses1", "build", old,
+		textPart("ses1", "p1", "The SSE handler did not Flush after each event."),
+		&types.ReasoningPart{ID: "p2", SessionID: "ses1", MessageID: "ses1-msg", Type: "reasoning", Text: "Maybe the flush is buffered"},
+	)
+	seed(t, store, "proj2", "ses2", "plan", recent,
+		&types.ToolPart{ID: "p3", SessionID: "ses2", MessageID: "ses2-msg", Type: "tool", Tool: "bash",
+			State: types.ToolState{Status: "completed", Title: "go test", Output: "FAIL: sse stream flush timeout"}},
+		&types.ToolPart{ID: "p4", SessionID: "ses2", MessageID: "ses2-msg", Type: "tool", Tool: "bash",
+			State: types.ToolState{Status: "running", Output: "sse flush"}},
+	)
+
+	idx := NewIndex(store)
+
+	hits, err := idx.Search(ctx, Query{Text: "SSE flush"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"p1", "p3"}, partIDs(hits))
+
+	hits, err = idx.Search(ctx, Query{Text: "flush", ProjectID: "proj1"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"p1", "p2"}, partIDs(hits))
+
+	hits, err = idx.Search(ctx, Query{Text: "flush", Agent: "plan"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"p3"}, partIDs(hits))
+	assert.Equal(t, KindTool, hits[0].Kind)
+	assert.Equal(t, "bash", hits[0].Tool)
+	assert.Equal(t, "Session ses2", hits[0].SessionTitle)
+
+	hits, err = idx.Search(ctx, Query{Text: "flush", Since: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p3"}, partIDs(hits))
+
+	hits, err = idx.Search(ctx, Query{Text: "flush", Until: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+
+	_, err = idx.Search(ctx, Query{Text: " - "})
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+}
+
+func TestIndex_FollowsEvents(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	seed(t, store, "proj1", "ses1", "build", time.Now(), textPart("ses1", "p1", "nothing interesting"))
+
+	idx := NewIndex(store)
+	idx.Subscribe()
+	defer idx.Close()
+	require.NoError(t, idx.Build(ctx))
+
+	// A streamed part is republished on every delta
+	part := textPart("ses1", "p1", "the answer is")
+	event.PublishSync(event.Event{Type: event.MessagePartUpdated, Data: event.MessagePartUpdatedData{Part: part}})
+	part = textPart("ses1", "p1", "the answer is kangaroo")
+	require.NoError(t, store.Put(ctx, []string{"part", "ses1-msg", "p1"}, part))
+	event.PublishSync(event.Event{Type: event.MessagePartUpdated, Data: event.MessagePartUpdatedData{Part: part}})
+
+	hits, err := idx.Search(ctx, Query{Text: "kangaroo"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, partIDs(hits))
+
+	hits, err = idx.Search(ctx, Query{Text: "interesting"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	event.PublishSync(event.Event{Type: event.SessionDeleted, Data: event.SessionDeletedData{Info: &types.Session{ID: "ses1"}}})
+	hits, err = idx.Search(ctx, Query{Text: "kangaroo"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+}
+
+func TestIndex_DropsPartsMissingFromStorage(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	seed(t, store, "proj1", "ses1", "build", time.Now(), textPart("ses1", "p1", "wombat"))
+
+	idx := NewIndex(store)
+	require.NoError(t, idx.Build(ctx))
+
+	// Removed without an event, e.g. by garbage collection
+	require.NoError(t, store.Delete(ctx, []string{"part", "ses1-msg", "p1"}))
+
+	hits, err := idx.Search(ctx, Query{Text: "wombat"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+	assert.NotContains(t, idx.docs, "p1")
+}
+
+func TestIndex_LoadsSavedIndex(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	seed(t, store, "proj1", "ses1", "build", time.Now(), textPart("ses1", "p1", "wombat burrow"))
+	seed(t, store, "proj1", "ses2", "build", time.Now(), textPart("ses2", "p2", "wombat tunnel"))
+
+	idx := NewIndex(store)
+	hits, err := idx.Search(ctx, Query{Text: "wombat"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"p1", "p2"}, partIDs(hits))
+	require.NoError(t, idx.Save(ctx))
+
+	// Pretend it was saved later, so nothing is caught up: the saved
+	// terms are used rather than the stored text
+	var snap snapshot
+	require.NoError(t, store.Get(ctx, snapshotPath, &snap))
+	snap.Synced = time.Now().Add(time.Hour).UnixMilli()
+	require.NoError(t, store.Put(ctx, snapshotPath, &snap))
+	require.NoError(t, store.Put(ctx, []string{"part", "ses1-msg", "p1"}, textPart("ses1", "p1", "koala")))
+
+	loaded := NewIndex(store)
+	hits, err = loaded.Search(ctx, Query{Text: "burrow"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, partIDs(hits))
+	hits, err = loaded.Search(ctx, Query{Text: "koala"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	// Written since the save: re-indexed. Removed since: dropped.
+	snap.Synced = time.Now().Add(-time.Hour).UnixMilli()
+	require.NoError(t, store.Put(ctx, snapshotPath, &snap))
+	require.NoError(t, store.Delete(ctx, []string{"session", "proj1", "ses2"}))
+
+	caughtUp := NewIndex(store)
+	hits, err = caughtUp.Search(ctx, Query{Text: "koala"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, partIDs(hits))
+	hits, err = caughtUp.Search(ctx, Query{Text: "wombat"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+	assert.NotContains(t, caughtUp.sessions, "ses2")
+	assert.NotContains(t, caughtUp.docs, "p2")
+
+	// Saved again, the next load needs no catching up
+	require.NoError(t, caughtUp.Save(ctx))
+	var saved snapshot
+	require.NoError(t, store.Get(ctx, snapshotPath, &saved))
+	assert.Greater(t, saved.Synced, time.Now().Add(-2*time.Minute).UnixMilli())
+	assert.Contains(t, saved.Parts, "p1")
+	assert.NotContains(t, saved.Parts, "p2")
+}
+
+func TestSnippet(t *testing.T) {
+	text := "Line one.\n" +
+		"Some long preamble that pushes the match well past the start of the part text. " +
+		"Then we FIXED the flush bug in sse.go and the stream worked again, " +
+		"followed by plenty more text so the window is cut at the end as well as the start of it."
+
+	s, highlights := snippet(text, map[string]bool{"flush": true, "sse": true})
+	assert.True(t, len(s) < len(text))
+	assert.NotContains(t, s, "\n")
+	assert.Equal(t, "…", s[:len("…")])
+
+	var marked []string
+	for _, h := range highlights {
+		marked = append(marked, s[h[0]:h[1]])
+	}
+	assert.Equal(t, []string{"flush", "sse"}, marked)
+}
+
+func TestParseTime(t *testing.T) {
+	ts, err := ParseTime("1700000000000")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000000), ts.UnixMilli())
+
+	ts, err = ParseTime("2025-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, 2025, ts.Year())
+
+	_, err = ParseTime("2025-01-02")
+	require.NoError(t, err)
+
+	_, err = ParseTime("yesterday")
+	assert.Error(t, err)
+
+	// A plain date ends with the day
+	ts, err = ParseUntil("2025-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 3, 0, 0, 0, 0, time.Local).Add(-time.Millisecond), ts)
+	ts, err = ParseUntil("2025-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, 3, ts.Hour())
+	_, err = ParseUntil("yesterday")
+	assert.Error(t, err)
+}
@@ -0,0 +1,257 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// snapshotVersion changes whenever the terms stored for a part would differ,
+// so saved indexes from other versions are rebuilt.
+const snapshotVersion = 1
+
+// catchUpSlack is how far before the save time records are re-read when a
+// saved index is loaded. It covers writes whose events had not reached the
+// index yet when it was saved.
+const catchUpSlack = time.Minute
+
+// snapshotPath is where the index is saved. Keeping it in storage means it
+// is encrypted along with the sessions it was built from.
+var snapshotPath = []string{"search", "index"}
+
+// snapshot is the saved form of the index.
+type snapshot struct {
+	Version  int                        `json:"version"`
+	Synced   int64                      `json:"synced"` // Records written before this time are indexed, unix ms
+	Sessions map[string]snapshotSession `json:"sessions"`
+	Messages map[string]snapshotMessage `json:"messages"`
+	Parts    map[string]snapshotPart    `json:"parts"`
+}
+
+type snapshotSession struct {
+	ProjectID string `json:"projectID"`
+	Title     string `json:"title"`
+}
+
+type snapshotMessage struct {
+	SessionID string `json:"sessionID"`
+	Agent     string `json:"agent,omitempty"`
+	Created   int64  `json:"created"`
+}
+
+type snapshotPart struct {
+	SessionID string         `json:"sessionID"`
+	MessageID string         `json:"messageID"`
+	Kind      string         `json:"kind"`
+	Tool      string         `json:"tool,omitempty"`
+	Terms     map[string]int `json:"terms"` // Term frequencies
+}
+
+// Load restores the index saved in storage and re-indexes what was written
+// since it was saved. Without a usable saved index it builds one from
+// storage, as Build does.
+func (i *Index) Load(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.load(ctx)
+}
+
+// load restores the saved index or builds a new one. Must be called with mu held.
+func (i *Index) load(ctx context.Context) error {
+	var snap snapshot
+	if err := i.storage.Get(ctx, snapshotPath, &snap); err != nil || snap.Version != snapshotVersion {
+		return i.build(ctx)
+	}
+
+	i.restore(&snap)
+	if err := i.catchUp(ctx, time.UnixMilli(snap.Synced)); err != nil {
+		return err
+	}
+
+	i.built = true
+	i.flush()
+	return nil
+}
+
+// Save writes the index to storage if it changed since it was loaded or
+// last saved.
+func (i *Index) Save(ctx context.Context) error {
+	i.mu.Lock()
+	if !i.built {
+		i.mu.Unlock()
+		return nil
+	}
+	// Events keep a subscribed index current up to now
+	if i.unsubscribe != nil {
+		i.synced = time.Now()
+	}
+	i.flush()
+	if !i.dirty {
+		i.mu.Unlock()
+		return nil
+	}
+	snap := i.snapshot()
+	i.dirty = false
+	i.mu.Unlock()
+
+	if err := i.storage.Put(ctx, snapshotPath, snap); err != nil {
+		i.mu.Lock()
+		i.dirty = true
+		i.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// snapshot captures the index for saving. Must be called with mu held.
+func (i *Index) snapshot() *snapshot {
+	snap := &snapshot{
+		Version:  snapshotVersion,
+		Synced:   i.synced.Add(-catchUpSlack).UnixMilli(),
+		Sessions: make(map[string]snapshotSession, len(i.sessions)),
+		Messages: make(map[string]snapshotMessage, len(i.messages)),
+		Parts:    make(map[string]snapshotPart, len(i.docs)),
+	}
+	for id, meta := range i.sessions {
+		snap.Sessions[id] = snapshotSession{ProjectID: meta.projectID, Title: meta.title}
+	}
+	for id, meta := range i.messages {
+		snap.Messages[id] = snapshotMessage{SessionID: meta.sessionID, Agent: meta.agent, Created: meta.created}
+	}
+	for id, doc := range i.docs {
+		terms := make(map[string]int, len(doc.terms))
+		for _, term := range doc.terms {
+			terms[term] = i.postings[term][id]
+		}
+		snap.Parts[id] = snapshotPart{SessionID: doc.sessionID, MessageID: doc.messageID, Kind: doc.kind, Tool: doc.tool, Terms: terms}
+	}
+	return snap
+}
+
+// restore replaces the contents of the index with a saved one. Must be
+// called with mu held.
+func (i *Index) restore(snap *snapshot) {
+	i.docs = make(map[string]*document, len(snap.Parts))
+	i.postings = make(map[string]map[string]int)
+	i.messages = make(map[string]messageMeta, len(snap.Messages))
+	i.sessions = make(map[string]sessionMeta, len(snap.Sessions))
+
+	for id, s := range snap.Sessions {
+		i.sessions[id] = sessionMeta{projectID: s.ProjectID, title: s.Title}
+	}
+	for id, m := range snap.Messages {
+		i.messages[id] = messageMeta{sessionID: m.SessionID, agent: m.Agent, created: m.Created}
+	}
+	for id, p := range snap.Parts {
+		doc := &document{
+			sessionID: p.SessionID,
+			messageID: p.MessageID,
+			kind:      p.Kind,
+			tool:      p.Tool,
+			terms:     make([]string, 0, len(p.Terms)),
+		}
+		for term, n := range p.Terms {
+			postings, ok := i.postings[term]
+			if !ok {
+				postings = make(map[string]int)
+				i.postings[term] = postings
+			}
+			postings[id] = n
+			doc.terms = append(doc.terms, term)
+			doc.length += n
+		}
+		i.docs[id] = doc
+	}
+}
+
+// catchUp re-indexes the messages and parts written since the given time
+// and drops the sessions and messages removed from storage. Sessions are
+// few, so they are all re-read. Must be called with mu held.
+func (i *Index) catchUp(ctx context.Context, since time.Time) error {
+	start := time.Now()
+	opts := storage.QueryOptions{Since: since}
+
+	projects, err := i.storage.List(ctx, []string{"session"})
+	if err != nil {
+		return err
+	}
+
+	var sessionIDs []string
+	for _, projectID := range projects {
+		err := i.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
+			var session types.Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				return nil
+			}
+			if meta := newSessionMeta(&session); i.sessions[session.ID] != meta {
+				i.sessions[session.ID] = meta
+				i.dirty = true
+			}
+			sessionIDs = append(sessionIDs, session.ID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	liveSessions := make(map[string]bool, len(sessionIDs))
+	liveMessages := make(map[string]bool)
+	for _, sessionID := range sessionIDs {
+		liveSessions[sessionID] = true
+
+		messageIDs, err := i.storage.List(ctx, []string{"message", sessionID})
+		if err != nil {
+			return err
+		}
+		err = i.storage.Query(ctx, []string{"message", sessionID}, opts, func(key string, data json.RawMessage) error {
+			var msg types.Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				return nil
+			}
+			i.messages[msg.ID] = newMessageMeta(&msg)
+			i.dirty = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, messageID := range messageIDs {
+			liveMessages[messageID] = true
+			err := i.storage.Query(ctx, []string{"part", messageID}, opts, func(key string, data json.RawMessage) error {
+				part, err := types.UnmarshalPart(data)
+				if err != nil {
+					return nil
+				}
+				if text, ok := extractText(part); ok {
+					i.indexPart(text)
+					i.dirty = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for sessionID := range i.sessions {
+		if !liveSessions[sessionID] {
+			i.removeSession(sessionID)
+			i.dirty = true
+		}
+	}
+	for messageID := range i.messages {
+		if !liveMessages[messageID] {
+			i.removeMessage(messageID)
+			i.dirty = true
+		}
+	}
+
+	i.synced = start
+	return nil
+}
@@ -0,0 +1,293 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ErrEmptyQuery is returned when a query contains no searchable terms.
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// DefaultLimit is the number of hits returned when a query sets no limit.
+const DefaultLimit = 20
+
+// Snippet window around the first match, in bytes.
+const (
+	snippetBefore = 60
+	snippetAfter  = 140
+)
+
+// Query selects parts to return. Every term in Text must occur in a part for
+// it to match. Empty filters match everything.
+type Query struct {
+	Text      string
+	ProjectID string
+	Agent     string
+	Since     time.Time // Messages created at or after
+	Until     time.Time // Messages created at or before
+	Limit     int
+}
+
+// Hit is a matching part.
+type Hit struct {
+	SessionID    string  `json:"sessionID"`
+	SessionTitle string  `json:"sessionTitle"`
+	ProjectID    string  `json:"projectID"`
+	MessageID    string  `json:"messageID"`
+	PartID       string  `json:"partID"`
+	Kind         string  `json:"kind"` // "text" | "reasoning" | "tool"
+	Tool         string  `json:"tool,omitempty"`
+	Agent        string  `json:"agent,omitempty"`
+	Time         int64   `json:"time"` // Message creation time, unix ms
+	Score        float64 `json:"score"`
+	Snippet      string  `json:"snippet"`
+	// Highlights are [start, end) byte offsets of matched terms in Snippet.
+	Highlights [][2]int `json:"highlights"`
+}
+
+// Search returns the parts matching q, best first.
+func (i *Index) Search(ctx context.Context, q Query) ([]Hit, error) {
+	terms := queryTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	i.mu.Lock()
+	if err := i.ensureBuilt(ctx); err != nil {
+		i.mu.Unlock()
+		return nil, err
+	}
+	candidates := i.match(terms, q)
+	i.mu.Unlock()
+
+	termSet := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		termSet[term] = true
+	}
+
+	// Snippets come from storage so the index does not keep a copy of every
+	// part. Parts that have since been removed are dropped from the index.
+	hits := make([]Hit, 0, limit)
+	for _, hit := range candidates {
+		if len(hits) == limit {
+			break
+		}
+
+		var raw json.RawMessage
+		if err := i.storage.Get(ctx, []string{"part", hit.MessageID, hit.PartID}, &raw); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				i.mu.Lock()
+				i.removePart(hit.PartID)
+				i.mu.Unlock()
+				continue
+			}
+			return nil, err
+		}
+		part, err := types.UnmarshalPart(raw)
+		if err != nil {
+			continue
+		}
+		text, ok := extractText(part)
+		if !ok {
+			continue
+		}
+
+		hit.Snippet, hit.Highlights = snippet(text.text, termSet)
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
+
+// match returns every part containing all terms that passes the filters of
+// q, ordered by score. Must be called with mu held.
+func (i *Index) match(terms []string, q Query) []Hit {
+	// Start from the rarest term so the intersection stays small
+	sort.Slice(terms, func(a, b int) bool {
+		return len(i.postings[terms[a]]) < len(i.postings[terms[b]])
+	})
+
+	first := i.postings[terms[0]]
+	total := float64(len(i.docs))
+
+	var hits []Hit
+	for partID := range first {
+		doc := i.docs[partID]
+
+		score := 0.0
+		for _, term := range terms {
+			tf, ok := i.postings[term][partID]
+			if !ok {
+				score = -1
+				break
+			}
+			idf := math.Log(1 + total/float64(len(i.postings[term])))
+			score += (1 + math.Log(float64(tf))) * idf
+		}
+		if score < 0 {
+			continue
+		}
+		// Prefer short parts where the terms are a larger share of the text
+		score /= math.Sqrt(float64(doc.length))
+
+		message := i.messages[doc.messageID]
+		session, hasSession := i.sessions[doc.sessionID]
+
+		if q.ProjectID != "" && (!hasSession || session.projectID != q.ProjectID) {
+			continue
+		}
+		if q.Agent != "" && message.agent != q.Agent {
+			continue
+		}
+		if !q.Since.IsZero() && message.created < q.Since.UnixMilli() {
+			continue
+		}
+		if !q.Until.IsZero() && message.created > q.Until.UnixMilli() {
+			continue
+		}
+
+		hits = append(hits, Hit{
+			SessionID:    doc.sessionID,
+			SessionTitle: session.title,
+			ProjectID:    session.projectID,
+			MessageID:    doc.messageID,
+			PartID:       partID,
+			Kind:         doc.kind,
+			Tool:         doc.tool,
+			Agent:        message.agent,
+			Time:         message.created,
+			Score:        score,
+		})
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].Time > hits[b].Time
+	})
+
+	return hits
+}
+
+// queryTerms returns the distinct terms of a query.
+func queryTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	tokenize(text, func(term string, start, end int) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	})
+	return terms
+}
+
+// tokenize calls fn for every term in text with its byte offsets. Terms are
+// runs of letters and digits, lowercased. Single characters are skipped.
+func tokenize(text string, fn func(term string, start, end int)) {
+	start := -1
+	emit := func(end int) {
+		if start >= 0 && utf8.RuneCountInString(text[start:end]) > 1 {
+			fn(strings.ToLower(text[start:end]), start, end)
+		}
+		start = -1
+	}
+
+	for pos, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = pos
+			}
+			continue
+		}
+		emit(pos)
+	}
+	emit(len(text))
+}
+
+// snippet cuts a window of text around the first matched term and returns it
+// on one line with the offsets of every matched term in it.
+func snippet(text string, terms map[string]bool) (string, [][2]int) {
+	first := -1
+	tokenize(text, func(term string, start, end int) {
+		if first < 0 && terms[term] {
+			first = start
+		}
+	})
+	if first < 0 {
+		first = 0
+	}
+
+	from := max(first-snippetBefore, 0)
+	to := min(first+snippetAfter, len(text))
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	window := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text[from:to])
+	if from > 0 {
+		window = "…" + window
+	}
+	if to < len(text) {
+		window += "…"
+	}
+
+	highlights := [][2]int{}
+	tokenize(window, func(term string, start, end int) {
+		if terms[term] {
+			highlights = append(highlights, [2]int{start, end})
+		}
+	})
+
+	return window, highlights
+}
+
+// ParseTime parses a search date filter: unix milliseconds, RFC 3339, or a
+// plain date (YYYY-MM-DD) in local time.
+func ParseTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use unix milliseconds, RFC 3339 or YYYY-MM-DD", s)
+}
+
+// ParseUntil parses the end of a search date filter as ParseTime does, except
+// that a plain date includes the whole day.
+func ParseUntil(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Millisecond), nil
+	}
+	return ParseTime(s)
+}
@@ -4,13 +4,20 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/opencode-ai/opencode/internal/event"
 
+	"github.com/opencode-ai/opencode/internal/auth"
+	"github.com/opencode-ai/opencode/internal/catalog"
 	"github.com/opencode-ai/opencode/internal/command"
 	"github.com/opencode-ai/opencode/internal/mcp"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/schedule"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -111,136 +118,131 @@ type ProvidersResponse struct {
 	Default   map[string]string `json:"default"`
 }
 
-// getDefaultProviders returns mock providers for TUI compatibility.
-// TODO: Replace with actual provider registration from models.dev.
+// getDefaultProviders returns the providers of the model catalog, and ARK,
+// whose models are the endpoints of its config.
 func getDefaultProviders() []ProviderInfo {
-	return []ProviderInfo{
-		{
-			ID:   "anthropic",
-			Name: "Anthropic",
-			Env:  []string{"ANTHROPIC_API_KEY"},
-			Npm:  "@ai-sdk/anthropic",
-			Models: map[string]ProviderModel{
-				"claude-sonnet-4-20250514": {
-					ID:          "claude-sonnet-4-20250514",
-					Name:        "Claude Sonnet 4",
-					ReleaseDate: "2025-05-14",
-					Capabilities: &ModelCapabilities{
-						Temperature: true,
-						Reasoning:   false,
-						Attachment:  true,
-						ToolCall:    true,
-						Input:       ModalityCapabilities{Text: true, Audio: false, Image: true, Video: false, PDF: true},
-						Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
-					},
-					Cost:    ModelCost{Input: 3.0, Output: 15.0, Cache: ModelCostCache{Read: 0.3, Write: 3.75}},
-					Limit:   ModelLimit{Context: 200000, Output: 64000},
-					Options: map[string]any{},
-				},
-				"claude-opus-4-20250514": {
-					ID:          "claude-opus-4-20250514",
-					Name:        "Claude Opus 4",
-					ReleaseDate: "2025-05-14",
-					Capabilities: &ModelCapabilities{
-						Temperature: true,
-						Reasoning:   false,
-						Attachment:  true,
-						ToolCall:    true,
-						Input:       ModalityCapabilities{Text: true, Audio: false, Image: true, Video: false, PDF: true},
-						Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
-					},
-					Cost:    ModelCost{Input: 15.0, Output: 75.0, Cache: ModelCostCache{Read: 1.5, Write: 18.75}},
-					Limit:   ModelLimit{Context: 200000, Output: 32000},
-					Options: map[string]any{},
-				},
-				"claude-3-5-haiku-20241022": {
-					ID:          "claude-3-5-haiku-20241022",
-					Name:        "Claude 3.5 Haiku",
-					ReleaseDate: "2024-10-22",
-					Capabilities: &ModelCapabilities{
-						Temperature: true,
-						Reasoning:   false,
-						Attachment:  true,
-						ToolCall:    true,
-						Input:       ModalityCapabilities{Text: true, Audio: false, Image: true, Video: false, PDF: true},
-						Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
-					},
-					Cost:    ModelCost{Input: 0.8, Output: 4.0, Cache: ModelCostCache{Read: 0.08, Write: 1.0}},
-					Limit:   ModelLimit{Context: 200000, Output: 8192},
-					Options: map[string]any{},
-				},
-			},
-		},
-		{
-			ID:   "openai",
-			Name: "OpenAI",
-			Env:  []string{"OPENAI_API_KEY"},
-			Npm:  "@ai-sdk/openai",
-			Models: map[string]ProviderModel{
-				"gpt-4o": {
-					ID:          "gpt-4o",
-					Name:        "GPT-4o",
-					ReleaseDate: "2024-05-13",
-					Capabilities: &ModelCapabilities{
-						Temperature: true,
-						Reasoning:   false,
-						Attachment:  true,
-						ToolCall:    true,
-						Input:       ModalityCapabilities{Text: true, Audio: false, Image: true, Video: false, PDF: false},
-						Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
-					},
-					Cost:    ModelCost{Input: 2.5, Output: 10.0, Cache: ModelCostCache{Read: 0, Write: 0}},
-					Limit:   ModelLimit{Context: 128000, Output: 16384},
-					Options: map[string]any{},
-				},
-				"gpt-4o-mini": {
-					ID:          "gpt-4o-mini",
-					Name:        "GPT-4o Mini",
-					ReleaseDate: "2024-07-18",
-					Capabilities: &ModelCapabilities{
-						Temperature: true,
-						Reasoning:   false,
-						Attachment:  true,
-						ToolCall:    true,
-						Input:       ModalityCapabilities{Text: true, Audio: false, Image: true, Video: false, PDF: false},
-						Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
-					},
-					Cost:    ModelCost{Input: 0.15, Output: 0.6, Cache: ModelCostCache{Read: 0, Write: 0}},
-					Limit:   ModelLimit{Context: 128000, Output: 16384},
-					Options: map[string]any{},
-				},
-			},
+	var providers []ProviderInfo
+	for _, p := range catalog.Default().Providers() {
+		info := ProviderInfo{
+			ID:     p.ID,
+			Name:   p.Name,
+			API:    p.API,
+			Env:    p.Env,
+			Npm:    p.Npm,
+			Models: make(map[string]ProviderModel, len(p.Models)),
+		}
+		if info.Env == nil {
+			info.Env = []string{}
+		}
+		for id, m := range p.Models {
+			info.Models[id] = catalogModel(m)
+		}
+		providers = append(providers, info)
+	}
+	return append(providers, ProviderInfo{
+		ID:     "ark",
+		Name:   "ARK (Volcengine)",
+		Env:    []string{"ARK_API_KEY"},
+		Npm:    "",
+		Models: map[string]ProviderModel{}, // Models populated dynamically from config
+	})
+}
+
+// catalogModel converts a catalog model to the models.dev format of clients.
+func catalogModel(m catalog.Model) ProviderModel {
+	modalities := func(kinds []string) ModalityCapabilities {
+		return ModalityCapabilities{
+			Text:  slices.Contains(kinds, "text"),
+			Audio: slices.Contains(kinds, "audio"),
+			Image: slices.Contains(kinds, "image"),
+			Video: slices.Contains(kinds, "video"),
+			PDF:   slices.Contains(kinds, "pdf"),
+		}
+	}
+	return ProviderModel{
+		ID:          m.ID,
+		Name:        m.Name,
+		ReleaseDate: m.ReleaseDate,
+		Capabilities: &ModelCapabilities{
+			Temperature: m.Temperature,
+			Reasoning:   m.Reasoning,
+			Attachment:  m.Attachment,
+			ToolCall:    m.ToolCall,
+			Input:       modalities(m.Modalities.Input),
+			Output:      modalities(m.Modalities.Output),
 		},
-		{
-			ID:     "ark",
-			Name:   "ARK (Volcengine)",
-			Env:    []string{"ARK_API_KEY"},
-			Npm:    "",
-			Models: map[string]ProviderModel{}, // Models populated dynamically from config
+		Cost: ModelCost{
+			Input:  m.Cost.Input,
+			Output: m.Cost.Output,
+			Cache:  ModelCostCache{Read: m.Cost.CacheRead, Write: m.Cost.CacheWrite},
 		},
+		Limit:   ModelLimit{Context: m.Limit.Context, Output: m.Limit.Output},
+		Options: map[string]any{},
 	}
 }
 
-// listProviders handles GET /config/providers
-func (s *Server) listProviders(w http.ResponseWriter, r *http.Request) {
-	providers := getDefaultProviders()
-
-	// Build default model map (first model for each provider)
-	defaultModels := make(map[string]string)
+// defaultModels returns the best model of each provider, as ranked by the
+// model catalog.
+func defaultModels(providers []ProviderInfo) map[string]string {
+	defaults := make(map[string]string)
 	for _, p := range providers {
-		for modelID := range p.Models {
-			defaultModels[p.ID] = modelID
-			break // Just get the first one
+		models := make([]types.Model, 0, len(p.Models))
+		for id := range p.Models {
+			models = append(models, types.Model{ID: id, ProviderID: p.ID})
+		}
+		catalog.Default().Sort(models)
+		if len(models) > 0 {
+			defaults[p.ID] = models[0].ID
 		}
 	}
+	return defaults
+}
+
+// listProviders handles GET /config/providers
+func (s *Server) listProviders(w http.ResponseWriter, r *http.Request) {
+	providers := getDefaultProviders()
+	applyCostConfig(providers, s.appConfig)
 
 	response := ProvidersResponse{
 		Providers: providers,
-		Default:   defaultModels,
+		Default:   defaultModels(providers),
 	}
 	writeJSON(w, http.StatusOK, response)
 }
 
+// applyCostConfig overrides model prices with those set in the config, so
+// clients show the prices sessions are charged at.
+func applyCostConfig(providers []ProviderInfo, cfg *types.Config) {
+	if cfg == nil {
+		return
+	}
+	for i := range providers {
+		providerCfg, ok := cfg.Provider[providers[i].ID]
+		if !ok {
+			continue
+		}
+		for modelID, modelCfg := range providerCfg.Models {
+			model, ok := providers[i].Models[modelID]
+			if !ok || modelCfg.Cost == nil {
+				continue
+			}
+			if modelCfg.Cost.Input != nil {
+				model.Cost.Input = *modelCfg.Cost.Input
+			}
+			if modelCfg.Cost.Output != nil {
+				model.Cost.Output = *modelCfg.Cost.Output
+			}
+			if modelCfg.Cost.CacheRead != nil {
+				model.Cost.Cache.Read = *modelCfg.Cost.CacheRead
+			}
+			if modelCfg.Cost.CacheWrite != nil {
+				model.Cost.Cache.Write = *modelCfg.Cost.CacheWrite
+			}
+			providers[i].Models[modelID] = model
+		}
+	}
+}
+
 // ProviderListResponse is the response format for /provider.
 type ProviderListResponse struct {
 	All       []ProviderInfo    `json:"all"`
@@ -259,35 +261,15 @@ func (s *Server) listAllProviders(w http.ResponseWriter, r *http.Request) {
 				// Get ARK models from the registered provider
 				if arkProvider, err := s.providerReg.Get("ark"); err == nil {
 					for _, m := range arkProvider.Models() {
-						providers[i].Models[m.ID] = ProviderModel{
-							ID:   m.ID,
-							Name: m.Name,
-							Capabilities: &ModelCapabilities{
-								Temperature: true,
-								Reasoning:   true,
-								Attachment:  true,
-								ToolCall:    m.SupportsTools,
-								Input:       ModalityCapabilities{Text: true, Audio: false, Image: m.SupportsVision, Video: false, PDF: false},
-								Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
-							},
-							Cost:    ModelCost{Input: m.InputPrice, Output: m.OutputPrice},
-							Limit:   ModelLimit{Context: m.ContextLength, Output: m.MaxOutputTokens},
-							Options: map[string]any{},
-						}
+						providers[i].Models[m.ID] = providerModel(m, true)
 					}
 				}
 			}
 		}
+		providers = append(providers, s.discoveredProviders()...)
 	}
 
-	// Build default model map
-	defaultModels := make(map[string]string)
-	for _, p := range providers {
-		for modelID := range p.Models {
-			defaultModels[p.ID] = modelID
-			break
-		}
-	}
+	applyCostConfig(providers, s.appConfig)
 
 	// Get connected providers (those with API keys configured)
 	connected := []string{}
@@ -319,12 +301,95 @@ func (s *Server) listAllProviders(w http.ResponseWriter, r *http.Request) {
 
 	response := ProviderListResponse{
 		All:       providers,
-		Default:   defaultModels,
+		Default:   defaultModels(providers),
 		Connected: connected,
 	}
 	writeJSON(w, http.StatusOK, response)
 }
 
+// providerModel converts a registry model to the models.dev format.
+func providerModel(m types.Model, reasoning bool) ProviderModel {
+	return ProviderModel{
+		ID:   m.ID,
+		Name: m.Name,
+		Capabilities: &ModelCapabilities{
+			Temperature: true,
+			Reasoning:   reasoning,
+			Attachment:  true,
+			ToolCall:    m.SupportsTools,
+			Input:       ModalityCapabilities{Text: true, Audio: false, Image: m.SupportsVision, Video: false, PDF: m.SupportsPDF},
+			Output:      ModalityCapabilities{Text: true, Audio: false, Image: false, Video: false, PDF: false},
+		},
+		Cost: ModelCost{
+			Input:  m.InputPrice,
+			Output: m.OutputPrice,
+			Cache:  ModelCostCache{Read: m.CacheReadPrice, Write: m.CacheWritePrice},
+		},
+		Limit:   ModelLimit{Context: m.ContextLength, Output: m.MaxOutputTokens},
+		Options: map[string]any{},
+	}
+}
+
+// discoveredProviders returns the registered providers that discover their
+// models, such as local OpenAI-compatible servers, with config overrides
+// applied to the models.
+func (s *Server) discoveredProviders() []ProviderInfo {
+	var providers []ProviderInfo
+	for _, p := range s.providerReg.List() {
+		if _, ok := p.(provider.ModelRefresher); !ok {
+			continue
+		}
+		info := ProviderInfo{
+			ID:     p.ID(),
+			Name:   p.Name(),
+			Env:    []string{},
+			Npm:    provider.NpmOpenAICompatible,
+			Models: map[string]ProviderModel{},
+		}
+		for _, m := range p.Models() {
+			if model, err := s.providerReg.GetModel(p.ID(), m.ID); err == nil {
+				info.Models[m.ID] = providerModel(*model, model.SupportsReasoning)
+			}
+		}
+		providers = append(providers, info)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].ID < providers[j].ID })
+	return providers
+}
+
+// refreshProviderModels handles POST /provider/refresh and
+// POST /provider/{providerID}/refresh, discovering the models of one or all
+// OpenAI-compatible providers again.
+func (s *Server) refreshProviderModels(w http.ResponseWriter, r *http.Request) {
+	providerID := chi.URLParam(r, "providerID")
+	if s.providerReg == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No providers configured")
+		return
+	}
+	if providerID != "" {
+		if _, err := s.providerReg.Get(providerID); err != nil {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
+			return
+		}
+		discovering := slices.ContainsFunc(s.discoveredProviders(), func(p ProviderInfo) bool { return p.ID == providerID })
+		if !discovering {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Provider does not discover its models: "+providerID)
+			return
+		}
+	}
+
+	if err := s.providerReg.RefreshModels(r.Context(), providerID); err != nil {
+		writeError(w, http.StatusBadGateway, ErrCodeProviderError, err.Error())
+		return
+	}
+
+	providers := s.discoveredProviders()
+	if providerID != "" {
+		providers = slices.DeleteFunc(providers, func(p ProviderInfo) bool { return p.ID != providerID })
+	}
+	writeJSON(w, http.StatusOK, providers)
+}
+
 // getEnvValue gets an environment variable value.
 func getEnvValue(key string) string {
 	return os.Getenv(key)
@@ -338,52 +403,164 @@ type AuthMethod struct {
 
 // getAuthMethods handles GET /provider/auth
 // Returns Record<string, AuthMethod[]> - map from provider ID to auth methods.
+// Providers with oauth options log in with the OAuth flows their endpoints
+// allow, the others with an API key.
 func (s *Server) getAuthMethods(w http.ResponseWriter, r *http.Request) {
-	// Return available auth methods for providers
-	// Format: { "providerId": [{"type": "api", "label": "..."}], ... }
-	authMethods := map[string][]AuthMethod{
-		"anthropic": {
-			{Type: "api", Label: "Manually enter API Key"},
-		},
-		"openai": {
-			{Type: "api", Label: "Manually enter API Key"},
-		},
+	providerIDs := []string{"anthropic", "openai"}
+	if s.appConfig != nil {
+		for id := range s.appConfig.Provider {
+			providerIDs = append(providerIDs, id)
+		}
+	}
+
+	authMethods := make(map[string][]AuthMethod)
+	for _, id := range providerIDs {
+		methods := []AuthMethod{}
+		for _, m := range auth.Methods(s.oauthConfig(id)) {
+			methods = append(methods, AuthMethod{Type: m.Type, Label: m.Label})
+		}
+		authMethods[id] = methods
 	}
 	writeJSON(w, http.StatusOK, authMethods)
 }
 
+// oauthConfig returns the OAuth endpoints of a provider, nil if it has none.
+func (s *Server) oauthConfig(providerID string) *types.OAuthConfig {
+	if s.appConfig == nil {
+		return nil
+	}
+	cfg, ok := s.appConfig.Provider[providerID]
+	if !ok || cfg.Options == nil {
+		return nil
+	}
+	return cfg.Options.OAuth
+}
+
 // oauthAuthorize handles POST /provider/{providerID}/oauth/authorize
+// Starts the OAuth flow of the method at the given index of GET
+// /provider/auth and returns the URL to open, replacing any flow of the
+// provider that did not finish.
 func (s *Server) oauthAuthorize(w http.ResponseWriter, r *http.Request) {
-	notImplemented(w)
+	providerID := chi.URLParam(r, "providerID")
+
+	var req struct {
+		Method int `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
+		return
+	}
+	cfg := s.oauthConfig(providerID)
+	if cfg == nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Provider has no OAuth endpoints: "+providerID)
+		return
+	}
+	methods := auth.Methods(cfg)
+	if req.Method < 0 || req.Method >= len(methods) || methods[req.Method].Type != auth.TypeOAuth {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Not an OAuth method: "+strconv.Itoa(req.Method))
+		return
+	}
+
+	flow, err := auth.NewClient(cfg, nil).Start(r.Context(), methods[req.Method])
+	if err != nil {
+		writeError(w, http.StatusBadGateway, ErrCodeProviderError, err.Error())
+		return
+	}
+
+	s.oauthMu.Lock()
+	if s.oauthFlows == nil {
+		s.oauthFlows = make(map[string]*auth.Flow)
+	}
+	if previous, ok := s.oauthFlows[providerID]; ok {
+		previous.Close()
+	}
+	s.oauthFlows[providerID] = flow
+	s.oauthMu.Unlock()
+
+	writeJSON(w, http.StatusOK, flow)
 }
 
 // oauthCallback handles POST /provider/{providerID}/oauth/callback
+// Finishes the OAuth flow started by oauthAuthorize, with the code the user
+// pasted for "code" flows, and stores the tokens.
 func (s *Server) oauthCallback(w http.ResponseWriter, r *http.Request) {
-	notImplemented(w)
+	providerID := chi.URLParam(r, "providerID")
+
+	var req struct {
+		Method int    `json:"method"`
+		Code   string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
+		return
+	}
+	if s.authStore == nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, "Credentials are not stored by this server")
+		return
+	}
+
+	s.oauthMu.Lock()
+	flow, ok := s.oauthFlows[providerID]
+	delete(s.oauthFlows, providerID)
+	s.oauthMu.Unlock()
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "No OAuth login in progress for provider: "+providerID)
+		return
+	}
+	defer flow.Close()
+
+	if flow.Method == auth.MethodCode && req.Code == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Authorization code required")
+		return
+	}
+	token, err := flow.Finish(r.Context(), req.Code)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := s.authStore.Set(providerID, token.Info()); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+	writeSuccess(w)
 }
 
 // setAuth handles PUT /auth/{providerID}
+// Stores credentials in the TypeScript format, such as {"type": "api",
+// "key": "..."}, or an API key as {"apiKey": "..."}.
 func (s *Server) setAuth(w http.ResponseWriter, r *http.Request) {
 	providerID := chi.URLParam(r, "providerID")
 
 	var req struct {
+		auth.Info
 		APIKey string `json:"apiKey"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
 		return
 	}
-
-	// Update provider config
-	if s.appConfig.Provider == nil {
-		s.appConfig.Provider = make(map[string]types.ProviderConfig)
+	info := req.Info
+	if info.Type == "" && req.APIKey != "" {
+		info = auth.Info{Type: auth.TypeAPI, Key: req.APIKey}
+	}
+	switch {
+	case info.Type == auth.TypeAPI && info.Key != "":
+	case info.Type == auth.TypeOAuth && info.Access != "":
+	case info.Type == auth.TypeWellKnown && info.Key != "" && info.Token != "":
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid credentials")
+		return
 	}
 
-	// This would typically save to config file
+	if s.authStore == nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, "Credentials are not stored by this server")
+		return
+	}
+	if err := s.authStore.Set(providerID, info); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
 	writeSuccess(w)
-
-	_ = providerID
-	_ = req
 }
 
 // getLSPStatus handles GET /lsp
@@ -867,6 +1044,9 @@ func (s *Server) listCommands(w http.ResponseWriter, r *http.Request) {
 	// Add custom commands from executor (config and file-based)
 	if s.commandExecutor != nil {
 		for _, cmd := range s.commandExecutor.List() {
+			if cmd.Source == "builtin" {
+				continue // Listed above
+			}
 			commands = append(commands, CommandInfo{
 				Name:        cmd.Name,
 				Description: cmd.Description,
@@ -1051,6 +1231,48 @@ func (s *Server) getCommand(w http.ResponseWriter, r *http.Request) {
 	writeError(w, http.StatusNotFound, ErrCodeNotFound, "Command not found")
 }
 
+// listSchedules handles GET /schedule
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		writeJSON(w, http.StatusOK, []schedule.Status{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.scheduler.Entries(r.Context()))
+}
+
+// getScheduleRuns handles GET /schedule/{name}/runs
+func (s *Server) getScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if s.scheduler == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Schedule not found")
+		return
+	}
+	if _, ok := s.scheduler.Entry(r.Context(), name); !ok {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Schedule not found")
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	runs, err := s.scheduler.History(r.Context(), name, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+	if runs == nil {
+		runs = []*types.ScheduleRun{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
 // getPath handles GET /path
 func (s *Server) getPath(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{
@@ -3,12 +3,14 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -27,6 +29,9 @@ type SendMessageRequest struct {
 	Model   *types.ModelRef  `json:"model,omitempty"`
 	Tools   map[string]bool  `json:"tools,omitempty"`
 	Files   []types.FilePart `json:"files,omitempty"`
+	// Delivery applies when the session is busy: "steer" adds the message at
+	// the next step boundary, "queue" (the default) runs it afterwards.
+	Delivery string `json:"delivery,omitempty"`
 }
 
 // GetContent returns the message content from either Content or Parts.
@@ -65,6 +70,10 @@ func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "content is required")
 		return
 	}
+	if req.Delivery != "" && req.Delivery != types.DeliverySteer && req.Delivery != types.DeliveryQueue {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "delivery must be steer or queue")
+		return
+	}
 
 	// Set streaming headers
 	w.Header().Set("Content-Type", "application/json")
@@ -79,109 +88,138 @@ func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get session
-	session, err := s.sessionService.Get(r.Context(), sessionID)
+	info, err := s.sessionService.Get(r.Context(), sessionID)
 	if err != nil {
 		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
 		return
 	}
 
-	// Create user message
-	// SDK compatible: user messages include summary field (initially with empty diffs)
-	userMsg := &types.Message{
-		ID:        generateID(),
+	// Publish updates via SSE
+	onUpdate := func(msg *types.Message, parts []types.Part) {
+		event.PublishSync(event.Event{
+			Type: "message.updated",
+			Data: event.MessageUpdatedData{Info: msg},
+		})
+	}
+
+	// A busy session takes the message into its queue; the response is the
+	// answer to it once delivered
+	assistantMsg, parts, err := s.sessionService.QueueMessage(r.Context(), &types.QueuedMessage{
 		SessionID: sessionID,
-		Role:      "user",
+		Delivery:  req.Delivery,
+		Text:      content,
+		Files:     req.Files,
 		Agent:     req.Agent,
 		Model:     req.Model,
 		Tools:     req.Tools,
-		Summary: &types.UserMessageSummary{
-			Diffs: []types.FileDiff{}, // SDK compatible: empty diffs array
-		},
-		Time: types.MessageTime{
-			Created: nowMillis(),
-		},
-	}
-
-	// Store user message
-	if err := s.sessionService.AddMessage(r.Context(), sessionID, userMsg); err != nil {
-		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+	}, onUpdate)
+	if errors.Is(err, session.ErrQueueCancelled) {
+		writeError(w, http.StatusConflict, ErrCodeInvalidRequest, err.Error())
 		return
 	}
+	if errors.Is(err, session.ErrNotBusy) {
+		// Continuing a reverted session drops the reverted messages
+		if err := s.sessionService.ApplyRevert(r.Context(), info); err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+			return
+		}
 
-	// Create user message parts (SDK compatible: include sessionID and messageID)
-	textPart := &types.TextPart{
-		ID:        generateID(),
-		SessionID: sessionID,
-		MessageID: userMsg.ID,
-		Type:      "text",
-		Text:      content,
-	}
-	userParts := []types.Part{textPart}
+		// Create user message
+		// SDK compatible: user messages include summary field (initially with empty diffs)
+		userMsg := &types.Message{
+			ID:        generateID(),
+			SessionID: sessionID,
+			Role:      "user",
+			Agent:     req.Agent,
+			Model:     req.Model,
+			Tools:     req.Tools,
+			Summary: &types.UserMessageSummary{
+				Diffs: []types.FileDiff{}, // SDK compatible: empty diffs array
+			},
+			Time: types.MessageTime{
+				Created: nowMillis(),
+			},
+		}
 
-	// Save text part to storage
-	if err := s.sessionService.SavePart(r.Context(), userMsg.ID, textPart); err != nil {
-		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
-		return
-	}
+		// Store user message
+		if err := s.sessionService.AddMessage(r.Context(), sessionID, userMsg); err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+			return
+		}
+
+		// Create user message parts (SDK compatible: include sessionID and messageID)
+		textPart := &types.TextPart{
+			ID:        generateID(),
+			SessionID: sessionID,
+			MessageID: userMsg.ID,
+			Type:      "text",
+			Text:      content,
+		}
+		userParts := []types.Part{textPart}
 
-	// Add file parts if provided (SDK compatible: include sessionID and messageID)
-	for i := range req.Files {
-		req.Files[i].ID = generateID()
-		req.Files[i].SessionID = sessionID
-		req.Files[i].MessageID = userMsg.ID
-		req.Files[i].Type = "file"
-		userParts = append(userParts, &req.Files[i])
-		// Save file part to storage
-		if err := s.sessionService.SavePart(r.Context(), userMsg.ID, &req.Files[i]); err != nil {
+		// Save text part to storage
+		if err := s.sessionService.SavePart(r.Context(), userMsg.ID, textPart); err != nil {
 			writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
 			return
 		}
-	}
 
-	// Publish user message via SSE (SDK compatible: uses message.updated)
-	event.PublishSync(event.Event{
-		Type: event.MessageUpdated,
-		Data: event.MessageUpdatedData{Info: userMsg},
-	})
+		// Add file parts if provided (SDK compatible: include sessionID and messageID)
+		for i := range req.Files {
+			req.Files[i].ID = generateID()
+			req.Files[i].SessionID = sessionID
+			req.Files[i].MessageID = userMsg.ID
+			req.Files[i].Type = "file"
+			userParts = append(userParts, &req.Files[i])
+			// Save file part to storage
+			if err := s.sessionService.SavePart(r.Context(), userMsg.ID, &req.Files[i]); err != nil {
+				writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+				return
+			}
+		}
 
-	// Publish user message parts (SDK compatible: uses message.part.updated)
-	event.PublishSync(event.Event{
-		Type: event.MessagePartUpdated,
-		Data: event.MessagePartUpdatedData{
-			Part: textPart,
-		},
-	})
+		// Publish user message via SSE (SDK compatible: uses message.updated)
+		event.PublishSync(event.Event{
+			Type: event.MessageUpdated,
+			Data: event.MessageUpdatedData{Info: userMsg},
+		})
 
-	// Publish file parts if any
-	for i := range req.Files {
+		// Publish user message parts (SDK compatible: uses message.part.updated)
 		event.PublishSync(event.Event{
 			Type: event.MessagePartUpdated,
 			Data: event.MessagePartUpdatedData{
-				Part: &req.Files[i],
+				Part: textPart,
 			},
 		})
-	}
 
-	// Process message and generate response
-	// This is where the LLM provider is called
-	// Updates are published via SSE, not streamed in HTTP response
-	// IMPORTANT: Use background context for LLM processing to avoid cancellation
-	// when the HTTP request completes. The LLM call can take seconds/minutes.
-	llmCtx := context.Background()
-	assistantMsg, parts, err := s.sessionService.ProcessMessage(llmCtx, session, content, req.Model, func(msg *types.Message, parts []types.Part) {
-		// Publish updates via SSE
-		event.PublishSync(event.Event{
-			Type: "message.updated",
-			Data: event.MessageUpdatedData{Info: msg},
-		})
-	})
+		// Publish file parts if any
+		for i := range req.Files {
+			event.PublishSync(event.Event{
+				Type: event.MessagePartUpdated,
+				Data: event.MessagePartUpdatedData{
+					Part: &req.Files[i],
+				},
+			})
+		}
+
+		// Process message and generate response
+		// This is where the LLM provider is called
+		// Updates are published via SSE, not streamed in HTTP response
+		// IMPORTANT: Use background context for LLM processing to avoid cancellation
+		// when the HTTP request completes. The LLM call can take seconds/minutes.
+		llmCtx := context.Background()
+		assistantMsg, parts, err = s.sessionService.ProcessMessage(llmCtx, info, content, req.Model, onUpdate)
+	}
 
 	// Create JSON encoder for response
 	encoder := json.NewEncoder(w)
 
 	if err != nil {
-		// Create error object
+		// Create error object, keeping the type of a budget stop
 		msgError := types.NewUnknownError(err.Error())
+		var budgetErr *session.BudgetExceededError
+		if errors.As(err, &budgetErr) {
+			msgError = budgetErr.MessageError()
+		}
 
 		// Send error response as an assistant message
 		if assistantMsg != nil {
@@ -292,3 +330,45 @@ func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
 		Parts: parts,
 	})
 }
+
+// resumeSession handles POST /session/{sessionID}/resume
+// Continues an assistant message that was interrupted by a server crash,
+// starting after its last committed step. Updates are published via SSE and
+// the final message is returned when the loop completes.
+func (s *Server) resumeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	if _, err := s.sessionService.Get(r.Context(), sessionID); err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+
+	// Use background context so the loop is not cancelled with the request
+	assistantMsg, parts, err := s.sessionService.Resume(context.Background(), sessionID, func(msg *types.Message, parts []types.Part) {
+		event.PublishSync(event.Event{
+			Type: event.MessageUpdated,
+			Data: event.MessageUpdatedData{Info: msg},
+		})
+	})
+	if errors.Is(err, session.ErrNothingToResume) {
+		writeError(w, http.StatusConflict, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil && assistantMsg == nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+
+	var budgetErr *session.BudgetExceededError
+	if err != nil && !errors.As(err, &budgetErr) {
+		assistantMsg.Error = types.NewUnknownError(err.Error())
+	}
+	if parts == nil {
+		parts = []types.Part{}
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{
+		Info:  assistantMsg,
+		Parts: parts,
+	})
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/opencode-ai/opencode/internal/project"
+	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -44,3 +48,50 @@ func (s *Server) getCurrentProject(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, project)
 }
+
+// getUsage handles GET /usage
+// Reports cost and tokens per session, project and day. Optional query
+// parameters: project (ID) or directory to select a project, since and until
+// as Unix milliseconds, or days to count only the last days.
+func (s *Server) getUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := session.UsageFilter{ProjectID: query.Get("project")}
+
+	if directory := query.Get("directory"); filter.ProjectID == "" && directory != "" {
+		projectID, err := project.GetProjectID(directory)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		filter.ProjectID = projectID
+	}
+
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, name+" must be Unix milliseconds")
+			return
+		}
+		*t = time.UnixMilli(ms)
+	}
+	if value := query.Get("days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "days must be a positive number")
+			return
+		}
+		filter.Since = session.DaysAgo(days)
+	}
+
+	report, err := s.sessionService.Usage(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
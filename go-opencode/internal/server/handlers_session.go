@@ -1,14 +1,21 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/oklog/ulid/v2"
 
 	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/search"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -22,9 +29,11 @@ type CreateSessionRequest struct {
 func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
 	// Only use explicitly provided directory query parameter
 	// If not provided, list all sessions (directory = "")
+	// An optional limit keeps only the sessions updated last
 	directory := r.URL.Query().Get("directory")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 
-	sessions, err := s.sessionService.List(r.Context(), directory)
+	sessions, err := s.sessionService.ListRecent(r.Context(), directory, limit)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
 		return
@@ -155,6 +164,120 @@ func (s *Server) getSessionStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, statuses)
 }
 
+// searchSessions handles GET /session/search
+// Query parameters: q (required), project, agent, since, until, limit.
+// since and until accept unix milliseconds, RFC 3339 or YYYY-MM-DD.
+func (s *Server) searchSessions(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q := search.Query{
+		Text:      params.Get("q"),
+		ProjectID: params.Get("project"),
+		Agent:     params.Get("agent"),
+	}
+	if q.Text == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "q required")
+		return
+	}
+
+	for name, target := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := params.Get(name); v != "" {
+			parse := search.ParseTime
+			if name == "until" {
+				parse = search.ParseUntil
+			}
+			t, err := parse(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, name+": "+err.Error())
+				return
+			}
+			*target = t
+		}
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "limit must be a non-negative integer")
+			return
+		}
+		q.Limit = limit
+	}
+
+	hits, err := s.searchIndex.Search(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, search.ErrEmptyQuery) {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hits)
+}
+
+// exportSession handles GET /session/{sessionID}/export
+// Query parameters: format (json, markdown or html; default json),
+// gzip (json only) and snapshots (default true).
+func (s *Server) exportSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	params := r.URL.Query()
+
+	format := params.Get("format")
+	compress := params.Get("gzip") == "true"
+	snapshots := params.Get("snapshots") != "false"
+
+	var contentType string
+	switch format {
+	case "", session.FormatJSON:
+		contentType = "application/json"
+		if compress {
+			contentType = "application/gzip"
+		}
+	case session.FormatMarkdown, "md":
+		contentType = "text/markdown; charset=utf-8"
+	case session.FormatHTML:
+		contentType = "text/html; charset=utf-8"
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "format must be json, markdown or html")
+		return
+	}
+
+	bundle, err := s.sessionService.Export(r.Context(), sessionID, snapshots)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	session.WriteExport(w, bundle, format, compress)
+}
+
+// importSession handles POST /session/import
+// The body is a bundle from export, optionally gzipped. The directory query
+// parameter places the session in another project directory.
+func (s *Server) importSession(w http.ResponseWriter, r *http.Request) {
+	bundle, err := session.ReadBundle(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	info, err := s.sessionService.Import(r.Context(), bundle, r.URL.Query().Get("directory"))
+	if errors.Is(err, session.ErrInvalidBundleID) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
 // getChildren handles GET /session/{sessionID}/children
 func (s *Server) getChildren(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
@@ -198,6 +321,56 @@ func (s *Server) forkSession(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, newSession)
 }
 
+// CompareSessionRequest represents the request body for comparing models on
+// a prompt.
+type CompareSessionRequest struct {
+	Text    string   `json:"text"`
+	Models  []string `json:"models"` // "provider/model"
+	Agent   string   `json:"agent,omitempty"`
+	Isolate bool     `json:"isolate,omitempty"` // Run each fork on its own copy of the working tree
+}
+
+// compareSession handles POST /session/{sessionID}/compare
+// The session is forked once per model and the prompt sent to every fork; the
+// response summarizes the runs once they have all finished.
+func (s *Server) compareSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	var req CompareSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "text is required")
+		return
+	}
+
+	if _, err := s.sessionService.Get(r.Context(), sessionID); err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+
+	// Use background context so the runs are not aborted with the request
+	result, err := s.sessionService.Compare(context.Background(), session.CompareInput{
+		SessionID: sessionID,
+		Text:      req.Text,
+		Agent:     req.Agent,
+		Models:    req.Models,
+		Isolate:   req.Isolate,
+	})
+	if errors.Is(err, session.ErrNoCompareModels) || errors.Is(err, session.ErrInvalidModel) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
 // abortSession handles POST /session/{sessionID}/abort
 func (s *Server) abortSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
@@ -210,6 +383,51 @@ func (s *Server) abortSession(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w)
 }
 
+// setBudget handles POST /session/{sessionID}/budget
+// The body replaces the session's own limits; resume the session afterwards
+// to continue a message stopped by a budget.
+func (s *Server) setBudget(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	var budget types.BudgetConfig
+	if err := json.NewDecoder(r.Body).Decode(&budget); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
+		return
+	}
+	if budget.MaxCost < 0 || budget.MaxInputTokens < 0 || budget.MaxOutputTokens < 0 || budget.MaxSeconds < 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "budget limits must not be negative")
+		return
+	}
+
+	info, err := s.sessionService.SetBudget(r.Context(), sessionID, &budget)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// getQueue handles GET /session/{sessionID}/queue
+func (s *Server) getQueue(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	writeJSON(w, http.StatusOK, s.sessionService.Queue(sessionID))
+}
+
+// cancelQueue handles DELETE /session/{sessionID}/queue and
+// DELETE /session/{sessionID}/queue/{queueID}
+func (s *Server) cancelQueue(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	queueID := chi.URLParam(r, "queueID")
+
+	if s.sessionService.CancelQueued(sessionID, queueID) == 0 && queueID != "" {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Queued message not found")
+		return
+	}
+
+	writeSuccess(w)
+}
+
 // shareSession handles POST /session/{sessionID}/share
 func (s *Server) shareSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
@@ -297,6 +515,24 @@ func (s *Server) getDiff(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, diffs)
 }
 
+// getContext handles GET /session/{sessionID}/context
+// Returns how much of the context window of its model the session fills.
+func (s *Server) getContext(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	usage, err := s.sessionService.Context(r.Context(), sessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
+
 // getTodo handles GET /session/{sessionID}/todo
 func (s *Server) getTodo(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
@@ -309,7 +545,7 @@ func (s *Server) getTodo(w http.ResponseWriter, r *http.Request) {
 
 	// Ensure we return [] instead of null
 	if todos == nil {
-		todos = []map[string]any{}
+		todos = []types.TodoInfo{}
 	}
 
 	writeJSON(w, http.StatusOK, todos)
@@ -331,32 +567,59 @@ func (s *Server) revertSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.sessionService.Revert(r.Context(), sessionID, req.MessageID, req.PartID); err != nil {
+	info, err := s.sessionService.Revert(r.Context(), sessionID, req.MessageID, req.PartID)
+	if errors.Is(err, session.ErrSessionBusy) || errors.Is(err, session.ErrNoSnapshot) {
+		writeError(w, http.StatusConflict, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
 		return
 	}
 
-	writeSuccess(w)
+	event.PublishSync(event.Event{
+		Type: event.SessionUpdated,
+		Data: event.SessionUpdatedData{Info: info},
+	})
+
+	writeJSON(w, http.StatusOK, info)
 }
 
 // unrevertSession handles POST /session/{sessionID}/unrevert
 func (s *Server) unrevertSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
 
-	if err := s.sessionService.Unrevert(r.Context(), sessionID); err != nil {
+	info, err := s.sessionService.Unrevert(r.Context(), sessionID)
+	if errors.Is(err, session.ErrSessionBusy) {
+		writeError(w, http.StatusConflict, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
 		return
 	}
 
-	writeSuccess(w)
+	event.PublishSync(event.Event{
+		Type: event.SessionUpdated,
+		Data: event.SessionUpdatedData{Info: info},
+	})
+
+	writeJSON(w, http.StatusOK, info)
 }
 
 // SendCommandRequest represents the request body for sending a command.
+// A legacy command string such as "/review main" without arguments is split
+// into the command name and its arguments.
 type SendCommandRequest struct {
-	Command string `json:"command"`
+	MessageID string `json:"messageID,omitempty"`
+	Agent     string `json:"agent,omitempty"`
+	Model     string `json:"model,omitempty"` // "provider/model"
+	Arguments string `json:"arguments"`
+	Command   string `json:"command"`
 }
 
 // sendCommand handles POST /session/{sessionID}/command
+// Expands the command and waits for the assistant message answering it.
 func (s *Server) sendCommand(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
 
@@ -366,22 +629,68 @@ func (s *Server) sendCommand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := s.sessionService.ExecuteCommand(r.Context(), sessionID, req.Command)
-	if err != nil {
+	name := strings.TrimPrefix(strings.TrimSpace(req.Command), "/")
+	args := req.Arguments
+	if args == "" {
+		name, args, _ = strings.Cut(name, " ")
+		args = strings.TrimSpace(args)
+	}
+	if name == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "command is required")
+		return
+	}
+
+	if _, err := s.sessionService.Get(r.Context(), sessionID); err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+
+	// Use background context so the loop is not cancelled with the request
+	assistantMsg, parts, err := s.sessionService.ExecuteCommand(context.Background(), session.CommandInput{
+		SessionID: sessionID,
+		MessageID: req.MessageID,
+		Command:   name,
+		Arguments: args,
+		Agent:     req.Agent,
+		Model:     req.Model,
+	}, nil)
+	if errors.Is(err, session.ErrCommandNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, session.ErrSessionBusy) || errors.Is(err, session.ErrQueueCancelled) {
+		writeError(w, http.StatusConflict, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil && assistantMsg == nil {
 		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	if err != nil && assistantMsg.Error == nil {
+		assistantMsg.Error = types.NewUnknownError(err.Error())
+	}
+	if parts == nil {
+		parts = []types.Part{}
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{
+		Info:  assistantMsg,
+		Parts: parts,
+	})
 }
 
 // RunShellRequest represents the request body for running a shell command.
 type RunShellRequest struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout,omitempty"`
+	Agent   string          `json:"agent,omitempty"`
+	Model   *types.ModelRef `json:"model,omitempty"`
+	Command string          `json:"command"`
+	Timeout int             `json:"timeout,omitempty"` // Milliseconds
 }
 
 // runShell handles POST /session/{sessionID}/shell
+// Runs the command through the bash tool and returns the assistant message
+// recording it.
 func (s *Server) runShell(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
 
@@ -390,19 +699,41 @@ func (s *Server) runShell(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
 		return
 	}
+	if strings.TrimSpace(req.Command) == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "command is required")
+		return
+	}
 
-	result, err := s.sessionService.RunShell(r.Context(), sessionID, req.Command, req.Timeout)
-	if err != nil {
+	if _, err := s.sessionService.Get(r.Context(), sessionID); err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Session not found")
+		return
+	}
+
+	// Use background context so the command is not killed with the request
+	assistantMsg, _, err := s.sessionService.RunShell(context.Background(), session.ShellInput{
+		SessionID: sessionID,
+		Agent:     req.Agent,
+		Model:     req.Model,
+		Command:   req.Command,
+		Timeout:   req.Timeout,
+	}, nil)
+	if errors.Is(err, session.ErrSessionBusy) {
+		writeError(w, http.StatusConflict, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil && assistantMsg == nil {
 		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	writeJSON(w, http.StatusOK, assistantMsg)
 }
 
-// PermissionResponse represents the response body for permission.
+// PermissionResponse represents the request body answering a permission
+// request. The legacy Granted field is used when Response is empty.
 type PermissionResponse struct {
-	Granted bool `json:"granted"`
+	Response string `json:"response,omitempty"` // "once" | "always" | "reject"
+	Granted  bool   `json:"granted,omitempty"`
 }
 
 // respondPermission handles POST /session/{sessionID}/permissions/{permissionID}
@@ -416,26 +747,28 @@ func (s *Server) respondPermission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.sessionService.RespondPermission(r.Context(), sessionID, permissionID, req.Granted); err != nil {
-		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+	response := req.Response
+	if response == "" {
+		response = "reject"
+		if req.Granted {
+			response = "once"
+		}
+	}
+	if response != "once" && response != "always" && response != "reject" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "response must be once, always or reject")
 		return
 	}
 
-	// Convert granted bool to SDK response format
-	response := "reject"
-	if req.Granted {
-		response = "once"
+	// The permission checker publishes permission.replied
+	err := s.sessionService.RespondPermission(r.Context(), sessionID, permissionID, response)
+	if errors.Is(err, session.ErrPermissionNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternalError, err.Error())
+		return
 	}
-
-	// Publish event (SDK compatible: uses PermissionReplied)
-	event.PublishSync(event.Event{
-		Type: event.PermissionReplied,
-		Data: event.PermissionRepliedData{
-			PermissionID: permissionID,
-			SessionID:    sessionID,
-			Response:     response,
-		},
-	})
 
 	writeSuccess(w)
 }
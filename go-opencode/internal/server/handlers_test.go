@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/opencode-ai/opencode/internal/auth"
+	"github.com/opencode-ai/opencode/internal/search"
 	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/pkg/types"
@@ -22,6 +27,7 @@ func setupTestServer(t *testing.T) *Server {
 
 	srv := &Server{
 		sessionService: sessionSvc,
+		searchIndex:    search.NewIndex(store),
 		storage:        store,
 		appConfig:      &types.Config{},
 	}
@@ -213,6 +219,59 @@ func TestUpdateSession(t *testing.T) {
 	}
 }
 
+func TestSearchSessions(t *testing.T) {
+	srv := setupTestServer(t)
+	ctx := context.Background()
+
+	session, _ := srv.sessionService.Create(ctx, "/tmp/test", "Flush fix")
+	part := &types.TextPart{ID: "prt1", SessionID: session.ID, MessageID: "msg1", Type: "text", Text: "The SSE writer never flushed"}
+	created := time.Date(2025, 1, 2, 15, 0, 0, 0, time.Local).UnixMilli()
+	srv.storage.Put(ctx, []string{"message", session.ID, "msg1"}, &types.Message{ID: "msg1", SessionID: session.ID, Role: "assistant", Time: types.MessageTime{Created: created}})
+	srv.storage.Put(ctx, []string{"part", "msg1", "prt1"}, part)
+
+	req := httptest.NewRequest("GET", "/session/search?q=sse+flushed", nil)
+	w := httptest.NewRecorder()
+
+	srv.searchSessions(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var hits []search.Hit
+	if err := json.NewDecoder(w.Body).Decode(&hits); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	if len(hits) != 1 || hits[0].SessionID != session.ID || hits[0].SessionTitle != "Flush fix" {
+		t.Fatalf("Unexpected hits: %+v", hits)
+	}
+	if len(hits[0].Highlights) != 2 {
+		t.Errorf("Expected 2 highlights, got %v", hits[0].Highlights)
+	}
+
+	// An until date includes the whole day
+	for query, want := range map[string]int{"&until=2025-01-02": 1, "&until=2025-01-01": 0} {
+		w := httptest.NewRecorder()
+		srv.searchSessions(w, httptest.NewRequest("GET", "/session/search?q=sse"+query, nil))
+		var hits []search.Hit
+		if err := json.NewDecoder(w.Body).Decode(&hits); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if len(hits) != want {
+			t.Errorf("%q: expected %d hits, got %d", query, want, len(hits))
+		}
+	}
+
+	for _, query := range []string{"", "?q=x&since=yesterday", "?q=x&limit=-1"} {
+		w := httptest.NewRecorder()
+		srv.searchSessions(w, httptest.NewRequest("GET", "/session/search"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
 func TestGetConfig(t *testing.T) {
 	srv := setupTestServer(t)
 	srv.appConfig = &types.Config{
@@ -288,3 +347,62 @@ func TestReadFile_MissingPath(t *testing.T) {
 		t.Errorf("Expected 400, got %d", w.Code)
 	}
 }
+
+func TestOAuthAuthorizeAndCallback(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "code-1" || r.Form.Get("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-1","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	srv := setupTestServer(t)
+	srv.authStore = auth.NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	srv.appConfig.Provider = map[string]types.ProviderConfig{
+		"stub": {Options: &types.ProviderOptions{OAuth: &types.OAuthConfig{
+			ClientID:         "test-client",
+			AuthorizationURL: tokenServer.URL + "/authorize",
+			TokenURL:         tokenServer.URL + "/token",
+			RedirectURL:      "https://console.example.com/oauth/callback",
+		}}},
+	}
+
+	call := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("providerID", "stub")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	if w := call(srv.oauthCallback, `{"method":0,"code":"code-1"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a login in progress, got %d", w.Code)
+	}
+	if w := call(srv.oauthAuthorize, `{"method":1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for the API key method, got %d", w.Code)
+	}
+
+	w := call(srv.oauthAuthorize, `{"method":0}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var flow auth.Flow
+	json.NewDecoder(w.Body).Decode(&flow)
+	if flow.Method != auth.MethodCode || !strings.HasPrefix(flow.URL, tokenServer.URL+"/authorize?") {
+		t.Errorf("Got flow %+v", flow)
+	}
+
+	if w := call(srv.oauthCallback, `{"method":0,"code":"code-1"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	info, _ := srv.authStore.Get("stub")
+	if info == nil || info.Type != auth.TypeOAuth || info.Access != "access-1" || info.Refresh != "refresh-1" {
+		t.Errorf("Expected the tokens to be stored, got %+v", info)
+	}
+}
@@ -14,11 +14,16 @@ func (s *Server) setupRoutes() {
 		r.Get("/current", s.getCurrentProject)
 	})
 
+	// Cost and token usage
+	r.Get("/usage", s.getUsage)
+
 	// Session routes
 	r.Route("/session", func(r chi.Router) {
 		r.Get("/", s.listSessions)
 		r.Post("/", s.createSession)
 		r.Get("/status", s.getSessionStatus)
+		r.Get("/search", s.searchSessions)
+		r.Post("/import", s.importSession)
 
 		r.Route("/{sessionID}", func(r chi.Router) {
 			r.Get("/", s.getSession)
@@ -29,17 +34,25 @@ func (s *Server) setupRoutes() {
 			r.Get("/message", s.getMessages)
 			r.Post("/message", s.sendMessage) // Streaming response
 			r.Get("/message/{messageID}", s.getMessage)
+			r.Get("/queue", s.getQueue)
+			r.Delete("/queue", s.cancelQueue)
+			r.Delete("/queue/{queueID}", s.cancelQueue)
 
 			// Session operations
 			r.Get("/children", s.getChildren)
 			r.Post("/fork", s.forkSession)
+			r.Post("/compare", s.compareSession)
 			r.Post("/abort", s.abortSession)
+			r.Post("/resume", s.resumeSession)
+			r.Post("/budget", s.setBudget)
 			r.Post("/share", s.shareSession)
 			r.Delete("/share", s.unshareSession)
 			r.Post("/summarize", s.summarizeSession)
 			r.Post("/init", s.initSession)
 			r.Get("/diff", s.getDiff)
 			r.Get("/todo", s.getTodo)
+			r.Get("/context", s.getContext)
+			r.Get("/export", s.exportSession)
 			r.Post("/revert", s.revertSession)
 			r.Post("/unrevert", s.unrevertSession)
 			r.Post("/command", s.sendCommand)
@@ -79,6 +92,8 @@ func (s *Server) setupRoutes() {
 	r.Route("/provider", func(r chi.Router) {
 		r.Get("/", s.listAllProviders)
 		r.Get("/auth", s.getAuthMethods)
+		r.Post("/refresh", s.refreshProviderModels)
+		r.Post("/{providerID}/refresh", s.refreshProviderModels)
 		r.Post("/{providerID}/oauth/authorize", s.oauthAuthorize)
 		r.Post("/{providerID}/oauth/callback", s.oauthCallback)
 	})
@@ -117,6 +132,12 @@ func (s *Server) setupRoutes() {
 		r.Post("/{name}", s.executeCommand)
 	})
 
+	// Scheduled sessions
+	r.Route("/schedule", func(r chi.Router) {
+		r.Get("/", s.listSchedules)
+		r.Get("/{name}/runs", s.getScheduleRuns)
+	})
+
 	// Instance management
 	r.Get("/path", s.getPath)
 	r.Post("/log", s.writeLog)
@@ -3,22 +3,30 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 
+	"github.com/opencode-ai/opencode/internal/agent"
+	"github.com/opencode-ai/opencode/internal/auth"
 	"github.com/opencode-ai/opencode/internal/command"
 	"github.com/opencode-ai/opencode/internal/event"
 	"github.com/opencode-ai/opencode/internal/formatter"
 	"github.com/opencode-ai/opencode/internal/lsp"
 	"github.com/opencode-ai/opencode/internal/mcp"
+	"github.com/opencode-ai/opencode/internal/permission"
 	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/schedule"
+	"github.com/opencode-ai/opencode/internal/search"
 	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/snapshot"
 	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/internal/tool"
 	"github.com/opencode-ai/opencode/internal/vcs"
@@ -32,6 +40,9 @@ type Config struct {
 	EnableCORS   bool
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	SnapshotDir  string          // Shadow repositories for file snapshots; empty disables them
+	AuthPath     string          // Credentials file of logins to providers; empty disables them
+	Agents       *agent.Registry // Agents sessions run with; nil for the built-in ones
 }
 
 // DefaultConfig returns default server configuration.
@@ -51,8 +62,9 @@ type Server struct {
 	router           *chi.Mux
 	httpSrv          *http.Server
 	appConfig        *types.Config
-	storage          *storage.Storage
+	storage          storage.Storage
 	sessionService   *session.Service
+	searchIndex      *search.Index
 	providerReg      *provider.Registry
 	toolReg          *tool.Registry
 	bus              *event.Bus
@@ -61,10 +73,16 @@ type Server struct {
 	formatterManager *formatter.Manager
 	lspClient        *lsp.Client
 	vcsWatcher       *vcs.Watcher
+	scheduler        *schedule.Scheduler
+
+	// Provider logins; OAuth flows wait for their callback by provider ID
+	authStore  *auth.Store
+	oauthMu    sync.Mutex
+	oauthFlows map[string]*auth.Flow
 }
 
 // New creates a new Server instance.
-func New(cfg *Config, appConfig *types.Config, store *storage.Storage, providerReg *provider.Registry, toolReg *tool.Registry) *Server {
+func New(cfg *Config, appConfig *types.Config, store storage.Storage, providerReg *provider.Registry, toolReg *tool.Registry) *Server {
 	r := chi.NewRouter()
 
 	// Parse default provider and model from config
@@ -94,12 +112,45 @@ func New(cfg *Config, appConfig *types.Config, store *storage.Storage, providerR
 	// Initialize VCS watcher (watches for git branch changes)
 	vcsWatcher, _ := vcs.NewWatcher(cfg.Directory)
 
+	// Session search index, kept current from the event bus
+	searchIndex := search.NewIndex(store)
+	searchIndex.Subscribe()
+
+	// Built-in templated commands run like custom ones; custom commands of
+	// the same name take precedence
+	for _, info := range getBuiltInCommands(cfg.Directory) {
+		if _, ok := cmdExecutor.Get(info.Name); !ok {
+			cmdExecutor.AddCommand(&command.Command{
+				Name:        info.Name,
+				Description: info.Description,
+				Template:    info.Template,
+				Agent:       info.Agent,
+				Model:       info.Model,
+				Subtask:     info.Subtask,
+				Source:      "builtin",
+			})
+		}
+	}
+
+	// Permission requests are answered through POST /session/{id}/permissions/{id}
+	permChecker := permission.NewChecker()
+
+	sessionService := session.NewServiceWithProcessor(store, providerReg, toolReg, permChecker, defaultProviderID, defaultModelID)
+	sessionService.SetCommands(cmdExecutor)
+	if cfg.SnapshotDir != "" {
+		sessionService.SetSnapshots(snapshot.New(cfg.SnapshotDir))
+	}
+	sessionService.SetBudgets(session.NewBudgets(appConfig))
+	sessionService.SetFallbacks(session.NewFallbacks(appConfig))
+	sessionService.SetAgents(cfg.Agents)
+
 	s := &Server{
 		config:           cfg,
 		router:           r,
 		appConfig:        appConfig,
 		storage:          store,
-		sessionService:   session.NewServiceWithProcessor(store, providerReg, toolReg, nil, defaultProviderID, defaultModelID),
+		sessionService:   sessionService,
+		searchIndex:      searchIndex,
 		providerReg:      providerReg,
 		toolReg:          toolReg,
 		bus:              event.NewBus(),
@@ -109,6 +160,9 @@ func New(cfg *Config, appConfig *types.Config, store *storage.Storage, providerR
 		lspClient:        lspClient,
 		vcsWatcher:       vcsWatcher,
 	}
+	if cfg.AuthPath != "" {
+		s.authStore = auth.NewStore(cfg.AuthPath)
+	}
 
 	s.setupMiddleware()
 	s.setupRoutes()
@@ -142,6 +196,43 @@ func (s *Server) InitializeMCP(ctx context.Context) error {
 	return nil
 }
 
+// RecoverSessions marks messages left unfinished by a previous server
+// process as aborted. It should be called once at startup, before serving.
+func (s *Server) RecoverSessions(ctx context.Context) ([]string, error) {
+	return s.sessionService.RecoverInterrupted(ctx)
+}
+
+// CollectGarbage applies a storage retention policy and sweeps orphaned data.
+func (s *Server) CollectGarbage(ctx context.Context, policy session.RetentionPolicy) (*session.GCReport, error) {
+	return s.sessionService.CollectGarbage(ctx, policy, false)
+}
+
+// LoadSearchIndex loads the saved session search index, or indexes stored
+// sessions if none was saved, and saves the result. Without it the index is
+// loaded on the first search.
+func (s *Server) LoadSearchIndex(ctx context.Context) error {
+	if err := s.searchIndex.Load(ctx); err != nil {
+		return err
+	}
+	return s.searchIndex.Save(ctx)
+}
+
+// StartScheduler starts the scheduled sessions of the configuration. Runs
+// are stopped on Shutdown.
+func (s *Server) StartScheduler(ctx context.Context) error {
+	var entries map[string]types.ScheduleConfig
+	if s.appConfig != nil {
+		entries = s.appConfig.Schedule
+	}
+	scheduler, err := schedule.New(s.sessionService, s.storage, s.config.Directory, entries)
+	if err != nil {
+		return err
+	}
+	scheduler.Start(ctx)
+	s.scheduler = scheduler
+	return nil
+}
+
 // CloseMCP closes all MCP server connections.
 func (s *Server) CloseMCP() error {
 	if s.mcpClient != nil {
@@ -225,7 +316,12 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	if s.vcsWatcher != nil {
 		_ = s.vcsWatcher.Stop()
 	}
-	return s.httpSrv.Shutdown(ctx)
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
+	err := s.httpSrv.Shutdown(ctx)
+	s.searchIndex.Close()
+	return errors.Join(err, s.searchIndex.Save(ctx))
 }
 
 // Router returns the Chi router for testing.
@@ -1,6 +1,11 @@
 // Package session provides session processing and the agentic loop.
 package session
 
+import (
+	"github.com/opencode-ai/opencode/internal/agent"
+	"github.com/opencode-ai/opencode/internal/permission"
+)
+
 // Agent represents an agent configuration for processing.
 type Agent struct {
 	// Name is the agent identifier.
@@ -26,6 +31,10 @@ type Agent struct {
 
 	// Permission contains permission policy for this agent.
 	Permission AgentPermission `json:"permission,omitempty"`
+
+	// config is the registry agent this one was built from, whose tool
+	// patterns and bash command rules take precedence.
+	config *agent.Agent
 }
 
 // AgentPermission defines permission policies for an agent.
@@ -52,6 +61,10 @@ func (a *Agent) ToolEnabled(toolID string) bool {
 		}
 	}
 
+	if a.config != nil {
+		return a.config.ToolEnabled(toolID)
+	}
+
 	// If Tools is empty, all tools are enabled
 	if len(a.Tools) == 0 {
 		return true
@@ -67,6 +80,14 @@ func (a *Agent) ToolEnabled(toolID string) bool {
 	return false
 }
 
+// BashPolicy returns the permission policy for running a bash command.
+func (a *Agent) BashPolicy(command string) string {
+	if a.config != nil && len(a.config.Permission.Bash) > 0 {
+		return string(a.config.CheckBashPermission(command))
+	}
+	return a.Permission.Bash
+}
+
 // DefaultAgent returns the default agent configuration.
 func DefaultAgent() *Agent {
 	return &Agent{
@@ -110,7 +131,7 @@ func PlanAgent() *Agent {
 		Prompt: `You are a helpful assistant focused on planning and analysis.
 Break down complex tasks into manageable steps and provide clear explanations.
 Focus on understanding the problem before suggesting solutions.`,
-		DisabledTools: []string{"Write", "Edit", "Bash"},
+		DisabledTools: []string{"write", "edit", "bash"},
 		Permission: AgentPermission{
 			DoomLoop: "deny",
 			Bash:     "deny",
@@ -118,3 +139,67 @@ Focus on understanding the problem before suggesting solutions.`,
 		},
 	}
 }
+
+// AgentByName returns the agent configuration for an agent name sent by a
+// client. Names without a built-in configuration get the default one under
+// that name, so messages still record the agent the user picked.
+func AgentByName(name string) *Agent {
+	switch name {
+	case "":
+		return DefaultAgent()
+	case "code":
+		return CodeAgent()
+	case "plan":
+		return PlanAgent()
+	}
+	agent := DefaultAgent()
+	agent.Name = name
+	return agent
+}
+
+// AgentFromRegistry returns the configuration for processing with an agent
+// of the agent registry. Settings the agent leaves unset keep those of the
+// default agent.
+func AgentFromRegistry(a *agent.Agent) *Agent {
+	result := DefaultAgent()
+	result.Name = a.Name
+	result.Prompt = a.Prompt
+	if a.Temperature > 0 {
+		result.Temperature = a.Temperature
+	}
+	if a.TopP > 0 {
+		result.TopP = a.TopP
+	}
+	result.Permission = AgentPermission{
+		DoomLoop: string(a.GetPermission(permission.PermDoomLoop)),
+		Bash:     string(a.CheckBashPermission("*")),
+		Write:    string(a.GetPermission(permission.PermEdit)),
+	}
+	result.config = a
+	return result
+}
+
+// resolveAgent returns the configuration of an agent by name, from the agent
+// registry when it has one of that name.
+func (p *Processor) resolveAgent(name string) *Agent {
+	if p.agents != nil && name != "" {
+		if a, err := p.agents.Get(name); err == nil {
+			return AgentFromRegistry(a)
+		}
+	}
+	return AgentByName(name)
+}
+
+// SetAgents sets the registry agents named by messages and commands are
+// looked up in.
+func (p *Processor) SetAgents(agents *agent.Registry) {
+	p.agents = agents
+}
+
+// SetAgents sets the registry agents named by messages and commands are
+// looked up in.
+func (s *Service) SetAgents(agents *agent.Registry) {
+	if s.processor != nil {
+		s.processor.SetAgents(agents)
+	}
+}
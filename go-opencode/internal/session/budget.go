@@ -0,0 +1,222 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// Budgets holds the configured spending limits. Global applies to every
+// session and Agents to sessions run by the named agent; a limit set for the
+// agent, or on the session itself, overrides the less specific one.
+type Budgets struct {
+	Global *types.BudgetConfig
+	Agents map[string]*types.BudgetConfig
+}
+
+// NewBudgets collects the budgets of a configuration.
+func NewBudgets(cfg *types.Config) Budgets {
+	var b Budgets
+	if cfg == nil {
+		return b
+	}
+	b.Global = cfg.Budget
+	for name, agent := range cfg.Agent {
+		if agent.Budget != nil {
+			if b.Agents == nil {
+				b.Agents = make(map[string]*types.BudgetConfig)
+			}
+			b.Agents[name] = agent.Budget
+		}
+	}
+	return b
+}
+
+// resolve returns the limits for a session run by an agent, or nil if there
+// are none.
+func (b Budgets) resolve(agent string, session *types.Session) *types.BudgetConfig {
+	var out types.BudgetConfig
+	for _, layer := range []*types.BudgetConfig{b.Global, b.Agents[agent], session.Budget} {
+		if layer == nil {
+			continue
+		}
+		if layer.MaxCost > 0 {
+			out.MaxCost = layer.MaxCost
+		}
+		if layer.MaxInputTokens > 0 {
+			out.MaxInputTokens = layer.MaxInputTokens
+		}
+		if layer.MaxOutputTokens > 0 {
+			out.MaxOutputTokens = layer.MaxOutputTokens
+		}
+		if layer.MaxSeconds > 0 {
+			out.MaxSeconds = layer.MaxSeconds
+		}
+	}
+	if out == (types.BudgetConfig{}) {
+		return nil
+	}
+	return &out
+}
+
+// BudgetExceededError is returned when the agentic loop stops because a
+// session hit one of its budgets. Raising the budget and resuming the
+// session continues the stopped message.
+type BudgetExceededError struct {
+	SessionID string
+	Exceeded  string // "cost", "inputTokens", "outputTokens" or "time"
+	Budget    *types.BudgetConfig
+	Usage     types.BudgetUsage
+}
+
+func (e *BudgetExceededError) Error() string {
+	switch e.Exceeded {
+	case "cost":
+		return fmt.Sprintf("session budget exceeded: cost $%.4f of $%.4f", e.Usage.Cost, e.Budget.MaxCost)
+	case "inputTokens":
+		return fmt.Sprintf("session budget exceeded: %d of %d input tokens", e.Usage.InputTokens, e.Budget.MaxInputTokens)
+	case "outputTokens":
+		return fmt.Sprintf("session budget exceeded: %d of %d output tokens", e.Usage.OutputTokens, e.Budget.MaxOutputTokens)
+	default:
+		return fmt.Sprintf("session budget exceeded: ran for %.0fs of %ds", e.Usage.Seconds, e.Budget.MaxSeconds)
+	}
+}
+
+// MessageError returns the error as stored on the stopped message.
+func (e *BudgetExceededError) MessageError() *types.MessageError {
+	return types.NewBudgetExceededError(e.Error())
+}
+
+// checkBudget returns a BudgetExceededError if the session has spent its
+// budget. messages are the session's messages and started is when the
+// current run of the loop began.
+func (p *Processor) checkBudget(
+	ctx context.Context,
+	session *types.Session,
+	agent string,
+	messages []*types.Message,
+	started time.Time,
+) (*BudgetExceededError, error) {
+	budget := p.budgets.resolve(agent, session)
+	if budget == nil {
+		return nil, nil
+	}
+
+	usage, err := p.sessionUsage(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	usage.Seconds = time.Since(started).Seconds()
+
+	exceeded := ""
+	switch {
+	case budget.MaxCost > 0 && usage.Cost >= budget.MaxCost:
+		exceeded = "cost"
+	case budget.MaxInputTokens > 0 && usage.InputTokens >= budget.MaxInputTokens:
+		exceeded = "inputTokens"
+	case budget.MaxOutputTokens > 0 && usage.OutputTokens >= budget.MaxOutputTokens:
+		exceeded = "outputTokens"
+	case budget.MaxSeconds > 0 && usage.Seconds >= float64(budget.MaxSeconds):
+		exceeded = "time"
+	default:
+		return nil, nil
+	}
+
+	return &BudgetExceededError{
+		SessionID: session.ID,
+		Exceeded:  exceeded,
+		Budget:    budget,
+		Usage:     usage,
+	}, nil
+}
+
+// sessionUsage sums the cost and tokens of every step of a session.
+func (p *Processor) sessionUsage(ctx context.Context, messages []*types.Message) (types.BudgetUsage, error) {
+	var usage types.BudgetUsage
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		parts, err := p.loadParts(ctx, msg.ID)
+		if err != nil {
+			return usage, err
+		}
+		for _, part := range parts {
+			step, ok := part.(*types.StepFinishPart)
+			if !ok {
+				continue
+			}
+			usage.Cost += step.Cost
+			if step.Tokens != nil {
+				usage.InputTokens += step.Tokens.Input + step.Tokens.Cache.Read + step.Tokens.Cache.Write
+				usage.OutputTokens += step.Tokens.Output + step.Tokens.Reasoning
+			}
+		}
+	}
+	return usage, nil
+}
+
+// stopForBudget ends the assistant message with the budget error and tells
+// clients why the session stopped.
+func (p *Processor) stopForBudget(ctx context.Context, msg *types.Message, exceeded *BudgetExceededError) error {
+	msg.Error = exceeded.MessageError()
+	p.saveMessage(ctx, msg.SessionID, msg)
+
+	event.PublishSync(event.Event{
+		Type: event.SessionBudget,
+		Data: event.SessionBudgetData{
+			SessionID: exceeded.SessionID,
+			Exceeded:  exceeded.Exceeded,
+			Budget:    exceeded.Budget,
+			Usage:     exceeded.Usage,
+		},
+	})
+	event.PublishSync(event.Event{
+		Type: event.SessionError,
+		Data: event.SessionErrorData{
+			SessionID: exceeded.SessionID,
+			Error:     msg.Error,
+		},
+	})
+	return exceeded
+}
+
+// SetBudgets sets the configured budgets checked before every step.
+func (p *Processor) SetBudgets(budgets Budgets) {
+	p.budgets = budgets
+}
+
+// SetBudgets sets the configured budgets checked before every step.
+func (s *Service) SetBudgets(budgets Budgets) {
+	if s.processor != nil {
+		s.processor.SetBudgets(budgets)
+	}
+}
+
+// SetBudget replaces the limits set on a session, for example to raise a
+// budget it has hit. Zero values fall back to the configured budgets. After
+// raising it, Resume continues a message stopped by the budget.
+func (s *Service) SetBudget(ctx context.Context, sessionID string, budget *types.BudgetConfig) (*types.Session, error) {
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if budget != nil && *budget == (types.BudgetConfig{}) {
+		budget = nil
+	}
+	session.Budget = budget
+	session.Time.Updated = time.Now().UnixMilli()
+
+	if err := s.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session); err != nil {
+		return nil, err
+	}
+	event.PublishSync(event.Event{
+		Type: event.SessionUpdated,
+		Data: event.SessionUpdatedData{Info: session},
+	})
+	return session, nil
+}
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestBudgets_Resolve(t *testing.T) {
+	budgets := NewBudgets(&types.Config{
+		Budget: &types.BudgetConfig{MaxCost: 5, MaxSeconds: 600},
+		Agent: map[string]types.AgentConfig{
+			"build": {Budget: &types.BudgetConfig{MaxCost: 20, MaxOutputTokens: 100000}},
+			"plan":  {},
+		},
+	})
+
+	assert.Equal(t, &types.BudgetConfig{MaxCost: 5, MaxSeconds: 600}, budgets.resolve("plan", &types.Session{}))
+	assert.Equal(t, &types.BudgetConfig{MaxCost: 20, MaxOutputTokens: 100000, MaxSeconds: 600}, budgets.resolve("build", &types.Session{}))
+
+	// The session's own limits win
+	session := &types.Session{Budget: &types.BudgetConfig{MaxCost: 50}}
+	assert.Equal(t, 50.0, budgets.resolve("build", session).MaxCost)
+
+	assert.Nil(t, NewBudgets(nil).resolve("build", &types.Session{}))
+}
+
+func TestProcessor_CheckBudget(t *testing.T) {
+	store := storage.New(t.TempDir())
+	proc := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	ctx := context.Background()
+
+	step := func(msgID, id string, cost float64, input, output int) *types.StepFinishPart {
+		return &types.StepFinishPart{ID: id, MessageID: msgID, Type: "step-finish", Cost: cost,
+			Tokens: &types.TokenUsage{Input: input, Output: output}}
+	}
+	reasoned := step("msg2", "prt2", 0.5, 3000, 300)
+	reasoned.Tokens.Reasoning = 150
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant"},
+		step("msg2", "prt1", 0.25, 1000, 200), reasoned)
+	messages, err := proc.loadMessages(ctx, "ses1")
+	require.NoError(t, err)
+
+	session := &types.Session{ID: "ses1"}
+	exceeded, err := proc.checkBudget(ctx, session, "build", messages, time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, exceeded, "no budget configured")
+
+	proc.SetBudgets(Budgets{Global: &types.BudgetConfig{MaxCost: 1, MaxInputTokens: 4000}})
+	exceeded, err = proc.checkBudget(ctx, session, "build", messages, time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, "inputTokens", exceeded.Exceeded)
+	assert.Equal(t, 4000, exceeded.Usage.InputTokens)
+	assert.Equal(t, 650, exceeded.Usage.OutputTokens, "reasoning is output")
+	assert.InDelta(t, 0.75, exceeded.Usage.Cost, 1e-9)
+
+	// Raising the session's budget lets it continue
+	session.Budget = &types.BudgetConfig{MaxInputTokens: 10000}
+	exceeded, err = proc.checkBudget(ctx, session, "build", messages, time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, exceeded)
+
+	session.Budget = &types.BudgetConfig{MaxInputTokens: 10000, MaxSeconds: 60}
+	exceeded, err = proc.checkBudget(ctx, session, "build", messages, time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, "time", exceeded.Exceeded)
+}
+
+func TestProcessor_StopForBudgetIsResumable(t *testing.T) {
+	store := storage.New(t.TempDir())
+	proc := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	ctx := context.Background()
+
+	msg := &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant"}
+	err := proc.stopForBudget(ctx, msg, &BudgetExceededError{
+		SessionID: "ses1",
+		Exceeded:  "cost",
+		Budget:    &types.BudgetConfig{MaxCost: 1},
+		Usage:     types.BudgetUsage{Cost: 1.2},
+	})
+
+	var budgetErr *BudgetExceededError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Contains(t, err.Error(), "$1.2000 of $1.0000")
+
+	var stored types.Message
+	require.NoError(t, store.Get(ctx, []string{"message", "ses1", "msg2"}, &stored))
+	require.NotNil(t, stored.Error)
+	assert.Equal(t, types.ErrorNameBudgetExceeded, stored.Error.Name)
+	assert.True(t, isResumable(&stored))
+}
+
+func TestService_SetBudget(t *testing.T) {
+	store := storage.New(t.TempDir())
+	svc := NewService(store)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &types.Session{ID: "ses1", ProjectID: "proj1"}))
+
+	session, err := svc.SetBudget(ctx, "ses1", &types.BudgetConfig{MaxCost: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, session.Budget.MaxCost)
+
+	// An empty budget clears the session's limits
+	session, err = svc.SetBudget(ctx, "ses1", &types.BudgetConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, session.Budget)
+
+	stored, err := svc.Get(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Nil(t, stored.Budget)
+}
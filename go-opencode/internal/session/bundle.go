@@ -0,0 +1,409 @@
+package session
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/project"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// BundleVersion is the version of the bundle format written by Export.
+// Bundles without a version are plain exports from the TypeScript CLI.
+const BundleVersion = 1
+
+// ErrUnsupportedBundle is returned when importing a bundle written by a newer
+// version or missing its session.
+var ErrUnsupportedBundle = errors.New("unsupported session bundle")
+
+// ErrInvalidBundleID is returned when importing a bundle with a session,
+// message or part ID that is not a plain token. IDs become storage paths, so
+// one like "../x" would write outside the storage directory.
+var ErrInvalidBundleID = errors.New("invalid ID in session bundle")
+
+// Bundle is a self-contained copy of a session for moving it between
+// machines. It extends the TypeScript export format, so either can be
+// imported.
+type Bundle struct {
+	Version  int              `json:"version"`
+	Exported int64            `json:"exported"` // Unix ms
+	Info     *types.Session   `json:"info"`
+	Messages []BundleMessage  `json:"messages"`
+	Todos    []types.TodoInfo `json:"todos,omitempty"`
+	// Snapshots is a git pack of the file snapshots the session references.
+	Snapshots []byte `json:"snapshots,omitempty"`
+}
+
+// BundleMessage is a message of a bundle with its parts.
+type BundleMessage struct {
+	Info  *types.Message `json:"info"`
+	Parts []types.Part   `json:"parts"`
+}
+
+// UnmarshalJSON decodes parts by their type.
+func (m *BundleMessage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Info  *types.Message    `json:"info"`
+		Parts []json.RawMessage `json:"parts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	m.Info = raw.Info
+	m.Parts = make([]types.Part, 0, len(raw.Parts))
+	for _, data := range raw.Parts {
+		part, err := types.UnmarshalPart(data)
+		if err != nil {
+			return err
+		}
+		m.Parts = append(m.Parts, part)
+	}
+	return nil
+}
+
+// WriteBundle writes a bundle as indented JSON, gzipped if compress is set.
+func WriteBundle(w io.Writer, b *Bundle, compress bool) error {
+	if compress {
+		gz := gzip.NewWriter(w)
+		if err := WriteBundle(gz, b, false); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// ReadBundle reads a bundle written by WriteBundle, gzipped or not.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	var b Bundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("invalid session bundle: %w", err)
+	}
+	if b.Version > BundleVersion || b.Info == nil || b.Info.ID == "" {
+		return nil, ErrUnsupportedBundle
+	}
+	return &b, nil
+}
+
+// Export collects a session with its messages, parts and todos into a bundle.
+// With snapshots set, the file snapshots its steps and revert refer to are
+// included when they are available.
+func (s *Service) Export(ctx context.Context, sessionID string, snapshots bool) (*Bundle, error) {
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.GetMessages(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		Version:  BundleVersion,
+		Exported: time.Now().UnixMilli(),
+		Info:     session,
+		Messages: make([]BundleMessage, 0, len(messages)),
+	}
+
+	var hashes []string
+	if session.Revert != nil && session.Revert.Snapshot != nil {
+		hashes = append(hashes, *session.Revert.Snapshot)
+	}
+	for _, msg := range messages {
+		parts, err := s.GetParts(ctx, msg.ID)
+		if err != nil {
+			return nil, err
+		}
+		if parts == nil {
+			parts = []types.Part{}
+		}
+		b.Messages = append(b.Messages, BundleMessage{Info: msg, Parts: parts})
+
+		for _, part := range parts {
+			switch p := part.(type) {
+			case *types.StepStartPart:
+				if p.Snapshot != "" {
+					hashes = append(hashes, p.Snapshot)
+				}
+			case *types.StepFinishPart:
+				if p.Snapshot != "" {
+					hashes = append(hashes, p.Snapshot)
+				}
+			case *types.SnapshotPart:
+				hashes = append(hashes, p.Snapshot)
+			}
+		}
+	}
+
+	if b.Todos, err = GetTodos(ctx, s.storage, sessionID); err != nil {
+		return nil, err
+	}
+
+	if snapshots && s.snapshots != nil && len(hashes) > 0 {
+		// The project may have moved or no longer be a repository
+		if pack, err := s.snapshots.Export(ctx, session.Directory, hashes); err == nil {
+			b.Snapshots = pack
+		}
+	}
+
+	return b, nil
+}
+
+// Import stores the session of a bundle. The session is placed in the project
+// of directory, or of the directory it was exported from if empty. If the
+// session or any of its messages is already stored, for example when a bundle
+// is imported twice, the session, its messages and its parts all get new IDs.
+func (s *Service) Import(ctx context.Context, b *Bundle, directory string) (*types.Session, error) {
+	if b.Version > BundleVersion || b.Info == nil || b.Info.ID == "" {
+		return nil, ErrUnsupportedBundle
+	}
+
+	session := *b.Info
+	if directory != "" {
+		session.Directory = directory
+	}
+	projectID, err := project.GetProjectID(session.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project ID: %w", err)
+	}
+	session.ProjectID = projectID
+	session.Share = nil // Shares belong to the original
+
+	if err := validateBundleIDs(b); err != nil {
+		return nil, err
+	}
+
+	// New IDs are generated in the original order, so the order of messages
+	// and parts is kept
+	taken, err := s.bundleIDsTaken(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	remap := func(id string) string { return id }
+	if taken {
+		remap = newIDs()
+	}
+
+	session.ID = remap(session.ID)
+	if session.ParentID != nil {
+		if _, err := s.Get(ctx, *session.ParentID); err != nil {
+			session.ParentID = nil // The parent was not imported
+		}
+	}
+
+	messages := make([]*types.Message, 0, len(b.Messages))
+	parts := make([][]types.Part, 0, len(b.Messages))
+	for _, bm := range b.Messages {
+		if bm.Info == nil {
+			continue
+		}
+		msg := *bm.Info
+		msg.ID = remap(msg.ID)
+		msg.SessionID = session.ID
+		msg.ParentID = remap(msg.ParentID)
+
+		msgParts := make([]types.Part, 0, len(bm.Parts))
+		for _, part := range bm.Parts {
+			moved, err := movePart(part, remap(part.PartID()), session.ID, msg.ID)
+			if err != nil {
+				return nil, err
+			}
+			msgParts = append(msgParts, moved)
+		}
+		messages = append(messages, &msg)
+		parts = append(parts, msgParts)
+	}
+
+	if session.Revert != nil {
+		revert := *session.Revert
+		revert.MessageID = remap(revert.MessageID)
+		if revert.PartID != nil {
+			partID := remap(*revert.PartID)
+			revert.PartID = &partID
+		}
+		session.Revert = &revert
+	}
+
+	tx, err := s.storage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tx.Put(ctx, []string{"session", session.ProjectID, session.ID}, &session)
+	for i, msg := range messages {
+		tx.Put(ctx, []string{"message", session.ID, msg.ID}, msg)
+		for _, part := range parts[i] {
+			tx.Put(ctx, []string{"part", msg.ID, part.PartID()}, part)
+		}
+	}
+	if len(b.Todos) > 0 {
+		tx.Put(ctx, []string{"todo", session.ID}, b.Todos)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// Snapshots only matter for reverting; the session is usable without them
+	if len(b.Snapshots) > 0 && s.snapshots != nil {
+		if err := s.snapshots.Import(ctx, session.Directory, b.Snapshots); err != nil {
+			logging.Warn().Err(err).Str("sessionID", session.ID).Msg("Failed to import snapshots")
+		}
+	}
+
+	event.PublishSync(event.Event{
+		Type: event.SessionCreated,
+		Data: event.SessionCreatedData{Info: &session},
+	})
+	for i, msg := range messages {
+		event.PublishSync(event.Event{
+			Type: event.MessageCreated,
+			Data: event.MessageCreatedData{Info: msg},
+		})
+		for _, part := range parts[i] {
+			event.PublishSync(event.Event{
+				Type: event.MessagePartUpdated,
+				Data: event.MessagePartUpdatedData{Part: part},
+			})
+		}
+	}
+
+	return &session, nil
+}
+
+// validateBundleIDs checks that the IDs of a bundle are safe storage keys:
+// letters, digits, "_" and "-", as in ULIDs and the prefixed IDs of the
+// TypeScript CLI.
+func validateBundleIDs(b *Bundle) error {
+	ids := []string{b.Info.ID}
+	if b.Info.ParentID != nil {
+		ids = append(ids, *b.Info.ParentID)
+	}
+	if b.Info.Revert != nil {
+		ids = append(ids, b.Info.Revert.MessageID)
+		if b.Info.Revert.PartID != nil {
+			ids = append(ids, *b.Info.Revert.PartID)
+		}
+	}
+	for _, bm := range b.Messages {
+		if bm.Info == nil {
+			continue
+		}
+		ids = append(ids, bm.Info.ID)
+		if bm.Info.ParentID != "" {
+			ids = append(ids, bm.Info.ParentID)
+		}
+		for _, part := range bm.Parts {
+			ids = append(ids, part.PartID())
+		}
+	}
+	for _, id := range ids {
+		if !validID(id) {
+			return fmt.Errorf("%w: %q", ErrInvalidBundleID, id)
+		}
+	}
+	return nil
+}
+
+// validID reports whether id is a non-empty token of letters, digits, "_"
+// and "-".
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// bundleIDsTaken reports whether the session of a bundle, or any of its
+// messages, is already stored.
+func (s *Service) bundleIDsTaken(ctx context.Context, b *Bundle) (bool, error) {
+	if _, err := s.Get(ctx, b.Info.ID); err == nil {
+		return true, nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+
+	// Parts are stored by message ID alone
+	for _, bm := range b.Messages {
+		if bm.Info == nil {
+			continue
+		}
+		parts, err := s.storage.List(ctx, []string{"part", bm.Info.ID})
+		if err != nil {
+			return false, err
+		}
+		if len(parts) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// movePart returns a copy of part with the given IDs. Parts of every type
+// carry them under the same JSON names.
+// newIDs returns a function giving each ID a new one, the same every time it
+// is asked, and "" for "". IDs are generated in the order they are asked for.
+func newIDs() func(string) string {
+	ids := make(map[string]string)
+	return func(id string) string {
+		if id == "" {
+			return ""
+		}
+		if _, ok := ids[id]; !ok {
+			ids[id] = generateID()
+		}
+		return ids[id]
+	}
+}
+
+func movePart(part types.Part, id, sessionID, messageID string) (types.Part, error) {
+	data, err := json.Marshal(part)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["id"] = id
+	fields["sessionID"] = sessionID
+	fields["messageID"] = messageID
+
+	if data, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+	return types.UnmarshalPart(data)
+}
@@ -0,0 +1,174 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestService_ExportImport(t *testing.T) {
+	store := storage.New(t.TempDir())
+	svc := NewService(store)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1", Directory: dir, Title: "Fix the parser"}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, session))
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"},
+		&types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "Why does `parse` panic?"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1", ModelID: "claude", ProviderID: "anthropic"},
+		&types.ToolPart{ID: "prt2", SessionID: "ses1", MessageID: "msg2", Type: "tool", Tool: "read",
+			State: types.ToolState{Status: "completed", Input: map[string]any{"filePath": "parse.go"}, Output: "func parse() {}"}},
+		&types.TextPart{ID: "prt3", SessionID: "ses1", MessageID: "msg2", Type: "text", Text: "It reads past the <end>."})
+	require.NoError(t, store.Put(ctx, []string{"todo", "ses1"}, []types.TodoInfo{{ID: "1", Content: "add a test", Status: "pending"}}))
+
+	bundle, err := svc.Export(ctx, "ses1", true)
+	require.NoError(t, err)
+	assert.Equal(t, BundleVersion, bundle.Version)
+	require.Len(t, bundle.Messages, 2)
+	assert.Len(t, bundle.Messages[1].Parts, 2)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteBundle(&buf, bundle, true))
+	read, err := ReadBundle(&buf)
+	require.NoError(t, err)
+
+	// The session is still stored, so everything gets new IDs
+	imported, err := svc.Import(ctx, read, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, "ses1", imported.ID)
+	assert.Equal(t, "Fix the parser", imported.Title)
+	assert.Equal(t, dir, imported.Directory)
+
+	messages, err := svc.GetMessages(ctx, imported.ID)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "user", messages[0].Role)
+	assert.Equal(t, messages[0].ID, messages[1].ParentID)
+
+	parts, err := svc.GetParts(ctx, messages[1].ID)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	tool, ok := parts[0].(*types.ToolPart)
+	require.True(t, ok, "parts keep their order")
+	assert.Equal(t, imported.ID, tool.SessionID)
+	assert.Equal(t, messages[1].ID, tool.MessageID)
+	assert.Equal(t, "func parse() {}", tool.State.Output)
+
+	todos, err := GetTodos(ctx, store, imported.ID)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "add a test", todos[0].Content)
+
+	// The original is untouched
+	original, err := svc.GetParts(ctx, "msg2")
+	require.NoError(t, err)
+	assert.Len(t, original, 2)
+}
+
+func TestService_ImportKeepsFreeIDs(t *testing.T) {
+	svc := NewService(storage.New(t.TempDir()))
+	ctx := context.Background()
+
+	bundle := &Bundle{
+		Info: &types.Session{ID: "ses1", Directory: t.TempDir()},
+		Messages: []BundleMessage{{
+			Info:  &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"},
+			Parts: []types.Part{&types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "hi"}},
+		}},
+	}
+
+	imported, err := svc.Import(ctx, bundle, "")
+	require.NoError(t, err)
+	assert.Equal(t, "ses1", imported.ID)
+
+	parts, err := svc.GetParts(ctx, "msg1")
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "prt1", parts[0].PartID())
+}
+
+func TestService_ImportRejectsUnsafeIDs(t *testing.T) {
+	dir := t.TempDir()
+	svc := NewService(storage.New(filepath.Join(dir, "storage")))
+	ctx := context.Background()
+
+	bundle := func(sessionID, messageID, partID string) *Bundle {
+		return &Bundle{
+			Info: &types.Session{ID: sessionID, Directory: t.TempDir()},
+			Messages: []BundleMessage{{
+				Info:  &types.Message{ID: messageID, SessionID: sessionID, Role: "user"},
+				Parts: []types.Part{&types.TextPart{ID: partID, SessionID: sessionID, MessageID: messageID, Type: "text", Text: "hi"}},
+			}},
+		}
+	}
+	for _, b := range []*Bundle{
+		bundle("../../../config/opencode", "msg1", "prt1"),
+		bundle("ses1", `..\..\evil`, "prt1"),
+		bundle("ses1", "msg1", "a/b"),
+		bundle("ses1", "msg1", ""),
+	} {
+		_, err := svc.Import(ctx, b, "")
+		assert.ErrorIs(t, err, ErrInvalidBundleID)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		assert.Equal(t, "storage", e.Name(), "nothing is written outside the storage directory")
+	}
+}
+
+func TestReadBundle_Unsupported(t *testing.T) {
+	_, err := ReadBundle(bytes.NewBufferString(`{"version": 99, "info": {"id": "ses1"}}`))
+	assert.ErrorIs(t, err, ErrUnsupportedBundle)
+
+	_, err = ReadBundle(bytes.NewBufferString(`{"messages": []}`))
+	assert.ErrorIs(t, err, ErrUnsupportedBundle)
+
+	_, err = ReadBundle(bytes.NewBufferString(`not json`))
+	assert.Error(t, err)
+}
+
+func TestWriteExport_Transcripts(t *testing.T) {
+	bundle := &Bundle{
+		Version: BundleVersion,
+		Info:    &types.Session{ID: "ses1", Title: "Fix the parser"},
+		Messages: []BundleMessage{
+			{
+				Info:  &types.Message{ID: "msg1", Role: "user"},
+				Parts: []types.Part{&types.TextPart{ID: "prt1", Type: "text", Text: "Why does it panic?"}},
+			},
+			{
+				Info: &types.Message{ID: "msg2", Role: "assistant"},
+				Parts: []types.Part{
+					&types.StepStartPart{ID: "prt2", Type: "step-start"},
+					&types.ToolPart{ID: "prt3", Type: "tool", Tool: "bash",
+						State: types.ToolState{Status: "completed", Output: "```\n<script>"}},
+				},
+			},
+		},
+	}
+
+	var md bytes.Buffer
+	require.NoError(t, WriteExport(&md, bundle, FormatMarkdown, false))
+	assert.Contains(t, md.String(), "# Fix the parser")
+	assert.Contains(t, md.String(), "Why does it panic?")
+	assert.Contains(t, md.String(), "````\n```\n<script>\n````", "fences are longer than the output's")
+
+	var html bytes.Buffer
+	require.NoError(t, WriteExport(&html, bundle, FormatHTML, false))
+	assert.Contains(t, html.String(), "<title>Fix the parser</title>")
+	assert.Contains(t, html.String(), "Tool: bash")
+	assert.NotContains(t, html.String(), "<script>")
+
+	assert.Error(t, WriteExport(&html, bundle, "pdf", false))
+}
@@ -0,0 +1,278 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/command"
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ErrCommandNotFound is returned when running a command that is not defined.
+var ErrCommandNotFound = errors.New("command not found")
+
+// DefaultSubagent runs subtask commands that do not name an agent.
+const DefaultSubagent = "general"
+
+// CommandInput is a slash command sent to a session.
+type CommandInput struct {
+	SessionID string
+	MessageID string // ID of the user message; empty to generate one
+	Command   string // Name, without the slash
+	Arguments string
+	Agent     string // Used unless the command sets an agent
+	Model     string // "provider/model", used unless the command sets a model
+}
+
+// SetCommands sets the commands run by ExecuteCommand.
+func (s *Service) SetCommands(commands *command.Executor) {
+	s.commands = commands
+}
+
+// ExecuteCommand expands a command template with its arguments and sends the
+// result to the session as a user message, using the agent and model of the
+// command when it sets them. Subtask commands instead run the prompt through
+// the task tool, as a call made for the user. Like ProcessMessage, it returns
+// the assistant message answering the command.
+func (s *Service) ExecuteCommand(
+	ctx context.Context,
+	input CommandInput,
+	onUpdate func(msg *types.Message, parts []types.Part),
+) (*types.Message, []types.Part, error) {
+	if s.commands == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrCommandNotFound, input.Command)
+	}
+	cmd, ok := s.commands.Get(input.Command)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrCommandNotFound, input.Command)
+	}
+	if s.processor == nil {
+		return nil, nil, fmt.Errorf("no processor to run command %s", input.Command)
+	}
+
+	session, err := s.Get(ctx, input.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := s.commands.Execute(ctx, input.Command, input.Arguments)
+	if err != nil {
+		return nil, nil, err
+	}
+	prompt := strings.TrimSpace(result.Prompt)
+
+	agentName := result.Agent
+	if agentName == "" {
+		agentName = input.Agent
+	}
+	modelName := result.Model
+	if modelName == "" {
+		modelName = input.Model
+	}
+	model := parseModelRef(modelName)
+
+	if err := s.ApplyRevert(ctx, session); err != nil {
+		return nil, nil, err
+	}
+
+	var finalMsg *types.Message
+	var finalParts []types.Part
+	callback := func(msg *types.Message, parts []types.Part) {
+		finalMsg = msg
+		finalParts = parts
+		if onUpdate != nil {
+			onUpdate(msg, parts)
+		}
+	}
+
+	if result.Subtask {
+		description := cmd.Description
+		if description == "" {
+			description = cmd.Name
+		}
+		finalMsg, finalParts, err = s.processor.runSubtask(ctx, session.ID, agentName, model, description, prompt, callback)
+	} else {
+		// A busy session takes the command into its queue
+		finalMsg, finalParts, err = s.QueueMessage(ctx, &types.QueuedMessage{
+			SessionID: session.ID,
+			Text:      prompt,
+			Agent:     agentName,
+			Model:     model,
+		}, onUpdate)
+		if errors.Is(err, ErrNotBusy) {
+			err = s.sendPrompt(ctx, session.ID, input.MessageID, agentName, model, prompt, nil, callback)
+		}
+	}
+
+	if finalMsg != nil {
+		event.PublishSync(event.Event{
+			Type: event.CommandExecuted,
+			Data: event.CommandExecutedData{
+				Name:      input.Command,
+				SessionID: session.ID,
+				Arguments: input.Arguments,
+				MessageID: finalMsg.ID,
+			},
+		})
+	}
+	return finalMsg, finalParts, err
+}
+
+// PromptInput is a prompt sent to a session by the server itself, such as a
+// scheduled run.
+type PromptInput struct {
+	SessionID string
+	Text      string
+	Agent     string
+	Model     string // "provider/model"; empty for the last model used
+}
+
+// Prompt sends a prompt to a session as a user message and runs the agentic
+// loop to answer it, or queues it if the session is busy. Like
+// ProcessMessage, it returns the assistant message answering the prompt.
+func (s *Service) Prompt(
+	ctx context.Context,
+	input PromptInput,
+	onUpdate func(msg *types.Message, parts []types.Part),
+) (*types.Message, []types.Part, error) {
+	if s.processor == nil {
+		return nil, nil, fmt.Errorf("no processor to answer the prompt")
+	}
+
+	model := parseModelRef(input.Model)
+	finalMsg, finalParts, err := s.QueueMessage(ctx, &types.QueuedMessage{
+		SessionID: input.SessionID,
+		Text:      input.Text,
+		Agent:     input.Agent,
+		Model:     model,
+	}, onUpdate)
+	if errors.Is(err, ErrNotBusy) {
+		err = s.sendPrompt(ctx, input.SessionID, "", input.Agent, model, input.Text, nil, func(msg *types.Message, parts []types.Part) {
+			finalMsg = msg
+			finalParts = parts
+			if onUpdate != nil {
+				onUpdate(msg, parts)
+			}
+		})
+	}
+	return finalMsg, finalParts, err
+}
+
+// parseModelRef parses a "provider/model" string; it returns nil for names
+// without a provider.
+func parseModelRef(name string) *types.ModelRef {
+	providerID, modelID := provider.ParseModelString(name)
+	if providerID == "" {
+		return nil
+	}
+	return &types.ModelRef{ProviderID: providerID, ModelID: modelID}
+}
+
+// sendPrompt stores a prompt and the files attached to it as a user message
+// and runs the agentic loop to answer it.
+func (s *Service) sendPrompt(
+	ctx context.Context,
+	sessionID, messageID, agentName string,
+	model *types.ModelRef,
+	prompt string,
+	files []types.FilePart,
+	callback ProcessCallback,
+) error {
+	if messageID == "" {
+		messageID = generateID()
+	}
+	userMsg := &types.Message{
+		ID:        messageID,
+		SessionID: sessionID,
+		Role:      "user",
+		Agent:     agentName,
+		Model:     model,
+		Summary: &types.UserMessageSummary{
+			Diffs: []types.FileDiff{},
+		},
+		Time: types.MessageTime{
+			Created: time.Now().UnixMilli(),
+		},
+	}
+	if err := s.AddMessage(ctx, sessionID, userMsg); err != nil {
+		return err
+	}
+
+	textPart := &types.TextPart{
+		ID:        generateID(),
+		SessionID: sessionID,
+		MessageID: userMsg.ID,
+		Type:      "text",
+		Text:      prompt,
+	}
+	parts := []types.Part{textPart}
+	for i := range files {
+		file := files[i]
+		file.ID = generateID()
+		file.SessionID = sessionID
+		file.MessageID = userMsg.ID
+		file.Type = "file"
+		parts = append(parts, &file)
+	}
+	for _, part := range parts {
+		if err := s.SavePart(ctx, userMsg.ID, part); err != nil {
+			return err
+		}
+	}
+
+	event.PublishSync(event.Event{
+		Type: event.MessageUpdated,
+		Data: event.MessageUpdatedData{Info: userMsg},
+	})
+	for _, part := range parts {
+		event.PublishSync(event.Event{
+			Type: event.MessagePartUpdated,
+			Data: event.MessagePartUpdatedData{Part: part},
+		})
+	}
+
+	return s.processor.Process(ctx, sessionID, s.processor.resolveAgent(agentName), callback)
+}
+
+// runSubtask runs a prompt through the task tool for the user, recorded as a
+// user message holding a subtask part. Agents not set run the default
+// subagent.
+func (p *Processor) runSubtask(
+	ctx context.Context,
+	sessionID, agentName string,
+	model *types.ModelRef,
+	description, prompt string,
+	callback ProcessCallback,
+) (*types.Message, []types.Part, error) {
+	if agentName == "" {
+		agentName = DefaultSubagent
+	}
+
+	return p.runUserTool(ctx, &userToolCall{
+		sessionID: sessionID,
+		agent:     p.resolveAgent(agentName),
+		model:     model,
+		part: func(msg *types.Message) types.Part {
+			return &types.SubtaskPart{
+				ID:          generatePartID(),
+				SessionID:   msg.SessionID,
+				MessageID:   msg.ID,
+				Type:        "subtask",
+				Prompt:      prompt,
+				Description: description,
+				Agent:       agentName,
+			}
+		},
+		tool: "task",
+		input: map[string]any{
+			"description":  description,
+			"prompt":       prompt,
+			"subagentType": agentName,
+		},
+	}, callback)
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/command"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestService_ExecuteCommandNotFound(t *testing.T) {
+	svc, _ := newShellService(t, nil)
+
+	_, _, err := svc.ExecuteCommand(context.Background(), CommandInput{SessionID: "ses1", Command: "review"}, nil)
+	assert.ErrorIs(t, err, ErrCommandNotFound)
+
+	svc.SetCommands(command.NewExecutor(t.TempDir(), nil))
+	_, _, err = svc.ExecuteCommand(context.Background(), CommandInput{SessionID: "ses1", Command: "review"}, nil)
+	assert.ErrorIs(t, err, ErrCommandNotFound)
+}
+
+func TestService_ExecuteCommandSubtask(t *testing.T) {
+	svc, _ := newShellService(t, nil)
+	ctx := context.Background()
+
+	commands := command.NewExecutor(t.TempDir(), nil)
+	commands.AddCommand(&command.Command{
+		Name:        "audit",
+		Description: "audit a package",
+		Template:    "Audit $ARGUMENTS for races",
+		Agent:       "explore",
+		Model:       "openai/gpt-4o",
+		Subtask:     true,
+	})
+	svc.SetCommands(commands)
+
+	msg, parts, err := svc.ExecuteCommand(ctx, CommandInput{
+		SessionID: "ses1",
+		Command:   "audit",
+		Arguments: "internal/session",
+		Agent:     "build",
+		Model:     "anthropic/claude",
+	}, nil)
+	require.NoError(t, err)
+
+	// The command's agent and model win over the request's
+	assert.Equal(t, "explore", msg.Mode)
+	assert.Equal(t, "openai", msg.ProviderID)
+	assert.Equal(t, "gpt-4o", msg.ModelID)
+
+	require.Len(t, parts, 1)
+	toolPart := parts[0].(*types.ToolPart)
+	assert.Equal(t, "task", toolPart.Tool)
+	assert.Equal(t, "completed", toolPart.State.Status)
+	assert.Equal(t, "Audit internal/session for races", toolPart.State.Input["prompt"])
+	assert.Equal(t, "explore", toolPart.State.Input["subagentType"])
+
+	messages, err := svc.GetMessages(ctx, "ses1")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	userParts, err := svc.GetParts(ctx, messages[0].ID)
+	require.NoError(t, err)
+	require.Len(t, userParts, 1)
+	subtask := userParts[0].(*types.SubtaskPart)
+	assert.Equal(t, "audit a package", subtask.Description)
+	assert.Equal(t, "explore", subtask.Agent)
+}
+
+func TestAgentByName(t *testing.T) {
+	assert.Equal(t, "default", AgentByName("").Name)
+	assert.Equal(t, "deny", AgentByName("plan").Permission.Bash)
+	assert.Equal(t, "allow", AgentByName("code").Permission.Write)
+
+	custom := AgentByName("build")
+	assert.Equal(t, "build", custom.Name)
+	assert.Equal(t, DefaultAgent().Permission, custom.Permission)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/opencode-ai/opencode/internal/event"
 	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/tokenizer"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -24,112 +25,228 @@ type CompactionConfig struct {
 
 	// ContextThreshold is the percentage of context usage that triggers compaction.
 	ContextThreshold float64
+
+	// PruneProtectTokens is how much of the most recent tool output is never pruned.
+	PruneProtectTokens int
+
+	// PruneMinimumTokens is the least tool output worth pruning at once.
+	PruneMinimumTokens int
 }
 
 // DefaultCompactionConfig returns the default compaction configuration.
 var DefaultCompactionConfig = CompactionConfig{
-	MinMessagesToKeep: 4,
-	SummaryMaxTokens:  2000,
-	ContextThreshold:  0.75,
+	MinMessagesToKeep:  4,
+	SummaryMaxTokens:   2000,
+	ContextThreshold:   0.75,
+	PruneProtectTokens: 40000,
+	PruneMinimumTokens: 20000,
 }
 
-// compactMessages summarizes old messages to free context.
-func (p *Processor) compactMessages(
-	ctx context.Context,
-	sessionID string,
-	messages []*types.Message,
-) error {
-	if len(messages) <= DefaultCompactionConfig.MinMessagesToKeep {
-		return nil
-	}
+// prunedToolOutput replaces the output of pruned tool calls in prompts.
+const prunedToolOutput = "[Old tool result content cleared]"
 
-	// Update session compacting flag
-	session, err := p.findSession(ctx, sessionID)
-	if err != nil {
-		return err
-	}
+// legacyCompactionFile is the fake diff older versions stored summaries in.
+const legacyCompactionFile = "__compaction__"
 
-	now := time.Now().UnixMilli()
-	session.Time.Compacting = &now
-	p.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
+// isOverflow reports whether a request that used tokens filled enough of the
+// model's context window that the session should be compacted before the next
+// one. Models that do not report a context length are assumed to have
+// MaxContextTokens.
+func (c CompactionConfig) isOverflow(tokens *types.TokenUsage, model *types.Model) bool {
+	if tokens == nil || c.ContextThreshold <= 0 {
+		return false
+	}
 
-	defer func() {
-		session.Time.Compacting = nil
-		p.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
-	}()
+	limit := MaxContextTokens
+	if model != nil && model.ContextLength > 0 {
+		limit = model.ContextLength
+	}
 
-	// Determine which messages to compact
-	compactEnd := len(messages) - DefaultCompactionConfig.MinMessagesToKeep
-	toCompact := messages[:compactEnd]
+	used := tokens.Input + tokens.Cache.Read + tokens.Cache.Write + tokens.Output
+	return float64(used) > float64(limit)*c.ContextThreshold
+}
 
-	// Build summary prompt
-	summaryPrompt := buildSummaryPrompt(ctx, p, toCompact)
+// lastUsage returns the token usage of the most recent request in the
+// compacted history, or nil if none has completed since the last summary.
+func lastUsage(messages []*types.Message) *types.TokenUsage {
+	history := types.CompactedHistory(messages)
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if msg.Role == "assistant" && !msg.IsSummary && msg.Tokens != nil && msg.Tokens.Input+msg.Tokens.Cache.Read > 0 {
+			return msg.Tokens
+		}
+	}
+	return nil
+}
 
-	// Get default model for summarization
-	model, err := p.providerRegistry.DefaultModel()
+// compactAndContinue summarizes the session through an automatic compaction
+// request and restarts the loop, which then continues from the summary.
+func (p *Processor) compactAndContinue(
+	ctx context.Context,
+	sessionID string,
+	state *sessionState,
+	userMsg *types.Message,
+	agent *Agent,
+	callback ProcessCallback,
+) error {
+	compactionPart, err := p.requestCompaction(ctx, sessionID, userMsg)
 	if err != nil {
 		return err
 	}
 
-	prov, err := p.providerRegistry.Get(model.ProviderID)
+	messages, err := p.loadMessages(ctx, sessionID)
 	if err != nil {
 		return err
 	}
 
-	// Generate summary
-	systemMsg := &schema.Message{
-		Role:    schema.System,
-		Content: "You are a conversation summarizer. Create a concise summary of the conversation that preserves key context for continuing the discussion.",
+	if err := p.processCompaction(ctx, sessionID, messages, compactionPart, callback); err != nil {
+		return fmt.Errorf("compaction failed: %w", err)
 	}
 
-	userMsg := &schema.Message{
-		Role:    schema.User,
-		Content: summaryPrompt,
+	state.message = nil
+	state.parts = nil
+	state.resume = false
+	return p.runLoop(ctx, sessionID, state, agent, callback)
+}
+
+// requestCompaction stores a user message asking for automatic compaction,
+// carrying over the agent and model of userMsg.
+func (p *Processor) requestCompaction(ctx context.Context, sessionID string, userMsg *types.Message) (*types.CompactionPart, error) {
+	msg := &types.Message{
+		ID:        generatePartID(),
+		SessionID: sessionID,
+		Role:      "user",
+		Agent:     userMsg.Agent,
+		Model:     userMsg.Model,
+		Time: types.MessageTime{
+			Created: time.Now().UnixMilli(),
+		},
+	}
+	part := &types.CompactionPart{
+		ID:        generatePartID(),
+		SessionID: sessionID,
+		MessageID: msg.ID,
+		Type:      "compaction",
+		Auto:      true,
 	}
 
-	// Create streaming request
-	stream, err := prov.CreateCompletion(ctx, &provider.CompletionRequest{
-		Model:     model.ID,
-		Messages:  []*schema.Message{systemMsg, userMsg},
-		MaxTokens: DefaultCompactionConfig.SummaryMaxTokens,
+	tx, err := p.storage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	tx.Put(ctx, []string{"message", sessionID, msg.ID}, msg)
+	tx.Put(ctx, []string{"part", msg.ID, part.ID}, part)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	event.PublishSync(event.Event{
+		Type: event.MessageCreated,
+		Data: event.MessageCreatedData{Info: msg},
+	})
+	event.PublishSync(event.Event{
+		Type: event.MessagePartUpdated,
+		Data: event.MessagePartUpdatedData{Part: part},
 	})
+
+	return part, nil
+}
+
+// pruneToolOutputs marks the outputs of old tool calls as compacted so they
+// are no longer sent to the model. The last two user turns and the most recent
+// PruneProtectTokens of tool output are kept, and nothing is pruned unless at
+// least PruneMinimumTokens would be freed. Tokens are counted as model counts
+// them. Stored outputs are left intact.
+func (p *Processor) pruneToolOutputs(ctx context.Context, sessionID string, model *types.Model) error {
+	messages, err := p.loadMessages(ctx, sessionID)
 	if err != nil {
 		return err
 	}
-	defer stream.Close()
+	history := types.CompactedHistory(messages)
+	tok := tokenizer.ForModel(model)
 
-	// Collect response
-	var summary strings.Builder
-	for {
-		msg, err := stream.Recv()
-		if err == io.EOF {
+	var toPrune []*types.ToolPart
+	turns, total, pruned := 0, 0, 0
+
+scan:
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if msg.Role == "user" {
+			turns++
+		}
+		if turns < 2 {
+			continue
+		}
+		if msg.Role == "assistant" && msg.IsSummary {
 			break
 		}
+
+		parts, err := p.loadParts(ctx, msg.ID)
 		if err != nil {
 			return err
 		}
-		summary.WriteString(msg.Content)
+		for j := len(parts) - 1; j >= 0; j-- {
+			toolPart, ok := parts[j].(*types.ToolPart)
+			if !ok || toolPart.State.Status != "completed" {
+				continue
+			}
+			if toolPart.State.Time != nil && toolPart.State.Time.Compacted != nil {
+				break scan // Everything older was pruned before
+			}
+
+			n := tok.Count(toolPart.State.Output)
+			total += n
+			if total > p.compaction.PruneProtectTokens {
+				pruned += n
+				toPrune = append(toPrune, toolPart)
+			}
+		}
 	}
 
-	// Mark compacted messages as summarized
-	for _, msg := range toCompact {
-		// Update message to indicate it was summarized
-		// In a full implementation, we might add a "summarized" field
-		p.storage.Put(ctx, []string{"message", sessionID, msg.ID}, msg)
+	if pruned < p.compaction.PruneMinimumTokens {
+		return nil
 	}
 
-	// Create compaction marker in session
-	// This would be used to inject the summary into future prompts
-	session.Summary.Diffs = append(session.Summary.Diffs, types.FileDiff{
-		File:   "__compaction__",
-		Before: "",
-		After:  summary.String(),
-	})
-	p.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
+	tx, err := p.storage.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	for _, toolPart := range toPrune {
+		if toolPart.State.Time == nil {
+			toolPart.State.Time = &types.ToolTime{Start: now}
+		}
+		toolPart.State.Time.Compacted = &now
+		tx.Put(ctx, []string{"part", toolPart.MessageID, toolPart.ID}, toolPart)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, toolPart := range toPrune {
+		event.PublishSync(event.Event{
+			Type: event.MessagePartUpdated,
+			Data: event.MessagePartUpdatedData{Part: toolPart},
+		})
+	}
 
 	return nil
 }
 
+// withoutLegacyCompaction drops summaries that older versions stored as diffs.
+func withoutLegacyCompaction(diffs []types.FileDiff) []types.FileDiff {
+	var filtered []types.FileDiff
+	for _, d := range diffs {
+		if d.File != legacyCompactionFile {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
 // buildSummaryPrompt creates a prompt for summarizing messages.
 func buildSummaryPrompt(ctx context.Context, p *Processor, messages []*types.Message) string {
 	var prompt strings.Builder
@@ -178,12 +295,6 @@ func buildSummaryPrompt(ctx context.Context, p *Processor, messages []*types.Mes
 	return prompt.String()
 }
 
-// estimateTokens provides a rough estimate of token count.
-func estimateTokens(text string) int {
-	// Rough estimate: ~4 characters per token
-	return len(text) / 4
-}
-
 // compactionSystemPrompt is the system prompt for generating summaries.
 const compactionSystemPrompt = `You are a conversation summarizer. Create a concise summary of the conversation that preserves key context for continuing the discussion.
 
@@ -241,8 +352,9 @@ func (p *Processor) processCompaction(
 		p.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
 	}()
 
-	// Build summary prompt from all messages except the compaction request itself
-	summaryPrompt := buildSummaryPrompt(ctx, p, messages[:len(messages)-1])
+	// Build summary prompt from all messages except the compaction request itself.
+	// A previous summary stands in for everything before it.
+	summaryPrompt := buildSummaryPrompt(ctx, p, types.CompactedHistory(messages[:len(messages)-1]))
 	summaryPrompt += "\n\nSummarize our conversation above. This summary will be the only context available when the conversation continues, so preserve critical information including: what was accomplished, current work in progress, files involved, next steps, and any key user requests or constraints. Be concise but detailed enough that work can continue seamlessly."
 
 	// Create assistant message with summary flag
@@ -310,27 +422,41 @@ func (p *Processor) processCompaction(
 		Content: summaryPrompt,
 	}
 
+	ctx = provider.WithSession(ctx, sessionID, provider.PurposeCompaction)
 	stream, err := prov.CreateCompletion(ctx, &provider.CompletionRequest{
 		Model:     model.ID,
 		Messages:  []*schema.Message{systemMsg, userMsg},
-		MaxTokens: DefaultCompactionConfig.SummaryMaxTokens,
+		MaxTokens: p.compaction.SummaryMaxTokens,
 	})
 	if err != nil {
+		assistantMsg.Error = types.NewUnknownError(err.Error())
+		p.saveMessage(ctx, sessionID, assistantMsg)
 		return fmt.Errorf("failed to create completion: %w", err)
 	}
 	defer stream.Close()
 
 	// Stream the response
 	var fullText strings.Builder
+	var usage *schema.TokenUsage
+	var cacheWriteTokens int
 	for {
 		msg, err := stream.Recv()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			// An unfinished summary must not replace the history
+			assistantMsg.Error = types.NewUnknownError(err.Error())
+			p.saveMessage(ctx, sessionID, assistantMsg)
 			return fmt.Errorf("stream error: %w", err)
 		}
 
+		if msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+			usage = msg.ResponseMeta.Usage
+		}
+		if n := provider.CacheWriteTokens(msg); n > 0 {
+			cacheWriteTokens = n
+		}
 		fullText.WriteString(msg.Content)
 		textPart.Text = fullText.String()
 
@@ -347,19 +473,21 @@ func (p *Processor) processCompaction(
 		})
 	}
 
-	// Update message with final token counts
-	// (In a full implementation, we'd get actual token counts from the provider)
+	// Record token counts, estimated if the provider did not report usage.
+	// Finishing the message makes the summary take effect.
+	tok := tokenizer.ForModel(model)
 	assistantMsg.Tokens = &types.TokenUsage{
-		Input:  estimateTokens(summaryPrompt),
-		Output: estimateTokens(fullText.String()),
+		Input:  tok.Count(summaryPrompt),
+		Output: tok.Count(fullText.String()),
 	}
-	p.storage.Put(ctx, []string{"message", sessionID, assistantMsg.ID}, assistantMsg)
-
-	// Publish message updated event
-	event.PublishSync(event.Event{
-		Type: event.MessageUpdated,
-		Data: event.MessageUpdatedData{Info: assistantMsg},
-	})
+	if usage != nil {
+		tokens := usageTokens(usage.PromptTokens, usage.CompletionTokens, usage.PromptTokenDetails.CachedTokens, cacheWriteTokens)
+		assistantMsg.Tokens = &tokens
+	}
+	assistantMsg.Cost = model.Cost(assistantMsg.Tokens)
+	finish := "stop"
+	assistantMsg.Finish = &finish
+	p.saveMessage(ctx, sessionID, assistantMsg)
 
 	// Publish session.compacted event
 	event.PublishSync(event.Event{
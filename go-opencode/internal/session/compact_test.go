@@ -0,0 +1,162 @@
+package session
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func putMessage(t *testing.T, store storage.Storage, msg *types.Message, parts ...types.Part) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, []string{"message", msg.SessionID, msg.ID}, msg))
+	for _, part := range parts {
+		require.NoError(t, store.Put(ctx, []string{"part", msg.ID, part.PartID()}, part))
+	}
+}
+
+func toolOutput(msgID, id, output string) *types.ToolPart {
+	return &types.ToolPart{
+		ID: id, SessionID: "ses1", MessageID: msgID, Type: "tool", CallID: "call-" + id, Tool: "read",
+		State: types.ToolState{Status: "completed", Output: output, Time: &types.ToolTime{Start: 1}},
+	}
+}
+
+func TestCompactionConfig_IsOverflow(t *testing.T) {
+	c := DefaultCompactionConfig
+	model := &types.Model{ContextLength: 100000}
+
+	assert.False(t, c.isOverflow(nil, model))
+	assert.False(t, c.isOverflow(&types.TokenUsage{Input: 70000, Output: 5000}, model))
+	assert.True(t, c.isOverflow(&types.TokenUsage{Input: 70000, Output: 5001}, model))
+	assert.True(t, c.isOverflow(&types.TokenUsage{Input: 5000, Output: 1, Cache: types.CacheUsage{Read: 70000}}, model),
+		"cached prompt tokens fill the context too")
+
+	// Models without a context length fall back to MaxContextTokens
+	assert.False(t, c.isOverflow(&types.TokenUsage{Input: 100000}, &types.Model{}))
+	assert.True(t, c.isOverflow(&types.TokenUsage{Input: 120000}, &types.Model{}))
+}
+
+func TestCompactedHistory_SummaryReplacesEarlierMessages(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &types.Session{ID: "ses1", ProjectID: "proj1"}))
+
+	stop := "stop"
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"},
+		&types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "fix the flaky test"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1", Finish: &stop,
+		Tokens: &types.TokenUsage{Input: 90000}},
+		&types.TextPart{ID: "prt2", SessionID: "ses1", MessageID: "msg2", Type: "text", Text: "done"})
+	putMessage(t, store, &types.Message{ID: "msg3", SessionID: "ses1", Role: "user"},
+		&types.CompactionPart{ID: "prt3", SessionID: "ses1", MessageID: "msg3", Type: "compaction", Auto: true})
+	putMessage(t, store, &types.Message{ID: "msg4", SessionID: "ses1", Role: "assistant", ParentID: "msg3", IsSummary: true, Finish: &stop},
+		&types.TextPart{ID: "prt4", SessionID: "ses1", MessageID: "msg4", Type: "text", Text: "We fixed the flaky test."})
+	putMessage(t, store, &types.Message{ID: "msg5", SessionID: "ses1", Role: "user"},
+		&types.TextPart{ID: "prt5", SessionID: "ses1", MessageID: "msg5", Type: "text", Text: "now add a changelog entry"})
+
+	proc := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	messages, err := proc.loadMessages(ctx, "ses1")
+	require.NoError(t, err)
+
+	// Usage from before the summary no longer counts
+	assert.Nil(t, lastUsage(messages))
+
+	req, err := proc.buildCompletionRequest(ctx, "ses1", messages, messages[len(messages)-1], DefaultAgent(), &types.Model{ID: "test"})
+	require.NoError(t, err)
+
+	var contents []string
+	for _, msg := range req.Messages[1:] {
+		contents = append(contents, msg.Content)
+	}
+	assert.Equal(t, []string{types.CompactionRequestText, "We fixed the flaky test.", "now add a changelog entry"}, contents)
+}
+
+func TestProcessor_PruneToolOutputs(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &types.Session{ID: "ses1", ProjectID: "proj1"}))
+
+	big := strings.Repeat("word ", 30000) // ~30k tokens
+	stop := "stop"
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1", Finish: &stop},
+		toolOutput("msg2", "prt1", big), toolOutput("msg2", "prt2", big))
+	putMessage(t, store, &types.Message{ID: "msg3", SessionID: "ses1", Role: "user"})
+	putMessage(t, store, &types.Message{ID: "msg4", SessionID: "ses1", Role: "assistant", ParentID: "msg3", Finish: &stop},
+		toolOutput("msg4", "prt3", big))
+	putMessage(t, store, &types.Message{ID: "msg5", SessionID: "ses1", Role: "user"})
+	putMessage(t, store, &types.Message{ID: "msg6", SessionID: "ses1", Role: "assistant", ParentID: "msg5", Finish: &stop},
+		toolOutput("msg6", "prt4", big))
+
+	proc := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	require.NoError(t, proc.pruneToolOutputs(ctx, "ses1", nil))
+
+	compacted := func(msgID, partID string) bool {
+		var part types.ToolPart
+		require.NoError(t, store.Get(ctx, []string{"part", msgID, partID}, &part))
+		assert.Equal(t, big, part.State.Output, "stored output is kept")
+		return part.State.Time.Compacted != nil
+	}
+
+	// The last two turns are protected, then 40k tokens of the newest output
+	assert.False(t, compacted("msg6", "prt4"))
+	assert.False(t, compacted("msg4", "prt3"))
+	assert.False(t, compacted("msg2", "prt2"))
+	assert.True(t, compacted("msg2", "prt1"))
+
+	messages, err := proc.loadMessages(ctx, "ses1")
+	require.NoError(t, err)
+	req, err := proc.buildCompletionRequest(ctx, "ses1", messages, messages[len(messages)-1], DefaultAgent(), &types.Model{ID: "test"})
+	require.NoError(t, err)
+
+	var pruned int
+	for _, msg := range req.Messages {
+		if msg.Content == prunedToolOutput {
+			assert.Equal(t, "call-prt1", msg.ToolCallID)
+			pruned++
+		}
+	}
+	assert.Equal(t, 1, pruned)
+}
+
+func TestService_Context(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &types.Session{ID: "ses1", ProjectID: "proj1"}))
+
+	model := &types.ModelRef{ProviderID: "local", ModelID: "test-model"}
+	stop := "stop"
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user", Model: model},
+		&types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "fix the flaky test"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1", Finish: &stop,
+		Tokens: &types.TokenUsage{Input: 1000, Output: 200, Cache: types.CacheUsage{Read: 500}}})
+	putMessage(t, store, &types.Message{ID: "msg3", SessionID: "ses1", Role: "user", Model: model},
+		&types.TextPart{ID: "prt3", SessionID: "ses1", MessageID: "msg3", Type: "text", Text: "now add a changelog entry"})
+
+	svc := NewService(store)
+	svc.processor = NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+
+	usage, err := svc.Context(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Equal(t, &ContextUsage{
+		ProviderID: "local",
+		ModelID:    "test-model",
+		Tokenizer:  "estimate",
+		Limit:      MaxContextTokens,
+		Used:       1706,
+		Reported:   1700,
+		Counted:    6, // Only the message after the reported usage
+		CompactAt:  int(MaxContextTokens * DefaultCompactionConfig.ContextThreshold),
+	}, usage)
+
+	_, err = svc.Context(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
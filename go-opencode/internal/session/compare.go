@@ -0,0 +1,334 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+var (
+	// ErrNoCompareModels is returned when comparing a prompt on no models.
+	ErrNoCompareModels = errors.New("no models to compare")
+
+	// ErrInvalidModel is returned for a model not in "provider/model" form.
+	ErrInvalidModel = errors.New("invalid model")
+)
+
+// CompareInput is a prompt sent to several models to compare their answers.
+type CompareInput struct {
+	SessionID string
+	Text      string
+	Files     []types.FilePart // Attached to the prompt in every fork
+	Agent     string
+	Models    []string // "provider/model"
+	Isolate   bool     // Run each fork on its own copy of the working tree
+}
+
+// CompareRun is the outcome of a compared prompt on one model.
+type CompareRun struct {
+	Model     types.ModelRef   `json:"model"`
+	SessionID string           `json:"sessionID"` // The fork the prompt ran in
+	Directory string           `json:"directory"`
+	MessageID string           `json:"messageID,omitempty"` // Last assistant message
+	Text      string           `json:"text"`                // Text of the last assistant message
+	Latency   int64            `json:"latency"`             // Milliseconds
+	Diffs     []types.FileDiff `json:"diffs"`
+	Error     string           `json:"error,omitempty"`
+	Usage
+}
+
+// CompareResult is the summary of a comparison, with one run per model in
+// the order the models were given.
+type CompareResult struct {
+	SessionID string       `json:"sessionID"`
+	Prompt    string       `json:"prompt"`
+	Runs      []CompareRun `json:"runs"`
+}
+
+// Compare forks a session once per model and sends the prompt to each fork,
+// running the forks side by side. With Isolate, each fork works on a copy of
+// the session directory, so the changes of one model do not show in the
+// others. A run failing is reported on its CompareRun.
+func (s *Service) Compare(ctx context.Context, input CompareInput) (*CompareResult, error) {
+	if s.processor == nil {
+		return nil, fmt.Errorf("no processor to compare models")
+	}
+	if len(input.Models) == 0 {
+		return nil, ErrNoCompareModels
+	}
+	models := make([]types.ModelRef, len(input.Models))
+	for i, name := range input.Models {
+		providerID, modelID := provider.ParseModelString(strings.TrimSpace(name))
+		if providerID == "" || modelID == "" {
+			return nil, fmt.Errorf("%w %q: expected provider/model", ErrInvalidModel, name)
+		}
+		models[i] = types.ModelRef{ProviderID: providerID, ModelID: modelID}
+	}
+
+	session, err := s.Get(ctx, input.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ApplyRevert(ctx, session); err != nil {
+		return nil, err
+	}
+
+	forks := make([]*types.Session, len(models))
+	for i, model := range models {
+		fork, err := s.forkForCompare(ctx, session, model, input.Isolate)
+		if err != nil {
+			return nil, err
+		}
+		forks[i] = fork
+	}
+
+	result := &CompareResult{
+		SessionID: session.ID,
+		Prompt:    input.Text,
+		Runs:      make([]CompareRun, len(models)),
+	}
+	var wg sync.WaitGroup
+	for i := range models {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result.Runs[i] = s.runCompare(ctx, forks[i], models[i], input)
+		}(i)
+	}
+	wg.Wait()
+
+	return result, nil
+}
+
+// forkForCompare forks a session for the run of a model. The fork has
+// message and part IDs of its own, so it can be deleted or reverted without
+// touching the session. Its copy of the working tree, if isolated, is removed
+// with it.
+func (s *Service) forkForCompare(ctx context.Context, session *types.Session, model types.ModelRef, isolate bool) (*types.Session, error) {
+	fork, err := s.Fork(ctx, session.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	fork.Title = fmt.Sprintf("%s (%s/%s)", session.Title, model.ProviderID, model.ModelID)
+	if isolate {
+		dir, err := copyWorkTree(session.Directory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy working tree: %w", err)
+		}
+		fork.Directory = dir
+	}
+	if err := s.storage.Put(ctx, []string{"session", fork.ProjectID, fork.ID}, fork); err != nil {
+		return nil, err
+	}
+
+	event.PublishSync(event.Event{
+		Type: event.SessionCreated,
+		Data: event.SessionCreatedData{Info: fork},
+	})
+	return fork, nil
+}
+
+// runCompare sends the compared prompt to a fork and sums up the assistant
+// messages answering it.
+func (s *Service) runCompare(ctx context.Context, fork *types.Session, model types.ModelRef, input CompareInput) CompareRun {
+	run := CompareRun{
+		Model:     model,
+		SessionID: fork.ID,
+		Directory: fork.Directory,
+		Diffs:     []types.FileDiff{},
+	}
+
+	messageID := generateID()
+	start := time.Now()
+	err := s.sendPrompt(ctx, fork.ID, messageID, input.Agent, &model, input.Text, input.Files, func(*types.Message, []types.Part) {})
+	run.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	messages, err := s.GetMessages(ctx, fork.ID)
+	if err != nil {
+		if run.Error == "" {
+			run.Error = err.Error()
+		}
+		return run
+	}
+	answered := false
+	var last *types.Message
+	for _, msg := range messages {
+		if msg.ID == messageID {
+			answered = true
+			continue
+		}
+		if !answered || msg.Role != "assistant" {
+			continue
+		}
+		usage, err := s.messageUsage(ctx, msg)
+		if err != nil {
+			continue
+		}
+		run.Usage.add(usage)
+		last = msg
+	}
+
+	if last != nil {
+		run.MessageID = last.ID
+		if last.Error != nil && run.Error == "" {
+			run.Error = last.Error.Data.Message
+		}
+		parts, _ := s.GetParts(ctx, last.ID)
+		var text []string
+		for _, part := range parts {
+			if textPart, ok := part.(*types.TextPart); ok && textPart.Text != "" {
+				text = append(text, textPart.Text)
+			}
+		}
+		run.Text = strings.Join(text, "\n")
+	}
+
+	if diffs, err := s.GetDiffs(ctx, fork.ID); err == nil && diffs != nil {
+		run.Diffs = diffs
+	}
+	return run
+}
+
+// compareDirPrefix names the copies of working trees made for compared runs.
+const compareDirPrefix = "opencode-compare-"
+
+// copyWorkTree copies a working tree to a new temporary directory and returns
+// its path. In a git repository the files git would track are copied, so
+// ignored files such as dependencies and build output are left out;
+// elsewhere the whole tree is copied, without any .git directory.
+func copyWorkTree(src string) (string, error) {
+	dst, err := os.MkdirTemp("", compareDirPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+
+	if files, ok := gitFiles(src); ok {
+		for _, rel := range files {
+			info, err := os.Lstat(filepath.Join(src, rel))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // Deleted but not yet staged
+			}
+			if err == nil && !info.IsDir() {
+				if err = os.MkdirAll(filepath.Dir(filepath.Join(dst, rel)), 0755); err == nil {
+					err = copyEntry(filepath.Join(src, rel), filepath.Join(dst, rel), info.Mode())
+				}
+			}
+			if err != nil {
+				os.RemoveAll(dst)
+				return "", err
+			}
+		}
+		return dst, nil
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		return copyEntry(path, target, d.Type())
+	})
+	if err != nil {
+		os.RemoveAll(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+// gitFiles lists the files of a working tree that git tracks or would track,
+// relative to it. It reports false outside a git repository.
+func gitFiles(dir string) ([]string, bool) {
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+	var files []string
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, filepath.FromSlash(file))
+		}
+	}
+	return files, true
+}
+
+// copyEntry copies a regular file or a symlink. Sockets, pipes and devices
+// are left out.
+func copyEntry(path, target string, mode fs.FileMode) error {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	case mode.IsRegular():
+		return copyFile(path, target)
+	}
+	return nil
+}
+
+// removeWorkTreeCopy removes the copy of the working tree a compared run of
+// session worked on, if it did.
+func removeWorkTreeCopy(session *types.Session) {
+	dir := session.Directory
+	if session.ParentID == nil || !strings.HasPrefix(filepath.Base(dir), compareDirPrefix) ||
+		filepath.Dir(dir) != filepath.Clean(os.TempDir()) {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		logging.Warn().Err(err).Str("sessionID", session.ID).Msg("Failed to remove the copy of the working tree")
+	}
+}
+
+// copyFile copies a regular file, keeping its permissions.
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
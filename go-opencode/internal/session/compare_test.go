@@ -0,0 +1,145 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// echoProvider answers every completion with the name of the model.
+type echoProvider struct{}
+
+func (p *echoProvider) ID() string   { return "echo" }
+func (p *echoProvider) Name() string { return "Echo" }
+func (p *echoProvider) Models() []types.Model {
+	return []types.Model{
+		{ID: "small", ProviderID: "echo", Name: "Small"},
+		{ID: "large", ProviderID: "echo", Name: "Large"},
+	}
+}
+func (p *echoProvider) ChatModel() model.ToolCallingChatModel { return nil }
+
+func (p *echoProvider) CreateCompletion(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionStream, error) {
+	return provider.NewCompletionStream(schema.StreamReaderFromArray([]*schema.Message{{
+		Role:    schema.Assistant,
+		Content: "answer from " + req.Model,
+		ResponseMeta: &schema.ResponseMeta{
+			FinishReason: "stop",
+			Usage:        &schema.TokenUsage{PromptTokens: 10, CompletionTokens: len(req.Model)},
+		},
+	}})), nil
+}
+
+func TestService_Compare(t *testing.T) {
+	store := storage.New(t.TempDir())
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+
+	providerReg := provider.NewRegistry(nil)
+	providerReg.Register(&echoProvider{})
+	svc := NewServiceWithProcessor(store, providerReg, tool.NewRegistry(dir, store), nil, "echo", "small")
+	ctx := context.Background()
+
+	base, err := svc.Create(ctx, dir, "Bug hunt")
+	require.NoError(t, err)
+
+	result, err := svc.Compare(ctx, CompareInput{
+		SessionID: base.ID,
+		Text:      "Find the bug",
+		Models:    []string{"echo/large", "echo/small"},
+		Isolate:   true,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Runs, 2)
+
+	for i, modelID := range []string{"large", "small"} {
+		run := result.Runs[i]
+		defer os.RemoveAll(run.Directory)
+
+		assert.Equal(t, modelID, run.Model.ModelID)
+		assert.Empty(t, run.Error)
+		assert.Equal(t, "answer from "+modelID, run.Text)
+		assert.Equal(t, 10, run.Tokens.Input)
+		assert.Equal(t, len(modelID), run.Tokens.Output)
+		assert.NotEmpty(t, run.MessageID)
+		assert.Empty(t, run.Diffs)
+
+		// Each fork works on a copy of the tree, without .git
+		assert.NotEqual(t, dir, run.Directory)
+		assert.FileExists(t, filepath.Join(run.Directory, "main.go"))
+		assert.NoDirExists(t, filepath.Join(run.Directory, ".git"))
+
+		fork, err := svc.Get(ctx, run.SessionID)
+		require.NoError(t, err)
+		require.NotNil(t, fork.ParentID)
+		assert.Equal(t, base.ID, *fork.ParentID)
+		assert.Equal(t, "Bug hunt (echo/"+modelID+")", fork.Title)
+		assert.Equal(t, run.Directory, fork.Directory)
+	}
+	assert.NotEqual(t, result.Runs[0].Directory, result.Runs[1].Directory)
+
+	// The base session is left alone
+	messages, err := svc.GetMessages(ctx, base.ID)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestService_CompareInvalid(t *testing.T) {
+	svc, _ := newShellService(t, nil)
+	ctx := context.Background()
+
+	_, err := svc.Compare(ctx, CompareInput{SessionID: "ses1", Text: "hi"})
+	assert.ErrorIs(t, err, ErrNoCompareModels)
+
+	_, err = svc.Compare(ctx, CompareInput{SessionID: "ses1", Text: "hi", Models: []string{"gpt-4o"}})
+	assert.ErrorIs(t, err, ErrInvalidModel)
+}
+
+func TestService_CompareForksInGitRepo(t *testing.T) {
+	dir := gitRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("node_modules/\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "node_modules", "dep"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "node_modules", "dep", "index.js"), []byte("x"), 0644))
+
+	store := storage.New(t.TempDir())
+	providerReg := provider.NewRegistry(nil)
+	providerReg.Register(&echoProvider{})
+	svc := NewServiceWithProcessor(store, providerReg, tool.NewRegistry(dir, store), nil, "echo", "small")
+	ctx := context.Background()
+
+	base, err := svc.Create(ctx, dir, "Bug hunt")
+	require.NoError(t, err)
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: base.ID, Role: "user"},
+		&types.TextPart{ID: "prt1", SessionID: base.ID, MessageID: "msg1", Type: "text", Text: "Earlier"})
+
+	result, err := svc.Compare(ctx, CompareInput{SessionID: base.ID, Text: "Find the bug", Models: []string{"echo/small"}, Isolate: true})
+	require.NoError(t, err)
+	run := result.Runs[0]
+	require.Empty(t, run.Error)
+
+	// Tracked and untracked files are copied, ignored ones are not
+	assert.FileExists(t, filepath.Join(run.Directory, "main.go"))
+	assert.FileExists(t, filepath.Join(run.Directory, "new.go"))
+	assert.FileExists(t, filepath.Join(run.Directory, ".gitignore"))
+	assert.NoDirExists(t, filepath.Join(run.Directory, "node_modules"))
+
+	// Deleting the fork removes its copy and leaves the session whole
+	require.NoError(t, svc.Delete(ctx, run.SessionID))
+	assert.NoDirExists(t, run.Directory)
+	parts, err := svc.GetParts(ctx, "msg1")
+	require.NoError(t, err)
+	assert.Len(t, parts, 1)
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/opencode-ai/opencode/internal/tokenizer"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ContextUsage is how much of the context window of its model a session
+// fills.
+type ContextUsage struct {
+	ProviderID string `json:"providerID"`
+	ModelID    string `json:"modelID"`
+	Tokenizer  string `json:"tokenizer"` // Encoding counting the tokens, or "estimate"
+	Limit      int    `json:"limit"`     // Context window of the model
+	Used       int    `json:"used"`      // Reported + Counted
+	// Reported is the usage the provider reported for the last request since
+	// the last summary, Counted the tokens of the messages after it, or of
+	// every message since the summary if none was reported.
+	Reported int `json:"reported"`
+	Counted  int `json:"counted"`
+	// CompactAt is the number of tokens at which the session is compacted,
+	// 0 if it never is.
+	CompactAt int `json:"compactAt"`
+}
+
+// Context returns how full the context window of a session is, for the
+// model of its last user message.
+func (s *Service) Context(ctx context.Context, sessionID string) (*ContextUsage, error) {
+	if _, err := s.Get(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if s.processor == nil {
+		return nil, errors.New("sessions are not processed by this service")
+	}
+	return s.processor.contextUsage(ctx, sessionID)
+}
+
+// contextUsage measures the context window of a session. Usage reported by
+// the provider includes the system prompt and tools; the messages sent since
+// are counted with the tokenizer of the model.
+func (p *Processor) contextUsage(ctx context.Context, sessionID string) (*ContextUsage, error) {
+	messages, err := p.loadMessages(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &ContextUsage{ProviderID: p.defaultProviderID, ModelID: p.defaultModelID}
+	history := types.CompactedHistory(messages)
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" && history[i].Model != nil {
+			usage.ProviderID = history[i].Model.ProviderID
+			usage.ModelID = history[i].Model.ModelID
+			break
+		}
+	}
+
+	var model *types.Model
+	if p.providerRegistry != nil {
+		model, _ = p.providerRegistry.GetModel(usage.ProviderID, usage.ModelID)
+	}
+	if model == nil {
+		model = &types.Model{ID: usage.ModelID, ProviderID: usage.ProviderID}
+	}
+	usage.Limit = MaxContextTokens
+	if model.ContextLength > 0 {
+		usage.Limit = model.ContextLength
+	}
+	if p.compaction.ContextThreshold > 0 {
+		usage.CompactAt = int(float64(usage.Limit) * p.compaction.ContextThreshold)
+	}
+
+	tok := tokenizer.ForModel(model)
+	usage.Tokenizer = tok.Name()
+
+	// Only the messages after the last reported usage are counted
+	counted := history
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if msg.Role == "assistant" && !msg.IsSummary && msg.Tokens != nil && msg.Tokens.Input+msg.Tokens.Cache.Read > 0 {
+			usage.Reported = msg.Tokens.Input + msg.Tokens.Cache.Read + msg.Tokens.Cache.Write + msg.Tokens.Output
+			counted = history[i+1:]
+			break
+		}
+	}
+	for _, msg := range counted {
+		parts, err := p.loadParts(ctx, msg.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			usage.Counted += tok.Count(partText(part))
+		}
+	}
+	usage.Used = usage.Reported + usage.Counted
+	return usage, nil
+}
+
+// partText returns the text of a part sent to the model.
+func partText(part types.Part) string {
+	switch pt := part.(type) {
+	case *types.TextPart:
+		return pt.Text
+	case *types.ReasoningPart:
+		return pt.Text
+	case *types.ToolPart:
+		input, _ := json.Marshal(pt.State.Input)
+		output := pt.State.Output
+		if pt.State.Time != nil && pt.State.Time.Compacted != nil {
+			output = prunedToolOutput
+		}
+		return pt.Tool + string(input) + output + pt.State.Error
+	}
+	return ""
+}
@@ -141,7 +141,8 @@
 //
 // Robust error handling is implemented throughout:
 //
-//   - Exponential backoff for LLM API failures
+//   - Exponential backoff for LLM API failures, honouring Retry-After
+//   - Fallback models when a provider keeps failing (see Fallbacks)
 //   - Graceful degradation when tools fail
 //   - Context cancellation support
 //   - Detailed error propagation and logging
@@ -28,7 +28,8 @@ const (
 	RetryMaxInterval = 30 * time.Second
 	// RetryMaxElapsedTime is the maximum total time for retries.
 	RetryMaxElapsedTime = 2 * time.Minute
-	// MaxContextTokens is the threshold for triggering context compaction.
+	// MaxContextTokens is the context window assumed for models that do not
+	// report ContextLength when deciding whether to compact.
 	MaxContextTokens = 150000
 )
 
@@ -54,6 +55,9 @@ func (p *Processor) runLoop(
 	agent *Agent,
 	callback ProcessCallback,
 ) error {
+	// Completions are filed under the session when recorded
+	ctx = provider.WithSession(ctx, sessionID, provider.PurposeChat)
+
 	// Load session
 	var session types.Session
 	if err := p.storage.Get(ctx, []string{"session", sessionID}, &session); err != nil {
@@ -71,6 +75,12 @@ func (p *Processor) runLoop(
 		Data: event.SessionUpdatedData{Info: &session},
 	})
 
+	// Drop summaries older versions stored as a fake diff
+	if diffs := withoutLegacyCompaction(session.Summary.Diffs); len(diffs) != len(session.Summary.Diffs) {
+		session.Summary.Diffs = diffs
+		p.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, &session)
+	}
+
 	// Emit initial session.diff event (empty diffs at start)
 	event.PublishSync(event.Event{
 		Type: event.SessionDiff,
@@ -91,11 +101,36 @@ func (p *Processor) runLoop(
 	}
 
 	lastMsg := messages[len(messages)-1]
+
+	// When resuming, continue the interrupted assistant message and take
+	// model selection from the user message that prompted it.
+	var resumeMsg *types.Message
+	if state.resume {
+		if !isResumable(lastMsg) {
+			return ErrNothingToResume
+		}
+		resumeMsg = lastMsg
+		lastMsg = nil
+		for _, msg := range messages {
+			if msg.ID == resumeMsg.ParentID {
+				lastMsg = msg
+				break
+			}
+		}
+		if lastMsg == nil {
+			return fmt.Errorf("parent message not found: %s", resumeMsg.ParentID)
+		}
+	}
+
 	if lastMsg.Role != "user" {
 		return fmt.Errorf("expected user message, got %s", lastMsg.Role)
 	}
 	// Load user message parts
 	userParts, _ := p.loadParts(ctx, lastMsg.ID)
+	if resumeMsg != nil && isUserToolRun(userParts) {
+		// A shell command or subtask run for the user; there is no model turn to continue
+		return ErrNothingToResume
+	}
 	var compactionPart *types.CompactionPart
 	for _, part := range userParts {
 		if pt, ok := part.(*types.CompactionPart); ok {
@@ -128,30 +163,44 @@ func (p *Processor) runLoop(
 		return fmt.Errorf("model not found: %w", err)
 	}
 
-	// Get agent config
+	// Get agent config, that of the agent the user picked unless given
 	if agent == nil {
-		agent = DefaultAgent()
-	}
-
-	// Create assistant message
-	// IMPORTANT: Always include Tokens field (even with zeroes) because TUI expects it
-	now := time.Now().UnixMilli()
-	assistantMsg := &types.Message{
-		ID:         generatePartID(),
-		SessionID:  sessionID,
-		Role:       "assistant",
-		ParentID:   lastMsg.ID, // Link to the user message that prompted this
-		ProviderID: providerID,
-		ModelID:    modelID,
-		Mode:       agent.Name, // Agent name (e.g., "Coder", "Build") - required by TUI
-		Path: &types.MessagePath{
-			Cwd:  session.Directory, // Current working directory from session
-			Root: session.Directory, // Root directory (same as cwd for now)
-		},
-		Time: types.MessageTime{
-			Created: now,
-		},
-		Tokens: &types.TokenUsage{Input: 0, Output: 0},
+		agent = p.resolveAgent(lastMsg.Agent)
+	}
+
+	// Compact first if the previous request already filled the context window
+	if resumeMsg == nil && p.compaction.isOverflow(lastUsage(messages), model) {
+		return p.compactAndContinue(ctx, sessionID, state, lastMsg, agent, callback)
+	}
+
+	var assistantMsg *types.Message
+	if resumeMsg != nil {
+		// Keep the parts of the steps that were committed before the crash;
+		// the loop continues from there.
+		assistantMsg = resumeMsg
+		assistantMsg.Error = nil
+		state.parts, _ = p.loadParts(ctx, assistantMsg.ID)
+	} else {
+		// Create assistant message
+		// IMPORTANT: Always include Tokens field (even with zeroes) because TUI expects it
+		now := time.Now().UnixMilli()
+		assistantMsg = &types.Message{
+			ID:         generatePartID(),
+			SessionID:  sessionID,
+			Role:       "assistant",
+			ParentID:   lastMsg.ID, // Link to the user message that prompted this
+			ProviderID: providerID,
+			ModelID:    modelID,
+			Mode:       agent.Name, // Agent name (e.g., "Coder", "Build") - required by TUI
+			Path: &types.MessagePath{
+				Cwd:  session.Directory, // Current working directory from session
+				Root: session.Directory, // Root directory (same as cwd for now)
+			},
+			Time: types.MessageTime{
+				Created: now,
+			},
+			Tokens: &types.TokenUsage{Input: 0, Output: 0},
+		}
 	}
 	state.message = assistantMsg
 
@@ -161,13 +210,26 @@ func (p *Processor) runLoop(
 	}
 
 	// Notify callback
-	callback(assistantMsg, nil)
+	callback(assistantMsg, state.parts)
 
 	// Publish event (SDK compatible: uses "info" field)
-	event.PublishSync(event.Event{
-		Type: event.MessageCreated,
-		Data: event.MessageCreatedData{Info: assistantMsg},
-	})
+	if resumeMsg != nil {
+		event.PublishSync(event.Event{
+			Type: event.MessageUpdated,
+			Data: event.MessageUpdatedData{Info: assistantMsg},
+		})
+	} else {
+		event.PublishSync(event.Event{
+			Type: event.MessageCreated,
+			Data: event.MessageCreatedData{Info: assistantMsg},
+		})
+	}
+
+	// Budgets follow the agent the user picked for the message
+	budgetAgent := lastMsg.Agent
+	if budgetAgent == "" {
+		budgetAgent = agent.Name
+	}
 
 	maxSteps := agent.MaxSteps
 	if maxSteps <= 0 {
@@ -176,7 +238,25 @@ func (p *Processor) runLoop(
 
 	// Run loop
 	step := 0
-	retryBackoff := newRetryBackoff(ctx)
+	retries := newRetrier(ctx, p.fallbacks.chain(providerID, modelID))
+
+	// recoverRequest retries a failed request, possibly on a fallback model,
+	// and records the error on the message when the loop has to give up
+	recoverRequest := func(err error) error {
+		next, err := p.recoverRequest(ctx, retries, assistantMsg, loopModel{provider: prov, model: model}, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				assistantMsg.Error = types.NewUnknownError("Processing aborted")
+			} else {
+				assistantMsg.Error = requestError(providerID, err)
+			}
+			p.saveMessage(ctx, sessionID, assistantMsg)
+			return err
+		}
+		prov, model = next.provider, next.model
+		providerID, modelID = model.ProviderID, model.ID
+		return nil
+	}
 
 	// Get user content from first message for title generation
 	userContent := ""
@@ -204,13 +284,14 @@ func (p *Processor) runLoop(
 			return fmt.Errorf("max steps exceeded")
 		}
 
-		// Check for context overflow and compact if needed
-		if p.shouldCompact(messages) {
-			if err := p.compactMessages(ctx, sessionID, messages); err != nil {
-				// Log but don't fail
-			}
-			// Reload messages
-			messages, _ = p.loadMessages(ctx, sessionID)
+		// Compact when the last step filled too much of the context window.
+		// Its tool results are committed, so the message ends here and the
+		// loop continues from the summary.
+		if step > 0 && p.compaction.isOverflow(assistantMsg.Tokens, model) {
+			finish := "tool-calls"
+			assistantMsg.Finish = &finish
+			p.saveMessage(ctx, sessionID, assistantMsg)
+			return p.compactAndContinue(ctx, sessionID, state, lastMsg, agent, callback)
 		}
 
 		// Reload messages to include the current assistant message and tool results
@@ -219,6 +300,15 @@ func (p *Processor) runLoop(
 			return fmt.Errorf("failed to reload messages: %w", err)
 		}
 
+		// Stop before the step once the session has spent its budget
+		exceeded, err := p.checkBudget(ctx, &session, budgetAgent, messages, state.started)
+		if err != nil {
+			return fmt.Errorf("failed to check budget: %w", err)
+		}
+		if exceeded != nil {
+			return p.stopForBudget(ctx, assistantMsg, exceeded)
+		}
+
 		// Build completion request
 		req, err := p.buildCompletionRequest(ctx, sessionID, messages, assistantMsg, agent, model)
 		if err != nil {
@@ -253,19 +343,14 @@ func (p *Processor) runLoop(
 		// Call LLM with streaming
 		stream, err := prov.CreateCompletion(ctx, req)
 		if err != nil {
-			// Use exponential backoff with jitter for retries
-			nextInterval := retryBackoff.NextBackOff()
-			if nextInterval == backoff.Stop {
-				assistantMsg.Error = types.NewUnknownError(err.Error())
-				p.saveMessage(ctx, sessionID, assistantMsg)
+			if err := recoverRequest(err); err != nil {
 				return err
 			}
-			time.Sleep(nextInterval)
 			continue
 		}
 
 		// Process stream
-		finishReason, err := p.processStream(ctx, stream, state, callback)
+		finishReason, err := p.processStream(ctx, stream, state, model, callback)
 		stream.Close()
 
 		requestDuration := time.Since(requestStart)
@@ -277,6 +362,8 @@ func (p *Processor) runLoop(
 				Str("finishReason", finishReason).
 				Int("inputTokens", state.message.Tokens.Input).
 				Int("outputTokens", state.message.Tokens.Output).
+				Int("cacheReadTokens", state.message.Tokens.Cache.Read).
+				Float64("cost", state.message.Cost).
 				Dur("duration", requestDuration).
 				Msg("LLM response received")
 		} else {
@@ -311,19 +398,16 @@ func (p *Processor) runLoop(
 		}
 
 		if err != nil {
-			// Use exponential backoff with jitter for retries
-			nextInterval := retryBackoff.NextBackOff()
-			if nextInterval == backoff.Stop {
-				assistantMsg.Error = types.NewUnknownError(err.Error())
-				p.saveMessage(ctx, sessionID, assistantMsg)
+			if err := recoverRequest(err); err != nil {
 				return err
 			}
-			time.Sleep(nextInterval)
 			continue
 		}
 
-		// Reset backoff on success
-		retryBackoff.Reset()
+		// Reset retries on success
+		if retries.succeeded() {
+			publishStatus(sessionID, event.SessionStatusInfo{Type: "busy"})
+		}
 
 		// Ensure tokens field is always populated (TUI expects it)
 		if state.message.Tokens == nil {
@@ -342,6 +426,9 @@ func (p *Processor) runLoop(
 			finish := "stop"
 			assistantMsg.Finish = &finish
 			p.saveMessage(ctx, sessionID, assistantMsg)
+			if err := p.pruneToolOutputs(ctx, sessionID, model); err != nil {
+				logging.Warn().Err(err).Str("sessionID", sessionID).Msg("Failed to prune tool outputs")
+			}
 			return nil
 
 		case "tool_use", "tool_calls", "tool-calls":
@@ -352,6 +439,15 @@ func (p *Processor) runLoop(
 				// The error is captured in the tool part
 			}
 			step++
+
+			// Steer messages are added at the step boundary: this message
+			// ends with its tool results and the loop continues from them
+			if p.hasSteer(sessionID) {
+				finish := "tool-calls"
+				assistantMsg.Finish = &finish
+				p.saveMessage(ctx, sessionID, assistantMsg)
+				return nil
+			}
 			continue
 
 		case "max_tokens", "length":
@@ -363,12 +459,9 @@ func (p *Processor) runLoop(
 			return nil
 
 		case "error":
-			// Use exponential backoff with jitter for retries
-			nextInterval := retryBackoff.NextBackOff()
-			if nextInterval == backoff.Stop {
-				return fmt.Errorf("stream error: max retries exceeded")
+			if err := recoverRequest(fmt.Errorf("stream error")); err != nil {
+				return err
 			}
-			time.Sleep(nextInterval)
 			continue
 
 		default:
@@ -434,17 +527,6 @@ func (p *Processor) savePart(ctx context.Context, messageID string, part types.P
 	return p.storage.Put(ctx, []string{"part", messageID, part.PartID()}, part)
 }
 
-// shouldCompact checks if messages should be compacted.
-func (p *Processor) shouldCompact(messages []*types.Message) bool {
-	totalTokens := 0
-	for _, msg := range messages {
-		if msg.Tokens != nil {
-			totalTokens += msg.Tokens.Input + msg.Tokens.Output
-		}
-	}
-	return totalTokens > MaxContextTokens
-}
-
 // buildCompletionRequest builds an LLM completion request.
 func (p *Processor) buildCompletionRequest(
 	ctx context.Context,
@@ -467,8 +549,8 @@ func (p *Processor) buildCompletionRequest(
 		Content: systemPrompt.Build(),
 	})
 
-	// Add conversation history
-	for _, msg := range messages {
+	// Add conversation history, starting from the latest summary
+	for _, msg := range types.CompactedHistory(messages) {
 		// Skip errored messages without content
 		if msg.Error != nil && !p.hasUsableContent(ctx, msg) {
 			continue
@@ -485,11 +567,11 @@ func (p *Processor) buildCompletionRequest(
 			continue
 		}
 
-		einoMsg := p.convertMessage(msg, parts)
+		einoMsg := p.convertMessage(msg, parts, model)
 
 		// Skip messages that have no content and no tool calls
 		// (e.g., messages with only StepStartPart, StepFinishPart, etc.)
-		if einoMsg.Content == "" && len(einoMsg.ToolCalls) == 0 && einoMsg.ToolCallID == "" && einoMsg.ReasoningContent == "" {
+		if einoMsg.Content == "" && len(einoMsg.UserInputMultiContent) == 0 && len(einoMsg.ToolCalls) == 0 && einoMsg.ToolCallID == "" && einoMsg.ReasoningContent == "" {
 			continue
 		}
 
@@ -497,12 +579,15 @@ func (p *Processor) buildCompletionRequest(
 
 		// For assistant messages with tool parts, add separate tool result messages
 		if msg.Role == "assistant" {
+			var attached []*types.ToolPart
 			for _, part := range parts {
 				if toolPart, ok := part.(*types.ToolPart); ok {
 					// Only completed or errored tool parts should be added as results
 					if toolPart.State.Status == "completed" || toolPart.State.Status == "error" {
 						var toolContent string
-						if toolPart.State.Output != "" {
+						if toolPart.State.Time != nil && toolPart.State.Time.Compacted != nil {
+							toolContent = prunedToolOutput
+						} else if toolPart.State.Output != "" {
 							toolContent = toolPart.State.Output
 						} else if toolPart.State.Error != "" {
 							toolContent = "Error: " + toolPart.State.Error
@@ -517,9 +602,18 @@ func (p *Processor) buildCompletionRequest(
 							ToolCallID: toolPart.CallID,
 						}
 						einoMessages = append(einoMessages, toolMsg)
+
+						if toolPart.State.Status == "completed" && toolContent != prunedToolOutput {
+							attached = append(attached, toolPart)
+						}
 					}
 				}
 			}
+
+			// Files returned by the tools follow their results
+			if attachments := provider.AttachmentsMessage(attached, model); attachments != nil {
+				einoMessages = append(einoMessages, attachments)
+			}
 		}
 	}
 
@@ -570,8 +664,8 @@ func (p *Processor) hasUsableContent(ctx context.Context, msg *types.Message) bo
 	return len(parts) > 0
 }
 
-// convertMessage converts a types.Message to schema.Message.
-func (p *Processor) convertMessage(msg *types.Message, parts []types.Part) *schema.Message {
+// convertMessage converts a types.Message to schema.Message for model.
+func (p *Processor) convertMessage(msg *types.Message, parts []types.Part, model *types.Model) *schema.Message {
 	role := schema.Assistant
 	switch msg.Role {
 	case "user":
@@ -588,12 +682,19 @@ func (p *Processor) convertMessage(msg *types.Message, parts []types.Part) *sche
 	var toolCallID string
 
 	var reasoningContent string
+	var files []*types.FilePart
 	for _, part := range parts {
 		switch pt := part.(type) {
 		case *types.TextPart:
 			content += pt.Text
+		case *types.FilePart:
+			files = append(files, pt)
 		case *types.ReasoningPart:
 			reasoningContent += pt.Text
+		case *types.CompactionPart:
+			content += types.CompactionRequestText
+		case *types.SubtaskPart:
+			content += userToolText
 		case *types.ToolPart:
 			if msg.Role == "assistant" {
 				// For assistant messages, include all tool calls (even completed ones)
@@ -629,6 +730,9 @@ func (p *Processor) convertMessage(msg *types.Message, parts []types.Part) *sche
 	if toolCallID != "" {
 		einoMsg.ToolCallID = toolCallID
 	}
+	if role == schema.User && len(files) > 0 {
+		einoMsg = provider.UserMessage(content, files, model)
+	}
 
 	return einoMsg
 }
@@ -648,62 +752,16 @@ func (p *Processor) resolveTools(agent *Agent, model *types.Model) ([]*schema.To
 			continue
 		}
 
-		params := parseJSONSchemaToParams(t.Parameters())
 		result = append(result, &schema.ToolInfo{
 			Name:        t.ID(),
 			Desc:        t.Description(),
-			ParamsOneOf: schema.NewParamsOneOfByParams(params),
+			ParamsOneOf: provider.ToolParams(t.Parameters()),
 		})
 	}
 
 	return result, nil
 }
 
-// parseJSONSchemaToParams converts JSON Schema to Eino ParameterInfo.
-func parseJSONSchemaToParams(schemaJSON json.RawMessage) map[string]*schema.ParameterInfo {
-	var jsonSchema struct {
-		Properties map[string]struct {
-			Type        string `json:"type"`
-			Description string `json:"description"`
-		} `json:"properties"`
-		Required []string `json:"required"`
-	}
-
-	if err := json.Unmarshal(schemaJSON, &jsonSchema); err != nil {
-		return nil
-	}
-
-	requiredSet := make(map[string]bool)
-	for _, r := range jsonSchema.Required {
-		requiredSet[r] = true
-	}
-
-	params := make(map[string]*schema.ParameterInfo)
-	for name, prop := range jsonSchema.Properties {
-		paramType := schema.String
-		switch prop.Type {
-		case "integer":
-			paramType = schema.Integer
-		case "number":
-			paramType = schema.Number
-		case "boolean":
-			paramType = schema.Boolean
-		case "array":
-			paramType = schema.Array
-		case "object":
-			paramType = schema.Object
-		}
-
-		params[name] = &schema.ParameterInfo{
-			Type:     paramType,
-			Desc:     prop.Description,
-			Required: requiredSet[name],
-		}
-	}
-
-	return params
-}
-
 // generatePartID generates a new ULID for parts.
 func generatePartID() string {
 	return ulid.Make().String()
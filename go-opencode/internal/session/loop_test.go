@@ -6,7 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cloudwego/eino/schema"
 	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/opencode-ai/opencode/internal/permission"
 	"github.com/opencode-ai/opencode/internal/provider"
@@ -130,3 +133,51 @@ func TestAgenticLoopWithRealLLM(t *testing.T) {
 
 	t.Logf("Test passed! Received %d parts", len(receivedParts))
 }
+
+func TestBuildCompletionRequest_Attachments(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &types.Session{ID: "ses1", ProjectID: "proj1"}))
+
+	png := "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+	stop := "tool-calls"
+	read := toolOutput("msg2", "prt3", "(Image file)")
+	read.State.Attachments = []types.FilePart{{Type: "file", Mime: "image/png", Filename: "chart.png", URL: png}}
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"},
+		&types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "what does this show?"},
+		&types.FilePart{ID: "prt2", SessionID: "ses1", MessageID: "msg1", Type: "file", Mime: "image/png", Filename: "screen.png", URL: png})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1", Finish: &stop}, read)
+
+	proc := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	messages, err := proc.loadMessages(ctx, "ses1")
+	require.NoError(t, err)
+
+	t.Run("vision model", func(t *testing.T) {
+		req, err := proc.buildCompletionRequest(ctx, "ses1", messages, messages[len(messages)-1], DefaultAgent(), &types.Model{ID: "test", SupportsVision: true})
+		require.NoError(t, err)
+		require.Len(t, req.Messages, 5) // system, user, assistant, tool, attachments
+
+		user := req.Messages[1]
+		require.Len(t, user.UserInputMultiContent, 2)
+		assert.Equal(t, "what does this show?", user.UserInputMultiContent[0].Text)
+		assert.Equal(t, schema.ChatMessagePartTypeImageURL, user.UserInputMultiContent[1].Type)
+
+		assert.Equal(t, "(Image file)", req.Messages[3].Content)
+		attachments := req.Messages[4]
+		assert.Equal(t, schema.User, attachments.Role)
+		require.Len(t, attachments.UserInputMultiContent, 2)
+		assert.Equal(t, schema.ChatMessagePartTypeImageURL, attachments.UserInputMultiContent[1].Type)
+	})
+
+	t.Run("text model", func(t *testing.T) {
+		req, err := proc.buildCompletionRequest(ctx, "ses1", messages, messages[len(messages)-1], DefaultAgent(), &types.Model{ID: "test", ProviderID: "local"})
+		require.NoError(t, err)
+		require.Len(t, req.Messages, 5)
+
+		user := req.Messages[1]
+		assert.Empty(t, user.UserInputMultiContent)
+		assert.Contains(t, user.Content, "what does this show?")
+		assert.Contains(t, user.Content, "[File screen.png is attached but was not sent: local/test does not support images]")
+		assert.Contains(t, req.Messages[4].Content, "chart.png")
+	})
+}
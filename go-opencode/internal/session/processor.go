@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/opencode-ai/opencode/internal/agent"
 	"github.com/opencode-ai/opencode/internal/event"
 	"github.com/opencode-ai/opencode/internal/permission"
 	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/snapshot"
 	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/internal/tool"
 	"github.com/opencode-ai/opencode/pkg/types"
@@ -19,26 +22,46 @@ type Processor struct {
 
 	providerRegistry  *provider.Registry
 	toolRegistry      *tool.Registry
-	storage           *storage.Storage
+	storage           storage.Storage
 	permissionChecker *permission.Checker
 
 	// Default provider and model to use when not specified
 	defaultProviderID string
 	defaultModelID    string
 
+	// When to summarize the session and prune old tool output
+	compaction CompactionConfig
+
+	// File snapshots taken at every step start, nil when disabled
+	snapshots *snapshot.Store
+
+	// Spending limits checked before every step
+	budgets Budgets
+
+	// Models to switch to when a model's provider keeps failing
+	fallbacks Fallbacks
+
+	// Agents named by messages and commands, nil for the built-in ones
+	agents *agent.Registry
+
 	// Active sessions being processed
 	sessions map[string]*sessionState
+
+	// Messages sent to busy sessions, by session ID
+	queues map[string][]*queuedInput
 }
 
 // sessionState tracks the state of an active session being processed.
 type sessionState struct {
-	ctx      context.Context
-	cancel   context.CancelFunc
-	message  *types.Message
-	parts    []types.Part
-	waiters  []chan error
-	step     int
-	retries  int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	message *types.Message
+	parts   []types.Part
+	waiters []chan error
+	step    int
+	retries int
+	resume  bool      // Continue an interrupted assistant message instead of starting a new one
+	started time.Time // When the current run began, for time budgets
 }
 
 // ProcessCallback is called with message updates during processing.
@@ -48,7 +71,7 @@ type ProcessCallback func(msg *types.Message, parts []types.Part)
 func NewProcessor(
 	providerReg *provider.Registry,
 	toolReg *tool.Registry,
-	store *storage.Storage,
+	store storage.Storage,
 	permChecker *permission.Checker,
 	defaultProviderID string,
 	defaultModelID string,
@@ -67,13 +90,28 @@ func NewProcessor(
 		permissionChecker: permChecker,
 		defaultProviderID: defaultProviderID,
 		defaultModelID:    defaultModelID,
+		compaction:        DefaultCompactionConfig,
 		sessions:          make(map[string]*sessionState),
+		queues:            make(map[string][]*queuedInput),
 	}
 }
 
 // Process handles a new user message and generates an assistant response.
 // This is the main entry point for the agentic loop.
 func (p *Processor) Process(ctx context.Context, sessionID string, agent *Agent, callback ProcessCallback) error {
+	return p.process(ctx, sessionID, agent, callback, false)
+}
+
+// Resume continues the agentic loop for an assistant message that was
+// aborted by crash recovery. Parts from its committed steps are kept and the
+// loop picks up from there. Returns ErrNothingToResume if the session's last
+// message is not such a message.
+func (p *Processor) Resume(ctx context.Context, sessionID string, agent *Agent, callback ProcessCallback) error {
+	return p.process(ctx, sessionID, agent, callback, true)
+}
+
+// process runs the agentic loop, queueing behind any run already active for the session.
+func (p *Processor) process(ctx context.Context, sessionID string, agent *Agent, callback ProcessCallback, resume bool) error {
 	p.mu.Lock()
 
 	// Check if session is already processing
@@ -90,7 +128,7 @@ func (p *Processor) Process(ctx context.Context, sessionID string, agent *Agent,
 				return err
 			}
 			// Retry processing
-			return p.Process(ctx, sessionID, agent, callback)
+			return p.process(ctx, sessionID, agent, callback, resume)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
@@ -99,8 +137,10 @@ func (p *Processor) Process(ctx context.Context, sessionID string, agent *Agent,
 	// Create new session state
 	loopCtx, cancel := context.WithCancel(ctx)
 	state := &sessionState{
-		ctx:    loopCtx,
-		cancel: cancel,
+		ctx:     loopCtx,
+		cancel:  cancel,
+		resume:  resume,
+		started: time.Now(),
 	}
 	p.sessions[sessionID] = state
 	p.mu.Unlock()
@@ -114,35 +154,18 @@ func (p *Processor) Process(ctx context.Context, sessionID string, agent *Agent,
 		},
 	})
 
-	// Ensure cleanup
+	// Ensure cleanup. Messages queued meanwhile are answered in the
+	// background once the caller has its response.
+	var err error
 	defer func() {
-		p.mu.Lock()
-		delete(p.sessions, sessionID)
-
-		// Notify waiters
-		for _, waiter := range state.waiters {
-			waiter <- nil
+		if batch := p.endRun(sessionID, err); batch != nil {
+			go p.runQueued(loopCtx, sessionID, state, batch)
 		}
-		p.mu.Unlock()
-
-		// Emit session.status idle event (SDK compatible: TUI uses this to stop progress bar)
-		event.PublishSync(event.Event{
-			Type: event.SessionStatus,
-			Data: event.SessionStatusData{
-				SessionID: sessionID,
-				Status:    event.SessionStatusInfo{Type: "idle"},
-			},
-		})
-
-		// Emit session.idle event when processing completes
-		event.PublishSync(event.Event{
-			Type: event.SessionIdle,
-			Data: event.SessionIdleData{SessionID: sessionID},
-		})
 	}()
 
 	// Run the agentic loop
-	return p.runLoop(loopCtx, sessionID, state, agent, callback)
+	err = p.runLoop(loopCtx, sessionID, state, agent, callback)
+	return err
 }
 
 // Abort cancels processing for a session.
@@ -189,9 +189,9 @@ func TestPlanAgent(t *testing.T) {
 	assert.Equal(t, "plan", agent.Name)
 	assert.Equal(t, 0.5, agent.Temperature)
 	assert.Equal(t, 20, agent.MaxSteps)
-	assert.Contains(t, agent.DisabledTools, "Write")
-	assert.Contains(t, agent.DisabledTools, "Edit")
-	assert.Contains(t, agent.DisabledTools, "Bash")
+	assert.Contains(t, agent.DisabledTools, "write")
+	assert.Contains(t, agent.DisabledTools, "edit")
+	assert.Contains(t, agent.DisabledTools, "bash")
 	assert.Equal(t, "deny", agent.Permission.Write)
 }
 
@@ -246,23 +246,6 @@ func TestCompactionConfig(t *testing.T) {
 	assert.Equal(t, 0.75, config.ContextThreshold)
 }
 
-func TestEstimateTokens(t *testing.T) {
-	tests := []struct {
-		text     string
-		expected int
-	}{
-		{"", 0},
-		{"Hello", 1},
-		{"Hello World", 2},
-		{"This is a test message with some words", 9},
-	}
-
-	for _, tt := range tests {
-		result := estimateTokens(tt.text)
-		assert.Equal(t, tt.expected, result, "text: %s", tt.text)
-	}
-}
-
 func TestGeneratePartID(t *testing.T) {
 	id1 := generatePartID()
 	id2 := generatePartID()
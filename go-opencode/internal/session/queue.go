@@ -0,0 +1,344 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ErrNotBusy is returned when queueing a message for a session that is not
+// being processed. The message can be sent directly instead.
+var ErrNotBusy = errors.New("session is not busy")
+
+// ErrQueueCancelled is returned to the sender of a queued message that was
+// cancelled, or dropped because the loop before it failed.
+var ErrQueueCancelled = errors.New("queued message cancelled")
+
+// queuedInput is a queued message with the sender waiting for its answer.
+type queuedInput struct {
+	msg      *types.QueuedMessage
+	agent    *Agent
+	callback ProcessCallback
+	done     chan error
+}
+
+// Enqueue adds a message to the queue of a session being processed. Steer
+// messages end the running loop at its next step boundary; queued messages
+// run after it. The returned channel receives the result of the loop that
+// answers the message. Returns ErrNotBusy if the session is idle.
+func (p *Processor) Enqueue(msg *types.QueuedMessage, agent *Agent, callback ProcessCallback) (<-chan error, error) {
+	if msg.ID == "" {
+		msg.ID = generateID()
+	}
+	if msg.Delivery == "" {
+		msg.Delivery = types.DeliveryQueue
+	}
+	if msg.Time == 0 {
+		msg.Time = time.Now().UnixMilli()
+	}
+
+	p.mu.Lock()
+	if _, ok := p.sessions[msg.SessionID]; !ok {
+		p.mu.Unlock()
+		return nil, ErrNotBusy
+	}
+	in := &queuedInput{msg: msg, agent: agent, callback: callback, done: make(chan error, 1)}
+	p.queues[msg.SessionID] = append(p.queues[msg.SessionID], in)
+	queue := p.queueLocked(msg.SessionID)
+	p.mu.Unlock()
+
+	publishQueue(msg.SessionID, queue)
+	return in.done, nil
+}
+
+// Queue returns the messages waiting for a session, oldest first.
+func (p *Processor) Queue(sessionID string) []*types.QueuedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queueLocked(sessionID)
+}
+
+// CancelQueued removes a message from the queue of a session, or every
+// message if id is empty. It returns the number of messages removed; messages
+// already delivered cannot be cancelled.
+func (p *Processor) CancelQueued(sessionID, id string) int {
+	p.mu.Lock()
+	var cancelled, kept []*queuedInput
+	for _, in := range p.queues[sessionID] {
+		if id == "" || in.msg.ID == id {
+			cancelled = append(cancelled, in)
+		} else {
+			kept = append(kept, in)
+		}
+	}
+	if len(cancelled) == 0 {
+		p.mu.Unlock()
+		return 0
+	}
+	p.setQueueLocked(sessionID, kept)
+	queue := p.queueLocked(sessionID)
+	p.mu.Unlock()
+
+	for _, in := range cancelled {
+		in.done <- ErrQueueCancelled
+	}
+	publishQueue(sessionID, queue)
+	return len(cancelled)
+}
+
+// hasSteer reports whether a steer message is waiting for a session.
+func (p *Processor) hasSteer(sessionID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, in := range p.queues[sessionID] {
+		if in.msg.Delivery == types.DeliverySteer {
+			return true
+		}
+	}
+	return false
+}
+
+// endRun is called when a loop of a session ends. If messages were queued
+// meanwhile, the next ones are taken off the queue and returned, and the
+// session stays busy; otherwise it becomes idle. A failed or aborted loop
+// cancels the queue.
+func (p *Processor) endRun(sessionID string, err error) []*queuedInput {
+	p.mu.Lock()
+	var batch, cancelled []*queuedInput
+	if err != nil {
+		cancelled = p.queues[sessionID]
+		p.setQueueLocked(sessionID, nil)
+	} else {
+		batch = p.takeQueuedLocked(sessionID)
+	}
+
+	var waiters []chan error
+	if batch == nil {
+		if state, ok := p.sessions[sessionID]; ok {
+			waiters = state.waiters
+		}
+		delete(p.sessions, sessionID)
+	}
+	queue := p.queueLocked(sessionID)
+	p.mu.Unlock()
+
+	for _, in := range cancelled {
+		in.done <- ErrQueueCancelled
+	}
+	if len(batch) > 0 || len(cancelled) > 0 {
+		publishQueue(sessionID, queue)
+	}
+	if batch != nil {
+		return batch
+	}
+
+	// Notify waiters
+	for _, waiter := range waiters {
+		waiter <- nil
+	}
+
+	// Emit session.status idle event (SDK compatible: TUI uses this to stop progress bar)
+	event.PublishSync(event.Event{
+		Type: event.SessionStatus,
+		Data: event.SessionStatusData{
+			SessionID: sessionID,
+			Status:    event.SessionStatusInfo{Type: "idle"},
+		},
+	})
+
+	// Emit session.idle event when processing completes
+	event.PublishSync(event.Event{
+		Type: event.SessionIdle,
+		Data: event.SessionIdleData{SessionID: sessionID},
+	})
+	return nil
+}
+
+// runQueued answers queued messages until the queue of a session is empty.
+// Each batch is stored as user messages and answered by one loop.
+func (p *Processor) runQueued(ctx context.Context, sessionID string, state *sessionState, batch []*queuedInput) {
+	for batch != nil {
+		err := p.deliverQueued(ctx, sessionID, batch)
+		if err == nil {
+			last := batch[len(batch)-1]
+			state.message = nil
+			state.parts = nil
+			state.step = 0
+			state.retries = 0
+			state.resume = false
+			state.started = time.Now()
+			err = p.runLoop(ctx, sessionID, state, last.agent, func(msg *types.Message, parts []types.Part) {
+				for _, in := range batch {
+					if in.callback != nil {
+						in.callback(msg, parts)
+					}
+				}
+			})
+		}
+
+		for _, in := range batch {
+			in.done <- err
+		}
+		batch = p.endRun(sessionID, err)
+	}
+}
+
+// deliverQueued stores queued messages as user messages of the session.
+// IDs are generated now, so the messages follow the answer they waited for.
+func (p *Processor) deliverQueued(ctx context.Context, sessionID string, batch []*queuedInput) error {
+	for _, in := range batch {
+		q := in.msg
+		msg := &types.Message{
+			ID:        generatePartID(),
+			SessionID: sessionID,
+			Role:      "user",
+			Agent:     q.Agent,
+			Model:     q.Model,
+			Tools:     q.Tools,
+			Summary: &types.UserMessageSummary{
+				Diffs: []types.FileDiff{},
+			},
+			Time: types.MessageTime{
+				Created: time.Now().UnixMilli(),
+			},
+		}
+		if err := p.storage.Put(ctx, []string{"message", sessionID, msg.ID}, msg); err != nil {
+			return err
+		}
+
+		parts := []types.Part{&types.TextPart{
+			ID:        generatePartID(),
+			SessionID: sessionID,
+			MessageID: msg.ID,
+			Type:      "text",
+			Text:      q.Text,
+		}}
+		for i := range q.Files {
+			file := q.Files[i]
+			file.ID = generatePartID()
+			file.SessionID = sessionID
+			file.MessageID = msg.ID
+			file.Type = "file"
+			parts = append(parts, &file)
+		}
+		for _, part := range parts {
+			if err := p.savePart(ctx, msg.ID, part); err != nil {
+				return err
+			}
+		}
+
+		event.PublishSync(event.Event{
+			Type: event.MessageUpdated,
+			Data: event.MessageUpdatedData{Info: msg},
+		})
+		for _, part := range parts {
+			event.PublishSync(event.Event{
+				Type: event.MessagePartUpdated,
+				Data: event.MessagePartUpdatedData{Part: part},
+			})
+		}
+	}
+	return nil
+}
+
+// takeQueuedLocked removes the messages to deliver next from the queue of a
+// session: every steer message, or else the oldest queued one.
+func (p *Processor) takeQueuedLocked(sessionID string) []*queuedInput {
+	queue := p.queues[sessionID]
+	if len(queue) == 0 {
+		return nil
+	}
+
+	var steer, rest []*queuedInput
+	for _, in := range queue {
+		if in.msg.Delivery == types.DeliverySteer {
+			steer = append(steer, in)
+		} else {
+			rest = append(rest, in)
+		}
+	}
+	if len(steer) > 0 {
+		p.setQueueLocked(sessionID, rest)
+		return steer
+	}
+	p.setQueueLocked(sessionID, queue[1:])
+	return queue[:1]
+}
+
+func (p *Processor) setQueueLocked(sessionID string, queue []*queuedInput) {
+	if len(queue) == 0 {
+		delete(p.queues, sessionID)
+		return
+	}
+	p.queues[sessionID] = queue
+}
+
+func (p *Processor) queueLocked(sessionID string) []*types.QueuedMessage {
+	queue := make([]*types.QueuedMessage, 0, len(p.queues[sessionID]))
+	for _, in := range p.queues[sessionID] {
+		queue = append(queue, in.msg)
+	}
+	return queue
+}
+
+func publishQueue(sessionID string, queue []*types.QueuedMessage) {
+	event.PublishSync(event.Event{
+		Type: event.SessionQueue,
+		Data: event.SessionQueueData{SessionID: sessionID, Queue: queue},
+	})
+}
+
+// QueueMessage queues a message for a busy session and waits until it has
+// been answered, returning the assistant message like ProcessMessage. Returns
+// ErrNotBusy if the session is idle, and ErrQueueCancelled if the message is
+// cancelled before it is delivered. If ctx ends first the message stays
+// queued.
+func (s *Service) QueueMessage(
+	ctx context.Context,
+	msg *types.QueuedMessage,
+	onUpdate func(msg *types.Message, parts []types.Part),
+) (*types.Message, []types.Part, error) {
+	if s.processor == nil {
+		return nil, nil, ErrNotBusy
+	}
+
+	var finalMsg *types.Message
+	var finalParts []types.Part
+	done, err := s.processor.Enqueue(msg, s.processor.resolveAgent(msg.Agent), func(msg *types.Message, parts []types.Part) {
+		finalMsg = msg
+		finalParts = parts
+		if onUpdate != nil {
+			onUpdate(msg, parts)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	select {
+	case err := <-done:
+		return finalMsg, finalParts, err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// Queue returns the messages waiting for a busy session.
+func (s *Service) Queue(sessionID string) []*types.QueuedMessage {
+	if s.processor == nil {
+		return []*types.QueuedMessage{}
+	}
+	return s.processor.Queue(sessionID)
+}
+
+// CancelQueued cancels a queued message of a session, or all of them if id is
+// empty, returning how many were cancelled.
+func (s *Service) CancelQueued(sessionID, id string) int {
+	if s.processor == nil {
+		return 0
+	}
+	return s.processor.CancelQueued(sessionID, id)
+}
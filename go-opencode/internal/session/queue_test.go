@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func newQueueProcessor(t *testing.T) (*Processor, storage.Storage) {
+	store := storage.New(t.TempDir())
+	proc := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	return proc, store
+}
+
+func TestProcessor_EnqueueRequiresBusySession(t *testing.T) {
+	proc, _ := newQueueProcessor(t)
+
+	_, err := proc.Enqueue(&types.QueuedMessage{SessionID: "ses1", Text: "hi"}, nil, nil)
+	assert.ErrorIs(t, err, ErrNotBusy)
+	assert.Empty(t, proc.Queue("ses1"))
+}
+
+func TestProcessor_QueueOrderAndCancel(t *testing.T) {
+	proc, _ := newQueueProcessor(t)
+	proc.sessions["ses1"] = &sessionState{}
+
+	first, err := proc.Enqueue(&types.QueuedMessage{SessionID: "ses1", Text: "later"}, nil, nil)
+	require.NoError(t, err)
+	_, err = proc.Enqueue(&types.QueuedMessage{SessionID: "ses1", Text: "and then", Delivery: types.DeliveryQueue}, nil, nil)
+	require.NoError(t, err)
+	_, err = proc.Enqueue(&types.QueuedMessage{SessionID: "ses1", Text: "stop that", Delivery: types.DeliverySteer}, nil, nil)
+	require.NoError(t, err)
+
+	queue := proc.Queue("ses1")
+	require.Len(t, queue, 3)
+	assert.Equal(t, types.DeliveryQueue, queue[0].Delivery, "delivery defaults to queue")
+	assert.NotEmpty(t, queue[0].ID)
+	assert.True(t, proc.hasSteer("ses1"))
+
+	// Steer messages go first
+	proc.mu.Lock()
+	batch := proc.takeQueuedLocked("ses1")
+	proc.mu.Unlock()
+	require.Len(t, batch, 1)
+	assert.Equal(t, "stop that", batch[0].msg.Text)
+	assert.False(t, proc.hasSteer("ses1"))
+
+	assert.Equal(t, 1, proc.CancelQueued("ses1", queue[0].ID))
+	assert.ErrorIs(t, <-first, ErrQueueCancelled)
+	assert.Equal(t, 0, proc.CancelQueued("ses1", queue[0].ID))
+
+	remaining := proc.Queue("ses1")
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "and then", remaining[0].Text)
+}
+
+func TestProcessor_EndRun(t *testing.T) {
+	proc, _ := newQueueProcessor(t)
+	proc.sessions["ses1"] = &sessionState{}
+
+	first, err := proc.Enqueue(&types.QueuedMessage{SessionID: "ses1", Text: "one"}, nil, nil)
+	require.NoError(t, err)
+	second, err := proc.Enqueue(&types.QueuedMessage{SessionID: "ses1", Text: "two"}, nil, nil)
+	require.NoError(t, err)
+
+	// A finished loop hands the next message over and the session stays busy
+	batch := proc.endRun("ses1", nil)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "one", batch[0].msg.Text)
+	assert.True(t, proc.IsProcessing("ses1"))
+	assert.Len(t, proc.Queue("ses1"), 1)
+
+	// A failed loop drops the queue and the session becomes idle
+	assert.Nil(t, proc.endRun("ses1", errors.New("provider error")))
+	assert.False(t, proc.IsProcessing("ses1"))
+	assert.Empty(t, proc.Queue("ses1"))
+	assert.ErrorIs(t, <-second, ErrQueueCancelled)
+
+	select {
+	case <-first:
+		t.Fatal("the delivered message is answered by its own loop")
+	default:
+	}
+}
+
+func TestProcessor_DeliverQueued(t *testing.T) {
+	proc, store := newQueueProcessor(t)
+	ctx := context.Background()
+
+	putMessage(t, store, &types.Message{ID: generatePartID(), SessionID: "ses1", Role: "assistant"})
+
+	batch := []*queuedInput{{msg: &types.QueuedMessage{
+		SessionID: "ses1",
+		Text:      "use the other parser",
+		Model:     &types.ModelRef{ProviderID: "anthropic", ModelID: "claude"},
+		Files:     []types.FilePart{{ID: "old", Mime: "text/plain", URL: "file:///notes.txt"}},
+	}}}
+	require.NoError(t, proc.deliverQueued(ctx, "ses1", batch))
+
+	messages, err := proc.loadMessages(ctx, "ses1")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	user := messages[1]
+	assert.Equal(t, "user", user.Role, "delivered messages follow the running answer")
+	assert.Equal(t, "claude", user.Model.ModelID)
+
+	parts, err := proc.loadParts(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "use the other parser", parts[0].(*types.TextPart).Text)
+	file := parts[1].(*types.FilePart)
+	assert.NotEqual(t, "old", file.ID)
+	assert.Equal(t, user.ID, file.MessageID)
+}
@@ -0,0 +1,192 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ErrNothingToResume is returned by Resume when the session's last message
+// is not an assistant message interrupted by a crash, or stands for a shell
+// command or subtask run for the user.
+var ErrNothingToResume = errors.New("no interrupted message to resume")
+
+// interruptedMessage is the error stored on messages aborted by recovery.
+const interruptedMessage = "Processing was interrupted before the response finished"
+
+// isUnfinished reports whether an assistant message was left mid-loop: it
+// has neither a finish reason nor an error.
+func isUnfinished(msg *types.Message) bool {
+	return msg.Role == "assistant" && msg.Finish == nil && msg.Error == nil
+}
+
+// isResumable reports whether an assistant message was aborted by recovery,
+// or stopped by a budget, and can be picked up by Resume.
+func isResumable(msg *types.Message) bool {
+	return msg.Role == "assistant" && msg.Finish == nil && msg.Error != nil &&
+		(msg.Error.Name == types.ErrorNameMessageAborted || msg.Error.Name == types.ErrorNameBudgetExceeded)
+}
+
+// isUserToolRun reports whether the parts of a user message stand for a tool
+// call made for the user, a shell command or a subtask, rather than a prompt.
+func isUserToolRun(parts []types.Part) bool {
+	for _, part := range parts {
+		switch pt := part.(type) {
+		case *types.SubtaskPart:
+			return true
+		case *types.TextPart:
+			if pt.Synthetic && pt.Text == userToolText {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RecoverInterrupted marks assistant messages and tool parts left unfinished
+// by a previous process as aborted, so clients stop treating their sessions
+// as busy. Sessions currently being processed are skipped.
+// Returns the IDs of the sessions that were recovered.
+func (s *Service) RecoverInterrupted(ctx context.Context) ([]string, error) {
+	projects, err := s.storage.List(ctx, []string{"session"})
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []string
+	for _, projectID := range projects {
+		sessionIDs, err := s.storage.List(ctx, []string{"session", projectID})
+		if err != nil {
+			return recovered, err
+		}
+
+		for _, sessionID := range sessionIDs {
+			if s.processor != nil && s.processor.IsProcessing(sessionID) {
+				continue
+			}
+
+			messages, err := s.GetMessages(ctx, sessionID)
+			if err != nil {
+				return recovered, err
+			}
+
+			aborted := false
+			for _, msg := range messages {
+				if !isUnfinished(msg) {
+					continue
+				}
+				if err := s.abortInterrupted(ctx, msg); err != nil {
+					return recovered, err
+				}
+				aborted = true
+			}
+
+			if !aborted {
+				continue
+			}
+			recovered = append(recovered, sessionID)
+
+			// Clear any busy indicator left over from the dead process
+			event.PublishSync(event.Event{
+				Type: event.SessionStatus,
+				Data: event.SessionStatusData{
+					SessionID: sessionID,
+					Status:    event.SessionStatusInfo{Type: "idle"},
+				},
+			})
+			event.PublishSync(event.Event{
+				Type: event.SessionIdle,
+				Data: event.SessionIdleData{SessionID: sessionID},
+			})
+		}
+	}
+
+	return recovered, nil
+}
+
+// abortInterrupted marks an unfinished message and its in-flight tool parts
+// as aborted in a single transaction.
+func (s *Service) abortInterrupted(ctx context.Context, msg *types.Message) error {
+	parts, err := s.GetParts(ctx, msg.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.storage.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+
+	var abortedParts []types.Part
+	for _, part := range parts {
+		toolPart, ok := part.(*types.ToolPart)
+		if !ok || (toolPart.State.Status != "pending" && toolPart.State.Status != "running") {
+			continue
+		}
+
+		toolPart.State.Status = "error"
+		toolPart.State.Error = "Tool execution was interrupted"
+		if toolPart.State.Time == nil {
+			toolPart.State.Time = &types.ToolTime{Start: now}
+		}
+		toolPart.State.Time.End = &now
+
+		tx.Put(ctx, []string{"part", msg.ID, toolPart.ID}, toolPart)
+		abortedParts = append(abortedParts, toolPart)
+	}
+
+	msg.Error = types.NewMessageAbortedError(interruptedMessage)
+	msg.Time.Updated = &now
+	tx.Put(ctx, []string{"message", msg.SessionID, msg.ID}, msg)
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, part := range abortedParts {
+		event.PublishSync(event.Event{
+			Type: event.MessagePartUpdated,
+			Data: event.MessagePartUpdatedData{Part: part},
+		})
+	}
+
+	// Publish event (SDK compatible: uses "info" field)
+	event.PublishSync(event.Event{
+		Type: event.MessageUpdated,
+		Data: event.MessageUpdatedData{Info: msg},
+	})
+
+	return nil
+}
+
+// Resume continues the agentic loop of a message aborted by RecoverInterrupted,
+// starting after its last committed step.
+func (s *Service) Resume(
+	ctx context.Context,
+	sessionID string,
+	onUpdate func(msg *types.Message, parts []types.Part),
+) (*types.Message, []types.Part, error) {
+	if s.processor == nil {
+		return nil, nil, fmt.Errorf("processor not initialized")
+	}
+
+	var finalMsg *types.Message
+	var finalParts []types.Part
+
+	err := s.processor.Resume(ctx, sessionID, nil, func(msg *types.Message, parts []types.Part) {
+		finalMsg = msg
+		finalParts = parts
+		if onUpdate != nil {
+			onUpdate(msg, parts)
+		}
+	})
+
+	return finalMsg, finalParts, err
+}
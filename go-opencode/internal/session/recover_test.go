@@ -0,0 +1,120 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// seedInterruptedSession stores a session whose assistant message was cut off
+// while one tool was still running.
+func seedInterruptedSession(t *testing.T, store storage.Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1", Directory: t.TempDir()}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, session))
+
+	userMsg := &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"}
+	require.NoError(t, store.Put(ctx, []string{"message", "ses1", "msg1"}, userMsg))
+
+	stop := "stop"
+	finishedMsg := &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1", Finish: &stop}
+	require.NoError(t, store.Put(ctx, []string{"message", "ses1", "msg2"}, finishedMsg))
+
+	assistantMsg := &types.Message{ID: "msg3", SessionID: "ses1", Role: "assistant", ParentID: "msg1"}
+	require.NoError(t, store.Put(ctx, []string{"message", "ses1", "msg3"}, assistantMsg))
+
+	done := &types.ToolPart{
+		ID: "prt1", SessionID: "ses1", MessageID: "msg3", Type: "tool", CallID: "call1", Tool: "read",
+		State: types.ToolState{Status: "completed", Output: "ok", Time: &types.ToolTime{Start: 1}},
+	}
+	running := &types.ToolPart{
+		ID: "prt2", SessionID: "ses1", MessageID: "msg3", Type: "tool", CallID: "call2", Tool: "bash",
+		State: types.ToolState{Status: "running", Time: &types.ToolTime{Start: 1}},
+	}
+	require.NoError(t, store.Put(ctx, []string{"part", "msg3", "prt1"}, done))
+	require.NoError(t, store.Put(ctx, []string{"part", "msg3", "prt2"}, running))
+}
+
+func TestService_RecoverInterrupted(t *testing.T) {
+	store := storage.New(t.TempDir())
+	seedInterruptedSession(t, store)
+	svc := NewService(store)
+	ctx := context.Background()
+
+	recovered, err := svc.RecoverInterrupted(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ses1"}, recovered)
+
+	msg, err := svc.GetMessage(ctx, "ses1", "msg3")
+	require.NoError(t, err)
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, types.ErrorNameMessageAborted, msg.Error.Name)
+	assert.True(t, isResumable(msg))
+
+	// Finished messages are left alone
+	finished, err := svc.GetMessage(ctx, "ses1", "msg2")
+	require.NoError(t, err)
+	assert.Nil(t, finished.Error)
+
+	parts, err := svc.GetParts(ctx, "msg3")
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "completed", parts[0].(*types.ToolPart).State.Status)
+	assert.Equal(t, "error", parts[1].(*types.ToolPart).State.Status)
+	assert.NotNil(t, parts[1].(*types.ToolPart).State.Time.End)
+
+	// A second pass finds nothing left to recover
+	recovered, err = svc.RecoverInterrupted(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, recovered)
+}
+
+func TestService_ResumeRequiresInterruptedMessage(t *testing.T) {
+	store := storage.New(t.TempDir())
+	seedInterruptedSession(t, store)
+	toolReg := tool.NewRegistry(t.TempDir(), store)
+	svc := NewServiceWithProcessor(store, nil, toolReg, nil, "", "")
+	ctx := context.Background()
+
+	// Before recovery the message is still unfinished, not aborted
+	_, _, err := svc.Resume(ctx, "ses1", nil)
+	assert.ErrorIs(t, err, ErrNothingToResume)
+	assert.False(t, svc.GetProcessor().IsProcessing("ses1"))
+}
+
+func TestService_ResumeSkipsUserToolRuns(t *testing.T) {
+	store := storage.New(t.TempDir())
+	toolReg := tool.NewRegistry(t.TempDir(), store)
+	svc := NewServiceWithProcessor(store, nil, toolReg, nil, "", "")
+	ctx := context.Background()
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1", Directory: t.TempDir()}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, session))
+
+	// A shell command, then a subtask, each aborted while running
+	runs := []struct {
+		userID, assistantID string
+		part                types.Part
+	}{
+		{"msg1", "msg2", &types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: userToolText, Synthetic: true}},
+		{"msg3", "msg4", &types.SubtaskPart{ID: "prt3", SessionID: "ses1", MessageID: "msg3", Type: "subtask", Prompt: "Look around", Agent: "general"}},
+	}
+	for _, run := range runs {
+		putMessage(t, store, &types.Message{ID: run.userID, SessionID: "ses1", Role: "user"}, run.part)
+		aborted := &types.Message{ID: run.assistantID, SessionID: "ses1", Role: "assistant", ParentID: run.userID,
+			Error: types.NewMessageAbortedError("Processing aborted")}
+		putMessage(t, store, aborted)
+		require.True(t, isResumable(aborted))
+
+		_, _, err := svc.Resume(ctx, "ses1", nil)
+		assert.ErrorIs(t, err, ErrNothingToResume, run.part.PartType())
+	}
+}
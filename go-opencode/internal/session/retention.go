@@ -0,0 +1,402 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// RetentionPolicy decides which sessions garbage collection removes.
+// Zero limits are disabled.
+type RetentionPolicy struct {
+	MaxAge                time.Duration
+	MaxSessionsPerProject int
+	KeepStarred           bool
+}
+
+// NewRetentionPolicy builds a policy from config. A nil config expires no
+// sessions, so collection only sweeps orphaned data.
+func NewRetentionPolicy(cfg *types.RetentionConfig) RetentionPolicy {
+	policy := RetentionPolicy{KeepStarred: true}
+	if cfg == nil {
+		return policy
+	}
+
+	policy.MaxAge = time.Duration(cfg.MaxAgeDays) * 24 * time.Hour
+	policy.MaxSessionsPerProject = cfg.MaxSessionsPerProject
+	if cfg.KeepStarred != nil {
+		policy.KeepStarred = *cfg.KeepStarred
+	}
+
+	return policy
+}
+
+// expired returns the IDs of the sessions of one project that the policy removes.
+// Starred sessions that are kept still count towards MaxSessionsPerProject.
+func (p RetentionPolicy) expired(sessions []*types.Session, now time.Time) map[string]bool {
+	sorted := make([]*types.Session, len(sessions))
+	copy(sorted, sessions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Updated > sorted[j].Time.Updated
+	})
+
+	expired := make(map[string]bool)
+	kept := 0
+	for _, session := range sorted {
+		if p.KeepStarred && session.Starred {
+			kept++
+			continue
+		}
+
+		tooOld := p.MaxAge > 0 && now.Sub(time.UnixMilli(session.Time.Updated)) > p.MaxAge
+		overLimit := p.MaxSessionsPerProject > 0 && kept >= p.MaxSessionsPerProject
+		if tooOld || overLimit {
+			expired[session.ID] = true
+			continue
+		}
+		kept++
+	}
+
+	return expired
+}
+
+// GCReport summarizes what garbage collection removed, or would remove in a dry run.
+type GCReport struct {
+	DryRun          bool
+	ExpiredSessions []string // Sessions removed by the retention policy
+	OrphanMessages  int      // Messages whose session no longer exists
+	OrphanParts     int      // Parts whose message no longer exists
+	OrphanTodos     int      // Todo lists whose session no longer exists
+	Records         int      // All records removed, including expired sessions' messages and parts
+	Bytes           int64    // Total size of the removed records
+}
+
+// gcBatch stages deletions in a transaction and tallies their size.
+type gcBatch struct {
+	tx      storage.Tx
+	records int
+	bytes   int64
+}
+
+func (b *gcBatch) delete(ctx context.Context, path []string, size int) {
+	b.tx.Delete(ctx, path)
+	b.records++
+	b.bytes += int64(size)
+}
+
+// finish commits the batch, or discards it in a dry run, and adds it to the report.
+func (b *gcBatch) finish(ctx context.Context, report *GCReport) error {
+	if report.DryRun {
+		b.tx.Rollback()
+	} else if err := b.tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	report.Records += b.records
+	report.Bytes += b.bytes
+	return nil
+}
+
+// CollectGarbage removes sessions expired under policy together with their
+// messages, parts and todos, then sweeps messages, parts and todos whose
+// parent no longer exists. Sessions currently being processed are never
+// removed. With dryRun set nothing is deleted and the report describes what
+// would be reclaimed.
+func (s *Service) CollectGarbage(ctx context.Context, policy RetentionPolicy, dryRun bool) (*GCReport, error) {
+	report := &GCReport{DryRun: dryRun}
+	now := time.Now()
+
+	live := make(map[string]bool)
+	gone := make(map[string]bool)
+
+	// Message IDs whose parts were already staged. In a dry run they are still
+	// in storage and must not be counted again as orphans.
+	sweptMessages := make(map[string]bool)
+
+	projects, err := s.storage.List(ctx, []string{"session"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, projectID := range projects {
+		var sessions []*types.Session
+		err := s.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
+			var session types.Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				live[key] = true // Leave unreadable sessions and their data alone
+				return nil
+			}
+			// Data is stored under the key, whatever the record says
+			session.ID = key
+			sessions = append(sessions, &session)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		expired := policy.expired(sessions, now)
+		for _, session := range sessions {
+			if !expired[session.ID] || (s.processor != nil && s.processor.IsProcessing(session.ID)) {
+				live[session.ID] = true
+				continue
+			}
+
+			batch, messageIDs, err := s.stageSession(ctx, projectID, session.ID)
+			if err != nil {
+				return nil, err
+			}
+			if err := batch.finish(ctx, report); err != nil {
+				return nil, err
+			}
+			if !dryRun {
+				removeWorkTreeCopy(session)
+			}
+			report.ExpiredSessions = append(report.ExpiredSessions, session.ID)
+			gone[session.ID] = true
+			for _, id := range messageIDs {
+				sweptMessages[id] = true
+			}
+		}
+	}
+
+	// Message IDs of live sessions, whose parts are kept
+	liveMessages := make(map[string]bool)
+
+	sessionIDs, err := s.storage.List(ctx, []string{"message"})
+	if err != nil {
+		return nil, err
+	}
+	for _, sessionID := range sessionIDs {
+		if !live[sessionID] {
+			continue
+		}
+		messageIDs, err := s.storage.List(ctx, []string{"message", sessionID})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range messageIDs {
+			liveMessages[id] = true
+		}
+	}
+
+	// Messages of sessions that no longer exist
+	for _, sessionID := range sessionIDs {
+		if gone[sessionID] || live[sessionID] {
+			continue
+		}
+
+		batch, err := s.beginBatch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		messageIDs, err := s.stageMessages(ctx, batch, sessionID, liveMessages)
+		if err != nil {
+			batch.tx.Rollback()
+			return nil, err
+		}
+		if err := batch.finish(ctx, report); err != nil {
+			return nil, err
+		}
+		report.OrphanMessages += len(messageIDs)
+		for _, id := range messageIDs {
+			sweptMessages[id] = true
+		}
+	}
+
+	// Parts of messages that no longer exist
+	messageIDs, err := s.storage.List(ctx, []string{"part"})
+	if err != nil {
+		return nil, err
+	}
+	for _, messageID := range messageIDs {
+		if liveMessages[messageID] || sweptMessages[messageID] {
+			continue
+		}
+
+		batch, err := s.beginBatch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		n, err := s.stageParts(ctx, batch, messageID)
+		if err != nil {
+			batch.tx.Rollback()
+			return nil, err
+		}
+		if err := batch.finish(ctx, report); err != nil {
+			return nil, err
+		}
+		report.OrphanParts += n
+	}
+
+	// Todo lists of sessions that no longer exist
+	todoSessionIDs, err := s.storage.List(ctx, []string{"todo"})
+	if err != nil {
+		return nil, err
+	}
+	for _, sessionID := range todoSessionIDs {
+		if live[sessionID] || gone[sessionID] {
+			continue
+		}
+
+		batch, err := s.beginBatch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		n, err := s.stageTodo(ctx, batch, sessionID)
+		if err != nil {
+			batch.tx.Rollback()
+			return nil, err
+		}
+		if err := batch.finish(ctx, report); err != nil {
+			return nil, err
+		}
+		report.OrphanTodos += n
+	}
+
+	return report, nil
+}
+
+// deleteSessionData removes a session record with all of its messages,
+// parts and todos in a single transaction.
+func (s *Service) deleteSessionData(ctx context.Context, projectID, sessionID string) error {
+	batch, _, err := s.stageSession(ctx, projectID, sessionID)
+	if err != nil {
+		return err
+	}
+	return batch.tx.Commit(ctx)
+}
+
+// stageSession stages removal of a session and everything that belongs to it.
+// The returned batch is uncommitted. Returns the staged message IDs.
+func (s *Service) stageSession(ctx context.Context, projectID, sessionID string) (*gcBatch, []string, error) {
+	batch, err := s.beginBatch(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw json.RawMessage
+	if err := s.storage.Get(ctx, []string{"session", projectID, sessionID}, &raw); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		batch.tx.Rollback()
+		return nil, nil, err
+	}
+	batch.delete(ctx, []string{"session", projectID, sessionID}, len(raw))
+
+	// An unreadable session has no known parent
+	var session types.Session
+	_ = json.Unmarshal(raw, &session)
+	shared, err := s.sharedMessages(ctx, projectID, sessionID, session.ParentID)
+	if err != nil {
+		batch.tx.Rollback()
+		return nil, nil, err
+	}
+
+	messageIDs, err := s.stageMessages(ctx, batch, sessionID, shared)
+	if err != nil {
+		batch.tx.Rollback()
+		return nil, nil, err
+	}
+
+	if _, err := s.stageTodo(ctx, batch, sessionID); err != nil {
+		batch.tx.Rollback()
+		return nil, nil, err
+	}
+
+	return batch, messageIDs, nil
+}
+
+// beginBatch starts a transaction for staging deletions.
+func (s *Service) beginBatch(ctx context.Context) (*gcBatch, error) {
+	tx, err := s.storage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcBatch{tx: tx}, nil
+}
+
+// sharedMessages returns the message IDs of the parent and the forks of a
+// session. Forks made before they got message IDs of their own store their
+// messages, and so their parts, under the IDs of their parent's.
+func (s *Service) sharedMessages(ctx context.Context, projectID, sessionID string, parentID *string) (map[string]bool, error) {
+	var related []string
+	if parentID != nil {
+		related = append(related, *parentID)
+	}
+	err := s.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
+		var session types.Session
+		if json.Unmarshal(data, &session) == nil && session.ParentID != nil && *session.ParentID == sessionID {
+			related = append(related, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shared := make(map[string]bool)
+	for _, id := range related {
+		messageIDs, err := s.storage.List(ctx, []string{"message", id})
+		if err != nil {
+			return nil, err
+		}
+		for _, messageID := range messageIDs {
+			shared[messageID] = true
+		}
+	}
+	return shared, nil
+}
+
+// stageMessages stages removal of every message of a session and the parts
+// of those not in keep. Returns the staged message IDs.
+func (s *Service) stageMessages(ctx context.Context, batch *gcBatch, sessionID string, keep map[string]bool) ([]string, error) {
+	var messageIDs []string
+	err := s.storage.Scan(ctx, []string{"message", sessionID}, func(key string, data json.RawMessage) error {
+		batch.delete(ctx, []string{"message", sessionID, key}, len(data))
+		messageIDs = append(messageIDs, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, messageID := range messageIDs {
+		if keep[messageID] {
+			continue
+		}
+		if _, err := s.stageParts(ctx, batch, messageID); err != nil {
+			return nil, err
+		}
+	}
+
+	return messageIDs, nil
+}
+
+// stageParts stages removal of every part of a message. Returns the number staged.
+func (s *Service) stageParts(ctx context.Context, batch *gcBatch, messageID string) (int, error) {
+	n := 0
+	err := s.storage.Scan(ctx, []string{"part", messageID}, func(key string, data json.RawMessage) error {
+		batch.delete(ctx, []string{"part", messageID, key}, len(data))
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// stageTodo stages removal of a session's todo list. Returns 1 if one existed.
+func (s *Service) stageTodo(ctx context.Context, batch *gcBatch, sessionID string) (int, error) {
+	var raw json.RawMessage
+	if err := s.storage.Get(ctx, []string{"todo", sessionID}, &raw); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	batch.delete(ctx, []string{"todo", sessionID}, len(raw))
+	return 1, nil
+}
@@ -0,0 +1,198 @@
+package session
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestRetentionPolicy_Expired(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	at := func(age time.Duration) types.SessionTime {
+		return types.SessionTime{Updated: now.Add(-age).UnixMilli()}
+	}
+
+	sessions := []*types.Session{
+		{ID: "new", Time: at(time.Hour)},
+		{ID: "recent", Time: at(2 * day)},
+		{ID: "old", Time: at(40 * day)},
+		{ID: "old-starred", Time: at(50 * day), Starred: true},
+	}
+
+	byAge := RetentionPolicy{MaxAge: 30 * day, KeepStarred: true}
+	assert.Equal(t, map[string]bool{"old": true}, byAge.expired(sessions, now))
+
+	byCount := RetentionPolicy{MaxSessionsPerProject: 1, KeepStarred: true}
+	assert.Equal(t, map[string]bool{"recent": true, "old": true}, byCount.expired(sessions, now))
+
+	noStarred := RetentionPolicy{MaxAge: 30 * day}
+	assert.Equal(t, map[string]bool{"old": true, "old-starred": true}, noStarred.expired(sessions, now))
+
+	assert.Empty(t, NewRetentionPolicy(nil).expired(sessions, now))
+}
+
+// seedSession stores a session with one message, one part and a todo list.
+func seedSession(t *testing.T, store storage.Storage, id string, updated time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	session := &types.Session{ID: id, ProjectID: "proj1", Time: types.SessionTime{Updated: updated.UnixMilli()}}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", id}, session))
+
+	msgID := id + "-msg"
+	require.NoError(t, store.Put(ctx, []string{"message", id, msgID}, &types.Message{ID: msgID, SessionID: id, Role: "user"}))
+	require.NoError(t, store.Put(ctx, []string{"part", msgID, id + "-prt"}, &types.TextPart{ID: id + "-prt", Type: "text", Text: "hi"}))
+	require.NoError(t, store.Put(ctx, []string{"todo", id}, []types.TodoInfo{{ID: "1", Content: "x"}}))
+}
+
+func storageBackends(t *testing.T) map[string]storage.Storage {
+	sqlite, err := storage.NewSQLite(filepath.Join(t.TempDir(), "opencode.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlite.Close() })
+
+	return map[string]storage.Storage{
+		storage.BackendFile:   storage.New(t.TempDir()),
+		storage.BackendSQLite: sqlite,
+	}
+}
+
+func TestService_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range storageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSession(t, store, "keep", time.Now())
+			seedSession(t, store, "expire", time.Now().Add(-60*24*time.Hour))
+
+			// Orphans: messages, parts and todos with no parent
+			require.NoError(t, store.Put(ctx, []string{"message", "ghost", "ghost-msg"}, &types.Message{ID: "ghost-msg"}))
+			require.NoError(t, store.Put(ctx, []string{"part", "ghost-msg", "p1"}, &types.TextPart{ID: "p1", Type: "text"}))
+			require.NoError(t, store.Put(ctx, []string{"part", "lost-msg", "p2"}, &types.TextPart{ID: "p2", Type: "text"}))
+			require.NoError(t, store.Put(ctx, []string{"todo", "ghost"}, []types.TodoInfo{}))
+
+			svc := NewService(store)
+			policy := NewRetentionPolicy(&types.RetentionConfig{MaxAgeDays: 30})
+
+			report, err := svc.CollectGarbage(ctx, policy, true)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"expire"}, report.ExpiredSessions)
+			assert.Equal(t, 1, report.OrphanMessages)
+			assert.Equal(t, 1, report.OrphanParts)
+			assert.Equal(t, 1, report.OrphanTodos)
+			// Expired session: session, message, part, todo.
+			// Orphans: ghost message with its part, lost part, ghost todo.
+			assert.Equal(t, 8, report.Records)
+			assert.Greater(t, report.Bytes, int64(0))
+
+			// Dry run deletes nothing
+			assert.True(t, store.Exists(ctx, []string{"session", "proj1", "expire"}))
+			assert.True(t, store.Exists(ctx, []string{"part", "lost-msg", "p2"}))
+
+			report, err = svc.CollectGarbage(ctx, policy, false)
+			require.NoError(t, err)
+			assert.Equal(t, 8, report.Records)
+
+			assert.False(t, store.Exists(ctx, []string{"session", "proj1", "expire"}))
+			assert.False(t, store.Exists(ctx, []string{"message", "expire", "expire-msg"}))
+			assert.False(t, store.Exists(ctx, []string{"part", "expire-msg", "expire-prt"}))
+			assert.False(t, store.Exists(ctx, []string{"todo", "expire"}))
+			assert.False(t, store.Exists(ctx, []string{"part", "lost-msg", "p2"}))
+			assert.False(t, store.Exists(ctx, []string{"todo", "ghost"}))
+
+			// Live data is untouched
+			assert.True(t, store.Exists(ctx, []string{"session", "proj1", "keep"}))
+			assert.True(t, store.Exists(ctx, []string{"message", "keep", "keep-msg"}))
+			assert.True(t, store.Exists(ctx, []string{"part", "keep-msg", "keep-prt"}))
+			assert.True(t, store.Exists(ctx, []string{"todo", "keep"}))
+
+			report, err = svc.CollectGarbage(ctx, policy, false)
+			require.NoError(t, err)
+			assert.Zero(t, report.Records)
+		})
+	}
+}
+
+func TestService_CollectGarbageKeepsUnreadableSessions(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range storageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			seedSession(t, store, "garbled", time.Now().Add(-60*24*time.Hour))
+			require.NoError(t, store.Put(ctx, []string{"session", "proj1", "garbled"}, "not a session"))
+
+			// A record whose ID disagrees with its key is collected by its key
+			seedSession(t, store, "renamed", time.Now().Add(-60*24*time.Hour))
+			require.NoError(t, store.Put(ctx, []string{"session", "proj1", "renamed"}, &types.Session{
+				ID:        "other",
+				ProjectID: "proj1",
+				Time:      types.SessionTime{Updated: time.Now().Add(-60 * 24 * time.Hour).UnixMilli()},
+			}))
+
+			svc := NewService(store)
+			report, err := svc.CollectGarbage(ctx, NewRetentionPolicy(&types.RetentionConfig{MaxAgeDays: 30}), false)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"renamed"}, report.ExpiredSessions)
+			assert.Zero(t, report.OrphanMessages)
+			assert.Zero(t, report.OrphanParts)
+			assert.Zero(t, report.OrphanTodos)
+
+			assert.True(t, store.Exists(ctx, []string{"session", "proj1", "garbled"}))
+			assert.True(t, store.Exists(ctx, []string{"message", "garbled", "garbled-msg"}))
+			assert.True(t, store.Exists(ctx, []string{"part", "garbled-msg", "garbled-prt"}))
+			assert.True(t, store.Exists(ctx, []string{"todo", "garbled"}))
+
+			assert.False(t, store.Exists(ctx, []string{"session", "proj1", "renamed"}))
+			assert.False(t, store.Exists(ctx, []string{"message", "renamed", "renamed-msg"}))
+		})
+	}
+}
+
+func TestService_DeleteRemovesSessionData(t *testing.T) {
+	store := storage.New(t.TempDir())
+	seedSession(t, store, "ses1", time.Now())
+	ctx := context.Background()
+
+	require.NoError(t, NewService(store).Delete(ctx, "ses1"))
+
+	assert.False(t, store.Exists(ctx, []string{"session", "proj1", "ses1"}))
+	assert.False(t, store.Exists(ctx, []string{"message", "ses1", "ses1-msg"}))
+	assert.False(t, store.Exists(ctx, []string{"part", "ses1-msg", "ses1-prt"}))
+	assert.False(t, store.Exists(ctx, []string{"todo", "ses1"}))
+}
+
+func TestService_DeleteKeepsSharedParts(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range storageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Forks used to store their messages under the IDs of the parent's
+			seedSession(t, store, "parent", time.Now())
+			parentID := "parent"
+			require.NoError(t, store.Put(ctx, []string{"session", "proj1", "fork"}, &types.Session{ID: "fork", ProjectID: "proj1", ParentID: &parentID}))
+			require.NoError(t, store.Put(ctx, []string{"message", "fork", "parent-msg"}, &types.Message{ID: "parent-msg", SessionID: "fork", Role: "user"}))
+			svc := NewService(store)
+
+			require.NoError(t, svc.Delete(ctx, "fork"))
+			assert.False(t, store.Exists(ctx, []string{"message", "fork", "parent-msg"}))
+			assert.True(t, store.Exists(ctx, []string{"part", "parent-msg", "parent-prt"}))
+
+			// Nor are they collected as orphans
+			report, err := svc.CollectGarbage(ctx, NewRetentionPolicy(nil), false)
+			require.NoError(t, err)
+			assert.Zero(t, report.OrphanParts)
+			assert.True(t, store.Exists(ctx, []string{"part", "parent-msg", "parent-prt"}))
+
+			// Once no other session has them, they go with the last
+			require.NoError(t, svc.Delete(ctx, "parent"))
+			assert.False(t, store.Exists(ctx, []string{"part", "parent-msg", "parent-prt"}))
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// RetryMaxAfter is the longest Retry-After the loop waits for. A provider
+// asking for longer is failed over, or the request fails.
+const RetryMaxAfter = time.Minute
+
+// Fallbacks holds the configured fallback chains: the models to switch to,
+// in order, by the "provider/model" they stand in for.
+type Fallbacks map[string][]types.ModelRef
+
+// NewFallbacks collects the fallback chains of a configuration.
+func NewFallbacks(cfg *types.Config) Fallbacks {
+	if cfg == nil || len(cfg.Fallback) == 0 {
+		return nil
+	}
+	f := make(Fallbacks, len(cfg.Fallback))
+	for model, chain := range cfg.Fallback {
+		for _, s := range chain {
+			providerID, modelID := provider.ParseModelString(s)
+			if providerID == "" {
+				logging.Warn().Str("model", model).Str("fallback", s).Msg("Ignoring fallback model without a provider")
+				continue
+			}
+			f[model] = append(f[model], types.ModelRef{ProviderID: providerID, ModelID: modelID})
+		}
+	}
+	return f
+}
+
+// chain returns the models to fall back to from a model.
+func (f Fallbacks) chain(providerID, modelID string) []types.ModelRef {
+	var out []types.ModelRef
+	for _, ref := range f[providerID+"/"+modelID] {
+		if ref.ProviderID != providerID || ref.ModelID != modelID {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+// SetFallbacks sets the fallback chains the loop moves down when a model's
+// provider keeps failing.
+func (p *Processor) SetFallbacks(fallbacks Fallbacks) {
+	p.fallbacks = fallbacks
+}
+
+// SetFallbacks sets the fallback chains the loop moves down when a model's
+// provider keeps failing.
+func (s *Service) SetFallbacks(fallbacks Fallbacks) {
+	if s.processor != nil {
+		s.processor.SetFallbacks(fallbacks)
+	}
+}
+
+// loopModel is the model the loop sends its requests to.
+type loopModel struct {
+	provider provider.Provider
+	model    *types.Model
+}
+
+// retrier decides how the loop recovers from a failed request: by sending it
+// again after a delay, or by moving down the fallback chain once the model's
+// retries are used up or its error can't be retried.
+type retrier struct {
+	backoff   backoff.BackOff
+	attempt   int
+	fallbacks []types.ModelRef
+
+	// The session status is "retry" until a request succeeds
+	retrying bool
+}
+
+func newRetrier(ctx context.Context, fallbacks []types.ModelRef) *retrier {
+	return &retrier{backoff: newRetryBackoff(ctx), fallbacks: fallbacks}
+}
+
+// delay returns how long to wait before sending again a request that failed
+// with err, or false if it should not be sent again. A delay asked for by
+// the provider is honoured, up to RetryMaxAfter.
+func (r *retrier) delay(err *provider.APIError) (time.Duration, bool) {
+	if !err.Kind.Retryable() || err.RetryAfter > RetryMaxAfter {
+		return 0, false
+	}
+	next := r.backoff.NextBackOff()
+	if next == backoff.Stop {
+		return 0, false
+	}
+	r.attempt++
+	return max(next, err.RetryAfter), true
+}
+
+// succeeded resets the retries after a request went through, and reports
+// whether the session was shown as retrying.
+func (r *retrier) succeeded() bool {
+	r.backoff.Reset()
+	r.attempt = 0
+	retrying := r.retrying
+	r.retrying = false
+	return retrying
+}
+
+// recoverRequest handles a failed request to current: it waits before the
+// request is sent again, or switches msg to the next available model of the
+// fallback chain. It returns the model to continue with, or the classified
+// error to end the loop with.
+func (p *Processor) recoverRequest(ctx context.Context, r *retrier, msg *types.Message, current loopModel, err error) (loopModel, error) {
+	apiErr := provider.ClassifyError(err)
+	if ctx.Err() != nil {
+		return current, ctx.Err()
+	}
+
+	if delay, ok := r.delay(apiErr); ok {
+		logging.Warn().
+			Str("sessionID", msg.SessionID).
+			Str("provider", msg.ProviderID).
+			Str("kind", string(apiErr.Kind)).
+			Int("attempt", r.attempt).
+			Dur("delay", delay).
+			Err(err).
+			Msg("Retrying request")
+
+		r.retrying = true
+		publishStatus(msg.SessionID, event.SessionStatusInfo{
+			Type:    "retry",
+			Attempt: r.attempt,
+			Message: apiErr.Error(),
+			Next:    time.Now().Add(delay).UnixMilli(),
+		})
+		select {
+		case <-time.After(delay):
+			return current, nil
+		case <-ctx.Done():
+			return current, ctx.Err()
+		}
+	}
+
+	if !apiErr.Kind.FailsOver() {
+		return current, apiErr
+	}
+	for len(r.fallbacks) > 0 {
+		ref := r.fallbacks[0]
+		r.fallbacks = r.fallbacks[1:]
+
+		next, err := p.resolveModel(ref)
+		if err != nil {
+			logging.Warn().Str("sessionID", msg.SessionID).Err(err).Msg("Skipping unavailable fallback model")
+			continue
+		}
+
+		logging.Warn().
+			Str("sessionID", msg.SessionID).
+			Str("from", msg.ProviderID+"/"+msg.ModelID).
+			Str("to", ref.ProviderID+"/"+ref.ModelID).
+			Str("kind", string(apiErr.Kind)).
+			Err(apiErr).
+			Msg("Switching to fallback model")
+
+		msg.Fallbacks = append(msg.Fallbacks, types.ModelFallback{
+			From:  types.ModelRef{ProviderID: msg.ProviderID, ModelID: msg.ModelID},
+			To:    ref,
+			Error: apiErr.Error(),
+			Time:  time.Now().UnixMilli(),
+		})
+		msg.ProviderID = ref.ProviderID
+		msg.ModelID = ref.ModelID
+		p.saveMessage(ctx, msg.SessionID, msg)
+
+		r.backoff.Reset()
+		r.attempt = 0
+		r.retrying = true
+		publishStatus(msg.SessionID, event.SessionStatusInfo{
+			Type:    "retry",
+			Message: fmt.Sprintf("Switching to %s/%s: %s", ref.ProviderID, ref.ModelID, apiErr.Error()),
+			Next:    time.Now().UnixMilli(),
+		})
+		return next, nil
+	}
+	return current, apiErr
+}
+
+// resolveModel looks up a fallback model in the provider registry.
+func (p *Processor) resolveModel(ref types.ModelRef) (loopModel, error) {
+	prov, err := p.providerRegistry.Get(ref.ProviderID)
+	if err != nil {
+		return loopModel{}, err
+	}
+	model, err := p.providerRegistry.GetModel(ref.ProviderID, ref.ModelID)
+	if err != nil {
+		return loopModel{}, err
+	}
+	return loopModel{provider: prov, model: model}, nil
+}
+
+// requestError returns the error recorded on a message whose request failed.
+func requestError(providerID string, err error) *types.MessageError {
+	if apiErr := provider.ClassifyError(err); apiErr.Kind == provider.ErrorAuth {
+		return types.NewProviderAuthError(providerID, err.Error())
+	}
+	return types.NewUnknownError(err.Error())
+}
+
+// publishStatus publishes a session.status event.
+func publishStatus(sessionID string, status event.SessionStatusInfo) {
+	event.PublishSync(event.Event{
+		Type: event.SessionStatus,
+		Data: event.SessionStatusData{
+			SessionID: sessionID,
+			Status:    status,
+		},
+	})
+}
@@ -0,0 +1,154 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// downProvider fails every completion with err.
+type downProvider struct {
+	err error
+}
+
+func (p *downProvider) ID() string   { return "down" }
+func (p *downProvider) Name() string { return "Down" }
+func (p *downProvider) Models() []types.Model {
+	return []types.Model{{ID: "down-1", ProviderID: "down", Name: "Down 1"}}
+}
+func (p *downProvider) ChatModel() model.ToolCallingChatModel { return nil }
+
+func (p *downProvider) CreateCompletion(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionStream, error) {
+	return nil, p.err
+}
+
+// newFallbackProcessor returns a processor for a session with one user
+// message, whose model is down with err.
+func newFallbackProcessor(t *testing.T, err error, fallbacks Fallbacks) (*Processor, storage.Storage) {
+	t.Helper()
+	store := storage.New(t.TempDir())
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &types.Session{ID: "ses1", ProjectID: "proj1", Directory: dir}))
+	putMessage(t, store,
+		&types.Message{ID: "msg1", SessionID: "ses1", Role: "user", Model: &types.ModelRef{ProviderID: "down", ModelID: "down-1"}},
+		&types.TextPart{ID: "part1", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "Fix the build"},
+	)
+
+	providerReg := provider.NewRegistry(nil)
+	providerReg.Register(&downProvider{err: err})
+	providerReg.Register(&echoProvider{})
+	p := NewProcessor(providerReg, tool.NewRegistry(dir, store), store, nil, "down", "down-1")
+	p.SetFallbacks(fallbacks)
+	return p, store
+}
+
+func TestNewFallbacks(t *testing.T) {
+	fallbacks := NewFallbacks(&types.Config{Fallback: map[string][]string{
+		"anthropic/claude-sonnet-4": {"openai/gpt-4o", "gpt-4o-mini", "anthropic/claude-sonnet-4", "ark/endpoint"},
+	}})
+
+	assert.Equal(t, []types.ModelRef{
+		{ProviderID: "openai", ModelID: "gpt-4o"},
+		{ProviderID: "ark", ModelID: "endpoint"},
+	}, fallbacks.chain("anthropic", "claude-sonnet-4"))
+	assert.Empty(t, fallbacks.chain("openai", "gpt-4o"))
+	assert.Nil(t, NewFallbacks(&types.Config{}))
+}
+
+func TestProcessor_Fallback(t *testing.T) {
+	p, _ := newFallbackProcessor(t, errors.New("401 Unauthorized: authentication_error: invalid x-api-key"), Fallbacks{
+		"down/down-1": {{ProviderID: "missing", ModelID: "gone"}, {ProviderID: "echo", ModelID: "large"}},
+	})
+
+	var mu sync.Mutex
+	var statuses []event.SessionStatusInfo
+	unsubscribe := event.Subscribe(event.SessionStatus, func(e event.Event) {
+		if data, ok := e.Data.(event.SessionStatusData); ok && data.SessionID == "ses1" {
+			mu.Lock()
+			statuses = append(statuses, data.Status)
+			mu.Unlock()
+		}
+	})
+	defer unsubscribe()
+
+	var final *types.Message
+	err := p.Process(context.Background(), "ses1", DefaultAgent(), func(msg *types.Message, parts []types.Part) {
+		final = msg
+	})
+	require.NoError(t, err)
+
+	// The unavailable fallback is skipped, and the switch recorded
+	require.NotNil(t, final)
+	assert.Nil(t, final.Error)
+	assert.Equal(t, "echo", final.ProviderID)
+	assert.Equal(t, "large", final.ModelID)
+	require.Len(t, final.Fallbacks, 1)
+	assert.Equal(t, types.ModelRef{ProviderID: "down", ModelID: "down-1"}, final.Fallbacks[0].From)
+	assert.Equal(t, types.ModelRef{ProviderID: "echo", ModelID: "large"}, final.Fallbacks[0].To)
+	assert.Contains(t, final.Fallbacks[0].Error, "invalid x-api-key")
+
+	mu.Lock()
+	defer mu.Unlock()
+	var kinds []string
+	for _, status := range statuses {
+		kinds = append(kinds, status.Type)
+	}
+	assert.Equal(t, []string{"busy", "retry", "busy", "idle"}, kinds)
+	assert.Contains(t, statuses[1].Message, "Switching to echo/large")
+}
+
+func TestProcessor_FallbackExhausted(t *testing.T) {
+	p, _ := newFallbackProcessor(t, errors.New("400 Bad Request: invalid_request_error: prompt is too long"), Fallbacks{
+		"down/down-1": {{ProviderID: "echo", ModelID: "large"}},
+	})
+
+	var final *types.Message
+	err := p.Process(context.Background(), "ses1", DefaultAgent(), func(msg *types.Message, parts []types.Part) {
+		final = msg
+	})
+	require.Error(t, err)
+
+	// Context length errors are neither retried nor failed over
+	var apiErr *provider.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, provider.ErrorContextLength, apiErr.Kind)
+	require.NotNil(t, final.Error)
+	assert.Equal(t, "down", final.ProviderID)
+	assert.Empty(t, final.Fallbacks)
+}
+
+func TestRetrier_Delay(t *testing.T) {
+	r := newRetrier(context.Background(), nil)
+
+	delay, ok := r.delay(&provider.APIError{Kind: provider.ErrorRateLimit, RetryAfter: 20 * time.Second})
+	assert.True(t, ok)
+	assert.Equal(t, 20*time.Second, delay, "Retry-After beyond the backoff is honoured")
+	assert.Equal(t, 1, r.attempt)
+
+	_, ok = r.delay(&provider.APIError{Kind: provider.ErrorOverloaded, RetryAfter: 2 * RetryMaxAfter})
+	assert.False(t, ok, "Retry-After beyond RetryMaxAfter is not waited for")
+
+	_, ok = r.delay(&provider.APIError{Kind: provider.ErrorAuth})
+	assert.False(t, ok, "auth errors are not retried")
+
+	for i := 1; i < MaxRetries; i++ {
+		_, ok = r.delay(&provider.APIError{Kind: provider.ErrorTransient})
+		assert.True(t, ok)
+	}
+	_, ok = r.delay(&provider.APIError{Kind: provider.ErrorTransient})
+	assert.False(t, ok, "retries stop after MaxRetries")
+}
@@ -0,0 +1,256 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/snapshot"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// ErrSessionBusy is returned when a session cannot be changed while it is
+// being processed.
+var ErrSessionBusy = errors.New("session is busy")
+
+// ErrNoSnapshot is returned when the files cannot be reverted because the
+// steps being reverted took no snapshot.
+var ErrNoSnapshot = errors.New("no snapshot to restore the files from")
+
+// trackSnapshot records the project files at the start of a step. It returns
+// an empty hash when snapshots are disabled or cannot be taken.
+func (p *Processor) trackSnapshot(ctx context.Context, msg *types.Message) string {
+	if p.snapshots == nil || msg.Path == nil || msg.Path.Cwd == "" {
+		return ""
+	}
+
+	hash, err := p.snapshots.Track(ctx, msg.Path.Cwd)
+	if err != nil {
+		if !errors.Is(err, snapshot.ErrNotRepository) {
+			logging.Warn().Err(err).Str("sessionID", msg.SessionID).Msg("Failed to track snapshot")
+		}
+		return ""
+	}
+	return hash
+}
+
+// Revert rolls a session back to before a message, or a part of it. Files are
+// restored to the snapshot taken at the start of the step holding that point,
+// or of the first step after it. The state they were in before is kept in the
+// revert so that Unrevert can bring it back, and the diff between the two is
+// stored for review. Messages are kept until the session continues; see
+// ApplyRevert.
+func (s *Service) Revert(ctx context.Context, sessionID, messageID string, partID *string) (*types.Session, error) {
+	if s.processor != nil && s.processor.IsProcessing(sessionID) {
+		return nil, ErrSessionBusy
+	}
+
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.GetMessages(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var revert *types.SessionRevert
+	var lastUser string
+	var target *types.StepStartPart // Step whose snapshot the files go back to
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			lastUser = msg.ID
+		}
+
+		parts, err := s.GetParts(ctx, msg.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Reverting past every text and tool part of a message reverts the
+		// whole turn, from the user message that started it
+		useful := false
+		var step *types.StepStartPart // Last step started before the point
+		for _, part := range parts {
+			if revert == nil && ((msg.ID == messageID && partID == nil) || (partID != nil && part.PartID() == *partID)) {
+				revert = &types.SessionRevert{MessageID: msg.ID}
+				if useful {
+					revert.PartID = partID
+				} else if lastUser != "" {
+					revert.MessageID = lastUser
+				}
+
+				// A part within a step is undone with the whole step
+				if _, ok := part.(*types.StepStartPart); !ok {
+					target = step
+				}
+			}
+
+			if revert == nil {
+				switch pt := part.(type) {
+				case *types.TextPart, *types.ToolPart:
+					useful = true
+				case *types.StepStartPart:
+					step = pt
+				}
+				continue
+			}
+			if pt, ok := part.(*types.StepStartPart); ok && target == nil {
+				target = pt
+			}
+		}
+	}
+
+	if revert == nil {
+		return session, nil
+	}
+	if s.snapshots != nil && target != nil {
+		// Reverting again keeps the state from before the first revert
+		var current string
+		if session.Revert != nil && session.Revert.Snapshot != nil {
+			current = *session.Revert.Snapshot
+		} else if current, err = s.snapshots.Track(ctx, session.Directory); err != nil && !errors.Is(err, snapshot.ErrNotRepository) {
+			return nil, err
+		}
+
+		// Files outside a repository are never tracked and stay as they are
+		if current != "" {
+			if target.Snapshot == "" {
+				return nil, ErrNoSnapshot
+			}
+			diff, err := s.snapshots.Diff(ctx, session.Directory, current, target.Snapshot)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := s.snapshots.Restore(ctx, session.Directory, target.Snapshot); err != nil {
+				return nil, err
+			}
+			revert.Snapshot = &current
+			revert.Diff = &diff
+		}
+	}
+
+	session.Revert = revert
+	session.Time.Updated = time.Now().UnixMilli()
+
+	if err := s.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Unrevert cancels a revert, restoring the files to their state before it.
+func (s *Service) Unrevert(ctx context.Context, sessionID string) (*types.Session, error) {
+	if s.processor != nil && s.processor.IsProcessing(sessionID) {
+		return nil, ErrSessionBusy
+	}
+
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Revert == nil {
+		return session, nil
+	}
+
+	if s.snapshots != nil && session.Revert.Snapshot != nil {
+		if _, err := s.snapshots.Restore(ctx, session.Directory, *session.Revert.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	session.Revert = nil
+	session.Time.Updated = time.Now().UnixMilli()
+
+	if err := s.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ApplyRevert makes a pending revert permanent when the session continues:
+// the reverted messages and parts are deleted and the revert is cleared.
+func (s *Service) ApplyRevert(ctx context.Context, session *types.Session) error {
+	if session.Revert == nil {
+		return nil
+	}
+
+	messages, err := s.GetMessages(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.storage.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var removedMessages []string
+	var removedParts []event.MessagePartRemovedData
+	reverted := false
+	for _, msg := range messages {
+		if msg.ID == session.Revert.MessageID {
+			reverted = true
+			if session.Revert.PartID != nil {
+				// Keep the parts before the reverted one
+				parts, err := s.GetParts(ctx, msg.ID)
+				if err != nil {
+					return err
+				}
+				removing := false
+				for _, part := range parts {
+					removing = removing || part.PartID() == *session.Revert.PartID
+					if removing {
+						tx.Delete(ctx, []string{"part", msg.ID, part.PartID()})
+						removedParts = append(removedParts, event.MessagePartRemovedData{
+							SessionID: session.ID,
+							MessageID: msg.ID,
+							PartID:    part.PartID(),
+						})
+					}
+				}
+				continue
+			}
+		}
+		if !reverted {
+			continue
+		}
+
+		parts, err := s.GetParts(ctx, msg.ID)
+		if err != nil {
+			return err
+		}
+		for _, part := range parts {
+			tx.Delete(ctx, []string{"part", msg.ID, part.PartID()})
+		}
+		tx.Delete(ctx, []string{"message", session.ID, msg.ID})
+		removedMessages = append(removedMessages, msg.ID)
+	}
+
+	session.Revert = nil
+	session.Time.Updated = time.Now().UnixMilli()
+	tx.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, data := range removedParts {
+		event.PublishSync(event.Event{Type: event.MessagePartRemoved, Data: data})
+	}
+	for _, messageID := range removedMessages {
+		event.PublishSync(event.Event{
+			Type: event.MessageRemoved,
+			Data: event.MessageRemovedData{SessionID: session.ID, MessageID: messageID},
+		})
+	}
+	event.PublishSync(event.Event{
+		Type: event.SessionUpdated,
+		Data: event.SessionUpdatedData{Info: session},
+	})
+	return nil
+}
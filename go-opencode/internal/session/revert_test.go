@@ -0,0 +1,189 @@
+package session
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/snapshot"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// gitRepo returns a repository holding main.go at v1.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("v1\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return dir
+}
+
+func TestService_RevertRestoresFiles(t *testing.T) {
+	dir := gitRepo(t)
+	file := filepath.Join(dir, "main.go")
+
+	store := storage.New(t.TempDir())
+	snapshots := snapshot.New(t.TempDir())
+	svc := NewService(store)
+	svc.SetSnapshots(snapshots)
+	ctx := context.Background()
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1", Directory: dir}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, session))
+
+	// Two turns, each step recording the files as they were when it started
+	stop := "stop"
+	turn := func(userID, assistantID string) {
+		before, err := snapshots.Track(ctx, dir)
+		require.NoError(t, err)
+		putMessage(t, store, &types.Message{ID: userID, SessionID: "ses1", Role: "user"},
+			&types.TextPart{ID: userID + "-text", SessionID: "ses1", MessageID: userID, Type: "text", Text: "change it"})
+		putMessage(t, store, &types.Message{ID: assistantID, SessionID: "ses1", Role: "assistant", ParentID: userID, Finish: &stop},
+			&types.StepStartPart{ID: assistantID + "-step", SessionID: "ses1", MessageID: assistantID, Type: "step-start", Snapshot: before},
+			&types.TextPart{ID: assistantID + "-text", SessionID: "ses1", MessageID: assistantID, Type: "text", Text: "changed"})
+	}
+	turn("msg1", "msg2")
+	require.NoError(t, os.WriteFile(file, []byte("v2\n"), 0644))
+	turn("msg3", "msg4")
+	require.NoError(t, os.WriteFile(file, []byte("v3\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.go"), []byte("new\n"), 0644))
+
+	reverted, err := svc.Revert(ctx, "ses1", "msg4", nil)
+	require.NoError(t, err)
+	require.NotNil(t, reverted.Revert)
+	assert.Equal(t, "msg3", reverted.Revert.MessageID, "reverting a whole reply reverts its turn")
+	require.NotNil(t, reverted.Revert.Diff)
+	assert.Contains(t, *reverted.Revert.Diff, "-v3")
+	assert.Contains(t, *reverted.Revert.Diff, "+v2")
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "v2\n", string(data))
+	assert.NoFileExists(t, filepath.Join(dir, "new.go"))
+
+	// Reverting further back still unreverts to the latest files
+	_, err = svc.Revert(ctx, "ses1", "msg1", nil)
+	require.NoError(t, err)
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "v1\n", string(data))
+
+	unreverted, err := svc.Unrevert(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Nil(t, unreverted.Revert)
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "v3\n", string(data))
+	assert.FileExists(t, filepath.Join(dir, "new.go"))
+}
+
+func TestService_RevertMidStep(t *testing.T) {
+	dir := gitRepo(t)
+	file := filepath.Join(dir, "main.go")
+
+	store := storage.New(t.TempDir())
+	snapshots := snapshot.New(t.TempDir())
+	svc := NewService(store)
+	svc.SetSnapshots(snapshots)
+	ctx := context.Background()
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1", Directory: dir}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, session))
+
+	// One step edits the file twice, the next one only answers
+	first, err := snapshots.Track(ctx, dir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, []byte("v2\n"), 0644))
+	require.NoError(t, os.WriteFile(file, []byte("v3\n"), 0644))
+	second, err := snapshots.Track(ctx, dir)
+	require.NoError(t, err)
+
+	tool := func(id string) *types.ToolPart {
+		return &types.ToolPart{ID: id, SessionID: "ses1", MessageID: "msg2", Type: "tool", Tool: "edit", State: types.ToolState{Status: "completed"}}
+	}
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"},
+		&types.TextPart{ID: "msg1-text", SessionID: "ses1", MessageID: "msg1", Type: "text", Text: "change it"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1"},
+		&types.StepStartPart{ID: "prt1", SessionID: "ses1", MessageID: "msg2", Type: "step-start", Snapshot: first},
+		tool("prt2"),
+		tool("prt3"),
+		&types.StepStartPart{ID: "prt4", SessionID: "ses1", MessageID: "msg2", Type: "step-start", Snapshot: second},
+		&types.TextPart{ID: "prt5", SessionID: "ses1", MessageID: "msg2", Type: "text", Text: "done"})
+
+	// Reverting the second edit undoes the step holding it
+	partID := "prt3"
+	reverted, err := svc.Revert(ctx, "ses1", "msg2", &partID)
+	require.NoError(t, err)
+	require.NotNil(t, reverted.Revert)
+	assert.Equal(t, &partID, reverted.Revert.PartID)
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "v1\n", string(data))
+
+	// A step without a snapshot cannot be reverted
+	_, err = svc.Unrevert(ctx, "ses1")
+	require.NoError(t, err)
+	putMessage(t, store, &types.Message{ID: "msg3", SessionID: "ses1", Role: "assistant", ParentID: "msg1"},
+		&types.StepStartPart{ID: "prt6", SessionID: "ses1", MessageID: "msg3", Type: "step-start"},
+		&types.TextPart{ID: "prt7", SessionID: "ses1", MessageID: "msg3", Type: "text", Text: "again"})
+	partID = "prt7"
+	_, err = svc.Revert(ctx, "ses1", "msg3", &partID)
+	assert.ErrorIs(t, err, ErrNoSnapshot)
+	unchanged, err := svc.Get(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Nil(t, unchanged.Revert)
+}
+
+func TestService_ApplyRevert(t *testing.T) {
+	store := storage.New(t.TempDir())
+	svc := NewService(store)
+	ctx := context.Background()
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1"}
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, session))
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", ParentID: "msg1"},
+		&types.TextPart{ID: "prt1", SessionID: "ses1", MessageID: "msg2", Type: "text", Text: "keep"},
+		&types.TextPart{ID: "prt2", SessionID: "ses1", MessageID: "msg2", Type: "text", Text: "drop"})
+	putMessage(t, store, &types.Message{ID: "msg3", SessionID: "ses1", Role: "user"})
+
+	partID := "prt2"
+	reverted, err := svc.Revert(ctx, "ses1", "msg2", &partID)
+	require.NoError(t, err)
+	require.NotNil(t, reverted.Revert)
+	assert.Equal(t, "msg2", reverted.Revert.MessageID)
+	assert.Nil(t, reverted.Revert.Snapshot, "no snapshots without a store")
+
+	require.NoError(t, svc.ApplyRevert(ctx, reverted))
+
+	messages, err := svc.GetMessages(ctx, "ses1")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	parts, err := svc.GetParts(ctx, "msg2")
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "prt1", parts[0].PartID())
+
+	stored, err := svc.Get(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Nil(t, stored.Revert)
+}
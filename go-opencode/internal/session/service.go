@@ -4,16 +4,20 @@ package session
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 
+	"github.com/opencode-ai/opencode/internal/command"
 	"github.com/opencode-ai/opencode/internal/event"
 	"github.com/opencode-ai/opencode/internal/permission"
 	"github.com/opencode-ai/opencode/internal/project"
 	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/snapshot"
 	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/internal/tool"
 	"github.com/opencode-ai/opencode/pkg/types"
@@ -21,7 +25,7 @@ import (
 
 // Service manages session operations.
 type Service struct {
-	storage *storage.Storage
+	storage storage.Storage
 
 	// Active session processing
 	mu       sync.RWMutex
@@ -30,8 +34,18 @@ type Service struct {
 
 	// Processor for agentic loop
 	processor *Processor
+
+	// File snapshots for revert, nil when disabled
+	snapshots *snapshot.Store
+
+	// Commands run by ExecuteCommand, nil when none are configured
+	commands *command.Executor
 }
 
+// ErrPermissionNotFound is returned when answering a permission request that
+// is not pending.
+var ErrPermissionNotFound = errors.New("permission request not found")
+
 // ActiveSession tracks an active processing session.
 type ActiveSession struct {
 	SessionID string
@@ -40,7 +54,7 @@ type ActiveSession struct {
 }
 
 // NewService creates a new session service.
-func NewService(store *storage.Storage) *Service {
+func NewService(store storage.Storage) *Service {
 	return &Service{
 		storage:  store,
 		active:   make(map[string]*ActiveSession),
@@ -50,7 +64,7 @@ func NewService(store *storage.Storage) *Service {
 
 // NewServiceWithProcessor creates a new session service with processor dependencies.
 func NewServiceWithProcessor(
-	store *storage.Storage,
+	store storage.Storage,
 	providerReg *provider.Registry,
 	toolReg *tool.Registry,
 	permChecker *permission.Checker,
@@ -66,6 +80,15 @@ func NewServiceWithProcessor(
 	return s
 }
 
+// SetSnapshots enables file snapshots: the processor records the project
+// files at every step and Revert restores them.
+func (s *Service) SetSnapshots(store *snapshot.Store) {
+	s.snapshots = store
+	if s.processor != nil {
+		s.processor.snapshots = store
+	}
+}
+
 // GetProcessor returns the session processor.
 func (s *Service) GetProcessor() *Processor {
 	return s.processor
@@ -148,6 +171,9 @@ func (s *Service) Update(ctx context.Context, sessionID string, updates map[stri
 	if title, ok := updates["title"].(string); ok {
 		session.Title = title
 	}
+	if starred, ok := updates["starred"].(bool); ok {
+		session.Starred = starred
+	}
 
 	session.Time.Updated = time.Now().UnixMilli()
 
@@ -165,25 +191,24 @@ func (s *Service) Delete(ctx context.Context, sessionID string) error {
 		return err
 	}
 
-	// Delete session file
-	if err := s.storage.Delete(ctx, []string{"session", session.ProjectID, sessionID}); err != nil {
+	// Delete the session with its messages, parts and todos
+	if err := s.deleteSessionData(ctx, session.ProjectID, sessionID); err != nil {
 		return err
 	}
-
-	// Delete associated messages
-	messages, _ := s.GetMessages(ctx, sessionID)
-	for _, msg := range messages {
-		s.storage.Delete(ctx, []string{"message", sessionID, msg.ID})
-	}
-
+	removeWorkTreeCopy(session)
 	return nil
 }
 
-// List lists sessions for a directory.
+// List lists sessions for a directory, most recently updated first.
 // If directory is empty, lists all sessions across all projects.
 func (s *Service) List(ctx context.Context, directory string) ([]*types.Session, error) {
-	var sessions []*types.Session
+	return s.ListRecent(ctx, directory, 0)
+}
 
+// ListRecent lists the limit sessions of a directory updated last, most
+// recent first. A limit of zero or less lists them all. If directory is
+// empty, lists sessions across all projects.
+func (s *Service) ListRecent(ctx context.Context, directory string, limit int) ([]*types.Session, error) {
 	if directory == "" {
 		// List ALL sessions across all projects
 		projects, err := s.storage.List(ctx, []string{"session"})
@@ -191,20 +216,21 @@ func (s *Service) List(ctx context.Context, directory string) ([]*types.Session,
 			return nil, err
 		}
 
+		var sessions []*types.Session
 		for _, projectID := range projects {
-			err := s.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
-				var session types.Session
-				if err := json.Unmarshal(data, &session); err != nil {
-					return err
-				}
-				sessions = append(sessions, &session)
-				return nil
-			})
+			recent, err := s.recentSessions(ctx, projectID, limit)
 			if err != nil {
 				return nil, err
 			}
+			sessions = append(sessions, recent...)
 		}
 
+		sort.SliceStable(sessions, func(i, j int) bool {
+			return sessions[i].Time.Updated > sessions[j].Time.Updated
+		})
+		if limit > 0 && len(sessions) > limit {
+			sessions = sessions[:limit]
+		}
 		return sessions, nil
 	}
 
@@ -225,7 +251,14 @@ func (s *Service) List(ctx context.Context, directory string) ([]*types.Session,
 		_ = err
 	}
 
-	err = s.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
+	return s.recentSessions(ctx, projectID, limit)
+}
+
+// recentSessions returns the limit sessions of a project updated last, most
+// recent first, or all of them if limit is zero or less.
+func (s *Service) recentSessions(ctx context.Context, projectID string, limit int) ([]*types.Session, error) {
+	var sessions []*types.Session
+	err := s.storage.Query(ctx, []string{"session", projectID}, storage.QueryOptions{Descending: true}, func(key string, data json.RawMessage) error {
 		var session types.Session
 		if err := json.Unmarshal(data, &session); err != nil {
 			return err
@@ -233,8 +266,20 @@ func (s *Service) List(ctx context.Context, directory string) ([]*types.Session,
 		sessions = append(sessions, &session)
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return sessions, err
+	// Storage orders by write time, which imports, key rotation and garbage
+	// collection change without updating sessions, so the limit is applied
+	// to the recorded update time
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Time.Updated > sessions[j].Time.Updated
+	})
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
 }
 
 // GetChildren returns child sessions (forks).
@@ -281,11 +326,32 @@ func (s *Service) Fork(ctx context.Context, sessionID, messageID string) (*types
 		return nil, err
 	}
 
+	// Messages and parts are copied under new IDs: parts are stored by
+	// message ID, and the fork must not share them with its parent
+	remap := newIDs()
 	for _, msg := range messages {
+		parts, err := s.GetParts(ctx, msg.ID)
+		if err != nil {
+			return nil, err
+		}
+
 		// Copy message
 		newMsg := *msg
+		newMsg.ID = remap(msg.ID)
 		newMsg.SessionID = newSession.ID
-		s.AddMessage(ctx, newSession.ID, &newMsg)
+		newMsg.ParentID = remap(msg.ParentID)
+		if err := s.AddMessage(ctx, newSession.ID, &newMsg); err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			moved, err := movePart(part, generateID(), newSession.ID, newMsg.ID)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.storage.Put(ctx, []string{"part", newMsg.ID, moved.PartID()}, moved); err != nil {
+				return nil, err
+			}
+		}
 
 		if msg.ID == messageID {
 			break
@@ -426,7 +492,7 @@ func (s *Service) Summarize(ctx context.Context, sessionID, providerID, modelID
 	// Trigger the processing loop
 	if s.processor != nil {
 		go func() {
-			s.processor.Process(context.Background(), session.ID, nil, nil)
+			s.processor.Process(context.Background(), session.ID, nil, func(*types.Message, []types.Part) {})
 		}()
 	}
 
@@ -440,59 +506,66 @@ func (s *Service) GetDiffs(ctx context.Context, sessionID string) ([]types.FileD
 		return nil, err
 	}
 
-	return session.Summary.Diffs, nil
+	return withoutLegacyCompaction(session.Summary.Diffs), nil
 }
 
-// GetTodos returns todos for a session.
-func (s *Service) GetTodos(ctx context.Context, sessionID string) ([]map[string]any, error) {
-	// TODO: Implement todo tracking
-	return []map[string]any{}, nil
+// GetTodos returns the todo list of a session, as last written by the
+// TodoWrite tool.
+func (s *Service) GetTodos(ctx context.Context, sessionID string) ([]types.TodoInfo, error) {
+	return GetTodos(ctx, s.storage, sessionID)
 }
 
-// Revert reverts a session to a specific message.
-func (s *Service) Revert(ctx context.Context, sessionID, messageID string, partID *string) error {
-	session, err := s.Get(ctx, sessionID)
-	if err != nil {
-		return err
-	}
-
-	session.Revert = &types.SessionRevert{
-		MessageID: messageID,
-		PartID:    partID,
+// RunShell runs a shell command for the user in a session. See
+// Processor.RunShell.
+func (s *Service) RunShell(
+	ctx context.Context,
+	input ShellInput,
+	onUpdate func(msg *types.Message, parts []types.Part),
+) (*types.Message, []types.Part, error) {
+	if s.processor == nil {
+		return nil, nil, fmt.Errorf("no processor to run shell commands")
 	}
-	session.Time.Updated = time.Now().UnixMilli()
 
-	return s.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
-}
-
-// Unrevert removes the revert state from a session.
-func (s *Service) Unrevert(ctx context.Context, sessionID string) error {
-	session, err := s.Get(ctx, sessionID)
+	session, err := s.Get(ctx, input.SessionID)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if s.processor.IsProcessing(session.ID) {
+		return nil, nil, ErrSessionBusy
+	}
+	if err := s.ApplyRevert(ctx, session); err != nil {
+		return nil, nil, err
 	}
 
-	session.Revert = nil
-	session.Time.Updated = time.Now().UnixMilli()
-
-	return s.storage.Put(ctx, []string{"session", session.ProjectID, session.ID}, session)
+	if onUpdate == nil {
+		onUpdate = func(*types.Message, []types.Part) {}
+	}
+	return s.processor.RunShell(ctx, input, onUpdate)
 }
 
-// ExecuteCommand executes a slash command.
-func (s *Service) ExecuteCommand(ctx context.Context, sessionID, command string) (map[string]any, error) {
-	// TODO: Implement command execution
-	return map[string]any{"result": "command executed"}, nil
-}
+// RespondPermission answers a pending permission request of a session with
+// "once", "always" or "reject". Returns ErrPermissionNotFound if the request
+// is not waiting for an answer.
+func (s *Service) RespondPermission(ctx context.Context, sessionID, permissionID, response string) error {
+	switch response {
+	case "once", "always", "reject":
+	default:
+		return fmt.Errorf("invalid permission response: %s", response)
+	}
 
-// RunShell runs a shell command in the session context.
-func (s *Service) RunShell(ctx context.Context, sessionID, command string, timeout int) (map[string]any, error) {
-	// TODO: Implement shell execution
-	return map[string]any{"output": ""}, nil
-}
+	var checker *permission.Checker
+	if s.processor != nil {
+		checker = s.processor.permissionChecker
+	}
+	if checker == nil {
+		return ErrPermissionNotFound
+	}
+	req, ok := checker.Pending(permissionID)
+	if !ok || req.SessionID != sessionID {
+		return ErrPermissionNotFound
+	}
 
-// RespondPermission responds to a permission request.
-func (s *Service) RespondPermission(ctx context.Context, sessionID, permissionID string, granted bool) error {
-	// TODO: Implement permission response handling
+	checker.Respond(permissionID, response)
 	return nil
 }
 
@@ -653,7 +726,7 @@ func (s *Service) ProcessMessage(
 		var finalMsg *types.Message
 		var finalParts []types.Part
 
-		err := s.processor.Process(ctx, session.ID, DefaultAgent(), func(msg *types.Message, parts []types.Part) {
+		err := s.processor.Process(ctx, session.ID, nil, func(msg *types.Message, parts []types.Part) {
 			finalMsg = msg
 			finalParts = parts
 			if onUpdate != nil {
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestService_ListRecent(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	for name, store := range storageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Written oldest first, with IDs sorting by creation as ULIDs do
+			for i, id := range []string{"ses1", "ses2", "ses3"} {
+				project := "proj1"
+				if id == "ses2" {
+					project = "proj2"
+				}
+				session := &types.Session{ID: id, ProjectID: project, Time: types.SessionTime{Updated: now.Add(time.Duration(i) * time.Minute).UnixMilli()}}
+				require.NoError(t, store.Put(ctx, []string{"session", project, id}, session))
+			}
+			svc := NewService(store)
+
+			ids := func(sessions []*types.Session) []string {
+				var ids []string
+				for _, session := range sessions {
+					ids = append(ids, session.ID)
+				}
+				return ids
+			}
+
+			all, err := svc.List(ctx, "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"ses3", "ses2", "ses1"}, ids(all))
+
+			recent, err := svc.ListRecent(ctx, "", 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"ses3", "ses2"}, ids(recent))
+
+			latest, err := svc.recentSessions(ctx, "proj1", 1)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"ses3"}, ids(latest))
+
+			// A session rewritten without being updated, as by an import,
+			// is not taken for the one updated last
+			time.Sleep(5 * time.Millisecond)
+			var ses1 types.Session
+			require.NoError(t, store.Get(ctx, []string{"session", "proj1", "ses1"}, &ses1))
+			require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"}, &ses1))
+			latest, err = svc.recentSessions(ctx, "proj1", 1)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"ses3"}, ids(latest))
+		})
+	}
+}
+
+func TestService_ForkOwnsItsParts(t *testing.T) {
+	store := storage.New(t.TempDir())
+	svc := NewService(store)
+	ctx := context.Background()
+
+	parent, err := svc.Create(ctx, t.TempDir(), "Parent")
+	require.NoError(t, err)
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: parent.ID, Role: "user"},
+		&types.TextPart{ID: "prt1", SessionID: parent.ID, MessageID: "msg1", Type: "text", Text: "Hi"})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: parent.ID, Role: "assistant", ParentID: "msg1"},
+		&types.TextPart{ID: "prt2", SessionID: parent.ID, MessageID: "msg2", Type: "text", Text: "Hello"})
+
+	fork, err := svc.Fork(ctx, parent.ID, "")
+	require.NoError(t, err)
+
+	// The fork has its own messages and parts, in the same order
+	messages, err := svc.GetMessages(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.NotEqual(t, "msg1", messages[0].ID)
+	assert.Equal(t, messages[0].ID, messages[1].ParentID)
+	parts, err := svc.GetParts(ctx, messages[1].ID)
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	text := parts[0].(*types.TextPart)
+	assert.Equal(t, "Hello", text.Text)
+	assert.Equal(t, fork.ID, text.SessionID)
+	assert.Equal(t, messages[1].ID, text.MessageID)
+
+	// Deleting the fork leaves the parent whole
+	require.NoError(t, svc.Delete(ctx, fork.ID))
+	for _, id := range []string{"msg1", "msg2"} {
+		parts, err := svc.GetParts(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, parts, 1, id)
+	}
+}
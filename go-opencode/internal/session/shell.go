@@ -0,0 +1,217 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// userToolText stands in for the user prompt of a tool call the user made.
+const userToolText = "The following tool was executed by the user"
+
+// ShellInput is a shell command run by the user in a session.
+type ShellInput struct {
+	SessionID string
+	Agent     string          // Agent recorded on the messages; empty for the default
+	Model     *types.ModelRef // Model recorded on the messages; nil for the last one used
+	Command   string
+	Timeout   int // Milliseconds; zero for the bash tool default
+}
+
+// userToolCall is a tool call made for the user rather than by the model. It
+// is recorded as a user message answered by an assistant message holding the
+// call, so the model sees the call and its result on the next turn.
+type userToolCall struct {
+	sessionID string
+	agent     *Agent
+	model     *types.ModelRef
+	part      func(msg *types.Message) types.Part // Builds the user message part
+	tool      string
+	input     map[string]any
+}
+
+// RunShell runs a command through the bash tool for the user, subject to the
+// agent's bash permission. Returns ErrSessionBusy if the session is being
+// processed.
+func (p *Processor) RunShell(ctx context.Context, input ShellInput, callback ProcessCallback) (*types.Message, []types.Part, error) {
+	agent := p.resolveAgent(input.Agent)
+	toolInput := map[string]any{"command": input.Command}
+	if input.Timeout > 0 {
+		toolInput["timeout"] = input.Timeout
+	}
+
+	return p.runUserTool(ctx, &userToolCall{
+		sessionID: input.SessionID,
+		agent:     agent,
+		model:     input.Model,
+		part: func(msg *types.Message) types.Part {
+			return &types.TextPart{
+				ID:        generatePartID(),
+				SessionID: msg.SessionID,
+				MessageID: msg.ID,
+				Type:      "text",
+				Text:      userToolText,
+				Synthetic: true,
+			}
+		},
+		tool:  "bash",
+		input: toolInput,
+	}, callback)
+}
+
+// runUserTool records and runs a tool call made for the user. The session is
+// busy meanwhile: it can be aborted, and messages sent to it are queued.
+// Tool failures are recorded on the tool part rather than returned.
+func (p *Processor) runUserTool(ctx context.Context, call *userToolCall, callback ProcessCallback) (*types.Message, []types.Part, error) {
+	sessionID := call.sessionID
+	session, err := p.findSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	if _, ok := p.sessions[sessionID]; ok {
+		p.mu.Unlock()
+		return nil, nil, ErrSessionBusy
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	state := &sessionState{
+		ctx:     runCtx,
+		cancel:  cancel,
+		started: time.Now(),
+	}
+	p.sessions[sessionID] = state
+	p.mu.Unlock()
+
+	event.PublishSync(event.Event{
+		Type: event.SessionStatus,
+		Data: event.SessionStatusData{
+			SessionID: sessionID,
+			Status:    event.SessionStatusInfo{Type: "busy"},
+		},
+	})
+
+	var runErr error
+	defer func() {
+		if batch := p.endRun(sessionID, runErr); batch != nil {
+			go p.runQueued(runCtx, sessionID, state, batch)
+		}
+	}()
+
+	model := call.model
+	if model == nil {
+		model = p.lastModel(ctx, sessionID)
+	}
+
+	userMsg := &types.Message{
+		ID:        generatePartID(),
+		SessionID: sessionID,
+		Role:      "user",
+		Agent:     call.agent.Name,
+		Model:     model,
+		Summary: &types.UserMessageSummary{
+			Diffs: []types.FileDiff{},
+		},
+		Time: types.MessageTime{
+			Created: time.Now().UnixMilli(),
+		},
+	}
+	if runErr = p.storage.Put(ctx, []string{"message", sessionID, userMsg.ID}, userMsg); runErr != nil {
+		return nil, nil, runErr
+	}
+	userPart := call.part(userMsg)
+	if runErr = p.savePart(ctx, userMsg.ID, userPart); runErr != nil {
+		return nil, nil, runErr
+	}
+	event.PublishSync(event.Event{
+		Type: event.MessageUpdated,
+		Data: event.MessageUpdatedData{Info: userMsg},
+	})
+	event.PublishSync(event.Event{
+		Type: event.MessagePartUpdated,
+		Data: event.MessagePartUpdatedData{Part: userPart},
+	})
+
+	now := time.Now().UnixMilli()
+	assistantMsg := &types.Message{
+		ID:         generatePartID(),
+		SessionID:  sessionID,
+		Role:       "assistant",
+		ParentID:   userMsg.ID,
+		ProviderID: model.ProviderID,
+		ModelID:    model.ModelID,
+		Mode:       call.agent.Name,
+		Path: &types.MessagePath{
+			Cwd:  session.Directory,
+			Root: session.Directory,
+		},
+		Time: types.MessageTime{
+			Created: now,
+		},
+		Tokens: &types.TokenUsage{Input: 0, Output: 0},
+	}
+	if runErr = p.storage.Put(ctx, []string{"message", sessionID, assistantMsg.ID}, assistantMsg); runErr != nil {
+		return nil, nil, runErr
+	}
+	event.PublishSync(event.Event{
+		Type: event.MessageCreated,
+		Data: event.MessageCreatedData{Info: assistantMsg},
+	})
+
+	toolPart := &types.ToolPart{
+		ID:        generatePartID(),
+		SessionID: sessionID,
+		MessageID: assistantMsg.ID,
+		Type:      "tool",
+		CallID:    generatePartID(),
+		Tool:      call.tool,
+		State: types.ToolState{
+			Status: "running",
+			Input:  call.input,
+			Time:   &types.ToolTime{Start: now},
+		},
+	}
+	state.message = assistantMsg
+	state.parts = []types.Part{toolPart}
+	if runErr = p.savePart(ctx, assistantMsg.ID, toolPart); runErr != nil {
+		return nil, nil, runErr
+	}
+	event.PublishSync(event.Event{
+		Type: event.MessagePartUpdated,
+		Data: event.MessagePartUpdatedData{Part: toolPart},
+	})
+	callback(assistantMsg, state.parts)
+
+	// Failures, including denied permissions, are recorded on the tool part
+	p.executeSingleTool(runCtx, state, call.agent, toolPart, callback)
+
+	if errors.Is(runCtx.Err(), context.Canceled) {
+		runErr = runCtx.Err()
+		assistantMsg.Error = types.NewMessageAbortedError("Processing aborted")
+	} else {
+		finish := "stop"
+		assistantMsg.Finish = &finish
+	}
+	p.saveMessage(ctx, sessionID, assistantMsg)
+	callback(assistantMsg, state.parts)
+
+	return assistantMsg, state.parts, runErr
+}
+
+// lastModel returns the model of the last user message of a session that
+// chose one, or the default model.
+func (p *Processor) lastModel(ctx context.Context, sessionID string) *types.ModelRef {
+	messages, _ := p.loadMessages(ctx, sessionID)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if msg := messages[i]; msg.Role == "user" && msg.Model != nil {
+			return msg.Model
+		}
+	}
+	return &types.ModelRef{
+		ProviderID: p.defaultProviderID,
+		ModelID:    p.defaultModelID,
+	}
+}
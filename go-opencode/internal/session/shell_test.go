@@ -0,0 +1,208 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/agent"
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func newShellService(t *testing.T, checker *permission.Checker) (*Service, storage.Storage) {
+	t.Helper()
+	store := storage.New(t.TempDir())
+	dir := t.TempDir()
+
+	toolReg := tool.NewRegistry(dir, store)
+	toolReg.Register(tool.NewBashTool(dir))
+	toolReg.Register(tool.NewTaskTool(dir, nil))
+
+	session := &types.Session{ID: "ses1", ProjectID: "proj1", Directory: dir}
+	require.NoError(t, store.Put(context.Background(), []string{"session", "proj1", "ses1"}, session))
+
+	return NewServiceWithProcessor(store, nil, toolReg, checker, "anthropic", "claude"), store
+}
+
+func TestService_RunShell(t *testing.T) {
+	svc, _ := newShellService(t, nil)
+	ctx := context.Background()
+
+	model := &types.ModelRef{ProviderID: "openai", ModelID: "gpt-4o"}
+	msg, parts, err := svc.RunShell(ctx, ShellInput{
+		SessionID: "ses1",
+		Agent:     "build",
+		Model:     model,
+		Command:   "echo hello",
+	}, nil)
+	require.NoError(t, err)
+
+	require.NotNil(t, msg.Finish)
+	assert.Equal(t, "stop", *msg.Finish)
+	assert.Nil(t, msg.Error)
+	assert.Equal(t, "build", msg.Mode)
+	assert.Equal(t, "openai", msg.ProviderID)
+	assert.False(t, svc.processor.IsProcessing("ses1"))
+
+	require.Len(t, parts, 1)
+	toolPart := parts[0].(*types.ToolPart)
+	assert.Equal(t, "bash", toolPart.Tool)
+	assert.Equal(t, "completed", toolPart.State.Status)
+	assert.Equal(t, "hello\n", toolPart.State.Output)
+
+	// The command is recorded as a synthetic user/assistant pair
+	messages, err := svc.GetMessages(ctx, "ses1")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	userMsg := messages[0]
+	assert.Equal(t, "user", userMsg.Role)
+	assert.Equal(t, "build", userMsg.Agent)
+	assert.Equal(t, model, userMsg.Model)
+	assert.Equal(t, userMsg.ID, messages[1].ParentID)
+
+	userParts, err := svc.GetParts(ctx, userMsg.ID)
+	require.NoError(t, err)
+	require.Len(t, userParts, 1)
+	textPart := userParts[0].(*types.TextPart)
+	assert.True(t, textPart.Synthetic)
+	assert.Equal(t, userToolText, textPart.Text)
+
+	stored, err := svc.GetParts(ctx, msg.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "completed", stored[0].(*types.ToolPart).State.Status)
+
+	// Without a model the last one chosen is used again
+	msg, _, err = svc.RunShell(ctx, ShellInput{SessionID: "ses1", Command: "true"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "openai", msg.ProviderID)
+	assert.Equal(t, "gpt-4o", msg.ModelID)
+}
+
+func TestService_RunShellBusy(t *testing.T) {
+	svc, _ := newShellService(t, nil)
+	svc.processor.sessions["ses1"] = &sessionState{}
+
+	_, _, err := svc.RunShell(context.Background(), ShellInput{SessionID: "ses1", Command: "true"}, nil)
+	assert.ErrorIs(t, err, ErrSessionBusy)
+}
+
+func TestService_RunShellPermission(t *testing.T) {
+	checker := permission.NewChecker()
+	svc, _ := newShellService(t, checker)
+	ctx := context.Background()
+
+	// The plan agent may not run commands
+	_, parts, err := svc.RunShell(ctx, ShellInput{SessionID: "ses1", Agent: "plan", Command: "echo no"}, nil)
+	require.NoError(t, err)
+	toolPart := parts[0].(*types.ToolPart)
+	assert.Equal(t, "error", toolPart.State.Status)
+	assert.Empty(t, toolPart.State.Output)
+
+	// The default agent asks, and the answer goes through RespondPermission
+	var asked []string
+	unsubscribe := event.Subscribe(event.PermissionUpdated, func(e event.Event) {
+		data := e.Data.(event.PermissionUpdatedData)
+		asked = append(asked, data.ID)
+		assert.ErrorIs(t, svc.RespondPermission(ctx, "ses2", data.ID, "once"), ErrPermissionNotFound)
+		assert.Error(t, svc.RespondPermission(ctx, "ses1", data.ID, "maybe"))
+		assert.NoError(t, svc.RespondPermission(ctx, "ses1", data.ID, "once"))
+	})
+	defer unsubscribe()
+
+	_, parts, err = svc.RunShell(ctx, ShellInput{SessionID: "ses1", Command: "echo yes"}, nil)
+	require.NoError(t, err)
+	require.Len(t, asked, 1)
+	toolPart = parts[0].(*types.ToolPart)
+	assert.Equal(t, "completed", toolPart.State.Status)
+	assert.Equal(t, "yes\n", toolPart.State.Output)
+
+	// Answered requests are no longer pending
+	assert.ErrorIs(t, svc.RespondPermission(ctx, "ses1", asked[0], "once"), ErrPermissionNotFound)
+}
+
+func TestProcessor_ToolPermission(t *testing.T) {
+	checker := permission.NewChecker()
+	svc, _ := newShellService(t, checker)
+	p := svc.processor
+	ctx := context.Background()
+
+	var asked []permission.Request
+	unsubscribe := event.Subscribe(event.PermissionUpdated, func(e event.Event) {
+		data := e.Data.(event.PermissionUpdatedData)
+		req, ok := checker.Pending(data.ID)
+		assert.True(t, ok)
+		asked = append(asked, req)
+		checker.Respond(data.ID, "reject")
+	})
+	defer unsubscribe()
+
+	run := func(agent *Agent, command string) *types.ToolPart {
+		state := &sessionState{ctx: ctx, message: &types.Message{ID: "msg1", SessionID: "ses1", Role: "assistant"}}
+		toolPart := &types.ToolPart{
+			ID:        generatePartID(),
+			SessionID: "ses1",
+			MessageID: "msg1",
+			Type:      "tool",
+			CallID:    generatePartID(),
+			Tool:      "bash",
+			State: types.ToolState{
+				Status: "running",
+				Input:  map[string]any{"command": command},
+				Time:   &types.ToolTime{Start: time.Now().UnixMilli()},
+			},
+		}
+		state.parts = []types.Part{toolPart}
+		p.executeSingleTool(ctx, state, agent, toolPart, func(*types.Message, []types.Part) {})
+		return toolPart
+	}
+
+	// A bash call of the model under an "ask" policy waits for an answer
+	toolPart := run(DefaultAgent(), "echo hi")
+	require.Len(t, asked, 1)
+	assert.Equal(t, permission.PermBash, asked[0].Type)
+	assert.Equal(t, []string{"echo hi"}, asked[0].Pattern)
+	assert.Equal(t, "error", toolPart.State.Status)
+
+	// Agents of the registry follow their command rules
+	p.SetAgents(agent.NewRegistry())
+	toolPart = run(p.resolveAgent("build"), "echo hi")
+	assert.Equal(t, "completed", toolPart.State.Status)
+	assert.Equal(t, "hi\n", toolPart.State.Output)
+
+	plan := p.resolveAgent("plan")
+	assert.False(t, plan.ToolEnabled("edit"))
+	toolPart = run(plan, "ls")
+	assert.Equal(t, "completed", toolPart.State.Status)
+	require.Len(t, asked, 1)
+	toolPart = run(plan, "rm -rf build")
+	assert.Equal(t, "error", toolPart.State.Status)
+	require.Len(t, asked, 2)
+	assert.Equal(t, []string{"rm -rf build"}, asked[1].Pattern)
+
+	// Agents it lacks keep the built-in configuration
+	assert.Equal(t, "ask", p.resolveAgent("custom").Permission.Bash)
+}
+
+func TestService_GetTodos(t *testing.T) {
+	svc, store := newShellService(t, nil)
+	ctx := context.Background()
+
+	todos, err := svc.GetTodos(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+
+	written := []types.TodoInfo{{ID: "1", Content: "Write tests", Status: "pending", Priority: "high"}}
+	require.NoError(t, UpdateTodos(ctx, store, "ses1", written))
+
+	todos, err = svc.GetTodos(ctx, "ses1")
+	require.NoError(t, err)
+	assert.Equal(t, written, todos)
+}
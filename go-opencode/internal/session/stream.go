@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 	"time"
@@ -10,7 +11,9 @@ import (
 	"github.com/cloudwego/eino/schema"
 
 	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/storage"
 	"github.com/opencode-ai/opencode/pkg/types"
 )
 
@@ -19,6 +22,7 @@ func (p *Processor) processStream(
 	ctx context.Context,
 	stream *provider.CompletionStream,
 	state *sessionState,
+	model *types.Model,
 	callback ProcessCallback,
 ) (string, error) {
 	var currentTextPart *types.TextPart
@@ -36,37 +40,60 @@ func (p *Processor) processStream(
 	// - MessageStartEvent (first): contains PromptTokens and cache info
 	// - MessageDeltaEvent (last): contains CompletionTokens only
 	// We need to merge both to get complete usage.
-	var inputTokens, completionTokens, cachedTokens int
+	var inputTokens, completionTokens, cachedTokens, cacheWriteTokens, reasoningTokens int
 	var hasUsage bool
 
+	// The step's parts and the updated message are committed together when
+	// the stream finishes, so a crash mid-step never leaves a torn step behind.
+	tx, err := p.storage.Begin(ctx)
+	if err != nil {
+		return "error", err
+	}
+	defer tx.Rollback()
+	var stageErr error
+	stage := func(path []string, v any) {
+		if err := tx.Put(ctx, path, v); err != nil && stageErr == nil {
+			stageErr = err
+		}
+	}
+	stagePart := func(part types.Part) {
+		stage([]string{"part", state.message.ID, part.PartID()}, part)
+	}
+
+	// A step failed by the provider leaves no parts behind, in storage or in
+	// the session, as it is retried. An aborted step keeps what was streamed.
+	partsBefore := len(state.parts)
+	fail := func(err error) (string, error) {
+		p.dropParts(state, partsBefore, callback)
+		return "error", err
+	}
+
 	// Emit step-start part at the beginning of inference
 	stepStartPart := &types.StepStartPart{
 		ID:        generatePartID(),
 		SessionID: state.message.SessionID,
 		MessageID: state.message.ID,
 		Type:      "step-start",
+		Snapshot:  p.trackSnapshot(ctx, state.message),
 	}
 	state.parts = append(state.parts, stepStartPart)
-	p.savePart(ctx, state.message.ID, stepStartPart)
+	stagePart(stepStartPart)
 	event.PublishSync(event.Event{
 		Type: event.MessagePartUpdated,
 		Data: event.MessagePartUpdatedData{Part: stepStartPart},
 	})
 	callback(state.message, state.parts)
 
-	for {
-		select {
-		case <-ctx.Done():
-			return "error", ctx.Err()
-		default:
-		}
-
+	for ctx.Err() == nil {
 		msg, err := stream.Recv()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return "error", err
+			if ctx.Err() != nil {
+				break // The read was cut short by the abort
+			}
+			return fail(err)
 		}
 
 		// Process the message chunk
@@ -90,6 +117,12 @@ func (p *Processor) processStream(
 				cachedTokens = usage.PromptTokenDetails.CachedTokens
 			}
 		}
+		if n := provider.ReasoningTokens(msg); n > reasoningTokens {
+			reasoningTokens = n
+		}
+		if n := provider.CacheWriteTokens(msg); n > cacheWriteTokens {
+			cacheWriteTokens = n
+		}
 
 		if finishReason != "" {
 			break
@@ -100,13 +133,29 @@ func (p *Processor) processStream(
 	if currentTextPart != nil {
 		now := time.Now().UnixMilli()
 		currentTextPart.Time.End = &now
-		p.savePart(ctx, state.message.ID, currentTextPart)
+		stagePart(currentTextPart)
 	}
 
 	if currentReasoningPart != nil {
 		now := time.Now().UnixMilli()
 		currentReasoningPart.Time.End = &now
-		p.savePart(ctx, state.message.ID, currentReasoningPart)
+		stagePart(currentReasoningPart)
+	}
+
+	// An aborted step keeps what was streamed; its tool calls will not run
+	if ctx.Err() != nil {
+		now := time.Now().UnixMilli()
+		for _, toolPart := range currentToolParts {
+			toolPart.State.Status = "error"
+			toolPart.State.Error = "Tool execution aborted"
+			if toolPart.State.Time == nil {
+				toolPart.State.Time = &types.ToolTime{Start: now}
+			}
+			toolPart.State.Time.End = &now
+			stagePart(toolPart)
+		}
+		stage([]string{"message", state.message.SessionID, state.message.ID}, state.message)
+		return p.keepAborted(ctx, tx, state, currentToolParts, stageErr, callback)
 	}
 
 	// Finalize tool parts
@@ -118,7 +167,7 @@ func (p *Processor) processStream(
 			}
 		}
 		toolPart.State.Status = "running"
-		p.savePart(ctx, state.message.ID, toolPart)
+		stagePart(toolPart)
 	}
 
 	// Determine finish reason from accumulated state
@@ -136,14 +185,21 @@ func (p *Processor) processStream(
 		finishReason = "tool-calls"
 	}
 
-	// Apply merged token usage after stream completes
+	// Apply merged token usage after stream completes. Providers count cached
+	// prompt tokens as input; they are kept apart since they are priced
+	// differently. The message keeps the last step's tokens and the cost of
+	// all its steps.
+	var stepCost float64
 	if hasUsage {
-		if state.message.Tokens == nil {
-			state.message.Tokens = &types.TokenUsage{}
+		tokens := usageTokens(inputTokens, completionTokens, cachedTokens, cacheWriteTokens)
+		// Reasoning tokens reported apart are kept out of the output
+		if reasoningTokens > 0 {
+			tokens.Reasoning = reasoningTokens
+			tokens.Output = max(completionTokens-reasoningTokens, 0)
 		}
-		state.message.Tokens.Input = inputTokens
-		state.message.Tokens.Output = completionTokens
-		state.message.Tokens.Cache.Read = cachedTokens
+		stepCost = model.Cost(&tokens)
+		state.message.Tokens = &tokens
+		state.message.Cost += stepCost
 	}
 
 	// Emit step-finish part at the end of inference with cost and token info
@@ -153,11 +209,23 @@ func (p *Processor) processStream(
 		MessageID: state.message.ID,
 		Type:      "step-finish",
 		Reason:    finishReason,
-		Cost:      state.message.Cost,
-		Tokens:    state.message.Tokens,
+		Cost:      stepCost,
+	}
+	if state.message.Tokens != nil {
+		tokens := *state.message.Tokens
+		stepFinishPart.Tokens = &tokens
 	}
 	state.parts = append(state.parts, stepFinishPart)
-	p.savePart(ctx, state.message.ID, stepFinishPart)
+	stagePart(stepFinishPart)
+	stage([]string{"message", state.message.SessionID, state.message.ID}, state.message)
+
+	if stageErr != nil {
+		return fail(fmt.Errorf("failed to stage step: %w", stageErr))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fail(fmt.Errorf("failed to commit step: %w", err))
+	}
+
 	event.PublishSync(event.Event{
 		Type: event.MessagePartUpdated,
 		Data: event.MessagePartUpdatedData{Part: stepFinishPart},
@@ -167,6 +235,66 @@ func (p *Processor) processStream(
 	return finishReason, nil
 }
 
+// keepAborted commits the staged parts of an aborted step, unless staging
+// failed, and returns the abort.
+func (p *Processor) keepAborted(
+	ctx context.Context,
+	tx storage.Tx,
+	state *sessionState,
+	toolParts map[string]*types.ToolPart,
+	stageErr error,
+	callback ProcessCallback,
+) (string, error) {
+	// The abort cancelled ctx; the commit must still go through
+	err := stageErr
+	if err == nil {
+		err = tx.Commit(context.WithoutCancel(ctx))
+	}
+	if err != nil {
+		logging.Warn().Err(err).Str("messageID", state.message.ID).Msg("Failed to keep the parts of an aborted step")
+	}
+
+	for _, toolPart := range toolParts {
+		event.PublishSync(event.Event{
+			Type: event.MessagePartUpdated,
+			Data: event.MessagePartUpdatedData{Part: toolPart},
+		})
+	}
+	callback(state.message, state.parts)
+	return "error", ctx.Err()
+}
+
+// dropParts removes the parts of a failed step, from n on, from the session
+// state and tells clients they are gone.
+func (p *Processor) dropParts(state *sessionState, n int, callback ProcessCallback) {
+	if len(state.parts) <= n {
+		return
+	}
+	dropped := state.parts[n:]
+	state.parts = state.parts[:n:n]
+	for _, part := range dropped {
+		event.PublishSync(event.Event{
+			Type: event.MessagePartRemoved,
+			Data: event.MessagePartRemovedData{
+				SessionID: state.message.SessionID,
+				MessageID: state.message.ID,
+				PartID:    part.PartID(),
+			},
+		})
+	}
+	callback(state.message, state.parts)
+}
+
+// usageTokens splits the prompt tokens reported by a provider into uncached
+// input, cache reads and cache writes.
+func usageTokens(prompt, completion, cached, written int) types.TokenUsage {
+	return types.TokenUsage{
+		Input:  max(prompt-cached-written, 0),
+		Output: completion,
+		Cache:  types.CacheUsage{Read: cached, Write: written},
+	}
+}
+
 // truncate truncates a string to the specified length.
 func truncate(s string, n int) string {
 	if len(s) <= n {
@@ -257,11 +385,18 @@ func (p *Processor) processMessageChunk(
 				Time:      types.PartTime{Start: &now},
 			}
 			state.parts = append(state.parts, *currentReasoningPart)
-			callback(state.message, state.parts)
 		} else {
-			(*currentReasoningPart).Text = msg.ReasoningContent
-			callback(state.message, state.parts)
+			// Providers stream reasoning as deltas
+			(*currentReasoningPart).Text += msg.ReasoningContent
 		}
+		event.PublishSync(event.Event{
+			Type: event.MessagePartUpdated,
+			Data: event.MessagePartUpdatedData{
+				Part:  *currentReasoningPart,
+				Delta: msg.ReasoningContent,
+			},
+		})
+		callback(state.message, state.parts)
 	}
 
 	// Handle tool calls
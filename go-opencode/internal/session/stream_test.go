@@ -0,0 +1,144 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/event"
+	"github.com/opencode-ai/opencode/internal/provider"
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/internal/tool"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// unwritableStore stages no writes in its transactions.
+type unwritableStore struct {
+	storage.Storage
+}
+
+func (s *unwritableStore) Begin(ctx context.Context) (storage.Tx, error) {
+	tx, err := s.Storage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &unwritableTx{Tx: tx}, nil
+}
+
+type unwritableTx struct {
+	storage.Tx
+}
+
+func (tx *unwritableTx) Put(ctx context.Context, path []string, v any) error {
+	return errors.New("disk full")
+}
+
+// streamOf returns a stream sending msgs, then failing with err if set.
+func streamOf(err error, msgs ...*schema.Message) *provider.CompletionStream {
+	sr, sw := schema.Pipe[*schema.Message](len(msgs) + 1)
+	for _, msg := range msgs {
+		sw.Send(msg, nil)
+	}
+	if err != nil {
+		sw.Send(nil, err)
+	}
+	sw.Close()
+	return provider.NewCompletionStream(sr)
+}
+
+func TestProcessor_StreamFailureDropsStep(t *testing.T) {
+	store := storage.New(t.TempDir())
+	ctx := context.Background()
+
+	var removed []string
+	unsubscribe := event.Subscribe(event.MessagePartRemoved, func(e event.Event) {
+		removed = append(removed, e.Data.(event.MessagePartRemovedData).PartID)
+	})
+	defer unsubscribe()
+
+	tests := []struct {
+		name    string
+		store   storage.Storage
+		stream  *provider.CompletionStream
+		removed int
+	}{
+		{
+			name:    "stream",
+			store:   store,
+			stream:  streamOf(errors.New("connection reset"), &schema.Message{Role: schema.Assistant, Content: "Half an answer"}),
+			removed: 2, // step-start and text
+		},
+		{
+			name:  "stage",
+			store: &unwritableStore{store},
+			stream: streamOf(nil,
+				&schema.Message{Role: schema.Assistant, Content: "An answer"},
+				&schema.Message{Role: schema.Assistant, ResponseMeta: &schema.ResponseMeta{FinishReason: "stop"}}),
+			removed: 3, // step-start, text and step-finish
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed = nil
+			p := NewProcessor(nil, tool.NewRegistry(t.TempDir(), tt.store), tt.store, nil, "", "")
+			earlier := &types.TextPart{ID: "prt0", SessionID: "ses1", MessageID: "msg-" + tt.name, Type: "text", Text: "earlier step"}
+			state := &sessionState{
+				message: &types.Message{ID: "msg-" + tt.name, SessionID: "ses1", Role: "assistant"},
+				parts:   []types.Part{earlier},
+			}
+
+			_, err := p.processStream(ctx, tt.stream, state, &types.Model{}, func(*types.Message, []types.Part) {})
+			require.Error(t, err)
+
+			// The step's parts are gone, and clients are told
+			assert.Equal(t, []types.Part{earlier}, state.parts)
+			assert.Len(t, removed, tt.removed)
+			parts, err := store.List(ctx, []string{"part", state.message.ID})
+			require.NoError(t, err)
+			assert.Empty(t, parts)
+		})
+	}
+}
+
+func TestProcessor_StreamAbortKeepsParts(t *testing.T) {
+	store := storage.New(t.TempDir())
+
+	var removed []string
+	unsubscribe := event.Subscribe(event.MessagePartRemoved, func(e event.Event) {
+		removed = append(removed, e.Data.(event.MessagePartRemovedData).PartID)
+	})
+	defer unsubscribe()
+
+	p := NewProcessor(nil, tool.NewRegistry(t.TempDir(), store), store, nil, "", "")
+	state := &sessionState{message: &types.Message{ID: "msg1", SessionID: "ses1", Role: "assistant"}}
+
+	// The user aborts once the answer starts showing
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := streamOf(nil,
+		&schema.Message{Role: schema.Assistant, Content: "Half an answer"},
+		&schema.Message{Role: schema.Assistant, Content: " never shown"})
+	_, err := p.processStream(ctx, stream, state, &types.Model{}, func(_ *types.Message, parts []types.Part) {
+		for _, part := range parts {
+			if _, ok := part.(*types.TextPart); ok {
+				cancel()
+			}
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// What was streamed stays, in the session and in storage
+	assert.Empty(t, removed)
+	require.Len(t, state.parts, 2)
+	text := state.parts[1].(*types.TextPart)
+	assert.Equal(t, "Half an answer", text.Text)
+	assert.NotNil(t, text.Time.End)
+
+	var stored types.TextPart
+	require.NoError(t, store.Get(context.Background(), []string{"part", "msg1", text.ID}, &stored))
+	assert.Equal(t, "Half an answer", stored.Text)
+}
@@ -65,6 +65,7 @@ func (p *Processor) ensureTitle(
 	}
 
 	// Create title generation request
+	ctx = provider.WithSession(ctx, session.ID, provider.PurposeTitle)
 	stream, err := prov.CreateCompletion(ctx, &provider.CompletionRequest{
 		Model: model.ID,
 		Messages: []*schema.Message{
@@ -10,7 +10,7 @@ import (
 )
 
 // GetTodos retrieves todos for a session.
-func GetTodos(ctx context.Context, store *storage.Storage, sessionID string) ([]types.TodoInfo, error) {
+func GetTodos(ctx context.Context, store storage.Storage, sessionID string) ([]types.TodoInfo, error) {
 	var todos []types.TodoInfo
 	err := store.Get(ctx, []string{"todo", sessionID}, &todos)
 	if err == storage.ErrNotFound {
@@ -23,7 +23,7 @@ func GetTodos(ctx context.Context, store *storage.Storage, sessionID string) ([]
 }
 
 // UpdateTodos updates todos for a session and publishes an event.
-func UpdateTodos(ctx context.Context, store *storage.Storage, sessionID string, todos []types.TodoInfo) error {
+func UpdateTodos(ctx context.Context, store storage.Storage, sessionID string, todos []types.TodoInfo) error {
 	if err := store.Put(ctx, []string{"todo", sessionID}, todos); err != nil {
 		return err
 	}
@@ -219,35 +219,24 @@ func (p *Processor) checkToolPermission(
 	var permType permission.PermissionType
 	var action permission.PermissionAction
 	var pattern []string
+	title := fmt.Sprintf("Allow %s?", toolPart.Tool)
+	var metadata map[string]any
 
 	switch toolPart.Tool {
-	case "Bash":
+	case "bash":
 		permType = permission.PermBash
-		if cmd, ok := toolPart.State.Input["command"].(string); ok {
-			pattern = []string{cmd}
-		}
-		switch agent.Permission.Bash {
-		case "allow":
-			action = permission.ActionAllow
-		case "deny":
-			action = permission.ActionDeny
-		default:
-			action = permission.ActionAsk
-		}
+		cmd, _ := toolPart.State.Input["command"].(string)
+		pattern = []string{cmd}
+		title = cmd
+		metadata = map[string]any{"command": cmd}
+		action = permissionAction(agent.BashPolicy(cmd))
 
-	case "Write", "Edit":
+	case "write", "edit":
 		permType = permission.PermEdit
 		if path, ok := toolPart.State.Input["filePath"].(string); ok {
 			pattern = []string{path}
 		}
-		switch agent.Permission.Write {
-		case "allow":
-			action = permission.ActionAllow
-		case "deny":
-			action = permission.ActionDeny
-		default:
-			action = permission.ActionAsk
-		}
+		action = permissionAction(agent.Permission.Write)
 
 	default:
 		// Other tools don't require permission
@@ -260,12 +249,26 @@ func (p *Processor) checkToolPermission(
 		SessionID: state.message.SessionID,
 		MessageID: state.message.ID,
 		CallID:    toolPart.CallID,
-		Title:     fmt.Sprintf("Allow %s?", toolPart.Tool),
+		Title:     title,
+		Metadata:  metadata,
 	}
 
 	return p.permissionChecker.Check(ctx, req, action)
 }
 
+// permissionAction converts an agent permission policy to the action taken
+// by the permission checker. Unset policies ask.
+func permissionAction(policy string) permission.PermissionAction {
+	switch policy {
+	case "allow":
+		return permission.ActionAllow
+	case "deny":
+		return permission.ActionDeny
+	default:
+		return permission.ActionAsk
+	}
+}
+
 // recordDiff captures file diffs from tool metadata and updates session summary/state.
 func (p *Processor) recordDiff(state *sessionState, toolPart *types.ToolPart) error {
 	if toolPart.State.Metadata == nil {
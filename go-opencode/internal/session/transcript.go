@@ -0,0 +1,294 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// transcriptEntry is a rendered part of a transcript.
+type transcriptEntry struct {
+	Kind   string // "text", "reasoning", "tool" or "file"
+	Text   string
+	Tool   string
+	Title  string
+	Input  string
+	Output string
+	Status string
+}
+
+// transcriptMessage is a message of a transcript with the parts worth reading.
+type transcriptMessage struct {
+	Role    string
+	Heading string
+	Time    string
+	Entries []transcriptEntry
+}
+
+// transcript turns a bundle into the messages shown in human-readable
+// exports. Step markers, snapshots and empty messages are left out.
+func transcript(b *Bundle) []transcriptMessage {
+	var out []transcriptMessage
+	for _, bm := range b.Messages {
+		if bm.Info == nil {
+			continue
+		}
+
+		msg := transcriptMessage{
+			Role:    bm.Info.Role,
+			Heading: "User",
+			Time:    formatTranscriptTime(bm.Info.Time.Created),
+		}
+		if bm.Info.Role == "assistant" {
+			msg.Heading = "Assistant"
+			var details []string
+			if bm.Info.Mode != "" {
+				details = append(details, bm.Info.Mode)
+			}
+			if bm.Info.ModelID != "" {
+				details = append(details, bm.Info.ProviderID+"/"+bm.Info.ModelID)
+			}
+			if len(details) > 0 {
+				msg.Heading += " (" + strings.Join(details, ", ") + ")"
+			}
+		}
+
+		for _, part := range bm.Parts {
+			switch p := part.(type) {
+			case *types.TextPart:
+				if strings.TrimSpace(p.Text) != "" {
+					msg.Entries = append(msg.Entries, transcriptEntry{Kind: "text", Text: p.Text})
+				}
+			case *types.ReasoningPart:
+				if strings.TrimSpace(p.Text) != "" {
+					msg.Entries = append(msg.Entries, transcriptEntry{Kind: "reasoning", Text: p.Text})
+				}
+			case *types.CompactionPart:
+				msg.Entries = append(msg.Entries, transcriptEntry{Kind: "text", Text: types.CompactionRequestText})
+			case *types.FilePart:
+				name := p.Filename
+				if name == "" && !strings.HasPrefix(p.URL, "data:") {
+					name = p.URL
+				}
+				if name == "" {
+					name = p.Mime
+				}
+				msg.Entries = append(msg.Entries, transcriptEntry{Kind: "file", Text: name})
+			case *types.ToolPart:
+				entry := transcriptEntry{
+					Kind:   "tool",
+					Tool:   p.Tool,
+					Title:  p.State.Title,
+					Status: p.State.Status,
+					Output: p.State.Output,
+				}
+				if p.State.Error != "" {
+					entry.Output = p.State.Error
+				}
+				if len(p.State.Input) > 0 {
+					input, _ := json.MarshalIndent(p.State.Input, "", "  ")
+					entry.Input = string(input)
+				}
+				msg.Entries = append(msg.Entries, entry)
+			}
+		}
+
+		if len(msg.Entries) > 0 {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+func formatTranscriptTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).Format("2006-01-02 15:04:05")
+}
+
+// fence returns a code fence longer than any backtick run in text.
+func fence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// WriteMarkdown writes a bundle as a Markdown transcript.
+func WriteMarkdown(w io.Writer, b *Bundle) error {
+	var sb strings.Builder
+
+	title := b.Info.Title
+	if title == "" {
+		title = "Session " + b.Info.ID
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "- Session: `%s`\n", b.Info.ID)
+	if b.Info.Directory != "" {
+		fmt.Fprintf(&sb, "- Directory: `%s`\n", b.Info.Directory)
+	}
+	if created := formatTranscriptTime(b.Info.Time.Created); created != "" {
+		fmt.Fprintf(&sb, "- Created: %s\n", created)
+	}
+	sb.WriteString("\n")
+
+	for _, msg := range transcript(b) {
+		sb.WriteString("---\n\n")
+		fmt.Fprintf(&sb, "## %s\n\n", msg.Heading)
+		if msg.Time != "" {
+			fmt.Fprintf(&sb, "_%s_\n\n", msg.Time)
+		}
+
+		for _, entry := range msg.Entries {
+			switch entry.Kind {
+			case "text":
+				sb.WriteString(strings.TrimSpace(entry.Text))
+				sb.WriteString("\n\n")
+			case "reasoning":
+				for _, line := range strings.Split(strings.TrimSpace(entry.Text), "\n") {
+					sb.WriteString("> " + line + "\n")
+				}
+				sb.WriteString("\n")
+			case "file":
+				fmt.Fprintf(&sb, "Attached: `%s`\n\n", entry.Text)
+			case "tool":
+				fmt.Fprintf(&sb, "**Tool: %s**", entry.Tool)
+				if entry.Title != "" {
+					fmt.Fprintf(&sb, " %s", entry.Title)
+				}
+				if entry.Status != "" && entry.Status != "completed" {
+					fmt.Fprintf(&sb, " (%s)", entry.Status)
+				}
+				sb.WriteString("\n\n")
+				if entry.Input != "" {
+					f := fence(entry.Input)
+					fmt.Fprintf(&sb, "%sjson\n%s\n%s\n\n", f, entry.Input, f)
+				}
+				if entry.Output != "" {
+					f := fence(entry.Output)
+					fmt.Fprintf(&sb, "%s\n%s\n%s\n\n", f, strings.TrimRight(entry.Output, "\n"), f)
+				}
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 860px; margin: 2em auto; padding: 0 1em; line-height: 1.5; color: #1f2328; }
+header dl { display: grid; grid-template-columns: max-content 1fr; gap: 0.25em 1em; color: #59636e; }
+header dd { margin: 0; }
+section { border-top: 1px solid #d1d9e0; padding: 1em 0; }
+section.user h2 { color: #0969da; }
+h2 { font-size: 1.1em; margin: 0; }
+time { color: #59636e; font-size: 0.85em; }
+.text { white-space: pre-wrap; }
+.reasoning { white-space: pre-wrap; color: #59636e; border-left: 3px solid #d1d9e0; padding-left: 1em; }
+details { margin: 0.5em 0; }
+summary { cursor: pointer; font-weight: 600; }
+pre { background: #f6f8fa; padding: 0.75em; overflow-x: auto; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>Session</dt><dd>{{.Session.ID}}</dd>
+{{- if .Session.Directory}}
+<dt>Directory</dt><dd>{{.Session.Directory}}</dd>
+{{- end}}
+{{- if .Created}}
+<dt>Created</dt><dd>{{.Created}}</dd>
+{{- end}}
+</dl>
+</header>
+{{- range .Messages}}
+<section class="{{.Role}}">
+<h2>{{.Heading}}</h2>
+{{- if .Time}}
+<time>{{.Time}}</time>
+{{- end}}
+{{- range .Entries}}
+{{- if eq .Kind "text"}}
+<div class="text">{{.Text}}</div>
+{{- else if eq .Kind "reasoning"}}
+<div class="reasoning">{{.Text}}</div>
+{{- else if eq .Kind "file"}}
+<p>Attached: <code>{{.Text}}</code></p>
+{{- else if eq .Kind "tool"}}
+<details>
+<summary>Tool: {{.Tool}}{{if .Title}} {{.Title}}{{end}}{{if and .Status (ne .Status "completed")}} ({{.Status}}){{end}}</summary>
+{{- if .Input}}
+<pre>{{.Input}}</pre>
+{{- end}}
+{{- if .Output}}
+<pre>{{.Output}}</pre>
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes a bundle as a standalone HTML transcript.
+func WriteHTML(w io.Writer, b *Bundle) error {
+	title := b.Info.Title
+	if title == "" {
+		title = "Session " + b.Info.ID
+	}
+	return transcriptHTML.Execute(w, struct {
+		Title    string
+		Session  *types.Session
+		Created  string
+		Messages []transcriptMessage
+	}{
+		Title:    title,
+		Session:  b.Info,
+		Created:  formatTranscriptTime(b.Info.Time.Created),
+		Messages: transcript(b),
+	})
+}
+
+// Export formats accepted by WriteExport.
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// WriteExport writes a bundle in one of the export formats. Only JSON bundles
+// can be imported again; compress gzips them.
+func WriteExport(w io.Writer, b *Bundle, format string, compress bool) error {
+	switch format {
+	case FormatJSON, "":
+		return WriteBundle(w, b, compress)
+	case FormatMarkdown, "md":
+		return WriteMarkdown(w, b)
+	case FormatHTML:
+		return WriteHTML(w, b)
+	default:
+		return fmt.Errorf("unknown export format %q: use json, markdown or html", format)
+	}
+}
@@ -0,0 +1,210 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// UsageFilter selects the assistant messages counted in a usage report.
+// Zero values match everything.
+type UsageFilter struct {
+	ProjectID string
+	Since     time.Time // Messages created at or after
+	Until     time.Time // Messages created before
+}
+
+// DaysAgo returns the start of the period covering the last days, today
+// included, in local time.
+func DaysAgo(days int) time.Time {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return today.AddDate(0, 0, 1-days)
+}
+
+// Usage is the cost and tokens of a set of assistant messages.
+type Usage struct {
+	Messages int              `json:"messages"`
+	Cost     float64          `json:"cost"` // USD
+	Tokens   types.TokenUsage `json:"tokens"`
+}
+
+func (u *Usage) add(other Usage) {
+	u.Messages += other.Messages
+	u.Cost += other.Cost
+	u.Tokens.Input += other.Tokens.Input
+	u.Tokens.Output += other.Tokens.Output
+	u.Tokens.Reasoning += other.Tokens.Reasoning
+	u.Tokens.Cache.Read += other.Tokens.Cache.Read
+	u.Tokens.Cache.Write += other.Tokens.Cache.Write
+}
+
+// SessionUsage is the usage of one session.
+type SessionUsage struct {
+	SessionID string `json:"sessionID"`
+	ProjectID string `json:"projectID"`
+	Title     string `json:"title"`
+	Usage
+}
+
+// ProjectUsage is the usage of all sessions of a project.
+type ProjectUsage struct {
+	ProjectID string `json:"projectID"`
+	Directory string `json:"directory"`
+	Usage
+}
+
+// DayUsage is the usage of the messages created on a day.
+type DayUsage struct {
+	Date string `json:"date"` // YYYY-MM-DD, local time
+	Usage
+}
+
+// UsageReport aggregates usage per session, per project and per day.
+// Sessions and projects are sorted by cost, most expensive first, and days
+// by date.
+type UsageReport struct {
+	Total    Usage          `json:"total"`
+	Sessions []SessionUsage `json:"sessions"`
+	Projects []ProjectUsage `json:"projects"`
+	Days     []DayUsage     `json:"days"`
+}
+
+// Usage reports the cost and tokens of the assistant messages matching
+// filter. Each message counts the steps it recorded; messages without steps,
+// such as summaries, count their own totals.
+func (s *Service) Usage(ctx context.Context, filter UsageFilter) (*UsageReport, error) {
+	projectIDs := []string{filter.ProjectID}
+	if filter.ProjectID == "" {
+		var err error
+		if projectIDs, err = s.storage.List(ctx, []string{"session"}); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &UsageReport{
+		Sessions: []SessionUsage{},
+		Projects: []ProjectUsage{},
+		Days:     []DayUsage{},
+	}
+	days := make(map[string]*DayUsage)
+
+	for _, projectID := range projectIDs {
+		var sessions []*types.Session
+		err := s.storage.Scan(ctx, []string{"session", projectID}, func(key string, data json.RawMessage) error {
+			var session types.Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				return err
+			}
+			sessions = append(sessions, &session)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		projectUsage := ProjectUsage{ProjectID: projectID}
+		for _, session := range sessions {
+			messages, err := s.GetMessages(ctx, session.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			sessionUsage := SessionUsage{
+				SessionID: session.ID,
+				ProjectID: projectID,
+				Title:     session.Title,
+			}
+			for _, msg := range messages {
+				if msg.Role != "assistant" || !filter.matches(msg.Time.Created) {
+					continue
+				}
+				usage, err := s.messageUsage(ctx, msg)
+				if err != nil {
+					return nil, err
+				}
+				sessionUsage.add(usage)
+
+				date := time.UnixMilli(msg.Time.Created).Format(time.DateOnly)
+				day, ok := days[date]
+				if !ok {
+					day = &DayUsage{Date: date}
+					days[date] = day
+				}
+				day.add(usage)
+			}
+			if sessionUsage.Messages == 0 {
+				continue
+			}
+
+			report.Sessions = append(report.Sessions, sessionUsage)
+			projectUsage.add(sessionUsage.Usage)
+			if projectUsage.Directory == "" {
+				projectUsage.Directory = session.Directory
+			}
+		}
+		if projectUsage.Messages > 0 {
+			report.Projects = append(report.Projects, projectUsage)
+			report.Total.add(projectUsage.Usage)
+		}
+	}
+
+	for _, day := range days {
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Sessions, func(i, j int) bool {
+		return report.Sessions[i].Cost > report.Sessions[j].Cost
+	})
+	sort.Slice(report.Projects, func(i, j int) bool {
+		return report.Projects[i].Cost > report.Projects[j].Cost
+	})
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Date < report.Days[j].Date
+	})
+	return report, nil
+}
+
+func (f UsageFilter) matches(created int64) bool {
+	t := time.UnixMilli(created)
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !t.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// messageUsage sums the steps of an assistant message.
+func (s *Service) messageUsage(ctx context.Context, msg *types.Message) (Usage, error) {
+	usage := Usage{Messages: 1}
+	parts, err := s.GetParts(ctx, msg.ID)
+	if err != nil {
+		return usage, err
+	}
+
+	steps := 0
+	for _, part := range parts {
+		step, ok := part.(*types.StepFinishPart)
+		if !ok {
+			continue
+		}
+		steps++
+		stepUsage := Usage{Cost: step.Cost}
+		if step.Tokens != nil {
+			stepUsage.Tokens = *step.Tokens
+		}
+		usage.add(stepUsage)
+	}
+
+	if steps == 0 {
+		usage.Cost = msg.Cost
+		if msg.Tokens != nil {
+			usage.Tokens = *msg.Tokens
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,85 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opencode-ai/opencode/internal/storage"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestUsageTokens_SplitsCachedInput(t *testing.T) {
+	tokens := usageTokens(12000, 500, 10000, 0)
+	assert.Equal(t, types.TokenUsage{Input: 2000, Output: 500, Cache: types.CacheUsage{Read: 10000}}, tokens)
+
+	model := &types.Model{InputPrice: 3, OutputPrice: 15, CacheReadPrice: 0.3}
+	assert.InDelta(t, (2000*3+500*15+10000*0.3)/1e6, model.Cost(&tokens), 1e-12)
+}
+
+func TestUsageTokens_SplitsCacheWrites(t *testing.T) {
+	tokens := usageTokens(12000, 500, 4000, 7000)
+	assert.Equal(t, types.TokenUsage{Input: 1000, Output: 500, Cache: types.CacheUsage{Read: 4000, Write: 7000}}, tokens)
+
+	model := &types.Model{InputPrice: 3, OutputPrice: 15, CacheReadPrice: 0.3, CacheWritePrice: 3.75}
+	assert.InDelta(t, (1000*3+500*15+4000*0.3+7000*3.75)/1e6, model.Cost(&tokens), 1e-12)
+}
+
+func TestService_Usage(t *testing.T) {
+	store := storage.New(t.TempDir())
+	service := NewService(store)
+	ctx := context.Background()
+
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local).UnixMilli()
+	day2 := time.Date(2025, 3, 2, 10, 0, 0, 0, time.Local).UnixMilli()
+	step := func(msgID, id string, cost float64, input, output, cacheRead int) *types.StepFinishPart {
+		return &types.StepFinishPart{ID: id, MessageID: msgID, Type: "step-finish", Cost: cost,
+			Tokens: &types.TokenUsage{Input: input, Output: output, Cache: types.CacheUsage{Read: cacheRead}}}
+	}
+
+	require.NoError(t, store.Put(ctx, []string{"session", "proj1", "ses1"},
+		&types.Session{ID: "ses1", ProjectID: "proj1", Directory: "/work/one", Title: "First"}))
+	require.NoError(t, store.Put(ctx, []string{"session", "proj2", "ses2"},
+		&types.Session{ID: "ses2", ProjectID: "proj2", Directory: "/work/two", Title: "Second"}))
+
+	putMessage(t, store, &types.Message{ID: "msg1", SessionID: "ses1", Role: "user", Time: types.MessageTime{Created: day1}})
+	putMessage(t, store, &types.Message{ID: "msg2", SessionID: "ses1", Role: "assistant", Time: types.MessageTime{Created: day1}},
+		step("msg2", "prt1", 0.25, 1000, 100, 5000),
+		step("msg2", "prt2", 0.5, 2000, 200, 6000))
+	// A summary records its usage on the message only
+	putMessage(t, store, &types.Message{ID: "msg3", SessionID: "ses1", Role: "assistant", IsSummary: true, Cost: 0.125,
+		Tokens: &types.TokenUsage{Input: 400, Output: 40}, Time: types.MessageTime{Created: day2}})
+	putMessage(t, store, &types.Message{ID: "msg4", SessionID: "ses2", Role: "assistant", Time: types.MessageTime{Created: day2}},
+		step("msg4", "prt3", 2, 10000, 1000, 0))
+
+	report, err := service.Usage(ctx, UsageFilter{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.Total.Messages)
+	assert.InDelta(t, 2.875, report.Total.Cost, 1e-9)
+	assert.Equal(t, 13400, report.Total.Tokens.Input)
+	assert.Equal(t, 11000, report.Total.Tokens.Cache.Read)
+
+	require.Len(t, report.Sessions, 2)
+	assert.Equal(t, "ses2", report.Sessions[0].SessionID, "most expensive first")
+	assert.InDelta(t, 0.875, report.Sessions[1].Cost, 1e-9)
+
+	require.Len(t, report.Projects, 2)
+	assert.Equal(t, "/work/two", report.Projects[0].Directory)
+
+	require.Len(t, report.Days, 2)
+	assert.Equal(t, "2025-03-01", report.Days[0].Date)
+	assert.InDelta(t, 0.75, report.Days[0].Cost, 1e-9)
+	assert.InDelta(t, 2.125, report.Days[1].Cost, 1e-9)
+
+	// Filters by project and creation time
+	report, err = service.Usage(ctx, UsageFilter{ProjectID: "proj1", Since: time.UnixMilli(day2)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Total.Messages)
+	assert.InDelta(t, 0.125, report.Total.Cost, 1e-9)
+	require.Len(t, report.Sessions, 1)
+	assert.Equal(t, "ses1", report.Sessions[0].SessionID)
+}
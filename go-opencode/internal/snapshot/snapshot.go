@@ -0,0 +1,248 @@
+// Package snapshot records the files of a project so session changes can be
+// rolled back.
+//
+// Snapshots are git trees written to a shadow repository kept outside the
+// project, one per project, so the user's own repository, index and history
+// are never touched. Files ignored by the project's .gitignore are not
+// recorded. Like the TypeScript implementation, snapshots are only taken in
+// git repositories.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/project"
+)
+
+// ErrNotRepository is returned for directories outside a git repository.
+var ErrNotRepository = errors.New("snapshots require a git repository")
+
+// Store takes and restores snapshots. The shadow repositories live under its
+// directory, named by project ID.
+type Store struct {
+	dir string
+
+	// Git operations on a shadow repository share its index
+	mu sync.Mutex
+}
+
+// New creates a store that keeps shadow repositories under dir.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// repo is the shadow repository of a project.
+type repo struct {
+	gitDir   string
+	worktree string
+}
+
+// open returns the shadow repository for the project containing directory,
+// creating it on first use. Must be called with mu held.
+func (s *Store) open(ctx context.Context, directory string) (*repo, error) {
+	info, err := project.FromDirectory(directory)
+	if err != nil {
+		return nil, err
+	}
+	if info.VCS == nil || *info.VCS != "git" {
+		return nil, ErrNotRepository
+	}
+
+	r := &repo{gitDir: filepath.Join(s.dir, info.ID), worktree: info.Worktree}
+	if _, err := os.Stat(r.gitDir); err == nil {
+		return r, nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := r.git(ctx, nil, "init", "--quiet"); err != nil {
+		os.RemoveAll(r.gitDir)
+		return nil, err
+	}
+	return r, nil
+}
+
+// git runs a git command against the shadow repository.
+func (r *repo) git(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	command := args[0]
+	args = append([]string{
+		"-c", "core.autocrlf=false",
+		"-c", "core.quotepath=false",
+		"--git-dir", r.gitDir,
+		"--work-tree", r.worktree,
+	}, args...)
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.worktree
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// track writes the current files to a tree. Must be called with mu held.
+func (r *repo) track(ctx context.Context) (string, error) {
+	if _, err := r.git(ctx, nil, "add", "--all", "."); err != nil {
+		return "", err
+	}
+	out, err := r.git(ctx, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Track records the files of the project containing directory and returns
+// the snapshot hash.
+func (s *Store) Track(ctx context.Context, directory string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.open(ctx, directory)
+	if err != nil {
+		return "", err
+	}
+	return r.track(ctx)
+}
+
+// Diff returns the unified diff from one snapshot to another.
+func (s *Store) Diff(ctx context.Context, directory, from, to string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.open(ctx, directory)
+	if err != nil {
+		return "", err
+	}
+	out, err := r.git(ctx, nil, "diff", "--no-ext-diff", "--no-color", from, to, "--", ".")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Restore brings the files of the project back to a snapshot. Only files that
+// differ from the snapshot are written; files created since are removed.
+// Returns the paths that changed, relative to the project worktree.
+func (s *Store) Restore(ctx context.Context, directory, hash string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.open(ctx, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := r.track(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == hash {
+		return nil, nil
+	}
+
+	out, err := r.git(ctx, nil, "diff-tree", "-r", "-z", "--no-renames", "--name-status", current, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Output is NUL separated status and path pairs
+	var checkout bytes.Buffer
+	var changed []string
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, path := fields[i], fields[i+1]
+		changed = append(changed, path)
+		if status == "D" {
+			if err := os.Remove(filepath.Join(r.worktree, path)); err != nil && !os.IsNotExist(err) {
+				return changed, err
+			}
+			continue
+		}
+		checkout.WriteString(path)
+		checkout.WriteByte(0)
+	}
+
+	if checkout.Len() > 0 {
+		if _, err := r.git(ctx, checkout.Bytes(), "checkout", hash, "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// Export packs the given snapshots, with every file they contain, into a git
+// pack. Snapshots missing from the shadow repository are skipped. Returns nil
+// when there is nothing to pack.
+func (s *Store) Export(ctx context.Context, directory string, hashes []string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.open(ctx, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var objects bytes.Buffer
+	add := func(hash string) {
+		if !seen[hash] {
+			seen[hash] = true
+			objects.WriteString(hash)
+			objects.WriteByte('\n')
+		}
+	}
+
+	for _, hash := range hashes {
+		if seen[hash] {
+			continue
+		}
+		// Entries are "<mode> <type> <hash>\t<path>", NUL terminated
+		out, err := r.git(ctx, nil, "ls-tree", "-r", "-t", "-z", hash)
+		if err != nil {
+			continue
+		}
+		add(hash)
+		for _, entry := range strings.Split(string(out), "\x00") {
+			meta, _, ok := strings.Cut(entry, "\t")
+			if fields := strings.Fields(meta); ok && len(fields) == 3 {
+				add(fields[2])
+			}
+		}
+	}
+
+	if objects.Len() == 0 {
+		return nil, nil
+	}
+	return r.git(ctx, objects.Bytes(), "pack-objects", "--stdout", "--quiet")
+}
+
+// Import adds the snapshots of a pack created by Export to the shadow
+// repository of the project containing directory.
+func (s *Store) Import(ctx context.Context, directory string, pack []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.open(ctx, directory)
+	if err != nil {
+		return err
+	}
+	_, err = r.git(ctx, pack, "index-pack", "--stdin")
+	return err
+}
@@ -0,0 +1,139 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProject creates a git repository with one commit so it has a project ID.
+func newProject(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "README.md", "hello\n")
+	writeFile(t, dir, ".gitignore", "build/\n")
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestStore_TrackAndRestore(t *testing.T) {
+	dir := newProject(t)
+	store := New(t.TempDir())
+	ctx := context.Background()
+
+	writeFile(t, dir, "src/main.go", "package main\n")
+	before, err := store.Track(ctx, dir)
+	require.NoError(t, err)
+
+	// Unchanged files give the same snapshot
+	again, err := store.Track(ctx, filepath.Join(dir, "src"))
+	require.NoError(t, err)
+	assert.Equal(t, before, again)
+
+	writeFile(t, dir, "src/main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, dir, "src/new.go", "package main\n")
+	require.NoError(t, os.Remove(filepath.Join(dir, "README.md")))
+	writeFile(t, dir, "build/out.bin", "ignored")
+	after, err := store.Track(ctx, dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+
+	diff, err := store.Diff(ctx, dir, after, before)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "-func main() {}")
+	assert.Contains(t, diff, "deleted file mode 100644")
+	assert.NotContains(t, diff, "build/out.bin")
+
+	changed, err := store.Restore(ctx, dir, before)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"README.md", "src/main.go", "src/new.go"}, changed)
+	assert.Equal(t, "package main\n", readFile(t, dir, "src/main.go"))
+	assert.Equal(t, "hello\n", readFile(t, dir, "README.md"))
+	assert.NoFileExists(t, filepath.Join(dir, "src/new.go"))
+	assert.FileExists(t, filepath.Join(dir, "build/out.bin"))
+
+	// And forward again
+	_, err = store.Restore(ctx, dir, after)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {}\n", readFile(t, dir, "src/main.go"))
+	assert.FileExists(t, filepath.Join(dir, "src/new.go"))
+	assert.NoFileExists(t, filepath.Join(dir, "README.md"))
+
+	// The project's own repository is left alone
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "?? src/")
+}
+
+func TestStore_RequiresRepository(t *testing.T) {
+	store := New(t.TempDir())
+	_, err := store.Track(context.Background(), t.TempDir())
+	assert.ErrorIs(t, err, ErrNotRepository)
+}
+
+func TestStore_ExportImport(t *testing.T) {
+	dir := newProject(t)
+	ctx := context.Background()
+
+	source := New(t.TempDir())
+	writeFile(t, dir, "a.txt", "first\n")
+	first, err := source.Track(ctx, dir)
+	require.NoError(t, err)
+	writeFile(t, dir, "a.txt", "second\n")
+	second, err := source.Track(ctx, dir)
+	require.NoError(t, err)
+
+	pack, err := source.Export(ctx, dir, []string{first, second, first, "0000000000000000000000000000000000000000"})
+	require.NoError(t, err)
+	require.NotEmpty(t, pack)
+
+	target := New(t.TempDir())
+	require.NoError(t, target.Import(ctx, dir, pack))
+
+	diff, err := target.Diff(ctx, dir, first, second)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "-first")
+	assert.Contains(t, diff, "+second")
+
+	_, err = target.Restore(ctx, dir, first)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", readFile(t, dir, "a.txt"))
+
+	empty, err := source.Export(ctx, dir, nil)
+	require.NoError(t, err)
+	assert.Nil(t, empty)
+}
@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// DefaultKeyEnv is the environment variable read for the encryption key when
+// the config names neither a key file nor a variable.
+const DefaultKeyEnv = "OPENCODE_STORAGE_KEY"
+
+// cipherAESGCM identifies the envelope format written by EncryptedStorage.
+const cipherAESGCM = "aes-256-gcm"
+
+// ErrDecrypt is returned when a stored record cannot be decrypted, either
+// because its key is not configured or because it has been tampered with.
+var ErrDecrypt = errors.New("failed to decrypt record")
+
+// envelope is the stored form of an encrypted record. It is itself JSON so
+// both backends keep treating records as JSON documents.
+type envelope struct {
+	Encrypted string `json:"encrypted"`
+	KeyID     string `json:"kid"`
+	Nonce     []byte `json:"nonce"`
+	Data      []byte `json:"data"`
+}
+
+// encryptionKey is an AES-256-GCM key and its identifier.
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// EncryptedStorage wraps a backend and encrypts every record it writes with
+// AES-256-GCM. Paths are left in the clear so the on-disk layout and the
+// List/Delete/Exists behaviour of the backend are unchanged. Each record is
+// bound to its path, so a record copied to another path fails to decrypt.
+//
+// Records written before encryption was enabled are read as plaintext until
+// Reencrypt rewrites them.
+type EncryptedStorage struct {
+	inner   Storage
+	primary *encryptionKey
+	keys    map[string]*encryptionKey
+}
+
+// NewEncrypted wraps inner so records are encrypted with key. Records written
+// under any of oldKeys can still be read; Reencrypt moves them to key.
+func NewEncrypted(inner Storage, key []byte, oldKeys ...[]byte) (*EncryptedStorage, error) {
+	primary, err := newEncryptionKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EncryptedStorage{
+		inner:   inner,
+		primary: primary,
+		keys:    map[string]*encryptionKey{primary.id: primary},
+	}
+
+	for _, old := range oldKeys {
+		k, err := newEncryptionKey(old)
+		if err != nil {
+			return nil, err
+		}
+		s.keys[k.id] = k
+	}
+
+	return s, nil
+}
+
+func newEncryptionKey(key []byte) (*encryptionKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID only tells keys apart; it does not reveal the key.
+	sum := sha256.Sum256(key)
+	return &encryptionKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// ParseKey decodes a base64 encoded 32 byte key, as produced by
+// "openssl rand -base64 32". Surrounding whitespace is ignored.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// LoadKey reads the key named by cfg: the key file if set, otherwise the
+// environment variable cfg.KeyEnv or DefaultKeyEnv.
+func LoadKey(cfg *types.EncryptionConfig) ([]byte, error) {
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
+		return ParseKey(string(data))
+	}
+
+	env := cfg.KeyEnv
+	if env == "" {
+		env = DefaultKeyEnv
+	}
+	value := os.Getenv(env)
+	if value == "" {
+		return nil, fmt.Errorf("encryption key not set: %s is empty", env)
+	}
+	return ParseKey(value)
+}
+
+// seal encrypts data for the record at path with the primary key.
+func (s *EncryptedStorage) seal(path []string, data []byte) (*envelope, error) {
+	nonce := make([]byte, s.primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &envelope{
+		Encrypted: cipherAESGCM,
+		KeyID:     s.primary.id,
+		Nonce:     nonce,
+		Data:      s.primary.aead.Seal(nil, nonce, data, recordAAD(path)),
+	}, nil
+}
+
+// open returns the plaintext of a stored record. Records that are not
+// envelopes are returned unchanged.
+func (s *EncryptedStorage) open(path []string, data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+
+	key, ok := s.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %s: unknown key %s", ErrDecrypt, strings.Join(path, "/"), env.KeyID)
+	}
+
+	plain, err := key.aead.Open(nil, env.Nonce, env.Data, recordAAD(path))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrDecrypt, strings.Join(path, "/"), err)
+	}
+	return plain, nil
+}
+
+// parseEnvelope reports whether data is an encrypted envelope.
+func parseEnvelope(data []byte) (*envelope, bool) {
+	if !bytes.Contains(data, []byte(`"encrypted"`)) {
+		return nil, false
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Encrypted != cipherAESGCM {
+		return nil, false
+	}
+	return &env, true
+}
+
+// recordAAD binds a ciphertext to the path it was written at.
+func recordAAD(path []string) []byte {
+	return []byte(strings.Join(path, "/"))
+}
+
+// Get reads and decrypts a value from storage.
+func (s *EncryptedStorage) Get(ctx context.Context, path []string, v any) error {
+	var raw json.RawMessage
+	if err := s.inner.Get(ctx, path, &raw); err != nil {
+		return err
+	}
+
+	data, err := s.open(path, raw)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	return nil
+}
+
+// Put encrypts and stores a value.
+func (s *EncryptedStorage) Put(ctx context.Context, path []string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	env, err := s.seal(path, data)
+	if err != nil {
+		return err
+	}
+	return s.inner.Put(ctx, path, env)
+}
+
+// Delete removes a value from storage.
+func (s *EncryptedStorage) Delete(ctx context.Context, path []string) error {
+	return s.inner.Delete(ctx, path)
+}
+
+// List returns all items at a path.
+func (s *EncryptedStorage) List(ctx context.Context, path []string) ([]string, error) {
+	return s.inner.List(ctx, path)
+}
+
+// Scan iterates over all items at a path, decrypting each.
+func (s *EncryptedStorage) Scan(ctx context.Context, path []string, fn func(key string, data json.RawMessage) error) error {
+	return s.inner.Scan(ctx, path, s.decrypting(path, fn))
+}
+
+// Query iterates over items at a path filtered and ordered by update time, decrypting each.
+func (s *EncryptedStorage) Query(ctx context.Context, path []string, opts QueryOptions, fn func(key string, data json.RawMessage) error) error {
+	return s.inner.Query(ctx, path, opts, s.decrypting(path, fn))
+}
+
+// decrypting wraps a Scan callback so it receives plaintext records.
+func (s *EncryptedStorage) decrypting(path []string, fn func(key string, data json.RawMessage) error) func(key string, data json.RawMessage) error {
+	return func(key string, data json.RawMessage) error {
+		plain, err := s.open(childPath(path, key), data)
+		if err != nil {
+			return err
+		}
+		return fn(key, plain)
+	}
+}
+
+// Exists checks if a path exists.
+func (s *EncryptedStorage) Exists(ctx context.Context, path []string) bool {
+	return s.inner.Exists(ctx, path)
+}
+
+// Begin starts a transaction whose writes are encrypted.
+func (s *EncryptedStorage) Begin(ctx context.Context) (Tx, error) {
+	tx, err := s.inner.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedTx{s: s, tx: tx}, nil
+}
+
+// Close closes the wrapped backend.
+func (s *EncryptedStorage) Close() error {
+	return s.inner.Close()
+}
+
+// Reencrypt rewrites every record that is plaintext or encrypted under an
+// old key with the primary key, and returns the number rewritten. It can be
+// rerun after a failure; records already under the primary key are skipped.
+// Rewriting refreshes record update times, which Query orders by.
+func (s *EncryptedStorage) Reencrypt(ctx context.Context) (int, error) {
+	n := 0
+	err := Walk(ctx, s.inner, nil, func(path []string, data json.RawMessage) error {
+		if env, ok := parseEnvelope(data); ok && env.KeyID == s.primary.id {
+			return nil
+		}
+
+		plain, err := s.open(path, data)
+		if err != nil {
+			return err
+		}
+		env, err := s.seal(path, plain)
+		if err != nil {
+			return err
+		}
+		if err := s.inner.Put(ctx, path, env); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// encryptedTx encrypts writes before buffering them in the wrapped transaction.
+type encryptedTx struct {
+	s  *EncryptedStorage
+	tx Tx
+}
+
+func (t *encryptedTx) Put(ctx context.Context, path []string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	env, err := t.s.seal(path, data)
+	if err != nil {
+		return err
+	}
+	return t.tx.Put(ctx, path, env)
+}
+
+func (t *encryptedTx) Delete(ctx context.Context, path []string) error {
+	return t.tx.Delete(ctx, path)
+}
+
+func (t *encryptedTx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *encryptedTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// VerifyFailure is a record that could not be read.
+type VerifyFailure struct {
+	Path string
+	Err  error
+}
+
+// VerifyReport summarizes a storage verification.
+type VerifyReport struct {
+	Records   int // Records checked
+	Encrypted int // Records stored encrypted
+	Plaintext int // Records stored in the clear
+	Failures  []VerifyFailure
+}
+
+// Verify reads every record in s and checks that it decrypts and parses as
+// JSON. Unreadable records are collected in the report rather than stopping
+// the walk. Encrypted records in an unencrypted storage are failures.
+func Verify(ctx context.Context, s Storage) (*VerifyReport, error) {
+	enc, _ := s.(*EncryptedStorage)
+	raw := s
+	if enc != nil {
+		raw = enc.inner
+	}
+
+	report := &VerifyReport{}
+	err := Walk(ctx, raw, nil, func(path []string, data json.RawMessage) error {
+		report.Records++
+
+		fail := func(err error) {
+			report.Failures = append(report.Failures, VerifyFailure{Path: strings.Join(path, "/"), Err: err})
+		}
+
+		if _, ok := parseEnvelope(data); ok {
+			report.Encrypted++
+			if enc == nil {
+				fail(fmt.Errorf("%w: encryption is not configured", ErrDecrypt))
+				return nil
+			}
+			plain, err := enc.open(path, data)
+			if err != nil {
+				fail(err)
+				return nil
+			}
+			data = plain
+		} else {
+			report.Plaintext++
+		}
+
+		if !json.Valid(data) {
+			fail(errors.New("record is not valid JSON"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
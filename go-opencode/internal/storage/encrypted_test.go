@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncrypted_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	for name, inner := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewEncrypted(inner, newKey(t))
+			if err != nil {
+				t.Fatalf("NewEncrypted failed: %v", err)
+			}
+
+			data := testData{ID: "1", Name: "secret token", Value: 7}
+			if err := s.Put(ctx, []string{"items", "a"}, data); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			tx, err := s.Begin(ctx)
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+			tx.Put(ctx, []string{"items", "b"}, testData{ID: "2", Name: "secret token"})
+			if err := tx.Commit(ctx); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+
+			var got testData
+			if err := s.Get(ctx, []string{"items", "a"}, &got); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if got != data {
+				t.Errorf("Get = %+v, want %+v", got, data)
+			}
+
+			var ids []string
+			err = s.Scan(ctx, []string{"items"}, func(key string, raw json.RawMessage) error {
+				var d testData
+				if err := json.Unmarshal(raw, &d); err != nil {
+					return err
+				}
+				ids = append(ids, d.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			if len(ids) != 2 {
+				t.Errorf("Scan visited %v, want 2 records", ids)
+			}
+
+			// The backend only ever sees ciphertext
+			err = inner.Scan(ctx, []string{"items"}, func(key string, raw json.RawMessage) error {
+				if bytes.Contains(raw, []byte("secret token")) {
+					t.Errorf("record %s stored in plaintext: %s", key, raw)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncrypted_WrongKeyOrPath(t *testing.T) {
+	ctx := context.Background()
+	inner := New(t.TempDir())
+
+	s, _ := NewEncrypted(inner, newKey(t))
+	if err := s.Put(ctx, []string{"items", "a"}, testData{ID: "1"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	other, _ := NewEncrypted(inner, newKey(t))
+	var got testData
+	if err := other.Get(ctx, []string{"items", "a"}, &got); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get with wrong key = %v, want ErrDecrypt", err)
+	}
+
+	// A ciphertext moved to another path is rejected
+	var raw json.RawMessage
+	inner.Get(ctx, []string{"items", "a"}, &raw)
+	inner.Put(ctx, []string{"items", "b"}, raw)
+	if err := s.Get(ctx, []string{"items", "b"}, &got); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get of moved record = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncrypted_ReencryptAndVerify(t *testing.T) {
+	ctx := context.Background()
+
+	for name, inner := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Plaintext written before encryption was enabled
+			inner.Put(ctx, []string{"session", "proj1", "ses1"}, testData{ID: "ses1"})
+
+			oldKey, newKey := newKey(t), newKey(t)
+			old, _ := NewEncrypted(inner, oldKey)
+			old.Put(ctx, []string{"message", "ses1", "msg1"}, testData{ID: "msg1"})
+			old.Put(ctx, []string{"part", "msg1", "prt1"}, testData{ID: "prt1"})
+
+			report, err := Verify(ctx, old)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if report.Records != 3 || report.Encrypted != 2 || report.Plaintext != 1 || len(report.Failures) != 0 {
+				t.Errorf("Verify = %+v", report)
+			}
+
+			rotated, _ := NewEncrypted(inner, newKey, oldKey)
+			n, err := rotated.Reencrypt(ctx)
+			if err != nil {
+				t.Fatalf("Reencrypt failed: %v", err)
+			}
+			if n != 3 {
+				t.Errorf("Reencrypt rewrote %d records, want 3", n)
+			}
+
+			// Rerunning is a no-op
+			if n, _ := rotated.Reencrypt(ctx); n != 0 {
+				t.Errorf("second Reencrypt rewrote %d records, want 0", n)
+			}
+
+			// The old key is no longer needed
+			current, _ := NewEncrypted(inner, newKey)
+			report, _ = Verify(ctx, current)
+			if report.Records != 3 || report.Encrypted != 3 || len(report.Failures) != 0 {
+				t.Errorf("Verify after rotation = %+v", report)
+			}
+
+			var got testData
+			if err := current.Get(ctx, []string{"part", "msg1", "prt1"}, &got); err != nil || got.ID != "prt1" {
+				t.Errorf("Get after rotation = %+v, %v", got, err)
+			}
+
+			// Without the key every record fails
+			report, _ = Verify(ctx, inner)
+			if len(report.Failures) != 3 {
+				t.Errorf("Verify without key reported %d failures, want 3", len(report.Failures))
+			}
+		})
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	key := newKey(t)
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	keyFile := filepath.Join(t.TempDir(), "storage.key")
+	os.WriteFile(keyFile, []byte(encoded+"\n"), 0600)
+
+	got, err := LoadKey(&types.EncryptionConfig{KeyFile: keyFile})
+	if err != nil || !bytes.Equal(got, key) {
+		t.Errorf("LoadKey(file) = %x, %v", got, err)
+	}
+
+	t.Setenv(DefaultKeyEnv, encoded)
+	got, err = LoadKey(&types.EncryptionConfig{})
+	if err != nil || !bytes.Equal(got, key) {
+		t.Errorf("LoadKey(env) = %x, %v", got, err)
+	}
+
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString(key[:16])); err == nil {
+		t.Error("ParseKey accepted a 16 byte key")
+	}
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+// FileStorage stores each record as an indented JSON file under basePath,
+// matching the TypeScript on-disk layout.
+type FileStorage struct {
+	basePath string
+	mu       sync.RWMutex
+	locks    map[string]*FileLock
+
+	// commitMu is held exclusively while a transaction applies its renames
+	// and shared by every other operation, so no reader sees a partial commit.
+	commitMu sync.RWMutex
+}
+
+// New creates a new file-backed storage rooted at basePath.
+// Transactions interrupted by a crash are completed before it returns.
+func New(basePath string) *FileStorage {
+	s := &FileStorage{
+		basePath: basePath,
+		locks:    make(map[string]*FileLock),
+	}
+
+	if err := s.recoverJournals(); err != nil {
+		logging.Warn().Err(err).Str("path", basePath).Msg("Failed to recover storage journal")
+	}
+
+	return s
+}
+
+// pathToFile converts a path slice to a file path.
+func (s *FileStorage) pathToFile(path []string) string {
+	parts := append([]string{s.basePath}, path...)
+	return filepath.Join(parts...) + ".json"
+}
+
+// pathToDir converts a path slice to a directory path.
+func (s *FileStorage) pathToDir(path []string) string {
+	parts := append([]string{s.basePath}, path...)
+	return filepath.Join(parts...)
+}
+
+// Get retrieves a value from storage.
+func (s *FileStorage) Get(ctx context.Context, path []string, v any) error {
+	filePath := s.pathToFile(path)
+
+	s.commitMu.RLock()
+	data, err := os.ReadFile(filePath)
+	s.commitMu.RUnlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// Put stores a value in storage with file locking.
+func (s *FileStorage) Put(ctx context.Context, path []string, v any) error {
+	filePath := s.pathToFile(path)
+
+	// Ensure directory exists
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
+
+	// Acquire lock
+	lock := s.getLock(filePath)
+	if err := lock.Lock(); err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer lock.Unlock()
+
+	// Marshal data
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	// Write to temp file first, then rename (atomic operation)
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath) // Clean up temp file
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a value from storage.
+func (s *FileStorage) Delete(ctx context.Context, path []string) error {
+	filePath := s.pathToFile(path)
+
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
+
+	// Acquire lock
+	lock := s.getLock(filePath)
+	if err := lock.Lock(); err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer lock.Unlock()
+
+	if err := os.Remove(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Already deleted
+		}
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all items at a path.
+func (s *FileStorage) List(ctx context.Context, path []string) ([]string, error) {
+	dirPath := s.pathToDir(path)
+
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var items []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue // Internal, e.g. the transaction journal
+		}
+		if entry.IsDir() {
+			items = append(items, name)
+		} else if strings.HasSuffix(name, ".json") {
+			items = append(items, strings.TrimSuffix(name, ".json"))
+		}
+	}
+
+	return items, nil
+}
+
+// Scan iterates over all items at a path.
+func (s *FileStorage) Scan(ctx context.Context, path []string, fn func(key string, data json.RawMessage) error) error {
+	dirPath := s.pathToDir(path)
+
+	s.commitMu.RLock()
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		s.commitMu.RUnlock()
+		if os.IsNotExist(err) {
+			return nil // Nothing to scan
+		}
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		keys = append(keys, strings.TrimSuffix(name, ".json"))
+	}
+
+	records := readRecords(dirPath, keys)
+	s.commitMu.RUnlock()
+
+	return visitRecords(records, fn)
+}
+
+// Query iterates over items at a path filtered and ordered by modification time.
+// The file backend has no index, so this stats every entry in the directory.
+func (s *FileStorage) Query(ctx context.Context, path []string, opts QueryOptions, fn func(key string, data json.RawMessage) error) error {
+	dirPath := s.pathToDir(path)
+
+	s.commitMu.RLock()
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		s.commitMu.RUnlock()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	type candidate struct {
+		key     string
+		updated int64
+	}
+
+	var candidates []candidate
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		updated := info.ModTime().UnixMilli()
+		if !opts.matches(updated) {
+			continue
+		}
+		candidates = append(candidates, candidate{key: strings.TrimSuffix(name, ".json"), updated: updated})
+	}
+
+	// Ties are broken by key, as in the SQLite backend
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if opts.Descending {
+			a, b = b, a
+		}
+		if a.updated != b.updated {
+			return a.updated < b.updated
+		}
+		return a.key < b.key
+	})
+
+	if opts.Limit > 0 && len(candidates) > opts.Limit {
+		candidates = candidates[:opts.Limit]
+	}
+
+	keys := make([]string, len(candidates))
+	for i, c := range candidates {
+		keys[i] = c.key
+	}
+
+	records := readRecords(dirPath, keys)
+	s.commitMu.RUnlock()
+
+	return visitRecords(records, fn)
+}
+
+// Exists checks if a path exists.
+func (s *FileStorage) Exists(ctx context.Context, path []string) bool {
+	filePath := s.pathToFile(path)
+
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
+
+	_, err := os.Stat(filePath)
+	return err == nil
+}
+
+// Close is a no-op for the file backend.
+func (s *FileStorage) Close() error {
+	return nil
+}
+
+// getLock returns a file lock for a path.
+func (s *FileStorage) getLock(filePath string) *FileLock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[filePath]
+	if !ok {
+		lock = NewFileLock(filePath)
+		s.locks[filePath] = lock
+	}
+
+	return lock
+}
+
+// readRecords reads the named records in dirPath, skipping any that were
+// removed or cannot be read.
+func readRecords(dirPath string, keys []string) []record {
+	records := make([]record, 0, len(keys))
+	for _, key := range keys {
+		data, err := os.ReadFile(filepath.Join(dirPath, key+".json"))
+		if err != nil {
+			continue
+		}
+		records = append(records, record{key: key, data: data})
+	}
+	return records
+}
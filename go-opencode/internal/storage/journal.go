@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// journalDir holds commit journals for in-flight file transactions.
+const journalDir = ".journal"
+
+// stagedSuffix names the list of files a transaction stages, written to the
+// journal directory before staging them, so a crash before the commit point
+// leaves a record of what to remove.
+const stagedSuffix = ".staged"
+
+// staleStaged is the age past which a staged list without a journal is
+// known to belong to a crashed transaction, not one committing in another
+// process.
+const staleStaged = time.Minute
+
+// journalEntry records one file operation of a committed transaction.
+// Paths are relative to the storage base path.
+type journalEntry struct {
+	File string `json:"file"`
+	Tmp  string `json:"tmp,omitempty"` // Staged contents; empty for deletes
+}
+
+// fileTx is a file-backend transaction.
+//
+// Commit lists the files it stages in the journal directory, stages every
+// new record next to its target, then writes a journal listing the renames. Once the journal is on disk the transaction is
+// committed: the renames are applied under the storage commit lock, and a
+// crash part-way through is finished by recoverJournals on the next start.
+type fileTx struct {
+	s    *FileStorage
+	ops  []txOp
+	done bool
+}
+
+// Begin starts a transaction.
+func (s *FileStorage) Begin(ctx context.Context) (Tx, error) {
+	return &fileTx{s: s}, nil
+}
+
+// Put buffers a write.
+func (tx *fileTx) Put(ctx context.Context, path []string, v any) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	tx.ops = append(tx.ops, txOp{path: path, data: data})
+	return nil
+}
+
+// Delete buffers a delete.
+func (tx *fileTx) Delete(ctx context.Context, path []string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.ops = append(tx.ops, txOp{path: path})
+	return nil
+}
+
+// Rollback discards the buffered writes.
+func (tx *fileTx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// Commit applies the buffered writes atomically.
+func (tx *fileTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	s := tx.s
+	id := ulid.Make().String()
+
+	entries := make([]journalEntry, len(tx.ops))
+	var staged []string
+	for i, op := range tx.ops {
+		filePath := s.pathToFile(op.path)
+		entries[i].File = s.relPath(filePath)
+		if op.data != nil {
+			entries[i].Tmp = s.relPath(fmt.Sprintf("%s.%s-%d.tmp", filePath, id, i))
+			staged = append(staged, entries[i].Tmp)
+		}
+	}
+
+	journalPath := filepath.Join(s.basePath, journalDir, id+".json")
+	stagedPath := filepath.Join(s.basePath, journalDir, id+stagedSuffix)
+	if len(staged) > 0 {
+		list, err := json.Marshal(staged)
+		if err != nil {
+			return fmt.Errorf("failed to marshal staged files: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(stagedPath), 0755); err != nil {
+			return fmt.Errorf("failed to create journal directory: %w", err)
+		}
+		// Not synced: losing the list in a crash only leaves files behind
+		if err := os.WriteFile(stagedPath, list, 0644); err != nil {
+			return fmt.Errorf("failed to list staged files: %w", err)
+		}
+	}
+
+	// Stage new contents next to their targets so the final renames stay on
+	// one filesystem. Nothing is visible to readers yet.
+	for i, op := range tx.ops {
+		if op.data == nil {
+			continue
+		}
+		filePath := filepath.Join(s.basePath, entries[i].File)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			s.discardStaged(entries[:i], stagedPath)
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := writeFileSync(filepath.Join(s.basePath, entries[i].Tmp), op.data); err != nil {
+			s.discardStaged(entries[:i], stagedPath)
+			return fmt.Errorf("failed to stage file: %w", err)
+		}
+	}
+
+	journal, err := json.Marshal(entries)
+	if err != nil {
+		s.discardStaged(entries, stagedPath)
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+
+	// Writing the journal is the commit point.
+	if err := os.MkdirAll(filepath.Dir(journalPath), 0755); err != nil {
+		s.discardStaged(entries, stagedPath)
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	if err := writeFileSync(journalPath+".tmp", journal); err != nil {
+		s.discardStaged(entries, stagedPath)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(journalPath+".tmp", journalPath); err != nil {
+		os.Remove(journalPath + ".tmp")
+		s.discardStaged(entries, stagedPath)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	os.Remove(stagedPath) // The journal accounts for the staged files now
+
+	// If applying fails the journal stays behind and recovery retries it.
+	if err := s.applyJournal(entries); err != nil {
+		return err
+	}
+
+	os.Remove(journalPath)
+	return nil
+}
+
+// applyJournal performs the renames and deletes listed in a journal.
+// It is idempotent: entries whose staged file is already gone were applied
+// by an earlier attempt.
+func (s *FileStorage) applyJournal(entries []journalEntry) error {
+	for _, entry := range entries {
+		filePath := filepath.Join(s.basePath, entry.File)
+
+		if entry.Tmp == "" {
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete file: %w", err)
+			}
+			continue
+		}
+
+		if err := os.Rename(filepath.Join(s.basePath, entry.Tmp), filePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rename file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// recoverJournals finishes transactions that committed but were interrupted
+// before all of their renames were applied, then removes the staged files of
+// transactions that crashed before committing.
+func (s *FileStorage) recoverJournals() error {
+	dir := filepath.Join(s.basePath, journalDir)
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+
+	var staged []string
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		journalPath := filepath.Join(dir, name)
+
+		if strings.HasSuffix(name, stagedSuffix) {
+			staged = append(staged, journalPath)
+			continue
+		}
+
+		// A journal that never finished being written means the
+		// transaction did not commit.
+		if !strings.HasSuffix(name, ".json") {
+			os.Remove(journalPath)
+			continue
+		}
+
+		data, err := os.ReadFile(journalPath)
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		var entries []journalEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("failed to parse journal %s: %w", name, err)
+		}
+
+		if err := s.applyJournal(entries); err != nil {
+			return err
+		}
+
+		os.Remove(journalPath)
+	}
+
+	// Every journal is applied; staged lists left over did not commit
+	cutoff := time.Now().Add(-staleStaged)
+	for _, stagedPath := range staged {
+		if info, err := os.Stat(stagedPath); err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		data, err := os.ReadFile(stagedPath)
+		if err != nil {
+			return fmt.Errorf("failed to read staged files: %w", err)
+		}
+		var files []string
+		if err := json.Unmarshal(data, &files); err != nil {
+			return fmt.Errorf("failed to parse staged files %s: %w", filepath.Base(stagedPath), err)
+		}
+		for _, file := range files {
+			os.Remove(filepath.Join(s.basePath, file))
+		}
+		os.Remove(stagedPath)
+	}
+
+	return nil
+}
+
+// discardStaged removes the staged files of a transaction that did not
+// commit, and their list.
+func (s *FileStorage) discardStaged(entries []journalEntry, stagedPath string) {
+	for _, entry := range entries {
+		if entry.Tmp != "" {
+			os.Remove(filepath.Join(s.basePath, entry.Tmp))
+		}
+	}
+	os.Remove(stagedPath)
+}
+
+// relPath returns path relative to the storage base path.
+func (s *FileStorage) relPath(path string) string {
+	rel, err := filepath.Rel(s.basePath, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// writeFileSync writes data to path and flushes it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackends_TxCommit(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			s.Put(ctx, []string{"part", "msg1", "old"}, testData{ID: "old"})
+
+			tx, err := s.Begin(ctx)
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+
+			data := testData{ID: "p1", Value: 1}
+			tx.Put(ctx, []string{"message", "ses1", "msg1"}, testData{ID: "msg1"})
+			tx.Put(ctx, []string{"part", "msg1", "p1"}, data)
+			tx.Delete(ctx, []string{"part", "msg1", "old"})
+
+			// Changes after Put are not part of the transaction
+			data.Value = 2
+
+			// Nothing is visible before commit
+			if s.Exists(ctx, []string{"message", "ses1", "msg1"}) {
+				t.Error("Message visible before commit")
+			}
+			if !s.Exists(ctx, []string{"part", "msg1", "old"}) {
+				t.Error("Delete applied before commit")
+			}
+
+			if err := tx.Commit(ctx); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+
+			var got testData
+			if err := s.Get(ctx, []string{"part", "msg1", "p1"}, &got); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if got.Value != 1 {
+				t.Errorf("Expected value marshaled at Put time (1), got %d", got.Value)
+			}
+			if !s.Exists(ctx, []string{"message", "ses1", "msg1"}) {
+				t.Error("Message missing after commit")
+			}
+			if s.Exists(ctx, []string{"part", "msg1", "old"}) {
+				t.Error("Deleted part still exists after commit")
+			}
+
+			if err := tx.Commit(ctx); err != ErrTxDone {
+				t.Errorf("Expected ErrTxDone on second commit, got: %v", err)
+			}
+			if err := tx.Rollback(); err != ErrTxDone {
+				t.Errorf("Expected ErrTxDone on rollback after commit, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestBackends_TxRollback(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			tx, err := s.Begin(ctx)
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+
+			tx.Put(ctx, []string{"part", "msg1", "p1"}, testData{ID: "p1"})
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("Rollback failed: %v", err)
+			}
+
+			if err := tx.Commit(ctx); err != ErrTxDone {
+				t.Errorf("Expected ErrTxDone on commit after rollback, got: %v", err)
+			}
+			if s.Exists(ctx, []string{"part", "msg1", "p1"}) {
+				t.Error("Rolled back write is visible")
+			}
+		})
+	}
+}
+
+func TestFileStorage_RecoverJournal(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	s := New(tmpDir)
+	s.Put(ctx, []string{"part", "msg1", "gone"}, testData{ID: "gone"})
+
+	// Simulate a crash after the journal was written but before the
+	// renames were applied: p1 is staged, the delete is pending.
+	partDir := filepath.Join(tmpDir, "part", "msg1")
+	staged := filepath.Join(partDir, "p1.json.TX-0.tmp")
+	data, _ := json.Marshal(testData{ID: "p1", Value: 7})
+	if err := os.WriteFile(staged, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []journalEntry{
+		{File: filepath.Join("part", "msg1", "p1.json"), Tmp: filepath.Join("part", "msg1", "p1.json.TX-0.tmp")},
+		{File: filepath.Join("part", "msg1", "gone.json")},
+	}
+	journal, _ := json.Marshal(entries)
+	os.MkdirAll(filepath.Join(tmpDir, journalDir), 0755)
+	os.WriteFile(filepath.Join(tmpDir, journalDir, "TX.json"), journal, 0644)
+
+	// An unfinished journal belongs to a transaction that never committed
+	os.WriteFile(filepath.Join(tmpDir, journalDir, "TY.json.tmp"), []byte("[{"), 0644)
+
+	s = New(tmpDir)
+
+	var got testData
+	if err := s.Get(ctx, []string{"part", "msg1", "p1"}, &got); err != nil {
+		t.Fatalf("Recovered part missing: %v", err)
+	}
+	if got.Value != 7 {
+		t.Errorf("Expected recovered value 7, got %d", got.Value)
+	}
+	if s.Exists(ctx, []string{"part", "msg1", "gone"}) {
+		t.Error("Journaled delete was not applied")
+	}
+
+	left, _ := os.ReadDir(filepath.Join(tmpDir, journalDir))
+	if len(left) != 0 {
+		t.Errorf("Expected journal directory to be empty after recovery, got %d entries", len(left))
+	}
+}
+
+func TestFileStorage_RecoverStaged(t *testing.T) {
+	tmpDir := t.TempDir()
+	partDir := filepath.Join(tmpDir, "part", "msg1")
+	os.MkdirAll(partDir, 0755)
+	os.MkdirAll(filepath.Join(tmpDir, journalDir), 0755)
+
+	// Files staged by a transaction that crashed before its journal, and by
+	// one that may still be committing in another process
+	stale := filepath.Join("part", "msg1", "p1.json.TX-0.tmp")
+	fresh := filepath.Join("part", "msg1", "p2.json.TY-0.tmp")
+	other := filepath.Join("part", "msg1", "notes.tmp")
+	for _, path := range []string{stale, fresh, other} {
+		if err := os.WriteFile(filepath.Join(tmpDir, path), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * staleStaged)
+	for id, file := range map[string]string{"TX": stale, "TY": fresh} {
+		list, _ := json.Marshal([]string{file})
+		path := filepath.Join(tmpDir, journalDir, id+stagedSuffix)
+		if err := os.WriteFile(path, list, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if id == "TX" {
+			os.Chtimes(path, old, old)
+		}
+	}
+	os.Chtimes(filepath.Join(tmpDir, other), old, old)
+
+	New(tmpDir)
+
+	if _, err := os.Stat(filepath.Join(tmpDir, stale)); !os.IsNotExist(err) {
+		t.Error("Stale staged file was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, journalDir, "TX"+stagedSuffix)); !os.IsNotExist(err) {
+		t.Error("Stale staged list was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, fresh)); err != nil {
+		t.Error("A file staged just now may belong to a running commit")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, other)); err != nil {
+		t.Error("Files no transaction staged must not be removed")
+	}
+}
+
+func TestFileStorage_CommitRemovesStagedList(t *testing.T) {
+	tmpDir := t.TempDir()
+	s := New(tmpDir)
+	ctx := context.Background()
+
+	tx, _ := s.Begin(ctx)
+	tx.Put(ctx, []string{"part", "msg1", "p1"}, testData{ID: "p1"})
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, journalDir))
+	if len(entries) != 0 {
+		t.Errorf("Journal directory not empty after commit: %v", entries)
+	}
+}
@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+// sqliteSchema creates the records table. Each record is keyed by its parent
+// path (e.g. "message/<sessionID>") and its final path element, so listing a
+// session's messages or a project's sessions is a single indexed range query.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	parent  TEXT    NOT NULL,
+	key     TEXT    NOT NULL,
+	data    BLOB    NOT NULL,
+	updated INTEGER NOT NULL,
+	PRIMARY KEY (parent, key)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS records_parent_updated ON records (parent, updated);
+`
+
+// SQLiteStorage stores records in an embedded SQLite database.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLite opens (creating if needed) a SQLite database at dbPath.
+func NewSQLite(dbPath string) (*SQLiteStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	dsn := "file:" + dbPath + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+// splitPath splits a record path into its parent path and key.
+func splitPath(path []string) (string, string) {
+	if len(path) == 0 {
+		return "", ""
+	}
+	return strings.Join(path[:len(path)-1], "/"), path[len(path)-1]
+}
+
+// Get retrieves a value from storage.
+func (s *SQLiteStorage) Get(ctx context.Context, path []string, v any) error {
+	parent, key := splitPath(path)
+
+	var data []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT data FROM records WHERE parent = ? AND key = ?`, parent, key,
+	).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to read record: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// Put stores a value in storage.
+func (s *SQLiteStorage) Put(ctx context.Context, path []string, v any) error {
+	parent, key := splitPath(path)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	return putRecord(ctx, s.db, parent, key, data)
+}
+
+// Delete removes a value from storage.
+func (s *SQLiteStorage) Delete(ctx context.Context, path []string) error {
+	parent, key := splitPath(path)
+	return deleteRecord(ctx, s.db, parent, key)
+}
+
+// List returns all items at a path, including sub-paths that contain records.
+func (s *SQLiteStorage) List(ctx context.Context, path []string) ([]string, error) {
+	dir := strings.Join(path, "/")
+	seen := make(map[string]bool)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT key FROM records WHERE parent = ?`, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list records: %w", err)
+		}
+		seen[key] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+
+	// Sub-paths are parents that sit below dir. '0' is the byte after '/', so
+	// the half-open range [dir/, dir0) covers every parent with the dir/ prefix.
+	var children *sql.Rows
+	if dir == "" {
+		children, err = s.db.QueryContext(ctx, `SELECT DISTINCT parent FROM records WHERE parent != ''`)
+	} else {
+		children, err = s.db.QueryContext(ctx,
+			`SELECT DISTINCT parent FROM records WHERE parent >= ? AND parent < ?`, dir+"/", dir+"0")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+	defer children.Close()
+
+	for children.Next() {
+		var parent string
+		if err := children.Scan(&parent); err != nil {
+			return nil, fmt.Errorf("failed to list records: %w", err)
+		}
+		rest := parent
+		if dir != "" {
+			rest = strings.TrimPrefix(parent, dir+"/")
+		}
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[:i]
+		}
+		seen[rest] = true
+	}
+	if err := children.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+
+	items := make([]string, 0, len(seen))
+	for name := range seen {
+		items = append(items, name)
+	}
+	sort.Strings(items)
+
+	return items, nil
+}
+
+// Scan iterates over all items at a path in key order.
+func (s *SQLiteStorage) Scan(ctx context.Context, path []string, fn func(key string, data json.RawMessage) error) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, data FROM records WHERE parent = ? ORDER BY key`, strings.Join(path, "/"))
+	if err != nil {
+		return fmt.Errorf("failed to scan records: %w", err)
+	}
+
+	records, err := scanRows(rows)
+	if err != nil {
+		return err
+	}
+	return visitRecords(records, fn)
+}
+
+// Query iterates over items at a path filtered and ordered by update time.
+func (s *SQLiteStorage) Query(ctx context.Context, path []string, opts QueryOptions, fn func(key string, data json.RawMessage) error) error {
+	query := `SELECT key, data FROM records WHERE parent = ?`
+	args := []any{strings.Join(path, "/")}
+
+	if !opts.Since.IsZero() {
+		query += ` AND updated >= ?`
+		args = append(args, opts.Since.UnixMilli())
+	}
+	if !opts.Until.IsZero() {
+		query += ` AND updated <= ?`
+		args = append(args, opts.Until.UnixMilli())
+	}
+	if opts.Descending {
+		query += ` ORDER BY updated DESC, key DESC`
+	} else {
+		query += ` ORDER BY updated, key`
+	}
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+
+	records, err := scanRows(rows)
+	if err != nil {
+		return err
+	}
+	return visitRecords(records, fn)
+}
+
+// Exists checks if a path exists.
+func (s *SQLiteStorage) Exists(ctx context.Context, path []string) bool {
+	parent, key := splitPath(path)
+
+	var one int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT 1 FROM records WHERE parent = ? AND key = ?`, parent, key,
+	).Scan(&one)
+	return err == nil
+}
+
+// Begin starts a transaction.
+func (s *SQLiteStorage) Begin(ctx context.Context) (Tx, error) {
+	return &sqliteTx{s: s}, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// scanRows reads (key, data) rows into records and closes rows.
+func scanRows(rows *sql.Rows) ([]record, error) {
+	var records []record
+	for rows.Next() {
+		var r record
+		if err := rows.Scan(&r.key, &r.data); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		records = append(records, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
+	}
+
+	return records, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// putRecord upserts a record.
+func putRecord(ctx context.Context, ex execer, parent, key string, data []byte) error {
+	_, err := ex.ExecContext(ctx,
+		`INSERT INTO records (parent, key, data, updated) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (parent, key) DO UPDATE SET data = excluded.data, updated = excluded.updated`,
+		parent, key, data, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// deleteRecord removes a record.
+func deleteRecord(ctx context.Context, ex execer, parent, key string) error {
+	if _, err := ex.ExecContext(ctx,
+		`DELETE FROM records WHERE parent = ? AND key = ?`, parent, key,
+	); err != nil {
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+	return nil
+}
+
+// sqliteTx buffers writes and applies them in a single database transaction
+// on Commit, so no connection is held while the caller builds the batch.
+type sqliteTx struct {
+	s    *SQLiteStorage
+	ops  []txOp
+	done bool
+}
+
+// Put buffers a write.
+func (tx *sqliteTx) Put(ctx context.Context, path []string, v any) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	tx.ops = append(tx.ops, txOp{path: path, data: data})
+	return nil
+}
+
+// Delete buffers a delete.
+func (tx *sqliteTx) Delete(ctx context.Context, path []string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.ops = append(tx.ops, txOp{path: path})
+	return nil
+}
+
+// Rollback discards the buffered writes.
+func (tx *sqliteTx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// Commit applies the buffered writes atomically.
+func (tx *sqliteTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	dbTx, err := tx.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, op := range tx.ops {
+		parent, key := splitPath(op.path)
+		if op.data == nil {
+			err = deleteRecord(ctx, dbTx, parent, key)
+		} else {
+			err = putRecord(ctx, dbTx, parent, key, op.data)
+		}
+		if err != nil {
+			dbTx.Rollback()
+			return err
+		}
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
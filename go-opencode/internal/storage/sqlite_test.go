@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func newSQLiteForTest(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "opencode.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// backends returns a fresh instance of every storage backend.
+func backends(t *testing.T) map[string]Storage {
+	return map[string]Storage{
+		BackendFile:   New(t.TempDir()),
+		BackendSQLite: newSQLiteForTest(t),
+	}
+}
+
+func TestSQLite_PutGetDelete(t *testing.T) {
+	s := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	data := testData{ID: "123", Name: "test", Value: 42}
+	if err := s.Put(ctx, []string{"items", "item1"}, data); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got testData
+	if err := s.Get(ctx, []string{"items", "item1"}, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != data {
+		t.Errorf("Data mismatch: got %+v, want %+v", got, data)
+	}
+
+	// Overwrite
+	data.Value = 43
+	if err := s.Put(ctx, []string{"items", "item1"}, data); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Get(ctx, []string{"items", "item1"}, &got); err != nil || got.Value != 43 {
+		t.Errorf("Expected overwritten value 43, got %+v (err %v)", got, err)
+	}
+
+	if err := s.Delete(ctx, []string{"items", "item1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Get(ctx, []string{"items", "item1"}, &got); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got: %v", err)
+	}
+	if s.Exists(ctx, []string{"items", "item1"}) {
+		t.Error("Item should not exist after delete")
+	}
+}
+
+func TestBackends_ListIncludesSubPaths(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			s.Put(ctx, []string{"session", "projA", "s1"}, testData{ID: "s1"})
+			s.Put(ctx, []string{"session", "projA", "s2"}, testData{ID: "s2"})
+			s.Put(ctx, []string{"session", "projB", "s3"}, testData{ID: "s3"})
+			// Sibling prefix must not leak into "session"
+			s.Put(ctx, []string{"sessions", "x", "y"}, testData{ID: "y"})
+
+			projects, err := s.List(ctx, []string{"session"})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			sort.Strings(projects)
+			if len(projects) != 2 || projects[0] != "projA" || projects[1] != "projB" {
+				t.Errorf("Expected [projA projB], got %v", projects)
+			}
+
+			sessions, err := s.List(ctx, []string{"session", "projA"})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			sort.Strings(sessions)
+			if len(sessions) != 2 || sessions[0] != "s1" || sessions[1] != "s2" {
+				t.Errorf("Expected [s1 s2], got %v", sessions)
+			}
+
+			var scanned []string
+			err = s.Scan(ctx, []string{"session", "projA"}, func(key string, data json.RawMessage) error {
+				scanned = append(scanned, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			if len(scanned) != 2 || scanned[0] != "s1" || scanned[1] != "s2" {
+				t.Errorf("Expected scan [s1 s2], got %v", scanned)
+			}
+		})
+	}
+}
+
+func TestBackends_Query(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"a", "b", "c"} {
+				if err := s.Put(ctx, []string{"message", "ses1", id}, testData{ID: id}); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+				// Distinct update times (file mtimes have millisecond resolution or better)
+				time.Sleep(15 * time.Millisecond)
+			}
+
+			var keys []string
+			collect := func(key string, data json.RawMessage) error {
+				keys = append(keys, key)
+				return nil
+			}
+
+			if err := s.Query(ctx, []string{"message", "ses1"}, QueryOptions{Descending: true, Limit: 2}, collect); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(keys) != 2 || keys[0] != "c" || keys[1] != "b" {
+				t.Errorf("Expected newest two [c b], got %v", keys)
+			}
+
+			keys = nil
+			future := time.Now().Add(time.Hour)
+			if err := s.Query(ctx, []string{"message", "ses1"}, QueryOptions{Since: future}, collect); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(keys) != 0 {
+				t.Errorf("Expected no records since the future, got %v", keys)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, ok := s.(*FileStorage); !ok {
+		t.Errorf("Expected file backend by default, got %T", s)
+	}
+
+	s, err = Open(dir, &types.StorageConfig{Backend: BackendSQLite})
+	if err != nil {
+		t.Fatalf("Open sqlite failed: %v", err)
+	}
+	defer s.Close()
+	if _, ok := s.(*SQLiteStorage); !ok {
+		t.Errorf("Expected sqlite backend, got %T", s)
+	}
+
+	if _, err := Open(dir, &types.StorageConfig{Backend: "bogus"}); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
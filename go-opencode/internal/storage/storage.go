@@ -1,4 +1,8 @@
-// Package storage provides file-based JSON storage matching the TypeScript implementation.
+// Package storage provides pluggable JSON record storage for sessions, messages and parts.
+//
+// Records are addressed by a path slice such as ["message", sessionID, messageID].
+// The file backend maps paths onto the TypeScript on-disk layout; the SQLite backend
+// keeps the same addressing but indexes records by parent path and update time.
 package storage
 
 import (
@@ -6,175 +10,191 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
-	"strings"
-	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/pkg/types"
 )
 
 var (
 	ErrNotFound = errors.New("not found")
+	ErrTxDone   = errors.New("transaction already committed or rolled back")
 )
 
-// Storage provides file-based JSON storage.
-type Storage struct {
-	basePath string
-	mu       sync.RWMutex
-	locks    map[string]*FileLock
-}
+// Backend names accepted in the "storage.backend" config field.
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
 
-// New creates a new Storage instance.
-func New(basePath string) *Storage {
-	return &Storage{
-		basePath: basePath,
-		locks:    make(map[string]*FileLock),
-	}
-}
+// Storage is a key/value store for JSON records addressed by path.
+type Storage interface {
+	// Get reads the record at path into v. Returns ErrNotFound if it does not exist.
+	Get(ctx context.Context, path []string, v any) error
+
+	// Put writes v as the record at path, replacing any existing value.
+	Put(ctx context.Context, path []string, v any) error
+
+	// Delete removes the record at path. Deleting a missing record is not an error.
+	Delete(ctx context.Context, path []string) error
 
-// pathToFile converts a path slice to a file path.
-func (s *Storage) pathToFile(path []string) string {
-	parts := append([]string{s.basePath}, path...)
-	return filepath.Join(parts...) + ".json"
+	// List returns the names of records and sub-paths directly under path.
+	List(ctx context.Context, path []string) ([]string, error)
+
+	// Scan calls fn for every record directly under path.
+	Scan(ctx context.Context, path []string, fn func(key string, data json.RawMessage) error) error
+
+	// Query calls fn for records directly under path that match opts, ordered by update time.
+	Query(ctx context.Context, path []string, opts QueryOptions, fn func(key string, data json.RawMessage) error) error
+
+	// Exists reports whether a record exists at path.
+	Exists(ctx context.Context, path []string) bool
+
+	// Begin starts a transaction. Writes made through the returned Tx are
+	// buffered and become visible together when it is committed.
+	Begin(ctx context.Context) (Tx, error)
+
+	// Close releases any resources held by the backend.
+	Close() error
 }
 
-// pathToDir converts a path slice to a directory path.
-func (s *Storage) pathToDir(path []string) string {
-	parts := append([]string{s.basePath}, path...)
-	return filepath.Join(parts...)
+// Tx is a batch of writes applied atomically on Commit.
+// Readers observe either none or all of a committed transaction's writes.
+type Tx interface {
+	// Put buffers a write of v at path. v is marshaled immediately, so later
+	// changes to it are not part of the transaction.
+	Put(ctx context.Context, path []string, v any) error
+
+	// Delete buffers removal of the record at path.
+	Delete(ctx context.Context, path []string) error
+
+	// Commit applies all buffered writes as a unit.
+	Commit(ctx context.Context) error
+
+	// Rollback discards all buffered writes. After Commit it only returns ErrTxDone,
+	// so it can be deferred unconditionally.
+	Rollback() error
 }
 
-// Get retrieves a value from storage.
-func (s *Storage) Get(ctx context.Context, path []string, v any) error {
-	filePath := s.pathToFile(path)
+// record is a raw record read from a backend.
+type record struct {
+	key  string
+	data []byte
+}
 
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return ErrNotFound
+// visitRecords calls fn for each record in order. Backends read records
+// before visiting them so fn may call back into the storage, including
+// committing a transaction, without deadlocking.
+func visitRecords(records []record, fn func(key string, data json.RawMessage) error) error {
+	for _, r := range records {
+		if err := fn(r.key, json.RawMessage(r.data)); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	if err := json.Unmarshal(data, v); err != nil {
-		return fmt.Errorf("failed to unmarshal: %w", err)
 	}
-
 	return nil
 }
 
-// Put stores a value in storage with file locking.
-func (s *Storage) Put(ctx context.Context, path []string, v any) error {
-	filePath := s.pathToFile(path)
+// txOp is a buffered transaction write. A nil data marks a delete.
+type txOp struct {
+	path []string
+	data []byte
+}
 
-	// Ensure directory exists
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
+// QueryOptions filters and orders the records visited by Query.
+type QueryOptions struct {
+	// Since, when non-zero, skips records last written before this time.
+	Since time.Time
+	// Until, when non-zero, skips records last written after this time.
+	Until time.Time
+	// Limit caps the number of records visited. Zero means no limit.
+	Limit int
+	// Descending visits the most recently written records first.
+	Descending bool
+}
 
-	// Acquire lock
-	lock := s.getLock(filePath)
-	if err := lock.Lock(); err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
+// matches reports whether a record updated at the given unix millisecond time passes the filters.
+func (o QueryOptions) matches(updated int64) bool {
+	if !o.Since.IsZero() && updated < o.Since.UnixMilli() {
+		return false
+	}
+	if !o.Until.IsZero() && updated > o.Until.UnixMilli() {
+		return false
 	}
-	defer lock.Unlock()
+	return true
+}
 
-	// Marshal data
-	data, err := json.MarshalIndent(v, "", "  ")
+// Open creates the storage backend selected by cfg, rooted at basePath.
+// A nil cfg or empty backend selects the file backend. If cfg enables
+// encryption the backend is wrapped in an EncryptedStorage.
+func Open(basePath string, cfg *types.StorageConfig) (Storage, error) {
+	backend, err := OpenBackend(basePath, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal: %w", err)
+		return nil, err
 	}
 
-	// Write to temp file first, then rename (atomic operation)
-	tmpPath := filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+	if cfg == nil || cfg.Encryption == nil {
+		return backend, nil
 	}
 
-	if err := os.Rename(tmpPath, filePath); err != nil {
-		os.Remove(tmpPath) // Clean up temp file
-		return fmt.Errorf("failed to rename file: %w", err)
+	key, err := LoadKey(cfg.Encryption)
+	if err != nil {
+		backend.Close()
+		return nil, err
 	}
 
-	return nil
+	return NewEncrypted(backend, key)
 }
 
-// Delete removes a value from storage.
-func (s *Storage) Delete(ctx context.Context, path []string) error {
-	filePath := s.pathToFile(path)
-
-	// Acquire lock
-	lock := s.getLock(filePath)
-	if err := lock.Lock(); err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
+// OpenBackend creates the storage backend selected by cfg without applying
+// encryption. Records are returned exactly as stored.
+func OpenBackend(basePath string, cfg *types.StorageConfig) (Storage, error) {
+	backend := BackendFile
+	if cfg != nil && cfg.Backend != "" {
+		backend = cfg.Backend
 	}
-	defer lock.Unlock()
 
-	if err := os.Remove(filePath); err != nil {
-		if os.IsNotExist(err) {
-			return nil // Already deleted
+	switch backend {
+	case BackendFile:
+		if cfg != nil && cfg.Path != "" {
+			basePath = cfg.Path
 		}
-		return fmt.Errorf("failed to delete file: %w", err)
-	}
-
-	return nil
-}
+		return New(basePath), nil
 
-// List returns all items at a path.
-func (s *Storage) List(ctx context.Context, path []string) ([]string, error) {
-	dirPath := s.pathToDir(path)
-
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []string{}, nil
+	case BackendSQLite:
+		dbPath := filepath.Join(basePath, "opencode.db")
+		if cfg.Path != "" {
+			dbPath = cfg.Path
 		}
-		return nil, fmt.Errorf("failed to read directory: %w", err)
-	}
+		return NewSQLite(dbPath)
 
-	var items []string
-	for _, entry := range entries {
-		name := entry.Name()
-		if entry.IsDir() {
-			items = append(items, name)
-		} else if strings.HasSuffix(name, ".json") {
-			items = append(items, strings.TrimSuffix(name, ".json"))
-		}
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
 	}
-
-	return items, nil
 }
 
-// Scan iterates over all items at a path.
-func (s *Storage) Scan(ctx context.Context, path []string, fn func(key string, data json.RawMessage) error) error {
-	dirPath := s.pathToDir(path)
-
-	entries, err := os.ReadDir(dirPath)
+// Walk calls fn with the full path and raw data of every record under path,
+// descending into sub-paths. It relies only on Scan and List, so it works on
+// any backend. Names that hold a record are not descended into.
+func Walk(ctx context.Context, s Storage, path []string, fn func(path []string, data json.RawMessage) error) error {
+	records := make(map[string]bool)
+	err := s.Scan(ctx, path, func(key string, data json.RawMessage) error {
+		records[key] = true
+		return fn(childPath(path, key), data)
+	})
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Nothing to scan
-		}
-		return fmt.Errorf("failed to read directory: %w", err)
+		return err
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
+	names, err := s.List(ctx, path)
+	if err != nil {
+		return err
+	}
 
-		name := entry.Name()
-		if !strings.HasSuffix(name, ".json") {
+	for _, name := range names {
+		if records[name] {
 			continue
 		}
-
-		filePath := filepath.Join(dirPath, name)
-		data, err := os.ReadFile(filePath)
-		if err != nil {
-			continue // Skip files that can't be read
-		}
-
-		key := strings.TrimSuffix(name, ".json")
-		if err := fn(key, json.RawMessage(data)); err != nil {
+		if err := Walk(ctx, s, childPath(path, name), fn); err != nil {
 			return err
 		}
 	}
@@ -182,23 +202,10 @@ func (s *Storage) Scan(ctx context.Context, path []string, fn func(key string, d
 	return nil
 }
 
-// Exists checks if a path exists.
-func (s *Storage) Exists(ctx context.Context, path []string) bool {
-	filePath := s.pathToFile(path)
-	_, err := os.Stat(filePath)
-	return err == nil
-}
-
-// getLock returns a file lock for a path.
-func (s *Storage) getLock(filePath string) *FileLock {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	lock, ok := s.locks[filePath]
-	if !ok {
-		lock = NewFileLock(filePath)
-		s.locks[filePath] = lock
-	}
-
-	return lock
+// childPath returns a copy of path with name appended.
+func childPath(path []string, name string) []string {
+	child := make([]string, len(path)+1)
+	copy(child, path)
+	child[len(path)] = name
+	return child
 }
@@ -0,0 +1,132 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxPieceBytes bounds the pieces merged at once. Merging is quadratic in
+// the length of a piece, and pieces this long, such as minified code or
+// base64 data, are rare enough that cutting them barely changes the count.
+const maxPieceBytes = 4096
+
+// Encoding is a byte-pair encoding: text is split into pieces, whose bytes
+// are merged in rank order into the tokens of the vocabulary.
+type Encoding struct {
+	name  string
+	ranks map[string]int
+	split func(string) []string
+}
+
+// NewEncoding reads a vocabulary in the tiktoken format: one token, base64
+// encoded, and its rank per line.
+func NewEncoding(name string, r io.Reader, split func(string) []string) (*Encoding, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid vocabulary line %d", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid vocabulary line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid vocabulary line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for b := 0; b < 256; b++ {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("invalid vocabulary: byte %#x has no token", b)
+		}
+	}
+	return &Encoding{name: name, ranks: ranks, split: split}, nil
+}
+
+// Name implements Tokenizer.
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Count implements Tokenizer.
+func (e *Encoding) Count(text string) int {
+	n := 0
+	for _, piece := range e.pieces(text) {
+		if _, ok := e.ranks[piece]; ok {
+			n++
+		} else {
+			n += len(e.merge(piece))
+		}
+	}
+	return n
+}
+
+// Encode returns the tokens of text. Special tokens are encoded as text.
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.pieces(text) {
+		if rank, ok := e.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		for _, part := range e.merge(piece) {
+			tokens = append(tokens, e.ranks[part])
+		}
+	}
+	return tokens
+}
+
+// pieces pre-tokenizes text, cutting pieces longer than maxPieceBytes.
+func (e *Encoding) pieces(text string) []string {
+	var pieces []string
+	for _, piece := range e.split(text) {
+		for len(piece) > maxPieceBytes {
+			cut := maxPieceBytes
+			for cut > 1 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			pieces = append(pieces, piece[:cut])
+			piece = piece[cut:]
+		}
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
+
+// merge merges the bytes of a piece, the pair of lowest rank first, until
+// no pair is in the vocabulary, and returns the resulting tokens.
+func (e *Encoding) merge(piece string) []string {
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := e.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
@@ -0,0 +1,66 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Estimate counts tokens without a vocabulary. Its rates follow those of
+// cl100k_base on prose, code and CJK text: a token per common word, with its
+// leading space, and per 7 letters of longer words, per 2 letters of scripts
+// other than Latin, per 3 digits, per 2 symbols and per whitespace run other
+// than a single space, and a token per CJK character.
+var Estimate Tokenizer = estimator{}
+
+type estimator struct{}
+
+func (estimator) Name() string {
+	return "estimate"
+}
+
+func (estimator) Count(text string) int {
+	n := 0
+	s := newScanner(text)
+	for i := 0; i < len(s.runes); {
+		r := s.runes[i]
+		switch {
+		case isCJK(r):
+			n++
+			i++
+		case unicode.IsLetter(r):
+			end := s.run(i, func(r rune) bool { return unicode.IsLetter(r) && !isCJK(r) })
+			letters := end - i
+			if r >= utf8.RuneSelf {
+				// Scripts other than Latin and CJK split into short tokens
+				n += (letters + 1) / 2
+			} else {
+				n += (letters + 6) / 7
+			}
+			i = end
+		case unicode.IsNumber(r):
+			end := s.run(i, unicode.IsNumber)
+			n += (end - i + 2) / 3
+			i = end
+		case unicode.IsSpace(r):
+			end := s.run(i, unicode.IsSpace)
+			if end-i > 1 || r != ' ' {
+				n++
+			}
+			i = end
+		case r >= utf8.RuneSelf:
+			// Emoji and other symbols take a token per 2 bytes or so
+			n += (utf8.RuneLen(r) + 1) / 2
+			i++
+		default:
+			end := s.run(i, func(r rune) bool { return r < utf8.RuneSelf && isPunct(r) })
+			n += (end - i + 1) / 2
+			i = end
+		}
+	}
+	return n
+}
+
+// isCJK reports whether r is a Han, kana or Hangul character.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
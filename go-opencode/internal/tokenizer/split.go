@@ -0,0 +1,204 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// The splitters below pre-tokenize text as the regular expressions of the
+// encodings do. Those need look-ahead, which regexp lacks, so each is
+// written out as a scanner; the expressions are quoted above them.
+
+// scanner holds the runes of a text with their byte offsets, so that pieces
+// keep the bytes of invalid UTF-8.
+type scanner struct {
+	text  string
+	runes []rune
+	offs  []int // byte offset of each rune, then len(text)
+}
+
+func newScanner(text string) *scanner {
+	s := &scanner{text: text}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		s.runes = append(s.runes, r)
+		s.offs = append(s.offs, i)
+		i += size
+	}
+	s.offs = append(s.offs, len(text))
+	return s
+}
+
+// split cuts the text into the pieces ending where next says, given the
+// start of each.
+func (s *scanner) split(next func(i int) int) []string {
+	var pieces []string
+	for i := 0; i < len(s.runes); {
+		end := next(i)
+		pieces = append(pieces, s.text[s.offs[i]:s.offs[end]])
+		i = end
+	}
+	return pieces
+}
+
+// run returns the end of the run of runes from i matching class.
+func (s *scanner) run(i int, class func(rune) bool) int {
+	for i < len(s.runes) && class(s.runes[i]) {
+		i++
+	}
+	return i
+}
+
+// at reports whether the rune at i matches class.
+func (s *scanner) at(i int, class func(rune) bool) bool {
+	return i < len(s.runes) && class(s.runes[i])
+}
+
+// contraction matches (?i:'s|'t|'re|'ve|'m|'ll|'d) at i.
+func (s *scanner) contraction(i int) int {
+	if !s.at(i, func(r rune) bool { return r == '\'' }) {
+		return -1
+	}
+	lower := func(j int) rune {
+		if j >= len(s.runes) {
+			return 0
+		}
+		return unicode.ToLower(s.runes[j])
+	}
+	switch c := lower(i + 1); c {
+	case 's', 't', 'm', 'd':
+		return i + 2
+	case 'r', 'v':
+		if lower(i+2) == 'e' {
+			return i + 3
+		}
+	case 'l':
+		if lower(i+2) == 'l' {
+			return i + 3
+		}
+	}
+	return -1
+}
+
+// punctuation matches " ?[^\s\p{L}\p{N}]+" at i, then any runes of trail.
+func (s *scanner) punctuation(i int, optional func(rune) bool, trail func(rune) bool) int {
+	j := i
+	if s.at(j, optional) && s.at(j+1, isPunct) {
+		j++
+	}
+	if !s.at(j, isPunct) {
+		return -1
+	}
+	return s.run(s.run(j, isPunct), trail)
+}
+
+// whitespace matches "\s*[\r\n]+|\s+(?!\S)|\s+" at i.
+func (s *scanner) whitespace(i int) int {
+	end := s.run(i, unicode.IsSpace)
+	for j := end - 1; j >= i; j-- {
+		if isNewline(s.runes[j]) {
+			return j + 1
+		}
+	}
+	if end < len(s.runes) && end-i > 1 {
+		return end - 1 // Leave the last space to the next piece
+	}
+	return end
+}
+
+// splitCL100K splits text as cl100k_base:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitCL100K(text string) []string {
+	s := newScanner(text)
+	return s.split(func(i int) int {
+		if end := s.contraction(i); end > 0 {
+			return end
+		}
+		if s.at(i, unicode.IsLetter) {
+			return s.run(i, unicode.IsLetter)
+		}
+		if s.at(i, isWordPrefix) && s.at(i+1, unicode.IsLetter) {
+			return s.run(i+1, unicode.IsLetter)
+		}
+		if s.at(i, unicode.IsNumber) {
+			return min(s.run(i, unicode.IsNumber), i+3)
+		}
+		if end := s.punctuation(i, isSpaceChar, isNewline); end > 0 {
+			return end
+		}
+		return s.whitespace(i)
+	})
+}
+
+// splitO200K splits text as o200k_base, with words cut at case changes:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitO200K(text string) []string {
+	s := newScanner(text)
+	word := func(i int) int {
+		upper := s.run(i, isUpperClass)
+		for j := upper; j >= i; j-- {
+			if s.at(j, isLowerClass) {
+				return s.run(j, isLowerClass)
+			}
+		}
+		if upper > i {
+			return upper
+		}
+		return -1
+	}
+	return s.split(func(i int) int {
+		end := -1
+		if s.at(i, isWordPrefix) {
+			end = word(i + 1)
+		}
+		if end < 0 {
+			end = word(i)
+		}
+		if end > 0 {
+			if c := s.contraction(end); c > 0 {
+				return c
+			}
+			return end
+		}
+		if s.at(i, unicode.IsNumber) {
+			return min(s.run(i, unicode.IsNumber), i+3)
+		}
+		if end := s.punctuation(i, isSpaceChar, func(r rune) bool { return isNewline(r) || r == '/' }); end > 0 {
+			return end
+		}
+		return s.whitespace(i)
+	})
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+func isSpaceChar(r rune) bool {
+	return r == ' '
+}
+
+// isPunct matches [^\s\p{L}\p{N}].
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isWordPrefix matches [^\r\n\p{L}\p{N}].
+func isWordPrefix(r rune) bool {
+	return !isNewline(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isUpperClass matches [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}].
+func isUpperClass(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerClass matches [\p{Ll}\p{Lm}\p{Lo}\p{M}].
+func isLowerClass(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
@@ -0,0 +1,196 @@
+// Package tokenizer counts the tokens of text as models see them.
+//
+// OpenAI models are counted with their byte-pair encodings, cl100k_base and
+// o200k_base. Encodings are read offline from vocabularies in the tiktoken
+// format, one base64 token and its rank per line. The vocabularies in the vocab
+// directory, fetched by "make vocabularies" and checked against its SHA256SUMS,
+// are embedded in the binary. "opencode tokenizer install" copies others,
+// named after the encoding, to the tokenizers directory of the data
+// directory, where they take precedence:
+//
+//	~/.local/share/opencode/tokenizers/cl100k_base.tiktoken
+//	~/.local/share/opencode/tokenizers/o200k_base.tiktoken
+//
+// Anthropic publishes no encoding for current Claude models, so they are
+// counted with Estimate, as are models of other providers and models whose
+// vocabulary is not installed.
+package tokenizer
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+// Tokenizer counts tokens.
+type Tokenizer interface {
+	// Name is the name of the encoding, or "estimate".
+	Name() string
+	// Count returns the number of tokens of text.
+	Count(text string) int
+}
+
+// Encoding names.
+const (
+	CL100K = "cl100k_base"
+	O200K  = "o200k_base"
+)
+
+// splitters pre-tokenize text for the known encodings.
+var splitters = map[string]func(string) []string{
+	CL100K: splitCL100K,
+	O200K:  splitO200K,
+}
+
+// Encodings returns the names of the known encodings, sorted.
+func Encodings() []string {
+	names := make([]string, 0, len(splitters))
+	for name := range splitters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EncodingName returns the name of the encoding of a model, "" if it is not
+// known.
+func EncodingName(model *types.Model) string {
+	if model == nil {
+		return ""
+	}
+	id := strings.ToLower(model.ID)
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:] // openrouter-style "openai/gpt-4o"
+	}
+	switch {
+	case strings.HasPrefix(id, "gpt-4o"), strings.HasPrefix(id, "chatgpt-4o"),
+		strings.HasPrefix(id, "gpt-4.1"), strings.HasPrefix(id, "gpt-4.5"),
+		strings.HasPrefix(id, "gpt-5"), strings.HasPrefix(id, "gpt-oss"),
+		strings.HasPrefix(id, "o1"), strings.HasPrefix(id, "o3"), strings.HasPrefix(id, "o4"):
+		return O200K
+	case strings.HasPrefix(id, "gpt-4"), strings.HasPrefix(id, "gpt-3.5"),
+		strings.HasPrefix(id, "text-embedding-"):
+		return CL100K
+	}
+	return ""
+}
+
+//go:embed vocab
+var embeddedVocabularies embed.FS
+
+// vocabularies are the embedded vocabularies, replaced in tests.
+var vocabularies fs.FS = embeddedVocabularies
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*Encoding) // nil if not installed
+)
+
+// ForModel returns the tokenizer of a model: its encoding if the vocabulary
+// is installed, Estimate otherwise. A nil model is estimated.
+func ForModel(model *types.Model) Tokenizer {
+	if e := Installed(EncodingName(model)); e != nil {
+		return e
+	}
+	return Estimate
+}
+
+// Installed returns the encoding name read from the tokenizers directory,
+// or from the embedded vocabularies if it is not installed there. It returns
+// nil if neither has it or it cannot be read. Encodings are read once.
+func Installed(name string) *Encoding {
+	split, ok := splitters[name]
+	if !ok {
+		return nil
+	}
+
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if e, ok := encodings[name]; ok {
+		return e
+	}
+
+	path := vocabularyPath(name)
+	e, err := Load(name, path, split)
+	if errors.Is(err, os.ErrNotExist) {
+		e, err = embedded(name, split)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logging.Warn().Err(err).Str("path", path).Msg("Ignoring the tokenizer vocabulary")
+	}
+	encodings[name] = e
+	return e
+}
+
+// embedded reads an encoding from the vocabularies embedded in the binary.
+func embedded(name string, split func(string) []string) (*Encoding, error) {
+	f, err := vocabularies.Open("vocab/" + name + ".tiktoken")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewEncoding(name, f, split)
+}
+
+// Load reads an encoding from a vocabulary in the tiktoken format. split
+// pre-tokenizes text; nil splits it as the encoding name does.
+func Load(name, path string, split func(string) []string) (*Encoding, error) {
+	if split == nil {
+		split = splitters[name]
+	}
+	if split == nil {
+		return nil, errors.New("unknown encoding: " + name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewEncoding(name, f, split)
+}
+
+// Install checks that the vocabulary at from is one of the encoding name and
+// copies it to the tokenizers directory, where Installed reads it. It
+// returns the installed path.
+func Install(name, from string) (string, error) {
+	e, err := Load(name, from, nil)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return "", err
+	}
+
+	path := vocabularyPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	encodingsMu.Lock()
+	encodings[name] = e
+	encodingsMu.Unlock()
+	return path, nil
+}
+
+// vocabularyPath returns the path of the vocabulary of the encoding name.
+func vocabularyPath(name string) string {
+	return filepath.Join(config.GetPaths().TokenizerPath(), name+".tiktoken")
+}
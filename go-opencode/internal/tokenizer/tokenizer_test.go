@@ -0,0 +1,217 @@
+package tokenizer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opencode-ai/opencode/pkg/types"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		split func(string) []string
+		text  string
+		want  []string
+	}{
+		{"cl100k words", splitCL100K, "Hello world", []string{"Hello", " world"}},
+		{"cl100k contraction", splitCL100K, "I'M here", []string{"I", "'M", " here"}},
+		{"cl100k digits", splitCL100K, "12345", []string{"123", "45"}},
+		{"cl100k spaces", splitCL100K, "foo  bar  ", []string{"foo", " ", " bar", "  "}},
+		{"cl100k newlines", splitCL100K, "a \n\n  b", []string{"a", " \n\n", " ", " b"}},
+		{"cl100k code", splitCL100K, "x = (y);\n", []string{"x", " =", " (", "y", ");\n"}},
+		{"cl100k CJK", splitCL100K, "你好，世界", []string{"你好", "，世界"}},
+		{"o200k case", splitO200K, "HelloWorld's HTTPServer", []string{"Hello", "World's", " HTTPServer"}},
+		{"o200k path", splitO200K, "a/b//\n", []string{"a", "/b", "//\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.split(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplit_KeepsBytes(t *testing.T) {
+	text := "caf\xe9 \xff\xfe x  \n"
+	for name, split := range splitters {
+		if got := strings.Join(split(text), ""); got != text {
+			t.Errorf("%s: got %q, want %q", name, got, text)
+		}
+	}
+}
+
+// writeVocabulary writes a vocabulary of the 256 bytes and the given merges.
+func writeVocabulary(t *testing.T, merges ...string) string {
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), 256+i)
+	}
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncoding(t *testing.T) {
+	e, err := Load(CL100K, writeVocabulary(t, "ab", "abc", " ab"), nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if e.Name() != CL100K {
+		t.Errorf("Got name %s", e.Name())
+	}
+
+	// "abcab" merges ab, ab, then abc; " ab" is in the vocabulary
+	if got, want := e.Encode("abcab ab!"), []int{257, 256, 258, '!'}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Encode: got %v, want %v", got, want)
+	}
+	if n := e.Count("abcab ab!"); n != 4 {
+		t.Errorf("Count: got %d, want 4", n)
+	}
+	if n := e.Count(strings.Repeat("x", 3*maxPieceBytes)); n != 3*maxPieceBytes {
+		t.Errorf("Count of a long piece: got %d", n)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.tiktoken")
+	for _, data := range []string{"YQ== 0\n", "not-base64 1\n", "YQ==\n"} {
+		os.WriteFile(path, []byte(data), 0644)
+		if _, err := Load(CL100K, path, nil); err == nil {
+			t.Errorf("Load(%q) should fail", data)
+		}
+	}
+	if _, err := Load("unknown", writeVocabulary(t), nil); err == nil {
+		t.Error("Load of an unknown encoding should fail")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Hello", 1},
+		{"Hello, world!", 4},
+		{"This is a test message with some words", 8},
+		{"internationalization", 3},
+		{"if (x == 10) {\n\treturn y;\n}", 13},
+		{"你好，世界", 6},
+		{"Привет мир", 5},
+		{"🙂", 2},
+	}
+	for _, tt := range tests {
+		if got := Estimate.Count(tt.text); got != tt.want {
+			t.Errorf("Estimate(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEncodingName(t *testing.T) {
+	tests := map[string]string{
+		"claude-sonnet-4-20250514": "",
+		"gpt-4o-mini":              O200K,
+		"gpt-5":                    O200K,
+		"o3-mini":                  O200K,
+		"openai/gpt-4.1":           O200K,
+		"gpt-4-turbo":              CL100K,
+		"gpt-3.5-turbo":            CL100K,
+		"gemini-2.5-pro":           "",
+	}
+	for id, want := range tests {
+		if got := EncodingName(&types.Model{ID: id}); got != want {
+			t.Errorf("EncodingName(%s) = %q, want %q", id, got, want)
+		}
+	}
+	if EncodingName(nil) != "" {
+		t.Error("A nil model has no encoding")
+	}
+}
+
+func TestForModel(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if tok := ForModel(nil); tok != Estimate {
+		t.Errorf("Expected the estimate for a nil model, got %s", tok.Name())
+	}
+	if tok := ForModel(&types.Model{ID: "gemini-2.5-pro"}); tok != Estimate {
+		t.Errorf("Expected the estimate for an unknown model, got %s", tok.Name())
+	}
+}
+
+func TestForModel_Embedded(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	data, err := os.ReadFile(writeVocabulary(t, "ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := vocabularies
+	vocabularies = fstest.MapFS{"vocab/cl100k_base.tiktoken": {Data: data}}
+	t.Cleanup(func() {
+		vocabularies = saved
+		delete(encodings, CL100K)
+	})
+
+	if tok := ForModel(&types.Model{ID: "gpt-4-turbo"}); tok.Name() != CL100K || tok.Count("abab") != 2 {
+		t.Errorf("Expected the embedded encoding, got %s", tok.Name())
+	}
+}
+
+func TestInstall(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(func() { delete(encodings, CL100K) })
+
+	bad := filepath.Join(t.TempDir(), "bad.tiktoken")
+	os.WriteFile(bad, []byte("YQ== 0\n"), 0644)
+	if _, err := Install(CL100K, bad); err == nil {
+		t.Error("Install of an invalid vocabulary should fail")
+	}
+	if _, err := Install("claude", writeVocabulary(t)); err == nil {
+		t.Error("Install of an unknown encoding should fail")
+	}
+
+	path, err := Install(CL100K, writeVocabulary(t, "ab"))
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("The vocabulary is not installed: %v", err)
+	}
+	if tok := ForModel(&types.Model{ID: "gpt-4-turbo"}); tok.Name() != CL100K || tok.Count("abab") != 2 {
+		t.Errorf("Expected the installed encoding, got %s", tok.Name())
+	}
+}
+
+func TestEmbeddedVocabularies(t *testing.T) {
+	sums, err := embeddedVocabularies.ReadFile("vocab/SHA256SUMS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(sums)), "\n") {
+		want, file, _ := strings.Cut(line, "  ")
+		if _, ok := splitters[strings.TrimSuffix(file, ".tiktoken")]; !ok {
+			t.Errorf("SHA256SUMS lists %s, which is not a known encoding", file)
+		}
+		data, err := embeddedVocabularies.ReadFile("vocab/" + file)
+		if err != nil {
+			continue // Not fetched in this build
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != want {
+			t.Errorf("The embedded %s does not match its SHA256SUMS entry", file)
+		}
+	}
+}
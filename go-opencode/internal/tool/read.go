@@ -20,7 +20,7 @@ Usage:
 - By default, reads up to 2000 lines from the beginning
 - You can optionally specify offset and limit for pagination
 - Returns file contents with line numbers
-- Can read image files and return them as base64 data`
+- Can read image and PDF files and return them as base64 data`
 
 // ReadTool implements file reading.
 type ReadTool struct {
@@ -91,9 +91,9 @@ func (t *ReadTool) Execute(ctx context.Context, input json.RawMessage, toolCtx *
 		return nil, fmt.Errorf("path is a directory, not a file: %s", params.FilePath)
 	}
 
-	// Handle images
-	if isImageFile(params.FilePath) {
-		return t.readImage(params.FilePath)
+	// Handle images and PDFs, sent to the model as attachments
+	if isImageFile(params.FilePath) || isPDFFile(params.FilePath) {
+		return t.readAttachment(params.FilePath)
 	}
 
 	// Check for binary content
@@ -147,7 +147,7 @@ func (t *ReadTool) Execute(ctx context.Context, input json.RawMessage, toolCtx *
 	}, nil
 }
 
-func (t *ReadTool) readImage(path string) (*Result, error) {
+func (t *ReadTool) readAttachment(path string) (*Result, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -155,10 +155,14 @@ func (t *ReadTool) readImage(path string) (*Result, error) {
 
 	mediaType := detectMediaType(path)
 	dataURL := fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(data))
+	output := "(Image file)"
+	if mediaType == "application/pdf" {
+		output = "(PDF file)"
+	}
 
 	return &Result{
 		Title:  fmt.Sprintf("Read %s", filepath.Base(path)),
-		Output: "(Image file)",
+		Output: output,
 		Attachments: []Attachment{
 			{
 				Filename:  filepath.Base(path),
@@ -179,6 +183,10 @@ func isImageFile(path string) bool {
 		ext == ".gif" || ext == ".bmp" || ext == ".webp"
 }
 
+func isPDFFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".pdf"
+}
+
 func isBinaryFile(path string) bool {
 	file, err := os.Open(path)
 	if err != nil {
@@ -222,6 +230,8 @@ func detectMediaType(path string) string {
 		return "image/bmp"
 	case ".webp":
 		return "image/webp"
+	case ".pdf":
+		return "application/pdf"
 	default:
 		return "application/octet-stream"
 	}
@@ -182,6 +182,31 @@ func TestReadTool_ImageFile(t *testing.T) {
 	}
 }
 
+func TestReadTool_PDFFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	pdfFile := filepath.Join(tmpDir, "doc.pdf")
+	if err := os.WriteFile(pdfFile, []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"), 0644); err != nil {
+		t.Fatalf("Failed to create PDF file: %v", err)
+	}
+
+	tool := NewReadTool(tmpDir)
+	input := json.RawMessage(`{"filePath": "` + pdfFile + `"}`)
+	result, err := tool.Execute(context.Background(), input, testContext())
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.Output != "(PDF file)" {
+		t.Errorf("Expected output '(PDF file)', got %q", result.Output)
+	}
+	if len(result.Attachments) != 1 || result.Attachments[0].MediaType != "application/pdf" {
+		t.Fatalf("PDF file should have a PDF attachment, got %+v", result.Attachments)
+	}
+	if !strings.HasPrefix(result.Attachments[0].URL, "data:application/pdf;base64,") {
+		t.Error("Attachment URL should be a data URL")
+	}
+}
+
 func TestReadTool_BinaryFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	binFile := filepath.Join(tmpDir, "binary.dat")
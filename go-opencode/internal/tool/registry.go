@@ -7,6 +7,7 @@ import (
 	einotool "github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/opencode-ai/opencode/internal/agent"
+	"github.com/opencode-ai/opencode/internal/provider"
 	"github.com/opencode-ai/opencode/internal/storage"
 )
 
@@ -15,11 +16,11 @@ type Registry struct {
 	mu      sync.RWMutex
 	tools   map[string]Tool
 	workDir string
-	storage *storage.Storage
+	storage storage.Storage
 }
 
 // NewRegistry creates a new tool registry.
-func NewRegistry(workDir string, store *storage.Storage) *Registry {
+func NewRegistry(workDir string, store storage.Storage) *Registry {
 	return &Registry{
 		tools:   make(map[string]Tool),
 		workDir: workDir,
@@ -28,7 +29,7 @@ func NewRegistry(workDir string, store *storage.Storage) *Registry {
 }
 
 // Storage returns the storage instance.
-func (r *Registry) Storage() *storage.Storage {
+func (r *Registry) Storage() storage.Storage {
 	return r.storage
 }
 
@@ -91,18 +92,17 @@ func (r *Registry) ToolInfos() ([]*schema.ToolInfo, error) {
 
 	infos := make([]*schema.ToolInfo, 0, len(r.tools))
 	for _, t := range r.tools {
-		params := parseJSONSchemaToParams(t.Parameters())
 		infos = append(infos, &schema.ToolInfo{
 			Name:        t.ID(),
 			Desc:        t.Description(),
-			ParamsOneOf: schema.NewParamsOneOfByParams(params),
+			ParamsOneOf: provider.ToolParams(t.Parameters()),
 		})
 	}
 	return infos, nil
 }
 
 // DefaultRegistry creates a registry with all built-in tools.
-func DefaultRegistry(workDir string, store *storage.Storage) *Registry {
+func DefaultRegistry(workDir string, store storage.Storage) *Registry {
 	fmt.Printf("[registry] Creating DefaultRegistry with workDir=%s\n", workDir)
 	r := NewRegistry(workDir, store)
 
@@ -15,11 +15,11 @@ const todoreadDescription = `Use this tool to read your todo list`
 // TodoReadTool reads the current todo list for a session.
 type TodoReadTool struct {
 	workDir string
-	storage *storage.Storage
+	storage storage.Storage
 }
 
 // NewTodoReadTool creates a new todoread tool.
-func NewTodoReadTool(workDir string, store *storage.Storage) *TodoReadTool {
+func NewTodoReadTool(workDir string, store storage.Storage) *TodoReadTool {
 	return &TodoReadTool{
 		workDir: workDir,
 		storage: store,
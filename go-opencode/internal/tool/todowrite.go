@@ -59,7 +59,7 @@ When in doubt, use this tool. Being proactive with task management demonstrates
 // TodoWriteTool manages structured task lists for coding sessions.
 type TodoWriteTool struct {
 	workDir string
-	storage *storage.Storage
+	storage storage.Storage
 }
 
 // TodoWriteInput represents the input for the todowrite tool.
@@ -68,7 +68,7 @@ type TodoWriteInput struct {
 }
 
 // NewTodoWriteTool creates a new todowrite tool.
-func NewTodoWriteTool(workDir string, store *storage.Storage) *TodoWriteTool {
+func NewTodoWriteTool(workDir string, store storage.Storage) *TodoWriteTool {
 	return &TodoWriteTool{
 		workDir: workDir,
 		storage: store,
@@ -7,6 +7,8 @@ import (
 
 	einotool "github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+
+	"github.com/opencode-ai/opencode/internal/provider"
 )
 
 // Tool defines the interface for all tools.
@@ -112,11 +114,10 @@ type einoToolWrapper struct {
 
 // Info returns the tool information.
 func (w *einoToolWrapper) Info(ctx context.Context) (*schema.ToolInfo, error) {
-	params := parseJSONSchemaToParams(w.tool.Parameters())
 	return &schema.ToolInfo{
 		Name:        w.tool.ID(),
 		Desc:        w.tool.Description(),
-		ParamsOneOf: schema.NewParamsOneOfByParams(params),
+		ParamsOneOf: provider.ToolParams(w.tool.Parameters()),
 	}, nil
 }
 
@@ -134,47 +135,3 @@ func (w *einoToolWrapper) InvokableRun(ctx context.Context, argsJSON string, opt
 	return result.Output, nil
 }
 
-// parseJSONSchemaToParams converts JSON Schema to Eino ParameterInfo.
-func parseJSONSchemaToParams(schemaJSON json.RawMessage) map[string]*schema.ParameterInfo {
-	var jsonSchema struct {
-		Properties map[string]struct {
-			Type        string `json:"type"`
-			Description string `json:"description"`
-		} `json:"properties"`
-		Required []string `json:"required"`
-	}
-
-	if err := json.Unmarshal(schemaJSON, &jsonSchema); err != nil {
-		return nil
-	}
-
-	requiredSet := make(map[string]bool)
-	for _, r := range jsonSchema.Required {
-		requiredSet[r] = true
-	}
-
-	params := make(map[string]*schema.ParameterInfo)
-	for name, prop := range jsonSchema.Properties {
-		paramType := schema.String
-		switch prop.Type {
-		case "integer":
-			paramType = schema.Integer
-		case "number":
-			paramType = schema.Number
-		case "boolean":
-			paramType = schema.Boolean
-		case "array":
-			paramType = schema.Array
-		case "object":
-			paramType = schema.Object
-		}
-
-		params[name] = &schema.ParameterInfo{
-			Type:     paramType,
-			Desc:     prop.Description,
-			Required: requiredSet[name],
-		}
-	}
-
-	return params
-}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	einotool "github.com/cloudwego/eino/components/tool"
 )
 
 // Helper to create test context
@@ -179,68 +181,69 @@ func TestBaseTool_EinoTool(t *testing.T) {
 }
 
 // ============================================
-// parseJSONSchemaToParams Tests
+// Tool Parameters Tests
 // ============================================
 
-func TestParseJSONSchemaToParams_AllTypes(t *testing.T) {
-	schema := json.RawMessage(`{
-		"type": "object",
-		"properties": {
-			"stringProp": {"type": "string", "description": "A string"},
-			"intProp": {"type": "integer", "description": "An integer"},
-			"numProp": {"type": "number", "description": "A number"},
-			"boolProp": {"type": "boolean", "description": "A boolean"},
-			"arrayProp": {"type": "array", "description": "An array"},
-			"objectProp": {"type": "object", "description": "An object"}
-		},
-		"required": ["stringProp", "intProp"]
-	}`)
+func TestRegistry_ToolInfos_NestedParameters(t *testing.T) {
+	r := NewRegistry("", nil)
+	r.Register(NewTodoWriteTool("", nil))
 
-	params := parseJSONSchemaToParams(schema)
-	if params == nil {
-		t.Fatal("parseJSONSchemaToParams returned nil")
+	infos, err := r.ToolInfos()
+	if err != nil {
+		t.Fatalf("ToolInfos failed: %v", err)
 	}
-
-	// Check all properties exist
-	expectedProps := []string{"stringProp", "intProp", "numProp", "boolProp", "arrayProp", "objectProp"}
-	for _, prop := range expectedProps {
-		if _, ok := params[prop]; !ok {
-			t.Errorf("Expected property %q not found", prop)
-		}
+	if len(infos) != 1 {
+		t.Fatalf("Expected 1 tool info, got %d", len(infos))
 	}
 
-	// Check required fields
-	if !params["stringProp"].Required {
-		t.Error("stringProp should be required")
+	params, err := infos[0].ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		t.Fatalf("ToJSONSchema failed: %v", err)
 	}
-	if !params["intProp"].Required {
-		t.Error("intProp should be required")
+	todos, ok := params.Properties.Get("todos")
+	if !ok {
+		t.Fatal("Expected property \"todos\"")
 	}
-	if params["numProp"].Required {
-		t.Error("numProp should not be required")
+	if todos.Type != "array" || todos.Items == nil {
+		t.Fatalf("Expected todos to be an array with items, got %+v", todos)
 	}
 
-	// Check descriptions
-	if params["stringProp"].Desc != "A string" {
-		t.Errorf("Expected description 'A string', got %q", params["stringProp"].Desc)
+	// The item schema is kept, not flattened to "array"
+	status, ok := todos.Items.Properties.Get("status")
+	if !ok {
+		t.Fatal("Expected item property \"status\"")
 	}
-}
-
-func TestParseJSONSchemaToParams_InvalidJSON(t *testing.T) {
-	schema := json.RawMessage(`{invalid json}`)
-	params := parseJSONSchemaToParams(schema)
-	if params != nil {
-		t.Error("Expected nil for invalid JSON")
+	if status.Type != "string" || !strings.Contains(status.Description, "in_progress") {
+		t.Errorf("Got status %+v", status)
+	}
+	if len(todos.Items.Required) != 4 {
+		t.Errorf("Expected 4 required item properties, got %v", todos.Items.Required)
 	}
 }
 
-func TestParseJSONSchemaToParams_EmptySchema(t *testing.T) {
-	schema := json.RawMessage(`{}`)
-	params := parseJSONSchemaToParams(schema)
-	if params == nil {
-		t.Error("Expected empty map, not nil")
+func TestEinoTool_InvalidParameters(t *testing.T) {
+	info, err := (&einoToolWrapper{tool: &invalidSchemaTool{}}).Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	params, err := info.ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		t.Fatalf("ToJSONSchema failed: %v", err)
 	}
-	if len(params) != 0 {
-		t.Errorf("Expected 0 params, got %d", len(params))
+	if params.Type != "object" || params.Properties.Len() != 0 {
+		t.Errorf("Expected an object without properties, got %+v", params)
 	}
 }
+
+// invalidSchemaTool is a tool whose parameters are not valid JSON.
+type invalidSchemaTool struct{}
+
+func (t *invalidSchemaTool) ID() string                  { return "invalid" }
+func (t *invalidSchemaTool) Description() string         { return "Invalid parameters" }
+func (t *invalidSchemaTool) Parameters() json.RawMessage { return json.RawMessage(`{invalid json}`) }
+func (t *invalidSchemaTool) EinoTool() einotool.InvokableTool {
+	return &einoToolWrapper{tool: t}
+}
+func (t *invalidSchemaTool) Execute(ctx context.Context, input json.RawMessage, toolCtx *Context) (*Result, error) {
+	return &Result{}, nil
+}
@@ -13,6 +13,10 @@ type Config struct {
 	Model      string `json:"model,omitempty"`      // "anthropic/claude-sonnet-4"
 	SmallModel string `json:"smallModel,omitempty"` // For fast tasks (camelCase for TS compatibility)
 
+	// Models to switch to, in order, when a model's provider keeps failing,
+	// by "provider/model"
+	Fallback map[string][]string `json:"fallback,omitempty"`
+
 	// Theme (TUI only, for compatibility)
 	Theme string `json:"theme,omitempty"`
 
@@ -55,6 +59,18 @@ type Config struct {
 	// File watcher
 	Watcher *WatcherConfig `json:"watcher,omitempty"`
 
+	// Session storage backend
+	Storage *StorageConfig `json:"storage,omitempty"`
+
+	// File snapshots for session revert (default true)
+	Snapshot *bool `json:"snapshot,omitempty"`
+
+	// Spending limits for every session
+	Budget *BudgetConfig `json:"budget,omitempty"`
+
+	// Sessions started on a schedule by opencode serve, by name
+	Schedule map[string]ScheduleConfig `json:"schedule,omitempty"`
+
 	// Experimental features
 	Experimental *ExperimentalConfig `json:"experimental,omitempty"`
 }
@@ -63,7 +79,8 @@ type Config struct {
 // Compatible with TypeScript opencode provider configuration.
 type ProviderConfig struct {
 	// Npm package for the provider (TypeScript style)
-	// Supported: @ai-sdk/openai, @ai-sdk/openai-compatible, @ai-sdk/anthropic
+	// Supported: @ai-sdk/openai, @ai-sdk/openai-compatible, @ai-sdk/anthropic,
+	// @ai-sdk/google
 	Npm string `json:"npm,omitempty"`
 
 	// Model/Endpoint ID (for providers like ARK that require endpoint specification)
@@ -86,8 +103,31 @@ type ProviderConfig struct {
 // ModelConfig holds custom model configuration (TypeScript style).
 type ModelConfig struct {
 	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
 	Reasoning bool   `json:"reasoning,omitempty"`
-	ToolCall  bool   `json:"toolcall,omitempty"` // No underscore - matches TS capabilities.toolcall
+	ToolCall  *bool  `json:"toolcall,omitempty"` // No underscore - matches TS capabilities.toolcall
+
+	// Context and output size overrides, e.g. for a discovered local model
+	Limit *ModelLimitConfig `json:"limit,omitempty"`
+
+	// Pricing overrides for a built-in model
+	Cost *ModelCostConfig `json:"cost,omitempty"`
+}
+
+// ModelLimitConfig overrides the token limits of a model. Zero fields keep
+// the built-in or discovered limit.
+type ModelLimitConfig struct {
+	Context int `json:"context,omitempty"`
+	Output  int `json:"output,omitempty"`
+}
+
+// ModelCostConfig overrides the prices of a model, in USD per 1M tokens.
+// Unset fields keep the built-in price.
+type ModelCostConfig struct {
+	Input      *float64 `json:"input,omitempty"`
+	Output     *float64 `json:"output,omitempty"`
+	CacheRead  *float64 `json:"cache_read,omitempty"`
+	CacheWrite *float64 `json:"cache_write,omitempty"`
 }
 
 // ProviderOptions holds nested provider options (TypeScript style).
@@ -96,6 +136,32 @@ type ProviderOptions struct {
 	BaseURL       string `json:"baseURL,omitempty"`
 	EnterpriseURL string `json:"enterpriseUrl,omitempty"`
 	Timeout       *int   `json:"timeout,omitempty"` // ms, nil = default, 0 = disabled
+
+	// Prompt cache breakpoints of Anthropic models: "auto" (default) caches
+	// the tools, system prompt and conversation, "static" only the tools and
+	// system prompt, "off" nothing
+	CacheStrategy string `json:"cacheStrategy,omitempty"`
+
+	// OAuth endpoints to log in with "opencode auth login" in place of an
+	// API key
+	OAuth *OAuthConfig `json:"oauth,omitempty"`
+}
+
+// OAuthConfig holds the OAuth 2.0 client and endpoints of a provider. The
+// authorization code flow, with PKCE, needs AuthorizationURL and the device
+// code flow DeviceAuthorizationURL.
+type OAuthConfig struct {
+	ClientID               string   `json:"clientId"`
+	ClientSecret           string   `json:"clientSecret,omitempty"`
+	AuthorizationURL       string   `json:"authorizationUrl,omitempty"`
+	DeviceAuthorizationURL string   `json:"deviceAuthorizationUrl,omitempty"`
+	TokenURL               string   `json:"tokenUrl"`
+	Scopes                 []string `json:"scopes,omitempty"`
+
+	// RedirectURL receives the authorization code. Empty or a localhost URL
+	// is served by opencode; any other URL shows the code for the user to
+	// paste.
+	RedirectURL string `json:"redirectUrl,omitempty"`
 }
 
 // AgentConfig holds configuration for an agent.
@@ -122,6 +188,9 @@ type AgentConfig struct {
 	Mode        string `json:"mode,omitempty"`  // "subagent"|"primary"|"all"
 	Color       string `json:"color,omitempty"` // Hex color
 
+	// Spending limits for sessions run by this agent
+	Budget *BudgetConfig `json:"budget,omitempty"`
+
 	// Disable this agent
 	Disable bool `json:"disable,omitempty"`
 }
@@ -178,6 +247,62 @@ type WatcherConfig struct {
 	Ignore []string `json:"ignore,omitempty"`
 }
 
+// StorageConfig selects and configures the session storage backend.
+type StorageConfig struct {
+	Backend    string            `json:"backend,omitempty"`    // "file"|"sqlite" (default "file")
+	Path       string            `json:"path,omitempty"`       // Storage directory (file) or database file (sqlite)
+	Retention  *RetentionConfig  `json:"retention,omitempty"`  // Garbage collection policy
+	Encryption *EncryptionConfig `json:"encryption,omitempty"` // Encrypt records at rest
+}
+
+// EncryptionConfig enables AES-256-GCM encryption of stored records.
+// The key is 32 bytes, base64 encoded, read from KeyFile or, if that is
+// unset, from the environment variable named by KeyEnv.
+type EncryptionConfig struct {
+	KeyFile string `json:"keyFile,omitempty"`
+	KeyEnv  string `json:"keyEnv,omitempty"` // Default "OPENCODE_STORAGE_KEY"
+}
+
+// RetentionConfig controls which sessions storage garbage collection removes.
+// Zero values disable the corresponding limit.
+type RetentionConfig struct {
+	MaxAgeDays            int   `json:"maxAgeDays,omitempty"`            // Remove sessions not updated for this many days
+	MaxSessionsPerProject int   `json:"maxSessionsPerProject,omitempty"` // Keep only the most recently updated sessions
+	KeepStarred           *bool `json:"keepStarred,omitempty"`           // Never remove starred sessions (default true)
+}
+
+// BudgetConfig limits what the agentic loop may spend on a session. Zero
+// values mean no limit.
+type BudgetConfig struct {
+	MaxCost         float64 `json:"maxCost,omitempty"`         // USD
+	MaxInputTokens  int     `json:"maxInputTokens,omitempty"`  // Summed over all steps, cached included
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"` // Summed over all steps, reasoning included
+	MaxSeconds      int     `json:"maxSeconds,omitempty"`      // Wall-clock time of one run of the loop
+}
+
+// BudgetUsage is what a session has spent against its budget.
+type BudgetUsage struct {
+	Cost         float64 `json:"cost"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Seconds      float64 `json:"seconds"` // Of the current run
+}
+
+// ScheduleConfig is a session that opencode serve starts on a cron schedule,
+// sending either a prompt or a command.
+type ScheduleConfig struct {
+	Cron      string `json:"cron"`                // "min hour day month weekday", or @hourly, @daily, @weekly, @monthly, @yearly
+	Prompt    string `json:"prompt,omitempty"`    // Sent as the user message
+	Command   string `json:"command,omitempty"`   // Command run instead of a prompt
+	Arguments string `json:"arguments,omitempty"` // Arguments of Command
+	Agent     string `json:"agent,omitempty"`
+	Model     string `json:"model,omitempty"`     // "provider/model"; empty for the default
+	Directory string `json:"directory,omitempty"` // Default: the server directory
+	CatchUp   string `json:"catchUp,omitempty"`   // Runs missed while the server was down: "skip" (default), "once" or "all"
+	Overlap   string `json:"overlap,omitempty"`   // A run due while the last one runs: "skip" (default), "queue" or "allow"
+	Disable   bool   `json:"disable,omitempty"`
+}
+
 // ExperimentalConfig holds experimental feature flags.
 type ExperimentalConfig struct {
 	BatchTool bool `json:"batch_tool,omitempty"`
@@ -429,12 +554,71 @@ type Model struct {
 	MaxOutputTokens   int          `json:"maxOutputTokens,omitempty"`
 	SupportsTools     bool         `json:"supportsTools"`
 	SupportsVision    bool         `json:"supportsVision"`
+	SupportsPDF       bool         `json:"supportsPDF,omitempty"`
 	SupportsReasoning bool         `json:"supportsReasoning,omitempty"`
-	InputPrice        float64      `json:"inputPrice,omitempty"`  // per 1M tokens
-	OutputPrice       float64      `json:"outputPrice,omitempty"` // per 1M tokens
+	InputPrice        float64      `json:"inputPrice,omitempty"`      // per 1M tokens
+	OutputPrice       float64      `json:"outputPrice,omitempty"`     // per 1M tokens
+	CacheReadPrice    float64      `json:"cacheReadPrice,omitempty"`  // per 1M tokens
+	CacheWritePrice   float64      `json:"cacheWritePrice,omitempty"` // per 1M tokens
 	Options           ModelOptions `json:"options,omitempty"`
 }
 
+// Cost returns the price in USD of a request that used tokens. Input excludes
+// cached prompt tokens, which are priced separately, and reasoning tokens are
+// billed as output.
+func (m *Model) Cost(tokens *TokenUsage) float64 {
+	if m == nil || tokens == nil {
+		return 0
+	}
+	cost := float64(tokens.Input)*m.InputPrice +
+		float64(tokens.Output+tokens.Reasoning)*m.OutputPrice +
+		float64(tokens.Cache.Read)*m.CacheReadPrice +
+		float64(tokens.Cache.Write)*m.CacheWritePrice
+	return cost / 1_000_000
+}
+
+// WithConfig returns a copy of the model with the overrides of cfg applied.
+func (m Model) WithConfig(cfg ModelConfig) Model {
+	if cfg.Name != "" {
+		m.Name = cfg.Name
+	}
+	if cfg.Reasoning {
+		m.SupportsReasoning = true
+	}
+	if cfg.ToolCall != nil {
+		m.SupportsTools = *cfg.ToolCall
+	}
+	if cfg.Limit != nil {
+		if cfg.Limit.Context > 0 {
+			m.ContextLength = cfg.Limit.Context
+		}
+		if cfg.Limit.Output > 0 {
+			m.MaxOutputTokens = cfg.Limit.Output
+		}
+	}
+	return m.WithCost(cfg.Cost)
+}
+
+// WithCost returns a copy of the model with the prices overridden by cost.
+func (m Model) WithCost(cost *ModelCostConfig) Model {
+	if cost == nil {
+		return m
+	}
+	if cost.Input != nil {
+		m.InputPrice = *cost.Input
+	}
+	if cost.Output != nil {
+		m.OutputPrice = *cost.Output
+	}
+	if cost.CacheRead != nil {
+		m.CacheReadPrice = *cost.CacheRead
+	}
+	if cost.CacheWrite != nil {
+		m.CacheWritePrice = *cost.CacheWrite
+	}
+	return m
+}
+
 // ModelOptions contains model-specific options.
 type ModelOptions struct {
 	Temperature    *float64 `json:"temperature,omitempty"`
@@ -27,6 +27,9 @@ type Message struct {
 	Cost       float64       `json:"cost"`                 // Required by TUI
 	Tokens     *TokenUsage   `json:"tokens,omitempty"`
 	Error      *MessageError `json:"error,omitempty"`
+
+	// Models the message switched away from after they kept failing
+	Fallbacks []ModelFallback `json:"fallbacks,omitempty"`
 }
 
 // MarshalJSON implements custom JSON marshaling to handle the summary field
@@ -83,6 +86,26 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// CompactedHistory returns the messages of a session, in order, that are
+// still sent to the model. After a completed compaction this starts at the
+// user message that requested it, followed by the summary that stands in for
+// everything before.
+func CompactedHistory(messages []*Message) []*Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role != "assistant" || !msg.IsSummary || msg.Finish == nil || msg.Error != nil {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if messages[j].ID == msg.ParentID {
+				return messages[j:]
+			}
+		}
+		return messages[i:]
+	}
+	return messages
+}
+
 // MessagePath contains the current working directory and project root.
 type MessagePath struct {
 	Cwd  string `json:"cwd"`
@@ -109,6 +132,15 @@ type ModelRef struct {
 	ModelID    string `json:"modelID"`
 }
 
+// ModelFallback records a message switching to the next model of a fallback
+// chain after the provider of the model it used kept failing.
+type ModelFallback struct {
+	From  ModelRef `json:"from"`
+	To    ModelRef `json:"to"`
+	Error string   `json:"error"`
+	Time  int64    `json:"time"`
+}
+
 // TokenUsage contains token usage statistics for a message.
 // Note: All fields are required by TUI, do not use omitempty.
 type TokenUsage struct {
@@ -127,7 +159,7 @@ type CacheUsage struct {
 // MessageError represents an error that occurred during message processing.
 // Format: {"name": "UnknownError", "data": {"message": "..."}}
 type MessageError struct {
-	Name string           `json:"name"` // "UnknownError" | "ProviderAuthError" | "MessageOutputLengthError"
+	Name string           `json:"name"` // "UnknownError" | "ProviderAuthError" | "MessageOutputLengthError" | "MessageAbortedError"
 	Data MessageErrorData `json:"data"`
 }
 
@@ -152,3 +184,19 @@ func NewProviderAuthError(providerID, message string) *MessageError {
 		Data: MessageErrorData{Message: message, ProviderID: providerID},
 	}
 }
+
+// NewBudgetExceededError creates a new BudgetExceededError.
+func NewBudgetExceededError(message string) *MessageError {
+	return &MessageError{
+		Name: ErrorNameBudgetExceeded,
+		Data: MessageErrorData{Message: message},
+	}
+}
+
+// NewMessageAbortedError creates a new MessageAbortedError.
+func NewMessageAbortedError(message string) *MessageError {
+	return &MessageError{
+		Name: ErrorNameMessageAborted,
+		Data: MessageErrorData{Message: message},
+	}
+}
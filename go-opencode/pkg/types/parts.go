@@ -47,6 +47,7 @@ type TextPart struct {
 	MessageID string         `json:"messageID"` // SDK compatible
 	Type      string         `json:"type"`      // always "text"
 	Text      string         `json:"text"`
+	Synthetic bool           `json:"synthetic,omitempty"` // Added by opencode, not typed by the user
 	Time      PartTime       `json:"time,omitempty"`
 	Metadata  map[string]any `json:"metadata,omitempty"`
 }
@@ -156,6 +157,10 @@ func (p *CompactionPart) PartID() string        { return p.ID }
 func (p *CompactionPart) PartSessionID() string { return p.SessionID }
 func (p *CompactionPart) PartMessageID() string { return p.MessageID }
 
+// CompactionRequestText stands in for a compaction request when the history
+// is sent to a model, so the summary that follows reads as an answer.
+const CompactionRequestText = "What did we do so far?"
+
 // SnapshotPart marks a git snapshot point.
 // SDK compatible: includes sessionID and messageID fields.
 type SnapshotPart struct {
@@ -14,6 +14,8 @@ type Session struct {
 	Time         SessionTime    `json:"time"`
 	Revert       *SessionRevert `json:"revert,omitempty"`
 	CustomPrompt *CustomPrompt  `json:"customPrompt,omitempty"`
+	Starred      bool           `json:"starred,omitempty"` // Exempt from retention when keepStarred is set
+	Budget       *BudgetConfig  `json:"budget,omitempty"`  // Overrides the configured budgets
 }
 
 // SessionSummary contains statistics about code changes in a session.
@@ -75,6 +77,7 @@ const (
 	ErrorNameMessageOutputLength = "MessageOutputLengthError"
 	ErrorNameMessageAborted      = "MessageAbortedError"
 	ErrorNameAPI                 = "APIError"
+	ErrorNameBudgetExceeded      = "BudgetExceededError"
 )
 
 // Project represents a project (worktree) that can contain sessions.
@@ -105,3 +108,39 @@ type TodoInfo struct {
 	Status   string `json:"status"`   // pending, in_progress, completed, cancelled
 	Priority string `json:"priority"` // high, medium, low
 }
+
+// Delivery modes for messages sent to a busy session.
+const (
+	// DeliverySteer adds the message at the next step boundary of the
+	// running loop.
+	DeliverySteer = "steer"
+	// DeliveryQueue runs the message after the running loop ends.
+	DeliveryQueue = "queue"
+)
+
+// QueuedMessage is a user message waiting for a busy session. It is stored
+// as a message, with new IDs, only when it is delivered.
+type QueuedMessage struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"sessionID"`
+	Delivery  string          `json:"delivery"` // "steer" or "queue"
+	Text      string          `json:"text"`
+	Files     []FilePart      `json:"files,omitempty"`
+	Agent     string          `json:"agent,omitempty"`
+	Model     *ModelRef       `json:"model,omitempty"`
+	Tools     map[string]bool `json:"tools,omitempty"`
+	Time      int64           `json:"time"` // Unix ms when queued
+}
+
+// ScheduleRun is one run of a scheduled session.
+type ScheduleRun struct {
+	ID        string `json:"id"`
+	Schedule  string `json:"schedule"` // Name of the schedule entry
+	SessionID string `json:"sessionID,omitempty"`
+	Status    string `json:"status"` // "running" | "completed" | "failed" | "skipped"
+	Due       int64  `json:"due"`    // Unix ms the run was scheduled for
+	Started   int64  `json:"started"`
+	Finished  int64  `json:"finished,omitempty"`
+	CatchUp   bool   `json:"catchUp,omitempty"` // Made up for a run missed while the server was down
+	Error     string `json:"error,omitempty"`
+}
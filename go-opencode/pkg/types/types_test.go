@@ -394,3 +394,88 @@ func TestMessage_SummaryField_OmittedWhenNotSet(t *testing.T) {
 		t.Error("summary should be omitted when IsSummary is false")
 	}
 }
+
+func TestCompactedHistory(t *testing.T) {
+	stop := "stop"
+	messages := []*Message{
+		{ID: "msg1", Role: "user"},
+		{ID: "msg2", Role: "assistant", ParentID: "msg1", Finish: &stop},
+		{ID: "msg3", Role: "user"},
+		{ID: "msg4", Role: "assistant", ParentID: "msg3", IsSummary: true, Finish: &stop},
+		{ID: "msg5", Role: "user"},
+		{ID: "msg6", Role: "user"},
+		{ID: "msg7", Role: "assistant", ParentID: "msg6", IsSummary: true}, // Still streaming
+	}
+
+	ids := func(msgs []*Message) []string {
+		var out []string
+		for _, m := range msgs {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+
+	got := ids(CompactedHistory(messages))
+	want := []string{"msg3", "msg4", "msg5", "msg6", "msg7"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	if got := CompactedHistory(messages[:3]); len(got) != 3 {
+		t.Errorf("expected full history without a summary, got %v", ids(got))
+	}
+}
+
+func TestModel_Cost(t *testing.T) {
+	model := &Model{InputPrice: 3, OutputPrice: 15, CacheReadPrice: 0.3, CacheWritePrice: 3.75}
+	tokens := &TokenUsage{
+		Input:     1_000_000,
+		Output:    100_000,
+		Reasoning: 100_000,
+		Cache:     CacheUsage{Read: 2_000_000, Write: 400_000},
+	}
+
+	// 3 + 0.2*15 + 2*0.3 + 0.4*3.75
+	if got, want := model.Cost(tokens), 8.1; got < want-1e-9 || got > want+1e-9 {
+		t.Errorf("Cost = %v, want %v", got, want)
+	}
+
+	var unknown *Model
+	if got := unknown.Cost(tokens); got != 0 {
+		t.Errorf("Cost of unknown model = %v, want 0", got)
+	}
+}
+
+func TestModel_WithCost(t *testing.T) {
+	input, cacheRead := 1.0, 0.0
+	model := Model{InputPrice: 3, OutputPrice: 15, CacheReadPrice: 0.3}.WithCost(&ModelCostConfig{
+		Input:     &input,
+		CacheRead: &cacheRead,
+	})
+
+	if model.InputPrice != 1 || model.OutputPrice != 15 || model.CacheReadPrice != 0 {
+		t.Errorf("WithCost = %+v, want input 1, output 15 and free cache reads", model)
+	}
+}
+
+func TestModel_WithConfig(t *testing.T) {
+	noTools := false
+	model := Model{Name: "llama3", ContextLength: 32768, MaxOutputTokens: 4096, SupportsTools: true}.WithConfig(ModelConfig{
+		Name:      "Llama 3",
+		Reasoning: true,
+		ToolCall:  &noTools,
+		Limit:     &ModelLimitConfig{Context: 8192},
+	})
+
+	if model.Name != "Llama 3" || !model.SupportsReasoning || model.SupportsTools {
+		t.Errorf("WithConfig = %+v, want a renamed reasoning model without tools", model)
+	}
+	if model.ContextLength != 8192 || model.MaxOutputTokens != 4096 {
+		t.Errorf("WithConfig limits = %d/%d, want 8192/4096", model.ContextLength, model.MaxOutputTokens)
+	}
+}